@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// checkCmd reports the problems of a chart without modifying it
+var checkCmd = &cobra.Command{
+	Use:   "check [chart-directory]",
+	Short: "Report the problems of a chart without modifying it",
+	Long: `check scans the templates like sync and reports the values missing from the values
+files, along with malformed template actions, conflicting defaults and the findings of
+the chart checks. The chart is not modified. check fails if a value is missing, with
+exit status 2 rather than the status 1 of errors, so it can keep out-of-sync charts
+from being merged.`,
+	Example: `  # Check a chart in CI
+  shcv check ./my-helm-chart
+
+  # Also enforce camelCase value keys
+  shcv check --naming-case camelCase ./my-helm-chart`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		out := quietOutput(flags, cmd.OutOrStdout())
+		opts := append(scanOptions(flags), logOptions(flags, cmd.ErrOrStderr())...)
+		opts = append(opts, shcv.WithOutput(out))
+		return checkChart(args[0], verboseOutput(flags), out, opts...)
+	},
+}
+
+func init() {
+	addLogFlags(checkCmd.Flags())
+	addScanFlags(checkCmd.Flags())
+	RootCmd.AddCommand(checkCmd)
+}
+
+func checkChart(chartDir string, verbose bool, out io.Writer, opts ...shcv.Option) error {
+	chart, err := scanChart(chartDir, verbose, out, nil, opts...)
+	if err != nil || chart == nil {
+		return err
+	}
+
+	missing := chart.MissingValues()
+	for _, finding := range missing {
+		fmt.Fprintln(out, finding)
+	}
+	if len(missing) > 0 {
+		return &exitError{code: exitOutOfSync, err: fmt.Errorf("chart is out of sync: %d missing values", len(missing))}
+	}
+	fmt.Fprintln(out, "chart is in sync")
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckChart(t *testing.T) {
+	setup := func(t *testing.T, values string) string {
+		chartDir := filepath.Join(t.TempDir(), "check-chart")
+		require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte(values), 0644))
+		require.NoError(t, os.WriteFile(
+			filepath.Join(chartDir, "templates/deployment.yaml"),
+			[]byte("kind: Deployment\nspec:\n  replicas: {{ .Values.replicas }}\n"),
+			0644,
+		))
+		return chartDir
+	}
+
+	t.Run("in sync", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, checkChart(setup(t, "replicas: 1\n"), false, &out))
+		assert.Equal(t, "chart is in sync\n", out.String())
+	})
+
+	t.Run("missing values", func(t *testing.T) {
+		chartDir := setup(t, "name: app\n")
+		var out bytes.Buffer
+		err := checkChart(chartDir, false, &out)
+		assert.EqualError(t, err, "chart is out of sync: 1 missing values")
+		assert.Equal(t, exitOutOfSync, exitCode(err))
+		assert.Contains(t, out.String(), "templates/deployment.yaml:3: values.yaml does not define .Values.replicas")
+
+		// Neither the values file nor the deployment is modified
+		content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "name: app\n", string(content))
+		content, err = os.ReadFile(filepath.Join(chartDir, "templates/deployment.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "kind: Deployment\nspec:\n  replicas: {{ .Values.replicas }}\n", string(content))
+	})
+
+	t.Run("findings", func(t *testing.T) {
+		var out bytes.Buffer
+		policy := shcv.NamingPolicy{Case: shcv.CaseKebab}
+		require.NoError(t, checkChart(setup(t, "replicas: 1\nimageTag: v1\n"), false, &out, shcv.WithNamingPolicy(policy)))
+		assert.Contains(t, out.String(), `warning: `)
+		assert.Contains(t, out.String(), `key "imageTag" of .Values.imageTag is not kebab-case`)
+	})
+}
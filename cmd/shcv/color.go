@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// The ANSI colors of the terminal output
+const (
+	colorRed    = "31"
+	colorGreen  = "32"
+	colorYellow = "33"
+)
+
+// noColor disables the colors of the terminal output, set by --no-color and,
+// by convention, the NO_COLOR environment variable
+var noColor = os.Getenv("NO_COLOR") != ""
+
+// colorize returns s in color if w is a terminal showing colors, and s
+// unchanged otherwise, such as when the output is piped or captured.
+func colorize(w io.Writer, color, s string) string {
+	if noColor || os.Getenv("TERM") == "dumb" || !isTerminal(w) {
+		return s
+	}
+	return "\x1b[" + color + "m" + s + "\x1b[0m"
+}
+
+// isTerminal reports whether w writes to a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
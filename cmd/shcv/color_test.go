@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColorize(t *testing.T) {
+	t.Setenv("TERM", "xterm")
+	previous := noColor
+	noColor = false
+	defer func() { noColor = previous }()
+
+	// Captured and redirected output is never colored
+	assert.Equal(t, "added", colorize(&bytes.Buffer{}, colorGreen, "added"))
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+	require.NoError(t, err)
+	defer file.Close()
+	assert.Equal(t, "added", colorize(file, colorGreen, "added"))
+
+	// A character device such as a terminal is
+	device, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skipf("opening %s: %v", os.DevNull, err)
+	}
+	defer device.Close()
+	assert.Equal(t, "\x1b[32madded\x1b[0m", colorize(device, colorGreen, "added"))
+
+	noColor = true
+	assert.Equal(t, "added", colorize(device, colorGreen, "added"))
+	noColor = false
+	t.Setenv("TERM", "dumb")
+	assert.Equal(t, "added", colorize(device, colorGreen, "added"))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// completionCmd prints the shell completion scripts
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Print the shell completion script",
+	Long: `completion prints the script completing the commands, flags and arguments of shcv in
+a shell, including chart directories, values files and the values of flags such as
+--output. Load it in the current shell, or install it for every new shell:
+
+  bash        source <(shcv completion bash)
+              shcv completion bash > /etc/bash_completion.d/shcv
+  zsh         source <(shcv completion zsh)
+              shcv completion zsh > "${fpath[1]}/_shcv"
+  fish        shcv completion fish | source
+              shcv completion fish > ~/.config/fish/completions/shcv.fish
+  powershell  shcv completion powershell | Out-String | Invoke-Expression`,
+	ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, out := cmd.Root(), cmd.OutOrStdout()
+		switch args[0] {
+		case "bash":
+			return root.GenBashCompletionV2(out, true)
+		case "zsh":
+			return root.GenZshCompletion(out)
+		case "fish":
+			return root.GenFishCompletion(out, true)
+		default:
+			return root.GenPowerShellCompletionWithDesc(out)
+		}
+	},
+}
+
+func init() {
+	RootCmd.CompletionOptions.DisableDefaultCmd = true
+	RootCmd.AddCommand(completionCmd)
+}
+
+// flagValues are the values completed for the flags taking one of a few values
+var flagValues = map[string][]string{
+	"link-mode":   {"copy", "anchor"},
+	"naming-case": {shcv.CaseCamel, shcv.CaseKebab},
+	"disable":     shcv.LintRules,
+	"format":      {"table", "json", "yaml"},
+	"error-mode":  {string(shcv.ErrorModeFailFast), string(shcv.ErrorModeCollect)},
+}
+
+// outputFormats are the values completed for the --output flag, by command
+var outputFormats = map[string][]string{
+	"shcv":    {"text", "json"},
+	"sync":    {"text", "json"},
+	"diff":    {"text", "json"},
+	"graph":   {"dot", "json"},
+	"metrics": {"text", "json", "html"},
+	"unused":  {"text", "json"},
+	"version": {"text", "json"},
+}
+
+// fileFlags are the flags taking a file, with the extensions completed
+var fileFlags = map[string][]string{
+	"values":        {"yaml", "yml"},
+	"templates":     {"yaml", "yml", "tpl"},
+	"cache-file":    {"json"},
+	"report-file":   {"json"},
+	"patch-file":    {"patch", "diff"},
+	"capture-repro": {"gz"},
+	"manifest":      {"yaml", "yml"},
+	"html":          {"html"},
+}
+
+// dirFlags are the flags taking a directory
+var dirFlags = []string{"kustomize-scaffold", "workdir"}
+
+// registerCompletions registers the dynamic completions of the flags and chart
+// directory arguments of cmd and its subcommands, which are defined across the
+// files of the package.
+func registerCompletions(cmd *cobra.Command) {
+	if cmd.ValidArgsFunction == nil && strings.Contains(cmd.Use, " [chart-directory]") {
+		cmd.ValidArgsFunction = completeChartDirectory
+	}
+
+	flags := cmd.Flags()
+	for name, exts := range fileFlags {
+		if flags.Lookup(name) != nil {
+			_ = cmd.MarkFlagFilename(name, exts...)
+		}
+	}
+	for _, name := range dirFlags {
+		if flags.Lookup(name) != nil {
+			_ = cmd.MarkFlagDirname(name)
+		}
+	}
+	values := flagValues
+	if formats, ok := outputFormats[cmd.Name()]; ok {
+		values = map[string][]string{"output": formats}
+		for name, v := range flagValues {
+			values[name] = v
+		}
+	}
+	for name, v := range values {
+		if flags.Lookup(name) != nil {
+			// Registering again fails, when the completions are already registered
+			_ = cmd.RegisterFlagCompletionFunc(name, cobra.FixedCompletions(v, cobra.ShellCompDirectiveNoFileComp))
+		}
+	}
+
+	for _, sub := range cmd.Commands() {
+		registerCompletions(sub)
+	}
+}
+
+// completeChartDirectory completes the chart directory, the first argument of
+// the commands processing a chart, with directories.
+func completeChartDirectory(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return nil, cobra.ShellCompDirectiveFilterDirs
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletionScripts(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		t.Run(shell, func(t *testing.T) {
+			out, err := executeCommand(t, "completion", shell)
+			require.NoError(t, err)
+			assert.Contains(t, out, "shcv")
+			assert.Contains(t, out, cobra.ShellCompRequestCmd)
+		})
+	}
+
+	_, err := executeCommand(t, "completion", "tcsh")
+	assert.ErrorContains(t, err, `invalid argument "tcsh"`)
+}
+
+func TestDynamicCompletions(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		want      []string
+		directive cobra.ShellCompDirective
+	}{
+		{
+			name:      "chart directory",
+			args:      []string{"sync", ""},
+			directive: cobra.ShellCompDirectiveFilterDirs,
+		},
+		{
+			name:      "argument after the chart directory",
+			args:      []string{"lint", "chart", ""},
+			directive: cobra.ShellCompDirectiveNoFileComp,
+		},
+		{
+			name:      "values files",
+			args:      []string{"sync", "--values", ""},
+			want:      []string{"yaml", "yml"},
+			directive: cobra.ShellCompDirectiveFilterFileExt,
+		},
+		{
+			name:      "templates",
+			args:      []string{"list", "--templates", ""},
+			want:      []string{"yaml", "yml", "tpl"},
+			directive: cobra.ShellCompDirectiveFilterFileExt,
+		},
+		{
+			name:      "output format of sync",
+			args:      []string{"sync", "--output", ""},
+			want:      []string{"text", "json"},
+			directive: cobra.ShellCompDirectiveNoFileComp,
+		},
+		{
+			name:      "output format of graph",
+			args:      []string{"graph", "--output", ""},
+			want:      []string{"dot", "json"},
+			directive: cobra.ShellCompDirectiveNoFileComp,
+		},
+		{
+			name:      "list format",
+			args:      []string{"list", "--format", ""},
+			want:      []string{"table", "json", "yaml"},
+			directive: cobra.ShellCompDirectiveNoFileComp,
+		},
+		{
+			name:      "lint rules",
+			args:      []string{"lint", "--disable", ""},
+			want:      []string{"no-default", "conflicting-defaults", "mixed-case", "deep-path", "single-use"},
+			directive: cobra.ShellCompDirectiveNoFileComp,
+		},
+		{
+			name:      "link mode",
+			args:      []string{"sync", "--link-mode", ""},
+			want:      []string{"copy", "anchor"},
+			directive: cobra.ShellCompDirectiveNoFileComp,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := executeCommand(t, append([]string{cobra.ShellCompRequestCmd}, tt.args...)...)
+			require.NoError(t, err)
+			// The completions are followed by the directive, and cobra's debug
+			// message on standard error
+			lines := strings.Split(strings.TrimSpace(out), "\n")
+			require.GreaterOrEqual(t, len(lines), 1)
+			var got []string
+			directive := ""
+			for _, line := range lines {
+				if strings.HasPrefix(line, ":") {
+					directive = line
+					break
+				}
+				got = append(got, line)
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, ":"+strconv.Itoa(int(tt.directive)), directive)
+		})
+	}
+}
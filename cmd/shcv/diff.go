@@ -0,0 +1,218 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd compares the value references of two revisions of a chart
+var diffCmd = &cobra.Command{
+	Use:   "diff [old-chart new-chart]",
+	Short: "Compare the value references of two revisions of a chart",
+	Long: `diff compares the templates of two revisions of a chart and reports the values
+referenced by only one of them, and the values whose template default changed, for
+release notes and upgrade reviews. The revisions are two chart directories, or with
+--git ref1..ref2 two git revisions of a chart directory; without ref2, ref1 is
+compared with the chart directory as it is. The charts are not modified.`,
+	Example: `  # Compare two copies of a chart
+  shcv diff ./my-helm-chart-1.0 ./my-helm-chart
+
+  # Compare two releases of a chart in a git repository
+  shcv diff --git v1.0.0..v1.1.0 ./my-helm-chart
+
+  # Compare the last commit with the working tree, as JSON
+  shcv diff --git HEAD.. --output json ./my-helm-chart`,
+	Args: cobra.RangeArgs(1, 2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) < 2 {
+			return nil, cobra.ShellCompDirectiveFilterDirs
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		output, _ := flags.GetString("output")
+		revisions, _ := flags.GetString("git")
+		if revisions == "" {
+			if len(args) != 2 {
+				return errors.New("diff needs two chart directories, or a chart directory with --git")
+			}
+			return diffCharts(args[0], args[1], output, cmd.OutOrStdout(), scanOptions(flags)...)
+		}
+		if len(args) != 1 {
+			return errors.New("diff --git needs a single chart directory")
+		}
+		return diffRevisions(args[0], revisions, output, cmd.OutOrStdout(), scanOptions(flags)...)
+	},
+}
+
+func init() {
+	addScanFlags(diffCmd.Flags())
+	diffCmd.Flags().String("git", "", "compare two git revisions of the chart directory, given as ref1..ref2")
+	diffCmd.Flags().StringP("output", "o", "text", "output format: text or json")
+	RootCmd.AddCommand(diffCmd)
+}
+
+// diffCharts prints the reference changes from the chart in fromDir to the
+// chart in toDir.
+func diffCharts(fromDir, toDir, output string, out io.Writer, opts ...shcv.Option) error {
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unknown output format %q: must be text or json", output)
+	}
+	from, err := loadChart(fromDir, opts...)
+	if err != nil {
+		return err
+	}
+	to, err := loadChart(toDir, opts...)
+	if err != nil {
+		return err
+	}
+	changes := shcv.CompareReferences(from, to)
+
+	if output == "json" {
+		if changes == nil {
+			changes = []shcv.ReferenceChange{}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(changes)
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "no reference changes")
+	}
+	for _, change := range changes {
+		switch change.Kind {
+		case shcv.ReferenceAdded:
+			fmt.Fprintf(out, "%s%s\n", colorize(out, colorGreen, "added .Values."+change.Path), describeDefault(change.NewDefault))
+		case shcv.ReferenceRemoved:
+			fmt.Fprintf(out, "%s%s\n", colorize(out, colorRed, "removed .Values."+change.Path), describeDefault(change.OldDefault))
+		default:
+			fmt.Fprintf(out, "%s: %s -> %s\n", colorize(out, colorYellow, "changed default of .Values."+change.Path),
+				quotedDefault(change.OldDefault), quotedDefault(change.NewDefault))
+		}
+	}
+	return nil
+}
+
+// describeDefault describes the default of an added or removed value.
+func describeDefault(def string) string {
+	if def == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (default %q)", def)
+}
+
+// quotedDefault quotes a changed default, or returns "none" for no default.
+func quotedDefault(def string) string {
+	if def == "" {
+		return "none"
+	}
+	return fmt.Sprintf("%q", def)
+}
+
+// diffRevisions prints the reference changes between two git revisions of the
+// chart directory, given as ref1..ref2. Without ref2, ref1 is compared with
+// the chart directory.
+func diffRevisions(chartDir, revisions, output string, out io.Writer, opts ...shcv.Option) error {
+	fromRef, toRef, ok := strings.Cut(revisions, "..")
+	if !ok || fromRef == "" || strings.HasPrefix(toRef, ".") {
+		return fmt.Errorf("invalid revisions %q: must be ref1..ref2 or ref1..", revisions)
+	}
+	// Refs are passed to git, which would read a leading dash as an option
+	for _, ref := range []string{fromRef, toRef} {
+		if strings.HasPrefix(ref, "-") {
+			return fmt.Errorf("invalid revisions %q: ref %q starts with a dash", revisions, ref)
+		}
+	}
+
+	tmp, err := os.MkdirTemp("", "shcv-diff-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	fromDir := filepath.Join(tmp, "from")
+	if err := extractRevision(chartDir, fromRef, fromDir); err != nil {
+		return err
+	}
+	toDir := chartDir
+	if toRef != "" {
+		toDir = filepath.Join(tmp, "to")
+		if err := extractRevision(chartDir, toRef, toDir); err != nil {
+			return err
+		}
+	}
+	return diffCharts(fromDir, toDir, output, out, opts...)
+}
+
+// extractRevision writes the chart directory as it is in a git revision to dir.
+func extractRevision(chartDir, ref, dir string) error {
+	// The archive is made at the top of the repository, as git archive run in
+	// a subdirectory only archives that subdirectory of the tree
+	location, err := gitOutput(chartDir, "rev-parse", "--show-toplevel", "--show-prefix")
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", ref, err)
+	}
+	top, prefix, _ := strings.Cut(strings.TrimSuffix(location, "\n"), "\n")
+	commit, err := gitOutput(top, "rev-parse", "--verify", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", ref, err)
+	}
+	archive, err := gitOutput(top, "archive", "--format=tar", strings.TrimSpace(commit)+":"+prefix)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", ref, err)
+	}
+
+	files := tar.NewReader(strings.NewReader(archive))
+	for {
+		header, err := files.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", ref, err)
+		}
+		if !filepath.IsLocal(header.Name) {
+			continue
+		}
+		path := filepath.Join(dir, header.Name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, 0755)
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+				var data []byte
+				if data, err = io.ReadAll(files); err == nil {
+					err = os.WriteFile(path, data, 0644)
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("error extracting %s: %w", ref, err)
+		}
+	}
+}
+
+// gitOutput runs a git command in dir and returns its output, or its error
+// output in the error when it fails.
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var output, errOutput bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &errOutput
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(errOutput.String()))
+	}
+	return output.String(), nil
+}
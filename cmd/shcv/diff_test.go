@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeChartRevision writes the service template of a chart revision to dir.
+func writeChartRevision(t *testing.T, dir, template string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "values.yaml"), []byte("name: app\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "templates/service.yaml"), []byte(template), 0644))
+}
+
+const (
+	oldServiceTemplate = "port: {{ .Values.port | default 80 }}\ntype: {{ .Values.type }}\n"
+	newServiceTemplate = "port: {{ .Values.port | default 8080 }}\nhost: {{ .Values.ingress.host | default \"example.com\" }}\n"
+	serviceChanges     = `added .Values.ingress.host (default "example.com")
+changed default of .Values.port: "80" -> "8080"
+removed .Values.type
+`
+)
+
+func TestDiffCommand(t *testing.T) {
+	oldDir, newDir := filepath.Join(t.TempDir(), "old"), filepath.Join(t.TempDir(), "new")
+	writeChartRevision(t, oldDir, oldServiceTemplate)
+	writeChartRevision(t, newDir, newServiceTemplate)
+
+	out, err := executeCommand(t, "diff", oldDir, newDir)
+	require.NoError(t, err)
+	assert.Equal(t, serviceChanges, out)
+
+	out, err = executeCommand(t, "diff", oldDir, oldDir)
+	require.NoError(t, err)
+	assert.Equal(t, "no reference changes\n", out)
+
+	out, err = executeCommand(t, "diff", "--output", "json", oldDir, newDir)
+	require.NoError(t, err)
+	var changes []shcv.ReferenceChange
+	require.NoError(t, json.Unmarshal([]byte(out), &changes))
+	assert.Equal(t, []shcv.ReferenceChange{
+		{Path: "ingress.host", Kind: shcv.ReferenceAdded, NewDefault: "example.com"},
+		{Path: "port", Kind: shcv.ReferenceDefaultChanged, OldDefault: "80", NewDefault: "8080"},
+		{Path: "type", Kind: shcv.ReferenceRemoved},
+	}, changes)
+
+	_, err = executeCommand(t, "diff", oldDir)
+	assert.EqualError(t, err, "diff needs two chart directories, or a chart directory with --git")
+	_, err = executeCommand(t, "diff", "--output", "yaml", oldDir, newDir)
+	assert.EqualError(t, err, `unknown output format "yaml": must be text or json`)
+}
+
+func TestDiffCommandGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := t.TempDir()
+	chartDir := filepath.Join(repo, "deploy", "chart")
+	writeChartRevision(t, chartDir, oldServiceTemplate)
+	commitAll(t, repo)
+	writeChartRevision(t, chartDir, newServiceTemplate)
+	commitAll(t, repo)
+
+	out, err := executeCommand(t, "diff", "--git", "HEAD~1..HEAD", chartDir)
+	require.NoError(t, err)
+	assert.Equal(t, serviceChanges, out)
+
+	// Without ref2, the revision is compared with the chart directory
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/service.yaml"), []byte(oldServiceTemplate), 0644))
+	out, err = executeCommand(t, "diff", "--git", "HEAD~1..", chartDir)
+	require.NoError(t, err)
+	assert.Equal(t, "no reference changes\n", out)
+
+	_, err = executeCommand(t, "diff", "--git", "HEAD~1", chartDir)
+	assert.EqualError(t, err, `invalid revisions "HEAD~1": must be ref1..ref2 or ref1..`)
+	_, err = executeCommand(t, "diff", "--git", "HEAD..", chartDir, chartDir)
+	assert.EqualError(t, err, "diff --git needs a single chart directory")
+	_, err = executeCommand(t, "diff", "--git", "nonexistent..HEAD", chartDir)
+	assert.ErrorContains(t, err, "error reading nonexistent: git rev-parse")
+
+	// Refs are never read as git options
+	output := filepath.Join(t.TempDir(), "written.tar")
+	_, err = executeCommand(t, "diff", "--git", "--output="+output+"..HEAD", chartDir)
+	assert.EqualError(t, err, `invalid revisions "--output=`+output+`..HEAD": ref "--output=`+output+`" starts with a dash`)
+	_, err = executeCommand(t, "diff", "--git", "HEAD..--remote=file:///tmp", chartDir)
+	assert.ErrorContains(t, err, `ref "--remote=file:///tmp" starts with a dash`)
+	assert.NoFileExists(t, output)
+}
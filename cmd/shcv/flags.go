@@ -0,0 +1,230 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/pflag"
+)
+
+// The flags of the commands processing a chart are grouped by what they
+// configure, so that every command adding a group accepts them the same way.
+
+// addScanFlags adds the flags configuring how the chart is read and checked.
+func addScanFlags(flags *pflag.FlagSet) {
+	flags.StringSliceP("values", "f", nil, "additional values files, relative to the chart directory or absolute, loaded after values.yaml (repeatable)")
+	flags.Bool("layered", false, "treat the values files as overrides of values.yaml, like helm install -f, adding missing values to values.yaml only")
+	flags.String("wrap-root", "", "wrap the root of values files that are a list or a scalar under this key instead of failing")
+	flags.StringSlice("exclude", nil, "glob patterns of templates to skip, e.g. \"_*.tpl\" or \"tests/\" (repeatable)")
+	flags.StringSlice("templates", nil, "scan only these templates, relative to the chart directory, instead of all templates")
+	flags.Bool("strict", false, "fail on malformed template actions instead of reporting them as warnings")
+	flags.String("error-mode", string(shcv.ErrorModeFailFast), "fail-fast to stop at the first template that cannot be read, or collect to report all template errors together")
+	flags.Int64("max-file-size", 0, "fail on values files and templates larger than this many bytes instead of reading them, 0 for no limit")
+	flags.Bool("fail-on-conflict", false, "fail when a value has differing defaults in the templates instead of using the first one")
+	flags.Bool("define-policy", false, "warn about named templates that read .Values directly instead of taking values as arguments")
+	addNamingFlags(flags)
+}
+
+// scanOptions returns the chart options set by the scan flags.
+func scanOptions(flags *pflag.FlagSet) []shcv.Option {
+	exclude, _ := flags.GetStringSlice("exclude")
+	templates, _ := flags.GetStringSlice("templates")
+	strict, _ := flags.GetBool("strict")
+	errorMode, _ := flags.GetString("error-mode")
+	maxFileSize, _ := flags.GetInt64("max-file-size")
+	failOnConflict, _ := flags.GetBool("fail-on-conflict")
+	definePolicy, _ := flags.GetBool("define-policy")
+	rootKey, _ := flags.GetString("wrap-root")
+	valuesFiles, _ := flags.GetStringSlice("values")
+	layered, _ := flags.GetBool("layered")
+	opts := []shcv.Option{
+		shcv.WithValuesFileNames(valuesFiles),
+		shcv.WithLayeredValues(layered),
+		shcv.WithValuesRootKey(rootKey),
+		shcv.WithExcludePatterns(exclude),
+		shcv.WithTemplates(templates),
+		shcv.WithStrict(strict),
+		shcv.WithErrorMode(shcv.ErrorMode(errorMode)),
+		shcv.WithMaxFileSize(maxFileSize),
+		shcv.WithFailOnConflict(failOnConflict),
+		shcv.WithDefineValuesPolicy(definePolicy),
+	}
+	if policy := namingPolicy(flags); policy != nil {
+		opts = append(opts, shcv.WithNamingPolicy(*policy))
+	}
+	return opts
+}
+
+// addNamingFlags adds the flags configuring the naming policy.
+func addNamingFlags(flags *pflag.FlagSet) {
+	flags.String("naming-case", "", "require value keys to be camelCase or kebab-case")
+	flags.Int("naming-max-length", 0, "maximum length of value keys, 0 for no limit")
+	flags.Bool("naming-lowercase-top-level", false, "forbid uppercase letters in top-level value keys")
+	flags.StringSlice("naming-ignore", nil, "value paths exempt from the naming policy, with the paths below them (repeatable)")
+}
+
+// namingPolicy returns the naming policy configured by the naming flags, or
+// nil if none is.
+func namingPolicy(flags *pflag.FlagSet) *shcv.NamingPolicy {
+	var policy shcv.NamingPolicy
+	policy.Case, _ = flags.GetString("naming-case")
+	policy.MaxSegmentLength, _ = flags.GetInt("naming-max-length")
+	policy.LowercaseTopLevel, _ = flags.GetBool("naming-lowercase-top-level")
+	policy.Ignore, _ = flags.GetStringSlice("naming-ignore")
+	if policy.Case == "" && policy.MaxSegmentLength == 0 && !policy.LowercaseTopLevel {
+		return nil
+	}
+	return &policy
+}
+
+// addValuesFlags adds the flags choosing the values written for missing references.
+func addValuesFlags(flags *pflag.FlagSet) {
+	flags.Bool("null", false, "write null for missing values without a default")
+	flags.String("placeholder", "", "value written for missing values without a default, e.g. null, CHANGEME or \"TODO: <path>\"")
+	flags.StringArray("set", nil, "value written for a missing value instead of its default, e.g. image.tag=1.2.3, with the syntax of helm --set (repeatable)")
+	flags.Bool("string-defaults", false, "write unquoted template defaults such as 8080 as strings, as earlier versions did")
+	flags.StringSlice("link", nil, "keep a value equal to another one, as path=source, e.g. service.port=gateway.port (repeatable)")
+	flags.String("link-mode", "copy", "how --link writes missing values: copy, or anchor to write them as YAML aliases")
+	flags.Bool("provenance-comments", false, "write a comment naming the template and line above every added value")
+	flags.String("section-banner", "", "write added top-level values below this comment at the end of values files, e.g. \"--- synced by shcv ---\"")
+	flags.Bool("inject-strategy", false, "add a RollingUpdate strategy read from deployment.strategy to Deployment templates that set none")
+}
+
+// valuesOptions returns the chart options set by the values flags.
+func valuesOptions(flags *pflag.FlagSet) ([]shcv.Option, error) {
+	nullValues, _ := flags.GetBool("null")
+	stringDefaults, _ := flags.GetBool("string-defaults")
+	provenance, _ := flags.GetBool("provenance-comments")
+	banner, _ := flags.GetString("section-banner")
+	injectStrategy, _ := flags.GetBool("inject-strategy")
+	set, _ := flags.GetStringArray("set")
+	linkFlags, _ := flags.GetStringSlice("link")
+	linkMode, _ := flags.GetString("link-mode")
+	links, err := valueLinks(linkFlags, linkMode)
+	if err != nil {
+		return nil, err
+	}
+	opts := []shcv.Option{
+		shcv.WithNullValues(nullValues),
+		shcv.WithStringDefaults(stringDefaults),
+		shcv.WithValueLinks(links...),
+		shcv.WithProvenanceComments(provenance),
+		shcv.WithSectionBanner(banner),
+		shcv.WithSetValues(set...),
+		shcv.WithDeploymentStrategyInjection(injectStrategy),
+	}
+	if flags.Changed("placeholder") {
+		placeholder, _ := flags.GetString("placeholder")
+		opts = append(opts, shcv.WithPlaceholder(placeholderValue(placeholder)))
+	}
+	return opts, nil
+}
+
+// addWriteFlags adds the flags configuring how the chart files are written.
+func addWriteFlags(flags *pflag.FlagSet) {
+	flags.Bool("backup", false, "save every modified file to a .bak file first, and restore them if writing fails")
+	flags.Duration("lock-timeout", 30*time.Second, "how long to wait for another run on the chart to finish writing, 0 to fail right away")
+	flags.Bool("sort-keys", false, "rewrite the values files with their keys sorted alphabetically instead of keeping their order")
+}
+
+// writeOptions returns the chart options set by the write flags.
+func writeOptions(flags *pflag.FlagSet) []shcv.Option {
+	backup, _ := flags.GetBool("backup")
+	lockTimeout, _ := flags.GetDuration("lock-timeout")
+	sortKeys, _ := flags.GetBool("sort-keys")
+	return []shcv.Option{
+		shcv.WithBackup(backup),
+		shcv.WithLockTimeout(lockTimeout),
+		shcv.WithSortKeys(sortKeys),
+	}
+}
+
+// addSyncFlags adds the flags of a sync: all flag groups, and the flags for
+// the outputs of a run.
+func addSyncFlags(flags *pflag.FlagSet) {
+	addLogFlags(flags)
+	addScanFlags(flags)
+	addValuesFlags(flags)
+	addWriteFlags(flags)
+	addPruneFlags(flags)
+	flags.Bool("prune", false, "also remove the values no template references from the values files")
+	flags.Bool("dry-run", false, "print the changes to the values files and templates as a unified diff instead of writing them")
+	flags.Bool("interactive", false, "prompt for the values of missing values without a default instead of writing empty values")
+	flags.Bool("check", false, "like --dry-run, but exit with status 2 if the chart would change, for CI")
+	flags.String("patch-file", "", "with --dry-run or --check, also write the changes to this file as a patch for git apply in the current directory")
+	flags.String("cache-file", "", "record run state in this file and skip runs where nothing changed")
+	flags.String("report-file", "", "write a JSON report of the run to this file, also when the run fails")
+	flags.StringP("output", "o", "text", "output format: text, or json to print the report of the run to stdout and the text output to stderr")
+	flags.String("kustomize-scaffold", "", "after syncing, write a kustomize post-renderer scaffold for the chart to this directory")
+	flags.String("capture-repro", "", "write a redacted reproduction bundle (.tar.gz) for bug reports instead of updating the chart")
+}
+
+// addPruneFlags adds the flags configuring which unused values are pruned.
+func addPruneFlags(flags *pflag.FlagSet) {
+	flags.StringSlice("protect", nil, "value paths never pruned, with the paths below them, e.g. \"ci.*\" (repeatable)")
+}
+
+// pruneOptions returns the chart options set by the prune flags.
+func pruneOptions(flags *pflag.FlagSet) []shcv.Option {
+	protected, _ := flags.GetStringSlice("protect")
+	return []shcv.Option{shcv.WithProtectedPaths(protected...)}
+}
+
+// chartOptions returns the chart options set by the scan and values flags.
+func chartOptions(flags *pflag.FlagSet) ([]shcv.Option, error) {
+	values, err := valuesOptions(flags)
+	if err != nil {
+		return nil, err
+	}
+	return append(scanOptions(flags), values...), nil
+}
+
+// addLogFlags adds the flags selecting how much a run prints.
+func addLogFlags(flags *pflag.FlagSet) {
+	flags.CountP("verbose", "v", "verbose output showing all found references and the files written; -vv also logs the progress of the run")
+	flags.BoolP("quiet", "q", false, "only print errors")
+}
+
+// verboseOutput reports whether the log flags ask for verbose output.
+func verboseOutput(flags *pflag.FlagSet) bool {
+	verbosity, _ := flags.GetCount("verbose")
+	return verbosity > 0
+}
+
+// quietOutput returns out, or a writer dropping the output with --quiet.
+func quietOutput(flags *pflag.FlagSet, out io.Writer) io.Writer {
+	if quiet, _ := flags.GetBool("quiet"); quiet {
+		return io.Discard
+	}
+	return out
+}
+
+// logOptions returns the chart options logging the messages of the library
+// to w at the level set by the log flags: warnings by default, the files
+// written with -v, the progress of the run with -vv and only errors with
+// --quiet.
+func logOptions(flags *pflag.FlagSet, w io.Writer) []shcv.Option {
+	level := slog.LevelWarn
+	verbosity, _ := flags.GetCount("verbose")
+	switch quiet, _ := flags.GetBool("quiet"); {
+	case quiet:
+		level = slog.LevelError
+	case verbosity >= 2:
+		level = slog.LevelDebug
+	case verbosity == 1:
+		level = slog.LevelInfo
+	}
+	handler := slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: level,
+		// The messages are read in a terminal, where the time adds nothing
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) == 0 && attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	})
+	return []shcv.Option{shcv.WithLogger(slog.New(handler))}
+}
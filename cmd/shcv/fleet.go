@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// fleetCmd analyzes the charts of many repositories
+var fleetCmd = &cobra.Command{
+	Use:   "fleet",
+	Short: "Analyze charts across many repositories",
+	Long: `fleet clones or refreshes the chart repositories listed in a manifest, analyzes
+every chart without modifying it and prints an organization-wide report of missing
+values and check findings.
+
+Repositories are cloned shallowly into the work directory and refreshed on later runs.
+The manifest lists the repositories with the chart directories inside them:
+
+  repositories:
+    - url: https://github.com/example/payments.git
+      ref: main
+      charts: [deploy/chart]
+    - url: https://github.com/example/search.git
+
+A repository without charts is analyzed as a single chart at its root.`,
+	Example: `  # Report on all charts of the fleet
+  shcv fleet --manifest fleet.yaml
+
+  # Also write an HTML dashboard
+  shcv fleet --manifest fleet.yaml --html fleet.html`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manifest, _ := cmd.Flags().GetString("manifest")
+		workdir, _ := cmd.Flags().GetString("workdir")
+		html, _ := cmd.Flags().GetString("html")
+		return runFleet(manifest, workdir, html, cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	fleetCmd.Flags().String("manifest", "", "manifest listing the chart repositories")
+	fleetCmd.Flags().String("workdir", ".shcv-fleet", "directory the repositories are cloned into")
+	fleetCmd.Flags().String("html", "", "also write the report as an HTML dashboard to this file")
+	fleetCmd.MarkFlagRequired("manifest")
+	RootCmd.AddCommand(fleetCmd)
+}
+
+// fleetManifest lists the repositories of a fleet.
+type fleetManifest struct {
+	Repositories []fleetRepository `json:"repositories"`
+}
+
+// fleetRepository is a git repository containing one or more charts.
+type fleetRepository struct {
+	// Name identifies the repository in the report, defaults to the URL's base name
+	Name string `json:"name"`
+	// URL is the git URL to clone
+	URL string `json:"url"`
+	// Ref is the branch or tag to check out, defaults to the remote's default branch
+	Ref string `json:"ref"`
+	// Charts are the chart directories relative to the repository root
+	Charts []string `json:"charts"`
+}
+
+// fleetResult is the analysis of one chart of the fleet.
+type fleetResult struct {
+	Repository string
+	Chart      string
+	Templates  int
+	References int
+	Missing    []shcv.Finding
+	Findings   []shcv.Finding
+	Err        error
+}
+
+// loadFleetManifest reads a fleet manifest and fills in the defaults.
+func loadFleetManifest(file string) (*fleetManifest, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+	var manifest fleetManifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("error parsing manifest %s: %w", file, err)
+	}
+
+	names := make(map[string]bool)
+	for i := range manifest.Repositories {
+		repo := &manifest.Repositories[i]
+		if repo.URL == "" {
+			return nil, fmt.Errorf("error parsing manifest %s: repository %d has no url", file, i+1)
+		}
+		// Refs are passed to git, which would read a leading dash as an option
+		if strings.HasPrefix(repo.Ref, "-") {
+			return nil, fmt.Errorf("error parsing manifest %s: invalid ref %q of repository %d", file, repo.Ref, i+1)
+		}
+		if repo.Name == "" {
+			repo.Name = strings.TrimSuffix(path.Base(strings.TrimRight(repo.URL, "/")), ".git")
+		}
+		if !filepath.IsLocal(repo.Name) || strings.ContainsAny(repo.Name, `/\`) {
+			return nil, fmt.Errorf("error parsing manifest %s: invalid repository name %q", file, repo.Name)
+		}
+		for _, chart := range repo.Charts {
+			if !filepath.IsLocal(chart) {
+				return nil, fmt.Errorf("error parsing manifest %s: chart %q is outside repository %s", file, chart, repo.Name)
+			}
+		}
+		if names[repo.Name] {
+			return nil, fmt.Errorf("error parsing manifest %s: duplicate repository name %q", file, repo.Name)
+		}
+		names[repo.Name] = true
+		if len(repo.Charts) == 0 {
+			repo.Charts = []string{"."}
+		}
+	}
+	return &manifest, nil
+}
+
+func runFleet(manifestFile, workdir, htmlFile string, out io.Writer) error {
+	manifest, err := loadFleetManifest(manifestFile)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(workdir, 0755); err != nil {
+		return fmt.Errorf("error creating work directory: %w", err)
+	}
+
+	var results []fleetResult
+	for _, repo := range manifest.Repositories {
+		dir := filepath.Join(workdir, repo.Name)
+		if err := syncRepository(repo, dir); err != nil {
+			results = append(results, fleetResult{Repository: repo.Name, Err: err})
+			continue
+		}
+		for _, chart := range repo.Charts {
+			result := analyzeChart(filepath.Join(dir, chart))
+			result.Repository, result.Chart = repo.Name, chart
+			results = append(results, result)
+		}
+	}
+
+	if err := writeFleetReport(out, results); err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	if htmlFile != "" {
+		if err := writeFleetHTML(htmlFile, results); err != nil {
+			return fmt.Errorf("error writing HTML report: %w", err)
+		}
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d charts could not be analyzed", failed, len(results))
+	}
+	return nil
+}
+
+// syncRepository shallowly clones the repository into dir, or refreshes an
+// existing clone to the latest commit of its ref. A clone whose origin is no
+// longer the URL of the manifest is pointed at the new URL first.
+func syncRepository(repo fleetRepository, dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		origin, err := gitOutput(dir, "remote", "get-url", "origin")
+		if err != nil {
+			return fmt.Errorf("refreshing %s: %w", repo.URL, err)
+		}
+		if strings.TrimSpace(origin) != repo.URL {
+			if err := git("-C", dir, "remote", "set-url", "--", "origin", repo.URL); err != nil {
+				return fmt.Errorf("refreshing %s: %w", repo.URL, err)
+			}
+		}
+		ref := repo.Ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+		if err := git("-C", dir, "fetch", "--depth", "1", "--end-of-options", "origin", ref); err != nil {
+			return fmt.Errorf("refreshing %s: %w", repo.URL, err)
+		}
+		if err := git("-C", dir, "reset", "--hard", "FETCH_HEAD"); err != nil {
+			return fmt.Errorf("refreshing %s: %w", repo.URL, err)
+		}
+		return nil
+	}
+
+	args := []string{"clone", "--depth", "1"}
+	if repo.Ref != "" {
+		args = append(args, "--branch", repo.Ref)
+	}
+	if err := git(append(args, "--", repo.URL, dir)...); err != nil {
+		return fmt.Errorf("cloning %s: %w", repo.URL, err)
+	}
+	return nil
+}
+
+// git runs a git command, returning its output in the error when it fails.
+func git(args ...string) error {
+	cmd := exec.Command("git", args...)
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// analyzeChart runs the analysis on a chart without modifying it.
+func analyzeChart(dir string) fleetResult {
+	var result fleetResult
+	chart, err := shcv.NewChart(dir)
+	if err != nil {
+		result.Err = fmt.Errorf("error creating chart: %w", err)
+		return result
+	}
+	if err := chart.LoadValueFiles(); err != nil {
+		result.Err = fmt.Errorf("error loading values: %w", err)
+		return result
+	}
+	if err := chart.FindTemplates(); err != nil {
+		result.Err = fmt.Errorf("error finding templates: %w", err)
+		return result
+	}
+	if err := chart.ParseTemplates(); err != nil {
+		result.Err = fmt.Errorf("error parsing templates: %w", err)
+		return result
+	}
+	findings, err := chart.RunChecks()
+	if err != nil {
+		result.Err = fmt.Errorf("error checking chart: %w", err)
+		return result
+	}
+
+	result.Templates = len(chart.Templates)
+	result.References = len(chart.References)
+	result.Missing = relativeFindings(dir, chart.MissingValues())
+	result.Findings = relativeFindings(dir, findings)
+	return result
+}
+
+// relativeFindings makes the source files of findings relative to the chart directory.
+func relativeFindings(dir string, findings []shcv.Finding) []shcv.Finding {
+	for i := range findings {
+		if rel, err := filepath.Rel(dir, findings[i].SourceFile); err == nil {
+			findings[i].SourceFile = rel
+		}
+	}
+	return findings
+}
+
+// writeFleetReport prints a summary table of the fleet followed by the details of every chart.
+func writeFleetReport(out io.Writer, results []fleetResult) error {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tCHART\tTEMPLATES\tREFERENCES\tMISSING\tWARNINGS\tSTATUS")
+	var templates, references, missing, warnings int
+	for _, r := range results {
+		status := "ok"
+		switch {
+		case r.Err != nil:
+			status = "error"
+		case len(r.Missing) > 0:
+			status = "out of sync"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n",
+			r.Repository, r.Chart, r.Templates, r.References, len(r.Missing), len(r.Findings), status)
+		templates += r.Templates
+		references += r.References
+		missing += len(r.Missing)
+		warnings += len(r.Findings)
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d charts\t%d\t%d\t%d\t%d\t\n", len(results), templates, references, missing, warnings)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if r.Err == nil && len(r.Missing) == 0 && len(r.Findings) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "\n%s/%s:\n", r.Repository, r.Chart)
+		if r.Err != nil {
+			fmt.Fprintf(out, "  error: %v\n", r.Err)
+		}
+		for _, f := range r.Missing {
+			fmt.Fprintf(out, "  missing: %s\n", f)
+		}
+		for _, f := range r.Findings {
+			fmt.Fprintf(out, "  warning: %s\n", f)
+		}
+	}
+	return nil
+}
+
+// fleetHTML is the HTML dashboard of a fleet report
+var fleetHTML = template.Must(template.New("fleet").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>shcv fleet report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+.error, .missing { color: #b00020; } .warning { color: #a15c00; }
+</style>
+</head>
+<body>
+<h1>shcv fleet report</h1>
+<table>
+<tr><th>Repository</th><th>Chart</th><th>Templates</th><th>References</th><th>Missing</th><th>Warnings</th></tr>
+{{- range . }}
+<tr><td>{{ .Repository }}</td><td>{{ .Chart }}</td><td>{{ .Templates }}</td><td>{{ .References }}</td><td>{{ len .Missing }}</td><td>{{ len .Findings }}</td></tr>
+{{- end }}
+</table>
+{{- range . }}
+{{- if or .Err .Missing .Findings }}
+<h2>{{ .Repository }}/{{ .Chart }}</h2>
+<ul>
+{{- if .Err }}
+<li class="error">{{ .Err }}</li>
+{{- end }}
+{{- range .Missing }}
+<li class="missing">{{ .SourceFile }}:{{ .LineNumber }}: {{ .Message }}</li>
+{{- end }}
+{{- range .Findings }}
+<li class="warning">{{ .SourceFile }}:{{ .LineNumber }}: {{ .Message }}</li>
+{{- end }}
+</ul>
+{{- end }}
+{{- end }}
+</body>
+</html>
+`))
+
+// writeFleetHTML writes the fleet report as an HTML dashboard.
+func writeFleetHTML(file string, results []fleetResult) error {
+	var buf bytes.Buffer
+	if err := fleetHTML.Execute(&buf, results); err != nil {
+		return err
+	}
+	return os.WriteFile(file, buf.Bytes(), 0644)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createChartRepository creates a git repository with the given files and returns its file:// URL.
+func createChartRepository(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	commitAll(t, dir)
+	return "file://" + dir
+}
+
+// commitAll commits all files of the repository in dir, initializing it when needed.
+func commitAll(t *testing.T, dir string) {
+	t.Helper()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "update"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+}
+
+func TestLoadFleetManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     *fleetManifest
+		wantErr  string
+	}{
+		{
+			name:     "defaults",
+			manifest: "repositories:\n  - url: https://example.com/org/payments.git\n  - url: https://example.com/org/search/\n    name: find\n    ref: v1\n    charts: [deploy/a, deploy/b]\n",
+			want: &fleetManifest{Repositories: []fleetRepository{
+				{Name: "payments", URL: "https://example.com/org/payments.git", Charts: []string{"."}},
+				{Name: "find", URL: "https://example.com/org/search/", Ref: "v1", Charts: []string{"deploy/a", "deploy/b"}},
+			}},
+		},
+		{
+			name:     "missing url",
+			manifest: "repositories:\n  - name: a\n",
+			wantErr:  "repository 1 has no url",
+		},
+		{
+			name:     "duplicate name",
+			manifest: "repositories:\n  - url: https://a.example.com/chart\n  - url: https://b.example.com/chart.git\n",
+			wantErr:  `duplicate repository name "chart"`,
+		},
+		{
+			name:     "invalid name",
+			manifest: "repositories:\n  - url: https://example.com/chart\n    name: ../chart\n",
+			wantErr:  `invalid repository name "../chart"`,
+		},
+		{
+			name:     "chart outside repository",
+			manifest: "repositories:\n  - url: https://example.com/chart\n    charts: [../other]\n",
+			wantErr:  `chart "../other" is outside repository chart`,
+		},
+		{
+			name:     "ref read as an option",
+			manifest: "repositories:\n  - url: https://example.com/chart\n    ref: --upload-pack=touch /tmp/pwned\n",
+			wantErr:  `invalid ref "--upload-pack=touch /tmp/pwned" of repository 1`,
+		},
+		{
+			name:     "invalid yaml",
+			manifest: "repositories: [\n",
+			wantErr:  "error parsing manifest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "fleet.yaml")
+			require.NoError(t, os.WriteFile(file, []byte(tt.manifest), 0644))
+
+			got, err := loadFleetManifest(file)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRunFleet(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	synced := createChartRepository(t, map[string]string{
+		"values.yaml":               "name: app\n",
+		"templates/deployment.yaml": "name: {{ .Values.name }}\n",
+	})
+	outOfSync := createChartRepository(t, map[string]string{
+		"deploy/chart/values.yaml":            "name: app\n",
+		"deploy/chart/templates/service.yaml": "port: {{ .Values.service.port }}\n",
+	})
+
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "fleet.yaml")
+	require.NoError(t, os.WriteFile(manifest, []byte("repositories:\n"+
+		"  - url: "+synced+"\n    name: web\n"+
+		"  - url: "+outOfSync+"\n    name: api\n    charts: [deploy/chart]\n"), 0644))
+	workdir := filepath.Join(dir, "work")
+	htmlFile := filepath.Join(dir, "fleet.html")
+
+	var out bytes.Buffer
+	require.NoError(t, runFleet(manifest, workdir, htmlFile, &out))
+	assert.Regexp(t, `web\s+\.\s+1\s+1\s+0\s+0\s+ok`, out.String())
+	assert.Regexp(t, `api\s+deploy/chart\s+1\s+1\s+1\s+0\s+out of sync`, out.String())
+	assert.Regexp(t, `TOTAL\s+2 charts\s+2\s+2\s+1\s+0`, out.String())
+	assert.Contains(t, out.String(), "api/deploy/chart:\n  missing: templates/service.yaml:1: values.yaml does not define .Values.service.port\n")
+
+	html, err := os.ReadFile(htmlFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(html), "<h2>api/deploy/chart</h2>")
+	assert.Contains(t, string(html), "values.yaml does not define .Values.service.port")
+
+	// The analysis never modifies the cloned charts
+	values, err := os.ReadFile(filepath.Join(workdir, "api/deploy/chart/values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\n", string(values))
+
+	t.Run("refreshes existing clones", func(t *testing.T) {
+		repo := outOfSync[len("file://"):]
+		require.NoError(t, os.WriteFile(filepath.Join(repo, "deploy/chart/values.yaml"), []byte("name: app\nservice:\n  port: 80\n"), 0644))
+		commitAll(t, repo)
+
+		var out bytes.Buffer
+		require.NoError(t, runFleet(manifest, workdir, "", &out))
+		assert.Regexp(t, `api\s+deploy/chart\s+1\s+1\s+0\s+0\s+ok`, out.String())
+	})
+
+	t.Run("follows a changed url", func(t *testing.T) {
+		moved := createChartRepository(t, map[string]string{
+			"deploy/chart/values.yaml":            "name: app\nservice:\n  port: 80\nreplicas: 2\n",
+			"deploy/chart/templates/service.yaml": "port: {{ .Values.service.port }}\nreplicas: {{ .Values.replicas }}\n",
+		})
+		require.NoError(t, os.WriteFile(manifest, []byte("repositories:\n"+
+			"  - url: "+moved+"\n    name: api\n    charts: [deploy/chart]\n"), 0644))
+
+		var out bytes.Buffer
+		require.NoError(t, runFleet(manifest, workdir, "", &out))
+		assert.Regexp(t, `api\s+deploy/chart\s+1\s+2\s+0\s+0\s+ok`, out.String())
+		origin, err := gitOutput(filepath.Join(workdir, "api"), "remote", "get-url", "origin")
+		require.NoError(t, err)
+		assert.Equal(t, moved+"\n", origin)
+	})
+
+	t.Run("unreachable repository", func(t *testing.T) {
+		broken := filepath.Join(dir, "broken.yaml")
+		require.NoError(t, os.WriteFile(broken, []byte("repositories:\n  - url: file://"+filepath.Join(dir, "missing")+"\n"), 0644))
+
+		var out bytes.Buffer
+		err := runFleet(broken, workdir, "", &out)
+		assert.EqualError(t, err, "1 of 1 charts could not be analyzed")
+		assert.Regexp(t, `missing\s+0\s+0\s+0\s+0\s+error`, out.String())
+		assert.Contains(t, out.String(), "error: cloning file://")
+	})
+}
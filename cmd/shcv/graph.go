@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// graphCmd exports the reference graph of the chart
+var graphCmd = &cobra.Command{
+	Use:   "graph [chart-directory]",
+	Short: "Export the graph of value references",
+	Long: `graph exports which templates reference which values, and which values take their
+default from another value, as in {{ .Values.b | default .Values.a }}. The chart is not
+modified.`,
+	Example: `  # Render the graph with Graphviz
+  shcv graph ./my-helm-chart | dot -Tsvg > values.svg
+
+  # Export the graph as JSON
+  shcv graph --output json ./my-helm-chart`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		return referenceGraph(args[0], output, cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	graphCmd.Flags().StringP("output", "o", "dot", "output format: dot or json")
+	RootCmd.AddCommand(graphCmd)
+}
+
+func referenceGraph(chartDir, output string, out io.Writer) error {
+	chart, err := shcv.NewChart(chartDir)
+	if err != nil {
+		return fmt.Errorf("error creating chart: %w", err)
+	}
+	if err := chart.FindTemplates(); err != nil {
+		return fmt.Errorf("error finding templates: %w", err)
+	}
+	if err := chart.ParseTemplates(); err != nil {
+		return fmt.Errorf("error parsing templates: %w", err)
+	}
+	graph := chart.ReferenceGraph()
+
+	switch output {
+	case "dot":
+		return graph.WriteDOT(out)
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(graph)
+	default:
+		return fmt.Errorf("unknown output format %q: must be dot or json", output)
+	}
+}
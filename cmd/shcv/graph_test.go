@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferenceGraph(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "graph-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(chartDir, "templates/deployment.yaml"),
+		[]byte("tag: {{ .Values.image.tag | default .Values.global.tag }}\n"),
+		0644,
+	))
+
+	t.Run("dot", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, referenceGraph(chartDir, "dot", &out))
+		assert.Contains(t, out.String(), "\t\".Values.image.tag\" -> \".Values.global.tag\" [style=dashed, label=\"default\"];\n")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, referenceGraph(chartDir, "json", &out))
+		var graph shcv.ReferenceGraph
+		require.NoError(t, json.Unmarshal(out.Bytes(), &graph))
+		assert.Equal(t, []string{"templates/deployment.yaml"}, graph.Templates)
+		assert.Contains(t, graph.Edges, shcv.GraphEdge{From: "image.tag", To: "global.tag", Kind: shcv.EdgeDefault})
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		err := referenceGraph(chartDir, "svg", &bytes.Buffer{})
+		assert.EqualError(t, err, `unknown output format "svg": must be dot or json`)
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// initCmd scaffolds the values file of a chart from its templates
+var initCmd = &cobra.Command{
+	Use:   "init [chart-directory]",
+	Short: "Create the values file of a chart from its templates",
+	Long: `init creates the values.yaml of a chart that has templates but no values file yet.
+Every value the templates reference is written with its template default or the zero
+value of its inferred type, and the top-level values are grouped in sections below a
+comment naming the template referencing them first. With --schema, a values.schema.json
+declaring the types of the values is created as well. init refuses to overwrite an
+existing values file or schema; use sync to add missing values to a values file.`,
+	Example: `  # Create values.yaml for a new chart
+  shcv init ./my-helm-chart
+
+  # Also create values.schema.json, previewing the files first
+  shcv init --schema --dry-run ./my-helm-chart`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		schema, _ := flags.GetBool("schema")
+		dryRun, _ := flags.GetBool("dry-run")
+		return initChart(args[0], schema, dryRun, cmd.OutOrStdout(), scanOptions(flags)...)
+	},
+}
+
+func init() {
+	addScanFlags(initCmd.Flags())
+	initCmd.Flags().Bool("schema", false, "also create a values.schema.json declaring the types of the values")
+	initCmd.Flags().Bool("dry-run", false, "print the files as a diff instead of creating them")
+	RootCmd.AddCommand(initCmd)
+}
+
+func initChart(chartDir string, schema, dryRun bool, out io.Writer, opts ...shcv.Option) error {
+	chart, err := loadChart(chartDir, opts...)
+	if err != nil {
+		return err
+	}
+	changes, err := chart.ScaffoldValues(schema)
+	if err != nil {
+		return fmt.Errorf("error scaffolding values: %w", err)
+	}
+
+	if dryRun {
+		for _, change := range changes {
+			fmt.Fprint(out, change.Diff(chartDir))
+		}
+		return nil
+	}
+	if err := shcv.WriteChanges(changes); err != nil {
+		return fmt.Errorf("error writing changes: %w", err)
+	}
+	for _, change := range changes {
+		fmt.Fprintln(out, colorize(out, colorGreen, "created "+relPath(chartDir, change.Path)))
+	}
+	return nil
+}
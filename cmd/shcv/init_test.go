@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitCommand(t *testing.T) {
+	chartDir := writeCommandChart(t)
+	require.NoError(t, os.Remove(filepath.Join(chartDir, "values.yaml")))
+
+	out, err := executeCommand(t, "init", "--schema", "--dry-run", chartDir)
+	require.NoError(t, err)
+	assert.Contains(t, out, "--- a/values.yaml\n+++ b/values.yaml\n@@ -0,0 +1,2 @@\n+# templates/service.yaml\n+port: \"\"\n")
+	assert.Contains(t, out, "+++ b/values.schema.json\n")
+	_, err = os.Stat(filepath.Join(chartDir, "values.yaml"))
+	assert.True(t, os.IsNotExist(err))
+
+	out, err = executeCommand(t, "init", "--schema", chartDir)
+	require.NoError(t, err)
+	assert.Equal(t, "created values.yaml\ncreated values.schema.json\n", out)
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "# templates/service.yaml\nport: \"\"\n", string(content))
+	_, err = os.Stat(filepath.Join(chartDir, "values.schema.json"))
+	require.NoError(t, err)
+
+	_, err = executeCommand(t, "init", chartDir)
+	assert.ErrorContains(t, err, "error scaffolding values: values file")
+	assert.ErrorContains(t, err, "already exists")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// valuePrompt returns a prompt asking for the missing values without a
+// default on out, reading the answers from in. An empty answer, or the end of
+// in, skips the value; invalid answers are asked again.
+func valuePrompt(in io.Reader, out io.Writer) shcv.ValuePrompt {
+	scanner := bufio.NewScanner(in)
+	return func(ref shcv.ValueRef) (any, bool) {
+		for {
+			if ref.Type != shcv.TypeUnknown {
+				fmt.Fprintf(out, "value for %s (%s) [skip]: ", ref.Path, ref.Type)
+			} else {
+				fmt.Fprintf(out, "value for %s [skip]: ", ref.Path)
+			}
+			if !scanner.Scan() {
+				fmt.Fprintln(out)
+				return nil, false
+			}
+			answer := strings.TrimSpace(scanner.Text())
+			if answer == "" {
+				return nil, false
+			}
+			value, err := parseAnswer(answer, ref.Type)
+			if err == nil {
+				return value, true
+			}
+			fmt.Fprintf(out, "invalid value: %v\n", err)
+		}
+	}
+}
+
+// parseAnswer converts an answer to a value of type t. Answers for values of
+// unknown type are read as YAML, so that 8080 is a number and true a boolean.
+func parseAnswer(answer string, t shcv.ValueType) (any, error) {
+	switch t {
+	case shcv.TypeString:
+		return answer, nil
+	case shcv.TypeInt:
+		i, err := strconv.Atoi(answer)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", answer)
+		}
+		return i, nil
+	case shcv.TypeBool:
+		b, err := strconv.ParseBool(answer)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", answer)
+		}
+		return b, nil
+	}
+
+	var value any
+	if err := yamlv3.Unmarshal([]byte(answer), &value); err != nil {
+		if t == shcv.TypeUnknown {
+			return answer, nil
+		}
+		return nil, fmt.Errorf("%q is not YAML: %w", answer, err)
+	}
+	switch value.(type) {
+	case map[string]any:
+		if t == shcv.TypeMap || t == shcv.TypeUnknown {
+			return value, nil
+		}
+	case []any:
+		if t == shcv.TypeList || t == shcv.TypeUnknown {
+			return value, nil
+		}
+	default:
+		if t == shcv.TypeUnknown {
+			return value, nil
+		}
+	}
+	if t == shcv.TypeMap {
+		return nil, fmt.Errorf("%q is not a map, such as {key: value}", answer)
+	}
+	return nil, fmt.Errorf("%q is not a list, such as [a, b]", answer)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAnswer(t *testing.T) {
+	tests := []struct {
+		answer  string
+		typ     shcv.ValueType
+		want    any
+		wantErr string
+	}{
+		{answer: "8080", typ: shcv.TypeString, want: "8080"},
+		{answer: "3", typ: shcv.TypeInt, want: 3},
+		{answer: "three", typ: shcv.TypeInt, wantErr: `"three" is not an integer`},
+		{answer: "true", typ: shcv.TypeBool, want: true},
+		{answer: "yes", typ: shcv.TypeBool, wantErr: `"yes" is not a boolean`},
+		{answer: "{cpu: 1}", typ: shcv.TypeMap, want: map[string]any{"cpu": 1}},
+		{answer: "web", typ: shcv.TypeMap, wantErr: `"web" is not a map, such as {key: value}`},
+		{answer: "[a, b]", typ: shcv.TypeList, want: []any{"a", "b"}},
+		{answer: "a", typ: shcv.TypeList, wantErr: `"a" is not a list, such as [a, b]`},
+		{answer: "8080", want: 8080},
+		{answer: "example.com", want: "example.com"},
+		{answer: "a: [", want: "a: ["},
+	}
+	for _, tt := range tests {
+		t.Run(tt.answer, func(t *testing.T) {
+			got, err := parseAnswer(tt.answer, tt.typ)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSyncInteractive(t *testing.T) {
+	chartDir := writeCommandChart(t)
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/deployment.yaml"),
+		[]byte("replicas: {{ .Values.replicas | int }}\nhost: {{ .Values.gateway.domain }}\ntag: {{ .Values.tag | default \"v1\" }}\n"), 0644))
+
+	var out bytes.Buffer
+	in := strings.NewReader("three\n3\n\napi.example.com\n")
+	require.NoError(t, processChart(chartDir, false, &out, shcv.WithValuePrompt(valuePrompt(in, &out))))
+	assert.Equal(t, `value for replicas (int) [skip]: invalid value: "three" is not an integer
+value for replicas (int) [skip]: value for gateway.domain [skip]: value for port [skip]: added .Values.replicas (values.yaml)
+added .Values.gateway.domain (values.yaml)
+added .Values.tag (values.yaml)
+added .Values.port (values.yaml)
+`, out.String())
+
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\ngateway:\n  domain: \"\"\nport: api.example.com\nreplicas: 3\ntag: v1\n", string(content))
+}
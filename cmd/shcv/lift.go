@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// liftDefaultsCmd moves template defaults into the values file
+var liftDefaultsCmd = &cobra.Command{
+	Use:   "lift-defaults [chart-directory] path...",
+	Short: "Move template defaults into the values file",
+	Long: `lift-defaults removes the "| default" literals applied to the selected value paths
+from the templates and writes the literals into the values file instead. A path selects
+itself and every path nested below it.
+
+The templates and the values file are updated together, and a diff of the changes is
+printed before they are applied.`,
+	Example: `  # Move the defaults of all image values into values.yaml
+  shcv lift-defaults ./my-helm-chart image
+
+  # Preview the changes without applying them
+  shcv lift-defaults --dry-run ./my-helm-chart image.tag service.port`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		return liftDefaults(args[0], args[1:], dryRun, cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	liftDefaultsCmd.Flags().Bool("dry-run", false, "print the changes without applying them")
+	RootCmd.AddCommand(liftDefaultsCmd)
+}
+
+func liftDefaults(chartDir string, paths []string, dryRun bool, out io.Writer) error {
+	chart, err := shcv.NewChart(chartDir)
+	if err != nil {
+		return fmt.Errorf("error creating chart: %w", err)
+	}
+	if err := chart.LoadValueFiles(); err != nil {
+		return fmt.Errorf("error loading values: %w", err)
+	}
+	if err := chart.FindTemplates(); err != nil {
+		return fmt.Errorf("error finding templates: %w", err)
+	}
+	if err := chart.ParseTemplates(); err != nil {
+		return fmt.Errorf("error parsing templates: %w", err)
+	}
+
+	changes, err := chart.LiftDefaults(paths)
+	if err != nil {
+		return fmt.Errorf("error lifting defaults: %w", err)
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "no defaults to lift")
+		return nil
+	}
+
+	for _, change := range changes {
+		fmt.Fprint(out, change.Diff(chartDir))
+	}
+	if dryRun {
+		return nil
+	}
+
+	if err := shcv.WriteChanges(changes); err != nil {
+		return fmt.Errorf("error writing changes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiftDefaults(t *testing.T) {
+	setup := func(t *testing.T) string {
+		chartDir := filepath.Join(t.TempDir(), "lift-chart")
+		require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("name: app\n"), 0644))
+		require.NoError(t, os.WriteFile(
+			filepath.Join(chartDir, "templates/deployment.yaml"),
+			[]byte("image: {{ .Values.image.tag | default \"latest\" }}\n"),
+			0644,
+		))
+		return chartDir
+	}
+
+	t.Run("apply", func(t *testing.T) {
+		chartDir := setup(t)
+		var out bytes.Buffer
+		require.NoError(t, liftDefaults(chartDir, []string{"image"}, false, &out))
+
+		assert.Contains(t, out.String(), "--- a/templates/deployment.yaml")
+		assert.Contains(t, out.String(), "+image: {{ .Values.image.tag }}")
+		assert.Contains(t, out.String(), "+  tag: latest")
+
+		content, err := os.ReadFile(filepath.Join(chartDir, "templates/deployment.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "image: {{ .Values.image.tag }}\n", string(content))
+		content, err = os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "name: app\nimage:\n  tag: latest\n", string(content))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		chartDir := setup(t)
+		var out bytes.Buffer
+		require.NoError(t, liftDefaults(chartDir, []string{"image"}, true, &out))
+		assert.Contains(t, out.String(), "+image: {{ .Values.image.tag }}")
+
+		content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "name: app\n", string(content))
+	})
+
+	t.Run("nothing to lift", func(t *testing.T) {
+		chartDir := setup(t)
+		var out bytes.Buffer
+		require.NoError(t, liftDefaults(chartDir, []string{"service"}, false, &out))
+		assert.Equal(t, "no defaults to lift\n", out.String())
+	})
+
+	t.Run("invalid chart", func(t *testing.T) {
+		err := liftDefaults("nonexistent", []string{"image"}, false, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "error creating chart")
+	})
+}
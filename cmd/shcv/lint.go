@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// lintCmd reports the hygiene problems of the values of a chart
+var lintCmd = &cobra.Command{
+	Use:   "lint [chart-directory]",
+	Short: "Report the hygiene problems of the values of a chart",
+	Long: `lint checks the values layout of a chart with the following rules and prints their
+findings, each tagged with its rule:
+
+  no-default            values without a template default that no values file defines
+  conflicting-defaults  values given differing defaults by their references
+  mixed-case            keys in the less common of camelCase and kebab-case
+  deep-path             value paths nested deeper than --max-depth keys
+  single-use            values referenced only once in the templates
+
+Every rule can be disabled with --disable. The chart is not modified. lint fails with
+exit status 2 when there are findings, and status 1 on errors.`,
+	Example: `  # Lint a chart
+  shcv lint ./my-helm-chart
+
+  # Skip the single-use rule and allow paths up to 5 keys deep
+  shcv lint --disable single-use --max-depth 5 ./my-helm-chart`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		var policy shcv.LintPolicy
+		policy.Disabled, _ = flags.GetStringSlice("disable")
+		policy.MaxDepth, _ = flags.GetInt("max-depth")
+		opts := append(scanOptions(flags), shcv.WithLintPolicy(policy))
+		return lintChart(args[0], cmd.OutOrStdout(), opts...)
+	},
+}
+
+func init() {
+	addScanFlags(lintCmd.Flags())
+	lintCmd.Flags().StringSlice("disable", nil, "lint rules not to run, e.g. single-use (repeatable)")
+	lintCmd.Flags().Int("max-depth", shcv.DefaultLintMaxDepth, "number of keys value paths may have before deep-path reports them")
+	RootCmd.AddCommand(lintCmd)
+}
+
+// lintChart prints the lint findings of a chart, with paths relative to the
+// chart directory.
+func lintChart(chartDir string, out io.Writer, opts ...shcv.Option) error {
+	chart, err := loadChart(chartDir, opts...)
+	if err != nil {
+		return err
+	}
+	findings, err := chart.Lint()
+	if err != nil {
+		return fmt.Errorf("error linting chart: %w", err)
+	}
+	for _, finding := range findings {
+		finding.SourceFile = relPath(chartDir, finding.SourceFile)
+		fmt.Fprintf(out, "%s [%s]\n", finding, finding.Check)
+	}
+	if len(findings) > 0 {
+		return &exitError{code: exitOutOfSync, err: fmt.Errorf("%d lint findings", len(findings))}
+	}
+	fmt.Fprintln(out, "no lint findings")
+	return nil
+}
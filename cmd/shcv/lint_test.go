@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintChart(t *testing.T) {
+	chartDir := writeCommandChart(t)
+
+	var out bytes.Buffer
+	err := lintChart(chartDir, &out)
+	require.EqualError(t, err, "2 lint findings")
+	assert.Equal(t, exitOutOfSync, exitCode(err))
+	assert.Equal(t, "templates/service.yaml:1: .Values.port has no default in the templates or the values files [no-default]\n"+
+		"templates/service.yaml:1: .Values.port is only used once [single-use]\n", out.String())
+
+	out.Reset()
+	require.NoError(t, lintChart(chartDir, &out, shcv.WithLintPolicy(shcv.LintPolicy{Disabled: []string{shcv.LintNoDefault, shcv.LintSingleUse}})))
+	assert.Equal(t, "no lint findings\n", out.String())
+
+	// The chart is not modified
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\n", string(content))
+
+	_, err = executeCommand(t, "lint", "--disable", "typo", chartDir)
+	assert.ErrorContains(t, err, `unknown lint rule "typo"`)
+}
+
+func TestLintCommandSilencesUsage(t *testing.T) {
+	// Earlier runs silence the usage of the command they ran
+	lintCmd.SilenceUsage = false
+	var out, errOut bytes.Buffer
+	err := executeCommandOutputs(t, &out, &errOut, "lint", writeCommandChart(t))
+	assert.Equal(t, exitOutOfSync, exitCode(err))
+	assert.Contains(t, out.String(), "[no-default]")
+	assert.NotContains(t, out.String()+errOut.String(), "Usage:")
+}
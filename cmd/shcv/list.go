@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// listCmd lists the value references of the templates
+var listCmd = &cobra.Command{
+	Use:   "list [chart-directory]",
+	Short: "List the value references of the templates",
+	Long: `list prints every .Values reference found in the templates, with its path, the template
+and line it is in, its default and the values files defining it. The chart is not
+modified.`,
+	Example: `  # List the references as a table
+  shcv list ./my-helm-chart
+
+  # Export them as JSON or YAML
+  shcv list --format json ./my-helm-chart
+  shcv list --format yaml ./my-helm-chart`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		return listReferences(args[0], format, cmd.OutOrStdout(), scanOptions(cmd.Flags())...)
+	},
+}
+
+func init() {
+	addScanFlags(listCmd.Flags())
+	listCmd.Flags().String("format", "table", "output format: table, json or yaml")
+	RootCmd.AddCommand(listCmd)
+}
+
+// listedReference is a value reference printed by list.
+type listedReference struct {
+	Path    string `json:"path"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Default string `json:"default,omitempty"`
+	// Defined indicates that a values file defines the value
+	Defined bool `json:"defined"`
+	// ValuesFiles are the values files defining the value
+	ValuesFiles []string `json:"valuesFiles,omitempty"`
+	// Ignored indicates that an ignore directive excludes the reference from syncing
+	Ignored bool `json:"ignored,omitempty"`
+}
+
+func listReferences(chartDir, format string, out io.Writer, opts ...shcv.Option) error {
+	if format != "table" && format != "json" && format != "yaml" {
+		return fmt.Errorf("unknown output format %q: must be table, json or yaml", format)
+	}
+	chart, err := loadChart(chartDir, opts...)
+	if err != nil {
+		return err
+	}
+
+	refs := listReferencesOf(chart)
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(refs)
+	case "yaml":
+		data, err := yaml.Marshal(refs)
+		if err != nil {
+			return fmt.Errorf("error encoding references: %w", err)
+		}
+		_, err = out.Write(data)
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tFILE\tLINE\tDEFAULT\tDEFINED IN")
+	for i, listed := range refs {
+		def, defined := "-", "-"
+		if ref := chart.References[i]; ref.DefaultValue != "" {
+			def = ref.DefaultValue
+			if !ref.DefaultUnquoted {
+				def = fmt.Sprintf("%q", def)
+			}
+		}
+		if listed.Defined {
+			defined = strings.Join(listed.ValuesFiles, ", ")
+		} else if listed.Ignored {
+			defined = "ignored"
+		}
+		fmt.Fprintf(tw, ".Values.%s\t%s\t%d\t%s\t%s\n", listed.Path, listed.File, listed.Line, def, defined)
+	}
+	return tw.Flush()
+}
+
+// listReferencesOf returns the value references of a parsed chart, with the
+// values files defining them.
+func listReferencesOf(chart *shcv.Chart) []listedReference {
+	refs := make([]listedReference, 0, len(chart.References))
+	for _, ref := range chart.References {
+		listed := listedReference{
+			Path:    ref.Path,
+			File:    relPath(chart.Dir, ref.SourceFile),
+			Line:    ref.LineNumber,
+			Default: ref.DefaultValue,
+			Ignored: ref.Ignored,
+		}
+		for i := range chart.ValuesFiles {
+			if file := &chart.ValuesFiles[i]; file.Defines(ref.Path) {
+				listed.ValuesFiles = append(listed.ValuesFiles, relPath(chart.Dir, file.Path))
+			}
+		}
+		listed.Defined = len(listed.ValuesFiles) > 0
+		refs = append(refs, listed)
+	}
+	return refs
+}
+
+// relPath returns path relative to the chart directory if possible.
+func relPath(chartDir, path string) string {
+	if rel, err := filepath.Rel(chartDir, path); err == nil {
+		return rel
+	}
+	return path
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestListReferences(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "list-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	values := "image:\n  tag: v1\nname: app\n"
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte(values), 0644))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(chartDir, "templates/deployment.yaml"),
+		[]byte("image: {{ .Values.image.tag | default \"latest\" }}\nreplicas: {{ .Values.replicas | default 3 }} # shcv:ignore\n"),
+		0644,
+	))
+
+	var out bytes.Buffer
+	require.NoError(t, listReferences(chartDir, "table", &out))
+	assert.Equal(t, `PATH               FILE                       LINE  DEFAULT   DEFINED IN
+.Values.image.tag  templates/deployment.yaml  1     "latest"  values.yaml
+.Values.replicas   templates/deployment.yaml  2     3         ignored
+`, out.String())
+
+	want := []listedReference{
+		{Path: "image.tag", File: "templates/deployment.yaml", Line: 1, Default: "latest", Defined: true, ValuesFiles: []string{"values.yaml"}},
+		{Path: "replicas", File: "templates/deployment.yaml", Line: 2, Default: "3", Ignored: true},
+	}
+	out.Reset()
+	require.NoError(t, listReferences(chartDir, "json", &out))
+	var refs []listedReference
+	require.NoError(t, json.Unmarshal(out.Bytes(), &refs))
+	assert.Equal(t, want, refs)
+
+	out.Reset()
+	require.NoError(t, listReferences(chartDir, "yaml", &out))
+	refs = nil
+	require.NoError(t, yaml.Unmarshal(out.Bytes(), &refs))
+	assert.Equal(t, want, refs)
+
+	// The chart is not modified
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, values, string(content))
+
+	assert.EqualError(t, listReferences(chartDir, "html", &out), `unknown output format "html": must be table, json or yaml`)
+}
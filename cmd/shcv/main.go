@@ -1,10 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/agentstation/shcv/pkg/shcv"
 	"github.com/spf13/cobra"
@@ -20,18 +22,26 @@ by automatically synchronizing values.yaml with the parameters used in your Helm
 It scans all template files for {{ .Values.* }} expressions and ensures they are properly
 defined in your values file, including handling of default values and nested structures.
 
+Running shcv on a chart directory syncs it, like the sync command. The check and
+report commands analyze the chart without modifying it.
+
 Example:
   shcv ./my-helm-chart`,
 	Args: cobra.ExactArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		verbose, _ := cmd.Flags().GetBool("verbose")
-		return processChart(args[0], verbose, cmd.OutOrStdout())
+	// The arguments are valid once the hooks run: the errors of the run, such
+	// as an out of sync chart, are not usage errors. main prints the errors.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return nil
 	},
-	Version: shcv.Version,
+	RunE:          runSync,
+	Version:       shcv.Version,
+	SilenceErrors: true,
 }
 
 func init() {
-	RootCmd.Flags().BoolP("verbose", "v", false, "verbose output showing all found references")
+	addSyncFlags(RootCmd.Flags())
+	RootCmd.PersistentFlags().BoolVar(&noColor, "no-color", noColor, "disable colored output, which is only used on a terminal (default: true if NO_COLOR is set)")
 	RootCmd.SetVersionTemplate(`{{.Version}}
 `)
 
@@ -39,57 +49,384 @@ func init() {
 	RootCmd.Example = `  # Process chart in current directory
   shcv .
 
+  # The same, with the explicit subcommand
+  shcv sync .
+
+  # Keep the values in sync while editing the templates
+  shcv watch ./my-helm-chart
+
+  # Report the problems of a chart without modifying it
+  shcv check ./my-helm-chart
+
   # Process chart with verbose output
   shcv -v ./my-helm-chart
 
+  # Skip the run when nothing changed since the last one
+  shcv --cache-file .shcv-cache.json ./my-helm-chart
+
+  # Skip helper templates and chart tests
+  shcv --exclude "_*.tpl" --exclude tests/ ./my-helm-chart
+
+  # Check only the templates being edited
+  shcv --templates templates/deployment.yaml,templates/svc.yaml ./my-helm-chart
+
+  # Preview the changes as a unified diff without writing them
+  shcv --dry-run ./my-helm-chart
+
+  # Exit with status 2 in CI when the chart is out of sync
+  shcv --check ./my-helm-chart
+
+  # Sync the production values as an override of values.yaml
+  shcv -f values-prod.yaml --layered ./my-helm-chart
+
+  # Prompt for the values that have no default
+  shcv --interactive ./my-helm-chart
+
+  # Mark values without a default for review
+  shcv --placeholder "TODO: <path>" ./my-helm-chart
+
+  # Fail on malformed template actions, e.g. in CI
+  shcv --strict ./my-helm-chart
+
+  # Keep the service port equal to the gateway port, as a YAML alias
+  shcv --link service.port=gateway.port --link-mode anchor ./my-helm-chart
+
+  # Fail when templates disagree on the default of a value
+  shcv --fail-on-conflict ./my-helm-chart
+
+  # Print the result of the run as one JSON document, e.g. for CI annotations
+  shcv --output json ./my-helm-chart
+
+  # Write a JSON report for CI, also when the run fails
+  shcv --report-file shcv-report.json ./my-helm-chart
+
+  # Sync the chart and scaffold a kustomize post-renderer
+  shcv --kustomize-scaffold ./post-render ./my-helm-chart
+
+  # Capture a redacted reproduction bundle for a bug report
+  shcv --capture-repro repro.tar.gz ./my-helm-chart
+
   # Show version
   shcv --version`
 }
 
-func processChart(chartDir string, verbose bool, out io.Writer) error {
-	chart, err := shcv.NewChart(chartDir, shcv.WithVerbose(verbose))
+// valueLinks converts the --link flags, given as path=source, to value links
+// written in the --link-mode.
+func valueLinks(flags []string, mode string) ([]shcv.ValueLink, error) {
+	if mode != "copy" && mode != "anchor" {
+		return nil, fmt.Errorf("unknown link mode %q: must be copy or anchor", mode)
+	}
+	links := make([]shcv.ValueLink, 0, len(flags))
+	for _, flag := range flags {
+		path, source, ok := strings.Cut(flag, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid link %q: must be path=source", flag)
+		}
+		links = append(links, shcv.ValueLink{
+			Path:   strings.TrimSpace(path),
+			Source: strings.TrimSpace(source),
+			Anchor: mode == "anchor",
+		})
+	}
+	return links, nil
+}
+
+// placeholderValue converts the --placeholder flag to a placeholder value:
+// null and ~ write YAML nulls, anything else is written as a string.
+func placeholderValue(flag string) any {
+	if flag == "null" || flag == "~" {
+		return nil
+	}
+	return flag
+}
+
+func processChart(chartDir string, verbose bool, out io.Writer, opts ...shcv.Option) error {
+	return syncChart(chartDir, verbose, syncMode{}, out, nil, opts...)
+}
+
+// syncMode selects what a sync does besides adding the missing values.
+type syncMode struct {
+	// prune removes the values no template references
+	prune bool
+	// dryRun prints the changes as a diff instead of writing them
+	dryRun bool
+	// check is a dry run failing with exitOutOfSync if there are changes
+	check bool
+	// patchFile is where a dry run writes its changes as a patch, if set
+	patchFile string
+}
+
+// syncChart processes the chart like processChart in the given mode, and
+// records the results in report if it is not nil.
+func syncChart(chartDir string, verbose bool, mode syncMode, out io.Writer, report *runReport, opts ...shcv.Option) error {
+	mode.dryRun = mode.dryRun || mode.check
+	if mode.dryRun {
+		opts = append(opts, shcv.WithDryRun(true))
+	}
+	if mode.prune {
+		// A run recorded without pruning says nothing about the unused values
+		opts = append(opts, shcv.WithCacheFile(""))
+	}
+	chart, err := scanChart(chartDir, verbose, out, report, opts...)
+	if err != nil || chart == nil {
+		return err
+	}
+	return writeChart(chart, mode, out, report)
+}
+
+// scanChart loads the chart, parses its templates and runs the checks without
+// modifying the chart. The warnings and check findings are printed to out and
+// recorded in report if it is not nil. The chart is nil if the run is skipped
+// because nothing changed since the last one.
+func scanChart(chartDir string, verbose bool, out io.Writer, report *runReport, opts ...shcv.Option) (*shcv.Chart, error) {
+	chart, err := shcv.NewChart(chartDir, opts...)
 	if err != nil {
-		return fmt.Errorf("error creating chart: %w", err)
+		return nil, fmt.Errorf("error creating chart: %w", err)
 	}
 
 	if err := chart.LoadValueFiles(); err != nil {
-		return fmt.Errorf("error loading values: %w", err)
+		return nil, fmt.Errorf("error loading values: %w", err)
+	}
+	for _, file := range chart.ValuesFiles {
+		if file.RootKind != "" {
+			report.warn(out, "%s has a %s at the root instead of a map of values; it is wrapped under the --wrap-root key", file.Path, file.RootKind)
+		}
+		if file.Documents > 1 {
+			report.warn(out, "%s holds %d YAML documents; only the first, which Helm reads, is synced and the others are kept unchanged", file.Path, file.Documents)
+		}
 	}
 
 	if err := chart.FindTemplates(); err != nil {
-		return fmt.Errorf("error finding templates: %w", err)
+		return nil, fmt.Errorf("error finding templates: %w", err)
+	}
+	if report != nil {
+		report.Templates = len(chart.Templates)
 	}
 
-	if err := chart.ParseTemplates(); err != nil {
-		return fmt.Errorf("error parsing templates: %w", err)
+	unchanged, err := chart.Unchanged()
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache: %w", err)
+	}
+	if unchanged {
+		if report != nil {
+			report.Unchanged = true
+		}
+		fmt.Fprintln(out, "no changes")
+		return nil, nil
+	}
+
+	err = chart.ParseTemplates()
+	if report != nil {
+		report.References = len(chart.References)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing templates: %w", err)
+	}
+	if report != nil {
+		report.Values = listReferencesOf(chart)
+	}
+	for _, d := range chart.Diagnostics {
+		report.warn(out, "%s", d)
+	}
+	for _, conflict := range chart.DefaultConflicts() {
+		report.conflict(out, "%s", conflict)
 	}
 
 	if verbose {
-		fmt.Fprintf(out, "Found %d template files\n", len(chart.Templates))
-		fmt.Fprintf(out, "Found %d value references\n", len(chart.References))
-		for _, ref := range chart.References {
-			fmt.Fprintf(out, "- %s (from %s:%d)\n", ref.Path, filepath.Base(ref.SourceFile), ref.LineNumber)
-			if ref.DefaultValue != "" {
-				fmt.Fprintf(out, "  default: %s\n", ref.DefaultValue)
-			}
+		printReferences(chart, out)
+	}
+
+	findings, err := chart.RunChecks()
+	if err != nil {
+		return nil, fmt.Errorf("error checking chart: %w", err)
+	}
+	for _, finding := range findings {
+		report.finding(out, finding)
+	}
+
+	return chart, nil
+}
+
+// printReferences prints the number of templates and references of a scanned
+// chart, and its references as a table aligned in columns.
+func printReferences(chart *shcv.Chart, out io.Writer) {
+	fmt.Fprintf(out, "Found %d template files\n", len(chart.Templates))
+	fmt.Fprintf(out, "Found %d value references\n", len(chart.References))
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  PATH\tTEMPLATE\tDEFAULT\tTYPE")
+	for _, ref := range chart.References {
+		def, typ := ref.DefaultValue, string(ref.Type)
+		if def == "" {
+			def = "-"
+		}
+		if ref.Type == shcv.TypeUnknown {
+			typ = "-"
+		}
+		fmt.Fprintf(w, "  %s\t%s:%d\t%s\t%s\n", ref.Path, relPath(chart.Dir, ref.SourceFile), ref.LineNumber, def, typ)
+	}
+	w.Flush()
+	fmt.Fprintln(out)
+}
+
+// printAdded prints the values a sync added to the values files, the added
+// keys in green on a terminal.
+func printAdded(out io.Writer, chart *shcv.Chart) {
+	for _, file := range chart.ValuesFiles {
+		for _, path := range file.Added {
+			fmt.Fprintf(out, "%s (%s)\n", colorize(out, colorGreen, "added .Values."+path), relPath(chart.Dir, file.Path))
 		}
-		fmt.Fprintln(out)
 	}
+}
 
+// writeChart adds the missing values to the values files of a scanned chart,
+// removes the unused ones if the mode prunes, writes them and records the run.
+// In dry-run mode, the changes are printed as a unified diff instead, and in
+// check mode the run fails with exitOutOfSync if there are any.
+func writeChart(chart *shcv.Chart, mode syncMode, out io.Writer, report *runReport) error {
 	chart.ProcessReferences()
+	for _, file := range chart.ValuesFiles {
+		for _, conflict := range file.Conflicts {
+			report.conflict(out, "%s; the value is left unchanged", conflict)
+		}
+		if report != nil {
+			for _, path := range file.Added {
+				report.Added = append(report.Added, reportAddition{Path: path, File: file.Path})
+			}
+		}
+	}
+	if mode.prune {
+		pruned, err := chart.PruneUnused()
+		if err != nil {
+			return fmt.Errorf("error pruning values: %w", err)
+		}
+		action := "removed"
+		if mode.dryRun {
+			action = "would remove"
+		}
+		printPruned(out, chart.Dir, pruned, action)
+		if report != nil {
+			report.Pruned = pruned
+		}
+	}
+	if mode.dryRun {
+		changes, err := printChanges(chart, out)
+		if err == nil && mode.patchFile != "" {
+			err = writePatch(chart, mode.patchFile)
+		}
+		if err == nil && mode.check && changes > 0 {
+			err = &exitError{code: exitOutOfSync, err: fmt.Errorf("chart is out of sync: %d files would change", changes)}
+		}
+		return err
+	}
 	if err := chart.UpdateValueFiles(); err != nil {
-		return fmt.Errorf("error updating values: %w", err)
+		// The values files already written are rolled back; with backups, the
+		// templates the run modified are restored as well
+		rolledBack := make(map[string]bool)
+		var rollback *shcv.RollbackError
+		if errors.As(err, &rollback) {
+			for _, path := range rollback.Restored {
+				rolledBack[path] = true
+				fmt.Fprintf(out, "restored %s\n", path)
+			}
+		}
+		restored, restoreErr := chart.RestoreBackups()
+		for _, path := range restored {
+			if !rolledBack[path] {
+				fmt.Fprintf(out, "restored %s from its backup\n", path)
+			}
+		}
+		return errors.Join(fmt.Errorf("error updating values: %w", err), restoreErr)
+	}
+	if report != nil {
+		for _, file := range chart.ValuesFiles {
+			if file.Changed {
+				report.Updated = append(report.Updated, file.Path)
+			}
+		}
+	}
+
+	printAdded(out, chart)
+
+	if err := chart.RecordRun(); err != nil {
+		return fmt.Errorf("error writing cache: %w", err)
 	}
 
 	return nil
 }
 
+// printChanges prints the changes a dry run would make to the chart as a
+// unified diff, with paths relative to the chart directory, and returns the
+// number of files changed.
+func printChanges(chart *shcv.Chart, out io.Writer) (int, error) {
+	changes, err := chart.Changes()
+	if err != nil {
+		return 0, fmt.Errorf("error computing changes: %w", err)
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "no changes")
+		return 0, nil
+	}
+	for _, change := range changes {
+		fmt.Fprint(out, change.Diff(chart.Dir))
+	}
+	return len(changes), nil
+}
+
+// writePatch writes the changes a dry run would make to the chart to path as a
+// patch git apply applies in the current directory. Without changes, the patch
+// is empty.
+func writePatch(chart *shcv.Chart, path string) error {
+	changes, err := chart.Changes()
+	if err != nil {
+		return fmt.Errorf("error computing changes: %w", err)
+	}
+	var patch strings.Builder
+	for _, change := range changes {
+		patch.WriteString(change.Patch("."))
+	}
+	if err := os.WriteFile(path, []byte(patch.String()), 0644); err != nil {
+		return fmt.Errorf("error writing patch: %w", err)
+	}
+	return nil
+}
+
+// exitOutOfSync is the exit status of the runs finding a chart out of sync or
+// with lint findings, so that CI can tell them from the runs failing with an
+// error, which exit with 1
+const exitOutOfSync = 2
+
+// exitError is an error exiting with a status other than 1.
+type exitError struct {
+	code int
+	err  error
+}
+
+// Error returns the message of the error.
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error.
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// exitCode returns the exit status of a run failing with err.
+func exitCode(err error) int {
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return 1
+}
+
 // osExit is used to mock os.Exit in tests
 var osExit = os.Exit
 
 func main() {
+	registerCompletions(RootCmd)
 	if err := RootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		osExit(1)
+		fmt.Fprintln(os.Stderr, colorize(os.Stderr, colorRed, err.Error()))
+		osExit(exitCode(err))
 	}
 }
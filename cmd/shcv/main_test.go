@@ -70,8 +70,10 @@ func TestRootCommand(t *testing.T) {
 			if tt.wantErr {
 				assert.Error(t, err)
 				if tt.errContains != "" {
-					assert.Contains(t, output, tt.errContains)
+					assert.ErrorContains(t, err, tt.errContains)
 				}
+				// main prints the error, not cobra
+				assert.NotContains(t, output, "Error:")
 			} else {
 				assert.NoError(t, err)
 			}
@@ -166,7 +168,7 @@ func TestProcessChart(t *testing.T) {
 				))
 				require.NoError(t, os.WriteFile(
 					filepath.Join(chartDir, "templates/deployment.yaml"),
-					[]byte("{{ .Values.newValue | default \"defaultValue\" }}\n"),
+					[]byte("{{ .Values.newValue | default \"defaultValue\" }}\n{{ .Values.replicas | int }}\n"),
 					0644,
 				))
 				return chartDir, func() {}
@@ -177,7 +179,27 @@ func TestProcessChart(t *testing.T) {
 				assert.Contains(t, output.String(), "template files")
 				assert.Contains(t, output.String(), "value references")
 				assert.Contains(t, output.String(), "deployment.yaml")
-				assert.Contains(t, output.String(), "default: defaultValue")
+				assert.Regexp(t, `newValue +templates/deployment.yaml:1 +defaultValue +-\n`, output.String())
+				assert.Regexp(t, `replicas +templates/deployment.yaml:2 +- +int\n`, output.String())
+				assert.Contains(t, output.String(), "added .Values.newValue (values.yaml)")
+			},
+		},
+		{
+			name: "ingress host without tls entry",
+			setup: func() (string, func()) {
+				dir := t.TempDir()
+				chartDir := filepath.Join(dir, "ingress-chart")
+				require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+				require.NoError(t, os.WriteFile(
+					filepath.Join(chartDir, "templates/ingress.yaml"),
+					[]byte("kind: Ingress\nspec:\n  tls:\n    - hosts:\n        - {{ .Values.tlsHost }}\n  rules:\n    - host: {{ .Values.host }}\n"),
+					0644,
+				))
+				return chartDir, func() {}
+			},
+			validate: func(t *testing.T, chartDir string, output *bytes.Buffer) {
+				assert.Contains(t, output.String(), "warning: ")
+				assert.Contains(t, output.String(), "ingress.yaml:7: ingress host .Values.host has no matching TLS host")
 			},
 		},
 		{
@@ -289,6 +311,277 @@ func TestProcessChart(t *testing.T) {
 	}
 }
 
+func TestProcessChartCache(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "cached-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("existing: value\n"), 0644))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(chartDir, "templates/deployment.yaml"),
+		[]byte("{{ .Values.newValue }}\n"),
+		0644,
+	))
+
+	var first bytes.Buffer
+	require.NoError(t, processChart(chartDir, false, &first, shcv.WithCacheFile(".shcv-cache.json")))
+	assert.NotContains(t, first.String(), "no changes")
+	assert.FileExists(t, filepath.Join(chartDir, ".shcv-cache.json"))
+
+	var second bytes.Buffer
+	require.NoError(t, processChart(chartDir, false, &second, shcv.WithCacheFile(".shcv-cache.json")))
+	assert.Equal(t, "no changes\n", second.String())
+}
+
+func TestProcessChartExclude(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "exclude-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates", "tests"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("name: app\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/deployment.yaml"), []byte("{{ .Values.image }}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/tests/test.yaml"), []byte("{{ .Values.testImage }}\n"), 0644))
+
+	var out bytes.Buffer
+	require.NoError(t, processChart(chartDir, false, &out, shcv.WithExcludePatterns([]string{"tests/"})))
+
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "image:")
+	assert.NotContains(t, string(content), "testImage")
+}
+
+func TestProcessChartTemplates(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "templates-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("name: app\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/deployment.yaml"), []byte("{{ .Values.image }} {{ .Values.name }}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/service.yaml"), []byte("{{ .Values.port }}\n"), 0644))
+
+	require.NoError(t, processChart(chartDir, false, &bytes.Buffer{}, shcv.WithTemplates([]string{"templates/deployment.yaml"})))
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\nimage: \"\"\n", string(content))
+
+	err = processChart(chartDir, false, &bytes.Buffer{}, shcv.WithTemplates([]string{"templates/svc.yaml"}))
+	assert.ErrorContains(t, err, "template templates/svc.yaml not found")
+}
+
+func TestProcessChartDiagnostics(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "strict-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/deployment.yaml"), []byte("a: {{ .Values.a\nb: {{ .Values.b }}\n"), 0644))
+
+	var out bytes.Buffer
+	err := processChart(chartDir, false, &out, shcv.WithStrict(true))
+	assert.ErrorContains(t, err, "deployment.yaml:1:4: unclosed action")
+	assert.NoFileExists(t, filepath.Join(chartDir, "values.yaml"))
+
+	out.Reset()
+	require.NoError(t, processChart(chartDir, false, &out))
+	assert.Contains(t, out.String(), "warning: "+filepath.Join(chartDir, "templates/deployment.yaml")+":1:4: unclosed action\n")
+	assert.FileExists(t, filepath.Join(chartDir, "values.yaml"))
+}
+
+func TestProcessChartNullValues(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "null-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/deployment.yaml"), []byte("image: {{ .Values.image.tag }}\n{{- range .Values.hosts }}{{ end }}\n"), 0644))
+
+	require.NoError(t, processChart(chartDir, false, &bytes.Buffer{}, shcv.WithNullValues(true)))
+
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "hosts: null\nimage:\n  tag: null\n", string(content))
+
+	err = processChart(chartDir, false, &bytes.Buffer{}, shcv.WithNullValues(true), shcv.WithPlaceholder("x"))
+	assert.ErrorContains(t, err, "mutually exclusive")
+}
+
+func TestPlaceholderValue(t *testing.T) {
+	assert.Nil(t, placeholderValue("null"))
+	assert.Nil(t, placeholderValue("~"))
+	assert.Equal(t, "CHANGEME", placeholderValue("CHANGEME"))
+	assert.Equal(t, "", placeholderValue(""))
+}
+
+func TestProcessChartPlaceholder(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "placeholder-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/deployment.yaml"), []byte("image: {{ .Values.image.tag }}\n"), 0644))
+
+	var out bytes.Buffer
+	require.NoError(t, processChart(chartDir, false, &out, shcv.WithPlaceholder(placeholderValue("TODO: <path>"))))
+
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "image:\n  tag: 'TODO: image.tag'\n", string(content))
+}
+
+func TestProcessChartTypedDefaults(t *testing.T) {
+	setup := func(t *testing.T) string {
+		chartDir := filepath.Join(t.TempDir(), "defaults-chart")
+		require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+		require.NoError(t, os.WriteFile(
+			filepath.Join(chartDir, "templates/service.yaml"),
+			[]byte("port: {{ .Values.port | default 8080 }}\nname: {{ .Values.name | default \"8080\" }}\n"),
+			0644,
+		))
+		return chartDir
+	}
+
+	t.Run("typed", func(t *testing.T) {
+		chartDir := setup(t)
+		require.NoError(t, processChart(chartDir, false, &bytes.Buffer{}))
+		content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "name: \"8080\"\nport: 8080\n", string(content))
+	})
+
+	t.Run("string defaults", func(t *testing.T) {
+		chartDir := setup(t)
+		require.NoError(t, processChart(chartDir, false, &bytes.Buffer{}, shcv.WithStringDefaults(true)))
+		content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "name: \"8080\"\nport: \"8080\"\n", string(content))
+	})
+}
+
+func TestProcessChartConflicts(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "conflict-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/a.yaml"), []byte("tag: {{ .Values.tag | default \"1.0\" }}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/b.yaml"), []byte("tag: {{ .Values.tag | default \"2.0\" }}\n"), 0644))
+
+	var out bytes.Buffer
+	err := processChart(chartDir, false, &out, shcv.WithFailOnConflict(true))
+	assert.ErrorContains(t, err, "conflicting defaults for .Values.tag")
+	assert.NoFileExists(t, filepath.Join(chartDir, "values.yaml"))
+
+	out.Reset()
+	require.NoError(t, processChart(chartDir, false, &out))
+	assert.Contains(t, out.String(), "warning: conflicting defaults for .Values.tag: \"1.0\" ("+filepath.Join(chartDir, "templates/a.yaml")+":1)")
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "tag: \"1.0\"\n", string(content))
+}
+
+func TestProcessChartWrapRoot(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "list-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("- a\n- b\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/configmap.yaml"), []byte("{{- range .Values.items }}{{ . }}{{ end }}\nname: {{ .Values.name }}\n"), 0644))
+
+	err := processChart(chartDir, false, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "has a sequence at the root instead of a map of values")
+
+	var out bytes.Buffer
+	require.NoError(t, processChart(chartDir, false, &out, shcv.WithValuesRootKey("items")))
+	assert.Contains(t, out.String(), "warning: "+filepath.Join(chartDir, "values.yaml")+" has a sequence at the root instead of a map of values; it is wrapped under the --wrap-root key\n")
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "items:\n- a\n- b\nname: \"\"\n", string(content))
+}
+
+func TestProcessChartMultipleDocuments(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "multi-doc-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("a: 1\n---\nb: 2\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/configmap.yaml"), []byte("name: {{ .Values.name }}\n"), 0644))
+
+	var out bytes.Buffer
+	require.NoError(t, processChart(chartDir, false, &out))
+	assert.Contains(t, out.String(), "warning: "+filepath.Join(chartDir, "values.yaml")+" holds 2 YAML documents; only the first, which Helm reads, is synced and the others are kept unchanged\n")
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "a: 1\nname: \"\"\n---\nb: 2\n", string(content))
+}
+
+func TestProcessChartScalarConflicts(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "scalar-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("service: web\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/service.yaml"), []byte("port: {{ .Values.service.port }}\nname: {{ .Values.name }}\n"), 0644))
+
+	var out bytes.Buffer
+	require.NoError(t, processChart(chartDir, false, &out))
+	assert.Contains(t, out.String(), "warning: "+filepath.Join(chartDir, "values.yaml")+": cannot add .Values.service.port: service is a string (\"web\"), not a map; the value is left unchanged\n")
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "service: web\nname: \"\"\n", string(content))
+}
+
+func TestProcessChartDefaultFromValue(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "default-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/deployment.yaml"), []byte("tag: {{ default .Values.global.tag .Values.image.tag }}\n"), 0644))
+
+	require.NoError(t, processChart(chartDir, false, &bytes.Buffer{}))
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "global:\n  tag: \"\"\nimage:\n  tag: \"\"\n", string(content))
+}
+
+func TestProcessChartKeepsComments(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "comment-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	values := "# Default values for the chart.\n\nreplicas: 2 # per zone\n\n# Container image\nimage:\n  repository: nginx\n"
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte(values), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/deployment.yaml"), []byte("image: {{ .Values.image.repository }}:{{ .Values.image.tag | default \"latest\" }}\nport: {{ .Values.port | default 80 }}\n"), 0644))
+
+	require.NoError(t, processChart(chartDir, false, &bytes.Buffer{}))
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, values+"  tag: latest\nport: 80\n", string(content))
+}
+
+func TestProcessChartLinks(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "link-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("gateway:\n  port: 8080\nservice:\n  name: web\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/service.yaml"), []byte("port: {{ .Values.service.port }}\n"), 0644))
+
+	links, err := valueLinks([]string{"service.port=gateway.port"}, "anchor")
+	require.NoError(t, err)
+	require.NoError(t, processChart(chartDir, false, &bytes.Buffer{}, shcv.WithValueLinks(links...)))
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "gateway:\n  port: &gateway-port 8080\nservice:\n  name: web\n  port: *gateway-port\n", string(content))
+}
+
+func TestValueLinks(t *testing.T) {
+	links, err := valueLinks([]string{"service.port=gateway.port", " a = b "}, "copy")
+	require.NoError(t, err)
+	assert.Equal(t, []shcv.ValueLink{
+		{Path: "service.port", Source: "gateway.port"},
+		{Path: "a", Source: "b"},
+	}, links)
+
+	_, err = valueLinks([]string{"service.port"}, "copy")
+	assert.EqualError(t, err, `invalid link "service.port": must be path=source`)
+	_, err = valueLinks(nil, "symlink")
+	assert.EqualError(t, err, `unknown link mode "symlink": must be copy or anchor`)
+}
+
+func TestProcessChartBackup(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "backup-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("name: app\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/service.yaml"), []byte("{{ .Values.port }}\n"), 0644))
+
+	require.NoError(t, processChart(chartDir, false, &bytes.Buffer{}, shcv.WithBackup(true)))
+	backup, err := os.ReadFile(filepath.Join(chartDir, "values.yaml.bak"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\n", string(backup))
+}
+
+func TestProcessChartNamingPolicy(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "naming-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/service.yaml"), []byte("{{ .Values.service_port }}\n"), 0644))
+
+	var out bytes.Buffer
+	policy := shcv.NamingPolicy{Case: shcv.CaseCamel}
+	require.NoError(t, processChart(chartDir, false, &out, shcv.WithNamingPolicy(policy)))
+	assert.Contains(t, out.String(), `key "service_port" of .Values.service_port is not camelCase; rename it to .Values.servicePort`)
+}
+
 func TestMain(t *testing.T) {
 	// Save original args and restore them after the test
 	oldArgs := os.Args
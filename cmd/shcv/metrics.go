@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// metricsCmd reports the complexity of the chart's templates
+var metricsCmd = &cobra.Command{
+	Use:   "metrics [chart-directory]",
+	Short: "Report template complexity metrics",
+	Long: `metrics reports the complexity of every template of the chart, to help find templates
+that should be split or simplified: the number of template actions, the distinct values
+used, the deepest nesting of control structures (if, with, range, define, block) and the
+number of distinct named templates included. The chart is not modified.`,
+	Example: `  # Print the metrics as a table
+  shcv metrics ./my-helm-chart
+
+  # Export the metrics as JSON or as an HTML report
+  shcv metrics --output json ./my-helm-chart
+  shcv metrics --output html ./my-helm-chart > metrics.html`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		return templateMetrics(args[0], output, cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	metricsCmd.Flags().StringP("output", "o", "text", "output format: text, json or html")
+	RootCmd.AddCommand(metricsCmd)
+}
+
+func templateMetrics(chartDir, output string, out io.Writer) error {
+	chart, err := shcv.NewChart(chartDir)
+	if err != nil {
+		return fmt.Errorf("error creating chart: %w", err)
+	}
+	if err := chart.FindTemplates(); err != nil {
+		return fmt.Errorf("error finding templates: %w", err)
+	}
+	metrics, err := chart.Metrics()
+	if err != nil {
+		return fmt.Errorf("error computing metrics: %w", err)
+	}
+	for i := range metrics {
+		if rel, err := filepath.Rel(chartDir, metrics[i].File); err == nil {
+			metrics[i].File = rel
+		}
+	}
+
+	switch output {
+	case "text":
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "TEMPLATE\tACTIONS\tVALUES\tMAX DEPTH\tINCLUDES")
+		for _, m := range metrics {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", m.File, m.Actions, m.Values, m.MaxDepth, m.Includes)
+		}
+		return tw.Flush()
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(metrics)
+	case "html":
+		return metricsHTML.Execute(out, metrics)
+	default:
+		return fmt.Errorf("unknown output format %q: must be text, json or html", output)
+	}
+}
+
+// metricsHTML is the HTML report of the template metrics
+var metricsHTML = template.Must(template.New("metrics").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>shcv template metrics</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>shcv template metrics</h1>
+<table>
+<tr><th>Template</th><th>Actions</th><th>Values</th><th>Max depth</th><th>Includes</th></tr>
+{{- range . }}
+<tr><td>{{ .File }}</td><td>{{ .Actions }}</td><td>{{ .Values }}</td><td>{{ .MaxDepth }}</td><td>{{ .Includes }}</td></tr>
+{{- end }}
+</table>
+</body>
+</html>
+`))
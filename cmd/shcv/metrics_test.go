@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateMetrics(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "metrics-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(chartDir, "templates/deployment.yaml"),
+		[]byte("{{- if .Values.enabled }}\nname: {{ include \"app.name\" . }}\n{{- end }}\n"),
+		0644,
+	))
+
+	t.Run("text", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, templateMetrics(chartDir, "text", &out))
+		assert.Regexp(t, `TEMPLATE\s+ACTIONS\s+VALUES\s+MAX DEPTH\s+INCLUDES\n`, out.String())
+		assert.Regexp(t, `templates/deployment.yaml\s+3\s+1\s+1\s+1\n`, out.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, templateMetrics(chartDir, "json", &out))
+		var metrics []shcv.TemplateMetrics
+		require.NoError(t, json.Unmarshal(out.Bytes(), &metrics))
+		assert.Equal(t, []shcv.TemplateMetrics{
+			{File: "templates/deployment.yaml", Actions: 3, Values: 1, MaxDepth: 1, Includes: 1},
+		}, metrics)
+	})
+
+	t.Run("html", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, templateMetrics(chartDir, "html", &out))
+		assert.Contains(t, out.String(), "<tr><td>templates/deployment.yaml</td><td>3</td><td>1</td><td>1</td><td>1</td></tr>")
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		err := templateMetrics(chartDir, "xml", &bytes.Buffer{})
+		assert.EqualError(t, err, `unknown output format "xml": must be text, json or html`)
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// migrateValuesCmd applies the values migration of a chart to values files
+var migrateValuesCmd = &cobra.Command{
+	Use:   "migrate-values [migration-file] values-file...",
+	Short: "Apply the values renamed by a chart to your own values files",
+	Long: `migrate-values applies a values migration, written by rename-values or fix-naming with
+--migration-file, to values files outside of the chart, such as the override files passed
+to helm install -f. Renamed values keep their place and comments. Files that define none
+of the renamed values are left unchanged.
+
+A diff of the changes is printed before they are applied.`,
+	Example: `  # Migrate a values override file after upgrading the chart
+  shcv migrate-values ./my-helm-chart/values-migration.yaml values-prod.yaml
+
+  # Preview the changes without applying them
+  shcv migrate-values --dry-run values-migration.yaml values-*.yaml`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		return migrateValues(args[0], args[1:], dryRun, cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	migrateValuesCmd.Flags().Bool("dry-run", false, "print the changes without applying them")
+	RootCmd.AddCommand(migrateValuesCmd)
+}
+
+func migrateValues(migrationFile string, valuesFiles []string, dryRun bool, out io.Writer) error {
+	migration, err := shcv.ReadValuesMigration(migrationFile)
+	if err != nil {
+		return fmt.Errorf("error loading migration: %w", err)
+	}
+	changes, err := shcv.MigrateValues(migration, valuesFiles...)
+	if err != nil {
+		return fmt.Errorf("error migrating values: %w", err)
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "no values to migrate")
+		return nil
+	}
+
+	for _, change := range changes {
+		fmt.Fprint(out, change.Diff(filepath.Dir(change.Path)))
+	}
+	if dryRun {
+		return nil
+	}
+
+	if err := shcv.WriteChanges(changes); err != nil {
+		return fmt.Errorf("error writing changes: %w", err)
+	}
+	return nil
+}
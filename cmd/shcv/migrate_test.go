@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateValues(t *testing.T) {
+	// Renaming values in a chart records the migration
+	chartDir := filepath.Join(t.TempDir(), "migrate-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("image_tag: v1\nport: 80\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/deployment.yaml"), []byte("image: {{ .Values.image_tag }}\nport: {{ .Values.port }}\n"), 0644))
+	migrationFile := filepath.Join(chartDir, "values-migration.yaml")
+
+	var out bytes.Buffer
+	require.NoError(t, renameValues(chartDir, map[string]string{"image_tag": "image.tag"}, migrationFile, false, &out))
+	assert.Contains(t, out.String(), "+++ b/values-migration.yaml")
+	require.NoError(t, renameValues(chartDir, map[string]string{"port": "service.port"}, migrationFile, false, &out))
+	content, err := os.ReadFile(migrationFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "renames:\n- from: image_tag\n  to: image.tag\n- from: port\n  to: service.port\n")
+
+	// Users apply it to their own values files
+	valuesFile := filepath.Join(t.TempDir(), "values-prod.yaml")
+	require.NoError(t, os.WriteFile(valuesFile, []byte("image_tag: v2\nport: 443\n"), 0644))
+
+	out.Reset()
+	require.NoError(t, migrateValues(migrationFile, []string{valuesFile}, true, &out))
+	assert.Contains(t, out.String(), "+  tag: v2")
+	content, err = os.ReadFile(valuesFile)
+	require.NoError(t, err)
+	assert.Equal(t, "image_tag: v2\nport: 443\n", string(content))
+
+	require.NoError(t, migrateValues(migrationFile, []string{valuesFile}, false, &out))
+	content, err = os.ReadFile(valuesFile)
+	require.NoError(t, err)
+	assert.Equal(t, "image:\n  tag: v2\nservice:\n  port: 443\n", string(content))
+
+	out.Reset()
+	require.NoError(t, migrateValues(migrationFile, []string{valuesFile}, false, &out))
+	assert.Equal(t, "no values to migrate\n", out.String())
+
+	err = migrateValues(filepath.Join(chartDir, "missing.yaml"), []string{valuesFile}, false, &out)
+	assert.ErrorContains(t, err, "error loading migration")
+}
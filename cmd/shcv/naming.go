@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// fixNamingCmd renames the value keys breaking the naming policy
+var fixNamingCmd = &cobra.Command{
+	Use:   "fix-naming [chart-directory]",
+	Short: "Rename values to follow the naming policy",
+	Long: `fix-naming checks the keys of the value paths the templates reference and the values
+files define against the naming policy, and renames the keys that can be corrected
+automatically, as rename-values does. Keys that cannot be corrected, such as keys longer
+than --naming-max-length, are reported and left unchanged.
+
+A diff of the changes is printed before they are applied. With --migration-file, the
+renames are also recorded for migrate-values, as with rename-values.`,
+	Example: `  # Rename all keys to camelCase
+  shcv fix-naming --naming-case camelCase ./my-helm-chart
+
+  # Preview the changes without applying them
+  shcv fix-naming --naming-case kebab-case --dry-run ./my-helm-chart`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		policy := namingPolicy(cmd.Flags())
+		if policy == nil {
+			return fmt.Errorf("no naming policy: set --naming-case, --naming-max-length or --naming-lowercase-top-level")
+		}
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		migrationFile, _ := cmd.Flags().GetString("migration-file")
+		return fixNaming(args[0], *policy, migrationFile, dryRun, cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	addNamingFlags(fixNamingCmd.Flags())
+	fixNamingCmd.Flags().Bool("dry-run", false, "print the changes without applying them")
+	addMigrationFlag(fixNamingCmd.Flags())
+	RootCmd.AddCommand(fixNamingCmd)
+}
+
+func fixNaming(chartDir string, policy shcv.NamingPolicy, migrationFile string, dryRun bool, out io.Writer) error {
+	chart, err := loadChart(chartDir, shcv.WithNamingPolicy(policy))
+	if err != nil {
+		return err
+	}
+	findings, err := chart.CheckNaming()
+	if err != nil {
+		return fmt.Errorf("error checking naming: %w", err)
+	}
+
+	renames := make(map[string]string)
+	for _, finding := range findings {
+		if finding.Suggestion == "" {
+			fmt.Fprintf(out, "warning: %s\n", finding)
+			continue
+		}
+		renames[finding.Path] = finding.Suggestion
+	}
+	if len(renames) == 0 {
+		fmt.Fprintln(out, "no values to rename")
+		return nil
+	}
+	return applyRenames(chart, chartDir, renames, migrationFile, dryRun, out)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixNaming(t *testing.T) {
+	setup := func(t *testing.T) string {
+		chartDir := filepath.Join(t.TempDir(), "naming-chart")
+		require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("image:\n  pull_policy: Always\n"), 0644))
+		require.NoError(t, os.WriteFile(
+			filepath.Join(chartDir, "templates/deployment.yaml"),
+			[]byte("policy: {{ .Values.image.pull_policy }}\nclass: {{ .Values.storageClassName }}\n"),
+			0644,
+		))
+		return chartDir
+	}
+
+	t.Run("apply", func(t *testing.T) {
+		chartDir := setup(t)
+		var out bytes.Buffer
+		policy := shcv.NamingPolicy{Case: shcv.CaseCamel, MaxSegmentLength: 12}
+		require.NoError(t, fixNaming(chartDir, policy, "", false, &out))
+		assert.Contains(t, out.String(), `warning: `+filepath.Join(chartDir, "templates/deployment.yaml")+`:2: key "storageClassName" of .Values.storageClassName is longer than 12 characters`)
+		assert.Contains(t, out.String(), "+policy: {{ .Values.image.pullPolicy }}")
+
+		content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "image:\n  pullPolicy: Always\n", string(content))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		chartDir := setup(t)
+		var out bytes.Buffer
+		require.NoError(t, fixNaming(chartDir, shcv.NamingPolicy{Case: shcv.CaseCamel}, "", true, &out))
+		assert.Contains(t, out.String(), "+  pullPolicy: Always")
+
+		content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "image:\n  pull_policy: Always\n", string(content))
+	})
+
+	t.Run("no policy", func(t *testing.T) {
+		err := fixNamingCmd.RunE(fixNamingCmd, []string{setup(t)})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no naming policy")
+	})
+}
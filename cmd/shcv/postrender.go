@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+)
+
+// writeKustomizeScaffold writes the kustomize post-renderer scaffold of the chart to dir.
+func writeKustomizeScaffold(chartDir, dir string, out io.Writer, opts ...shcv.Option) error {
+	chart, err := shcv.NewChart(chartDir, opts...)
+	if err != nil {
+		return fmt.Errorf("error creating chart: %w", err)
+	}
+	if err := chart.LoadValueFiles(); err != nil {
+		return fmt.Errorf("error loading values: %w", err)
+	}
+	if err := chart.WriteKustomizeScaffold(dir); err != nil {
+		return fmt.Errorf("error writing kustomize scaffold: %w", err)
+	}
+	fmt.Fprintf(out, "wrote kustomize post-renderer scaffold to %s\n", dir)
+	return nil
+}
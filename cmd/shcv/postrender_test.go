@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteKustomizeScaffold(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "scaffold-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("name: app\n"), 0644))
+
+	dir := filepath.Join(t.TempDir(), "post-render")
+	var out bytes.Buffer
+	require.NoError(t, writeKustomizeScaffold(chartDir, dir, &out))
+	assert.Equal(t, "wrote kustomize post-renderer scaffold to "+dir+"\n", out.String())
+	assert.FileExists(t, filepath.Join(dir, "kustomization.yaml"))
+	assert.FileExists(t, filepath.Join(dir, "post-render.sh"))
+
+	assert.ErrorContains(t, writeKustomizeScaffold(chartDir, dir, &out), "already exists")
+}
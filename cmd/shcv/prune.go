@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// pruneCmd removes the values no template references from the values files
+var pruneCmd = &cobra.Command{
+	Use:   "prune [chart-directory]",
+	Short: "Remove the values no template references",
+	Long: `prune removes the values that no template references from the values files. Values
+that may still be read are kept: values referenced by template strings in the values files,
+which are usually rendered with tpl, all values when a template uses .Values as a whole,
+global, which Helm shares with subcharts, the values of the subcharts, unpacked or
+packaged in the charts directory or listed as dependencies in Chart.yaml, and the paths
+given with --protect.
+
+prune refuses to run when only some templates are scanned or when templates have malformed
+actions, as the references it cannot see would be pruned. "shcv sync --prune" adds the
+missing values and prunes the unused ones in one run.`,
+	Example: `  # Show the values that would be removed
+  shcv prune --dry-run ./my-helm-chart
+
+  # Remove the unused values, keeping the values read by CI
+  shcv prune --protect "ci.*" ./my-helm-chart`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		opts := append(scanOptions(flags), writeOptions(flags)...)
+		opts = append(opts, pruneOptions(flags)...)
+		dryRun, _ := flags.GetBool("dry-run")
+		return pruneChart(args[0], dryRun, cmd.OutOrStdout(), opts...)
+	},
+}
+
+func init() {
+	addScanFlags(pruneCmd.Flags())
+	addWriteFlags(pruneCmd.Flags())
+	addPruneFlags(pruneCmd.Flags())
+	pruneCmd.Flags().Bool("dry-run", false, "print the values that would be removed without removing them")
+	RootCmd.AddCommand(pruneCmd)
+}
+
+func pruneChart(chartDir string, dryRun bool, out io.Writer, opts ...shcv.Option) error {
+	chart, err := loadChart(chartDir, opts...)
+	if err != nil {
+		return err
+	}
+	pruned, err := chart.PruneUnused()
+	if err != nil {
+		return fmt.Errorf("error pruning values: %w", err)
+	}
+	if len(pruned) == 0 {
+		fmt.Fprintln(out, "no unused values")
+		return nil
+	}
+	if dryRun {
+		printPruned(out, chartDir, pruned, "would remove")
+		return nil
+	}
+
+	if err := chart.UpdateValueFiles(); err != nil {
+		return fmt.Errorf("error updating values: %w", err)
+	}
+	printPruned(out, chartDir, pruned, "removed")
+	return nil
+}
+
+// printPruned prints the pruned values, prefixed with what happened to them.
+func printPruned(out io.Writer, chartDir string, pruned []shcv.ValuePath, action string) {
+	for _, value := range pruned {
+		file := value.File
+		if rel, err := filepath.Rel(chartDir, file); err == nil {
+			file = rel
+		}
+		fmt.Fprintf(out, "%s .Values.%s (%s:%d)\n", action, value.Path, file, value.LineNumber)
+	}
+}
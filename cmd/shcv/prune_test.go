@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePruneChart writes a chart whose values file defines unused values.
+func writePruneChart(t *testing.T) string {
+	t.Helper()
+	chartDir := filepath.Join(t.TempDir(), "prune-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("name: app\nlegacy: true\nci:\n  token: x\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/service.yaml"), []byte("name: {{ .Values.name }}\nport: {{ .Values.port }}\n"), 0644))
+	return chartDir
+}
+
+func TestPruneChart(t *testing.T) {
+	t.Run("dry run", func(t *testing.T) {
+		chartDir := writePruneChart(t)
+		var out bytes.Buffer
+		require.NoError(t, pruneChart(chartDir, true, &out, shcv.WithProtectedPaths("ci")))
+		assert.Equal(t, "would remove .Values.legacy (values.yaml:2)\n", out.String())
+
+		content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "name: app\nlegacy: true\nci:\n  token: x\n", string(content))
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		chartDir := writePruneChart(t)
+		var out bytes.Buffer
+		require.NoError(t, pruneChart(chartDir, false, &out))
+		assert.Equal(t, "removed .Values.legacy (values.yaml:2)\nremoved .Values.ci (values.yaml:3)\n", out.String())
+
+		// Only pruning: the missing port is not added
+		content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "name: app\n", string(content))
+
+		out.Reset()
+		require.NoError(t, pruneChart(chartDir, false, &out))
+		assert.Equal(t, "no unused values\n", out.String())
+	})
+
+	t.Run("some templates scanned", func(t *testing.T) {
+		err := pruneChart(writePruneChart(t), false, &bytes.Buffer{}, shcv.WithExcludePatterns([]string{"service.yaml"}))
+		assert.EqualError(t, err, "error pruning values: cannot prune unused values when only some templates are scanned")
+	})
+}
+
+func TestSyncPrune(t *testing.T) {
+	chartDir := writePruneChart(t)
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	_, err := executeCommand(t, "sync", "--prune", "--protect", "ci.*", "--report-file", reportFile, chartDir)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\nci:\n  token: x\nport: \"\"\n", string(content))
+
+	data, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	var report runReport
+	require.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, []shcv.ValuePath{{Path: "legacy", File: filepath.Join(chartDir, "values.yaml"), LineNumber: 2}}, report.Pruned)
+
+	// A run recorded in the cache without pruning does not skip the prune
+	chartDir = writePruneChart(t)
+	_, err = executeCommand(t, "sync", "--cache-file", ".shcv-cache.json", chartDir)
+	require.NoError(t, err)
+	_, err = executeCommand(t, "sync", "--cache-file", ".shcv-cache.json", "--prune", chartDir)
+	require.NoError(t, err)
+	content, err = os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "legacy")
+
+	// Without --prune, unused values are kept
+	chartDir = writePruneChart(t)
+	_, err = executeCommand(t, "sync", chartDir)
+	require.NoError(t, err)
+	content, err = os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "legacy: true\n")
+}
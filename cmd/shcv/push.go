@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// pushDefaultsCmd adds values as template defaults
+var pushDefaultsCmd = &cobra.Command{
+	Use:   "push-defaults [chart-directory] path...",
+	Short: "Add values as template defaults",
+	Long: `push-defaults takes the literal values the values file defines for the selected value
+paths and adds them as "| default" to the templates, so that the chart also renders with an
+empty values file. A path selects itself and every path nested below it. Maps and lists are
+not pushed, and references that already have a default are left alone.
+
+With --remove, the pushed values are also removed from the values file. A diff of the
+changes is printed before they are applied.`,
+	Example: `  # Add the image values as template defaults
+  shcv push-defaults ./my-helm-chart image
+
+  # Move the values into the templates and preview the changes
+  shcv push-defaults --remove --dry-run ./my-helm-chart image service.port`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		remove, _ := cmd.Flags().GetBool("remove")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		return pushDefaults(args[0], args[1:], remove, dryRun, cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	pushDefaultsCmd.Flags().Bool("remove", false, "remove the pushed values from the values file")
+	pushDefaultsCmd.Flags().Bool("dry-run", false, "print the changes without applying them")
+	RootCmd.AddCommand(pushDefaultsCmd)
+}
+
+func pushDefaults(chartDir string, paths []string, remove, dryRun bool, out io.Writer) error {
+	chart, err := shcv.NewChart(chartDir)
+	if err != nil {
+		return fmt.Errorf("error creating chart: %w", err)
+	}
+	if err := chart.LoadValueFiles(); err != nil {
+		return fmt.Errorf("error loading values: %w", err)
+	}
+	if err := chart.FindTemplates(); err != nil {
+		return fmt.Errorf("error finding templates: %w", err)
+	}
+	if err := chart.ParseTemplates(); err != nil {
+		return fmt.Errorf("error parsing templates: %w", err)
+	}
+
+	changes, err := chart.PushDefaults(paths, remove)
+	if err != nil {
+		return fmt.Errorf("error pushing defaults: %w", err)
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "no values to push")
+		return nil
+	}
+
+	for _, change := range changes {
+		fmt.Fprint(out, change.Diff(chartDir))
+	}
+	if dryRun {
+		return nil
+	}
+
+	if err := shcv.WriteChanges(changes); err != nil {
+		return fmt.Errorf("error writing changes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushDefaults(t *testing.T) {
+	setup := func(t *testing.T) string {
+		chartDir := filepath.Join(t.TempDir(), "push-chart")
+		require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("image:\n  tag: latest\nname: app\n"), 0644))
+		require.NoError(t, os.WriteFile(
+			filepath.Join(chartDir, "templates/deployment.yaml"),
+			[]byte("image: {{ .Values.image.tag }}\n"),
+			0644,
+		))
+		return chartDir
+	}
+
+	t.Run("apply and remove", func(t *testing.T) {
+		chartDir := setup(t)
+		var out bytes.Buffer
+		require.NoError(t, pushDefaults(chartDir, []string{"image"}, true, false, &out))
+
+		assert.Contains(t, out.String(), "--- a/templates/deployment.yaml")
+		assert.Contains(t, out.String(), "+image: {{ .Values.image.tag | default \"latest\" }}")
+		assert.Contains(t, out.String(), "-  tag: latest")
+
+		content, err := os.ReadFile(filepath.Join(chartDir, "templates/deployment.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "image: {{ .Values.image.tag | default \"latest\" }}\n", string(content))
+		content, err = os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "name: app\n", string(content))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		chartDir := setup(t)
+		var out bytes.Buffer
+		require.NoError(t, pushDefaults(chartDir, []string{"image"}, false, true, &out))
+		assert.Contains(t, out.String(), "+image: {{ .Values.image.tag | default \"latest\" }}")
+		assert.NotContains(t, out.String(), "values.yaml")
+
+		content, err := os.ReadFile(filepath.Join(chartDir, "templates/deployment.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "image: {{ .Values.image.tag }}\n", string(content))
+	})
+
+	t.Run("nothing to push", func(t *testing.T) {
+		chartDir := setup(t)
+		var out bytes.Buffer
+		require.NoError(t, pushDefaults(chartDir, []string{"service"}, false, false, &out))
+		assert.Equal(t, "no values to push\n", out.String())
+	})
+
+	t.Run("invalid chart", func(t *testing.T) {
+		err := pushDefaults("nonexistent", []string{"image"}, false, false, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "error creating chart")
+	})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// renameValuesCmd renames value paths in the templates and values files
+var renameValuesCmd = &cobra.Command{
+	Use:   "rename-values [chart-directory] path=new-path...",
+	Short: "Rename values in the templates and values files",
+	Long: `rename-values renames value paths in the templates and in every values file. A path
+renames the paths nested below it along with it. Values keep their place in the values
+files when only their key changes, and keys that are not valid template identifiers are
+read with index.
+
+A diff of the changes is printed before they are applied. With --migration-file, the
+renames are also recorded in a migration that the users of the chart apply to their own
+values files with migrate-values.`,
+	Example: `  # Rename a key and move a value to another parent
+  shcv rename-values ./my-helm-chart image.Tag=image.tag imagePullPolicy=image.pullPolicy
+
+  # Record the renames for the users of the chart
+  shcv rename-values --migration-file ./my-helm-chart/values-migration.yaml ./my-helm-chart image.Tag=image.tag
+
+  # Preview the changes without applying them
+  shcv rename-values --dry-run ./my-helm-chart service_port=service.port`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		renames, err := parseRenames(args[1:])
+		if err != nil {
+			return err
+		}
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		migrationFile, _ := cmd.Flags().GetString("migration-file")
+		return renameValues(args[0], renames, migrationFile, dryRun, cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	renameValuesCmd.Flags().Bool("dry-run", false, "print the changes without applying them")
+	addMigrationFlag(renameValuesCmd.Flags())
+	RootCmd.AddCommand(renameValuesCmd)
+}
+
+// addMigrationFlag adds the flag recording renames in a migration file.
+func addMigrationFlag(flags *pflag.FlagSet) {
+	flags.String("migration-file", "", "also record the renames in this migration file for migrate-values, appending to it if it exists")
+}
+
+// parseRenames parses path=new-path arguments.
+func parseRenames(args []string) (map[string]string, error) {
+	renames := make(map[string]string, len(args))
+	for _, arg := range args {
+		from, to, ok := strings.Cut(arg, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rename %q: must be path=new-path", arg)
+		}
+		renames[strings.TrimSpace(from)] = strings.TrimSpace(to)
+	}
+	return renames, nil
+}
+
+func renameValues(chartDir string, renames map[string]string, migrationFile string, dryRun bool, out io.Writer) error {
+	chart, err := loadChart(chartDir)
+	if err != nil {
+		return err
+	}
+	return applyRenames(chart, chartDir, renames, migrationFile, dryRun, out)
+}
+
+// loadChart loads the values files of a chart and parses its templates.
+func loadChart(chartDir string, opts ...shcv.Option) (*shcv.Chart, error) {
+	chart, err := shcv.NewChart(chartDir, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating chart: %w", err)
+	}
+	if err := chart.LoadValueFiles(); err != nil {
+		return nil, fmt.Errorf("error loading values: %w", err)
+	}
+	if err := chart.FindTemplates(); err != nil {
+		return nil, fmt.Errorf("error finding templates: %w", err)
+	}
+	if err := chart.ParseTemplates(); err != nil {
+		return nil, fmt.Errorf("error parsing templates: %w", err)
+	}
+	return chart, nil
+}
+
+// applyRenames renames values of a loaded chart, printing the diff of the
+// changes. The renames are appended to migrationFile unless it is empty.
+func applyRenames(chart *shcv.Chart, chartDir string, renames map[string]string, migrationFile string, dryRun bool, out io.Writer) error {
+	changes, err := chart.RenameValues(renames)
+	if err != nil {
+		return fmt.Errorf("error renaming values: %w", err)
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "no values to rename")
+		return nil
+	}
+	if migrationFile != "" {
+		change, err := migrationChange(migrationFile, renames)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, change)
+	}
+
+	for _, change := range changes {
+		fmt.Fprint(out, change.Diff(chartDir))
+	}
+	if dryRun {
+		return nil
+	}
+
+	if err := shcv.WriteChanges(changes); err != nil {
+		return fmt.Errorf("error writing changes: %w", err)
+	}
+	return nil
+}
+
+// migrationChange returns the change appending renames to a migration file,
+// which is created if it doesn't exist.
+func migrationChange(path string, renames map[string]string) (shcv.FileChange, error) {
+	migration, err := shcv.NewValuesMigration(renames)
+	if err != nil {
+		return shcv.FileChange{}, fmt.Errorf("error recording migration: %w", err)
+	}
+	before, err := os.ReadFile(path)
+	if err == nil {
+		existing, err := shcv.ReadValuesMigration(path)
+		if err != nil {
+			return shcv.FileChange{}, fmt.Errorf("error recording migration: %w", err)
+		}
+		migration.Renames = append(existing.Renames, migration.Renames...)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return shcv.FileChange{}, fmt.Errorf("error recording migration: %w", err)
+	}
+
+	after, err := migration.Marshal()
+	if err != nil {
+		return shcv.FileChange{}, fmt.Errorf("error recording migration: %w", err)
+	}
+	return shcv.FileChange{Path: path, Before: before, After: after}, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameValues(t *testing.T) {
+	setup := func(t *testing.T) string {
+		chartDir := filepath.Join(t.TempDir(), "rename-chart")
+		require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("image_tag: v1\n"), 0644))
+		require.NoError(t, os.WriteFile(
+			filepath.Join(chartDir, "templates/deployment.yaml"),
+			[]byte("image: {{ .Values.image_tag }}\n"),
+			0644,
+		))
+		return chartDir
+	}
+
+	t.Run("apply", func(t *testing.T) {
+		chartDir := setup(t)
+		var out bytes.Buffer
+		require.NoError(t, renameValues(chartDir, map[string]string{"image_tag": "image.tag"}, "", false, &out))
+		assert.Contains(t, out.String(), "+image: {{ .Values.image.tag }}")
+
+		content, err := os.ReadFile(filepath.Join(chartDir, "templates/deployment.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "image: {{ .Values.image.tag }}\n", string(content))
+		content, err = os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "image:\n  tag: v1\n", string(content))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		chartDir := setup(t)
+		var out bytes.Buffer
+		require.NoError(t, renameValues(chartDir, map[string]string{"image_tag": "imageTag"}, "", true, &out))
+		assert.Contains(t, out.String(), "+imageTag: v1")
+
+		content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "image_tag: v1\n", string(content))
+	})
+
+	t.Run("nothing to rename", func(t *testing.T) {
+		chartDir := setup(t)
+		var out bytes.Buffer
+		require.NoError(t, renameValues(chartDir, map[string]string{"service": "svc"}, "", false, &out))
+		assert.Equal(t, "no values to rename\n", out.String())
+	})
+}
+
+func TestParseRenames(t *testing.T) {
+	renames, err := parseRenames([]string{"image_tag=image.tag", " a = b "})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"image_tag": "image.tag", "a": "b"}, renames)
+
+	_, err = parseRenames([]string{"image_tag"})
+	assert.EqualError(t, err, `invalid rename "image_tag": must be path=new-path`)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// reportCmd prints the JSON report of a chart without modifying it
+var reportCmd = &cobra.Command{
+	Use:   "report [chart-directory]",
+	Short: "Print a JSON report of a chart without modifying it",
+	Long: `report scans the chart like check and prints the same JSON report as the --report-file
+flag of sync, with the values missing from the values files. Warnings are printed to
+standard error. The chart is not modified.`,
+	Example: `  # Save the report of a chart for later CI steps
+  shcv report ./my-helm-chart > shcv-report.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return reportChart(args[0], cmd.OutOrStdout(), cmd.ErrOrStderr(), scanOptions(cmd.Flags())...)
+	},
+}
+
+func init() {
+	addScanFlags(reportCmd.Flags())
+	RootCmd.AddCommand(reportCmd)
+}
+
+// reportChart prints the report of a chart to out and its warnings to errOut.
+func reportChart(chartDir string, out, errOut io.Writer, opts ...shcv.Option) error {
+	report := newRunReport(chartDir)
+	chart, err := scanChart(chartDir, false, errOut, report, opts...)
+	if chart != nil {
+		for _, finding := range chart.MissingValues() {
+			report.Missing = append(report.Missing, newReportFinding(finding))
+		}
+	}
+	if encodeErr := report.encode(out, err); encodeErr != nil {
+		return errors.Join(err, encodeErr)
+	}
+	return err
+}
+
+// runReport is the structured report of a run written by --report-file. It is
+// written whether the run succeeds or not, with the results up to a failure.
+type runReport struct {
+	// Version is the shcv version that wrote the report
+	Version string `json:"version"`
+	// Chart is the chart directory
+	Chart string `json:"chart"`
+	// Success indicates that the run completed
+	Success bool `json:"success"`
+	// Error describes why the run failed
+	Error string `json:"error,omitempty"`
+	// Unchanged indicates that the run was skipped as nothing changed since the last one
+	Unchanged bool `json:"unchanged,omitempty"`
+	// Templates is the number of templates found
+	Templates int `json:"templates"`
+	// References is the number of value references found in the templates
+	References int `json:"references"`
+	// Values lists the value references found in the templates, with the values
+	// files defining them before the run
+	Values []listedReference `json:"values"`
+	// Added lists the values added to the values files, or that a dry run would add
+	Added []reportAddition `json:"added"`
+	// Conflicts lists the conflicting defaults and the values that could not be
+	// added, which are also among the warnings
+	Conflicts []string `json:"conflicts"`
+	// Warnings lists the warnings printed during the run
+	Warnings []string `json:"warnings"`
+	// Findings lists the check findings, which are also among the warnings
+	Findings []reportFinding `json:"findings"`
+	// Updated lists the values files that were written
+	Updated []string `json:"updated"`
+	// Missing lists the values the values files do not define; only the report
+	// command fills it, as sync adds them
+	Missing []reportFinding `json:"missing"`
+	// Pruned lists the unused values removed with --prune
+	Pruned []shcv.ValuePath `json:"pruned,omitempty"`
+}
+
+// reportFinding is a check finding of a run report.
+type reportFinding struct {
+	Check      string `json:"check"`
+	Path       string `json:"path"`
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+	ValuesFile string `json:"valuesFile,omitempty"`
+	ValuesLine int    `json:"valuesLine,omitempty"`
+}
+
+// reportAddition is a value added to a values file by a run.
+type reportAddition struct {
+	Path string `json:"path"`
+	File string `json:"file"`
+}
+
+// newRunReport creates the report of a run on a chart.
+func newRunReport(chartDir string) *runReport {
+	return &runReport{
+		Version:   shcv.Version,
+		Chart:     chartDir,
+		Values:    []listedReference{},
+		Added:     []reportAddition{},
+		Conflicts: []string{},
+		Warnings:  []string{},
+		Findings:  []reportFinding{},
+		Updated:   []string{},
+		Missing:   []reportFinding{},
+	}
+}
+
+// warn prints a warning and records it in the report, if any.
+func (r *runReport) warn(out io.Writer, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	fmt.Fprintf(out, "%s %s\n", colorize(out, colorYellow, "warning:"), message)
+	if r != nil {
+		r.Warnings = append(r.Warnings, message)
+	}
+}
+
+// conflict prints a conflict as a warning and records it in the report, if any.
+func (r *runReport) conflict(out io.Writer, format string, args ...any) {
+	r.warn(out, format, args...)
+	if r != nil {
+		r.Conflicts = append(r.Conflicts, r.Warnings[len(r.Warnings)-1])
+	}
+}
+
+// finding prints a check finding and records it in the report, if any.
+func (r *runReport) finding(out io.Writer, finding shcv.Finding) {
+	r.warn(out, "%s", finding)
+	if r != nil {
+		r.Findings = append(r.Findings, newReportFinding(finding))
+	}
+}
+
+// newReportFinding converts a finding for the report.
+func newReportFinding(finding shcv.Finding) reportFinding {
+	return reportFinding{
+		Check:      finding.Check,
+		Path:       finding.Path,
+		File:       finding.SourceFile,
+		Line:       finding.LineNumber,
+		Message:    finding.Message,
+		Suggestion: finding.Suggestion,
+		ValuesFile: finding.ValuesFile,
+		ValuesLine: finding.ValuesLine,
+	}
+}
+
+// write writes the report to path as JSON, completed with the outcome of the run.
+func (r *runReport) write(path string, runErr error) error {
+	data, err := r.marshal(runErr)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	return nil
+}
+
+// encode writes the report to w as JSON, completed with the outcome of the run.
+func (r *runReport) encode(w io.Writer, runErr error) error {
+	data, err := r.marshal(runErr)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	return nil
+}
+
+// marshal completes the report with the outcome of the run and returns it as
+// indented JSON.
+func (r *runReport) marshal(runErr error) ([]byte, error) {
+	r.Success = runErr == nil
+	if runErr != nil {
+		r.Error = runErr.Error()
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding report: %w", err)
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunReport(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "report-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/service.yaml"), []byte("port: {{ .Values.port | default 80 }}\nname: {{ .Values.name | default \"a\" }}\n---\nname: {{ .Values.name | default \"b\" }}\n"), 0644))
+	reportFile := filepath.Join(t.TempDir(), "shcv-report.json")
+
+	report := newRunReport(chartDir)
+	var out bytes.Buffer
+	err := syncChart(chartDir, false, syncMode{}, &out, report)
+	require.NoError(t, report.write(reportFile, err))
+
+	var got runReport
+	data, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.True(t, got.Success)
+	assert.Empty(t, got.Error)
+	assert.Equal(t, shcv.Version, got.Version)
+	assert.Equal(t, 1, got.Templates)
+	assert.Equal(t, 3, got.References)
+	require.Len(t, got.Warnings, 1)
+	assert.Contains(t, out.String(), "warning: "+got.Warnings[0]+"\n")
+	assert.Equal(t, []string{filepath.Join(chartDir, "values.yaml")}, got.Updated)
+}
+
+func TestRunReportFailure(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "report-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/service.yaml"), []byte("port: {{ .Values.port }\n"), 0644))
+	reportFile := filepath.Join(t.TempDir(), "shcv-report.json")
+
+	report := newRunReport(chartDir)
+	runErr := syncChart(chartDir, false, syncMode{}, &bytes.Buffer{}, report, shcv.WithStrict(true))
+	require.Error(t, runErr)
+	require.NoError(t, report.write(reportFile, runErr))
+
+	var got runReport
+	data, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.False(t, got.Success)
+	assert.Equal(t, runErr.Error(), got.Error)
+	assert.Equal(t, 1, got.Templates)
+	assert.Equal(t, []string{}, got.Updated)
+
+	// An invalid chart is reported too
+	report = newRunReport("nonexistent")
+	runErr = syncChart("nonexistent", false, syncMode{}, &bytes.Buffer{}, report)
+	require.NoError(t, report.write(reportFile, runErr))
+	data, err = os.ReadFile(reportFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"error": "error creating chart`)
+}
+
+func TestReportChart(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "report-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("name: app\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/service.yaml"), []byte("name: {{ .Values.name }}\nport: {{ .Values.port }}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/broken.yaml"), []byte("x: {{ .Values.x\n"), 0644))
+
+	var out, errOut bytes.Buffer
+	require.NoError(t, reportChart(chartDir, &out, &errOut))
+
+	var got runReport
+	require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+	assert.True(t, got.Success)
+	assert.Equal(t, 2, got.Templates)
+	require.Len(t, got.Warnings, 1)
+	assert.Equal(t, "warning: "+got.Warnings[0]+"\n", errOut.String())
+	assert.Equal(t, []string{}, got.Updated)
+	require.Len(t, got.Missing, 1)
+	assert.Equal(t, "port", got.Missing[0].Path)
+
+	// The chart is not modified
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\n", string(content))
+
+	// Failures are reported as well
+	out.Reset()
+	err = reportChart(chartDir, &out, &errOut, shcv.WithStrict(true))
+	require.Error(t, err)
+	require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+	assert.False(t, got.Success)
+	assert.Equal(t, err.Error(), got.Error)
+}
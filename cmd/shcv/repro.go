@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+)
+
+// captureRepro writes a redacted reproduction bundle of the chart to file
+// without modifying the chart.
+func captureRepro(chartDir, file string, out io.Writer, opts ...shcv.Option) error {
+	chart, err := shcv.NewChart(chartDir, opts...)
+	if err != nil {
+		return fmt.Errorf("error creating chart: %w", err)
+	}
+	if err := chart.LoadValueFiles(); err != nil {
+		return fmt.Errorf("error loading values: %w", err)
+	}
+	if err := chart.FindTemplates(); err != nil {
+		return fmt.Errorf("error finding templates: %w", err)
+	}
+	if err := chart.ParseTemplates(); err != nil {
+		return fmt.Errorf("error parsing templates: %w", err)
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("error creating reproduction bundle: %w", err)
+	}
+	if err := chart.CaptureRepro(f); err != nil {
+		f.Close()
+		return fmt.Errorf("error capturing reproduction bundle: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing reproduction bundle: %w", err)
+	}
+
+	fmt.Fprintf(out, "wrote reproduction bundle to %s\n", file)
+	fmt.Fprintln(out, "contents are redacted to template syntax and keys; review the bundle before attaching it")
+	return nil
+}
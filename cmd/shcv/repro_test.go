@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureRepro(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "repro-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("token: secret\n"), 0644))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(chartDir, "templates/deployment.yaml"),
+		[]byte("image: {{ .Values.image.tag }}\n"),
+		0644,
+	))
+
+	bundle := filepath.Join(t.TempDir(), "repro.tar.gz")
+	var out bytes.Buffer
+	require.NoError(t, captureRepro(chartDir, bundle, &out))
+	assert.Contains(t, out.String(), "wrote reproduction bundle to "+bundle)
+
+	info, err := os.Stat(bundle)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Size())
+
+	// The chart is left untouched
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "token: secret\n", string(content))
+
+	t.Run("option values", func(t *testing.T) {
+		bundle := filepath.Join(t.TempDir(), "repro.tar.gz")
+		_, err := executeCommand(t, "--set", "db.password=hunter2", "--placeholder", "TODO-ask-alice", "--capture-repro", bundle, chartDir)
+		require.NoError(t, err)
+
+		file, err := os.Open(bundle)
+		require.NoError(t, err)
+		defer file.Close()
+		gz, err := gzip.NewReader(file)
+		require.NoError(t, err)
+		archive, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		assert.Contains(t, string(archive), "db.password")
+		assert.NotContains(t, string(archive), "hunter2")
+		assert.NotContains(t, string(archive), "TODO-ask-alice")
+	})
+
+	t.Run("invalid chart", func(t *testing.T) {
+		err := captureRepro("nonexistent", bundle, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "error creating chart")
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// scanSnippetCmd prints the references of a template snippet
+var scanSnippetCmd = &cobra.Command{
+	Use:   "scan-snippet [file|-]",
+	Short: "Print the value references of a template snippet as JSON",
+	Long: `scan-snippet reads a template snippet from a file, or from standard input when the
+argument is -, and prints the value references found in it with their defaults and
+inferred types, and the malformed actions, as JSON. No chart directory is needed,
+which makes it convenient in shell pipelines and for other tools to run.`,
+	Example: `  # Scan a snippet from standard input
+  echo '{{ .Values.image.tag | default "latest" }}' | shcv scan-snippet -
+
+  # Scan a single template file
+  shcv scan-snippet templates/deployment.yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return scanSnippet(args[0], cmd.InOrStdin(), cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(scanSnippetCmd)
+}
+
+// snippetReport is the JSON output of scan-snippet.
+type snippetReport struct {
+	References  []snippetRef        `json:"references"`
+	Diagnostics []snippetDiagnostic `json:"diagnostics"`
+}
+
+// snippetRef is a value reference of a snippet.
+type snippetRef struct {
+	Path            string         `json:"path"`
+	Default         string         `json:"default,omitempty"`
+	DefaultUnquoted bool           `json:"defaultUnquoted,omitempty"`
+	DefaultPath     string         `json:"defaultPath,omitempty"`
+	Type            shcv.ValueType `json:"type,omitempty"`
+	Line            int            `json:"line"`
+	Column          int            `json:"column"`
+}
+
+// snippetDiagnostic is a malformed action of a snippet.
+type snippetDiagnostic struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func scanSnippet(source string, stdin io.Reader, out io.Writer) error {
+	var content []byte
+	var err error
+	if source == "-" {
+		content, err = io.ReadAll(stdin)
+	} else {
+		content, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading snippet: %w", err)
+	}
+
+	refs, diagnostics := shcv.ParseFileWithDiagnostics(string(content), source)
+	report := snippetReport{
+		References:  make([]snippetRef, 0, len(refs)),
+		Diagnostics: make([]snippetDiagnostic, 0, len(diagnostics)),
+	}
+	for _, ref := range refs {
+		report.References = append(report.References, snippetRef{
+			Path:            ref.Path,
+			Default:         ref.DefaultValue,
+			DefaultUnquoted: ref.DefaultUnquoted,
+			DefaultPath:     ref.DefaultPath,
+			Type:            ref.Type,
+			Line:            ref.LineNumber,
+			Column:          ref.Column,
+		})
+	}
+	for _, d := range diagnostics {
+		report.Diagnostics = append(report.Diagnostics, snippetDiagnostic{Line: d.LineNumber, Column: d.Column, Message: d.Message})
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanSnippet(t *testing.T) {
+	snippet := "tag: {{ .Values.image.tag | default \"latest\" }}\nport: {{ .Values.port | default 8080 }}\n{{ if .Values.enabled }}\nname: {{ .Values.name }\n"
+
+	var out bytes.Buffer
+	require.NoError(t, scanSnippet("-", strings.NewReader(snippet), &out))
+	var report snippetReport
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, []snippetRef{
+		{Path: "image.tag", Default: "latest", Line: 1, Column: 9},
+		{Path: "port", Default: "8080", DefaultUnquoted: true, Line: 2, Column: 10},
+		{Path: "enabled", Type: shcv.TypeBool, Line: 3, Column: 7},
+	}, report.References)
+	require.Len(t, report.Diagnostics, 1)
+	assert.Equal(t, 4, report.Diagnostics[0].Line)
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "snippet.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("{{ .Values.name }}\n"), 0644))
+		var out bytes.Buffer
+		require.NoError(t, scanSnippet(path, nil, &out))
+		assert.Contains(t, out.String(), `"path": "name"`)
+		assert.Contains(t, out.String(), `"diagnostics": []`)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		err := scanSnippet(filepath.Join(t.TempDir(), "missing.yaml"), nil, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "error reading snippet")
+	})
+}
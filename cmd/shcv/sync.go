@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// syncCmd syncs the values files with the templates; it is also run by the
+// root command given a chart directory
+var syncCmd = &cobra.Command{
+	Use:   "sync [chart-directory]",
+	Short: "Add the values referenced by the templates to the values files",
+	Long: `sync scans all template files for {{ .Values.* }} expressions and adds the values
+missing from the values files, with the template defaults or values of their inferred
+type. Existing values, comments and layout are kept. "shcv sync DIR" and "shcv DIR"
+are the same.`,
+	Example: `  # Sync a chart
+  shcv sync ./my-helm-chart
+
+  # Preview the changes as a diff without writing them
+  shcv sync --dry-run ./my-helm-chart
+
+  # Fail CI with exit status 2 when the values are out of sync
+  shcv sync --check ./my-helm-chart
+
+  # Also write the changes as a patch for git apply
+  shcv sync --check --patch-file shcv.patch ./my-helm-chart
+
+  # Print the result of the run as JSON for scripts
+  shcv sync --output json ./my-helm-chart
+
+  # Sync a chart with verbose output, writing a report for CI
+  shcv sync -v --report-file shcv-report.json ./my-helm-chart`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSync,
+}
+
+func init() {
+	addSyncFlags(syncCmd.Flags())
+	RootCmd.AddCommand(syncCmd)
+}
+
+// runSync syncs the chart with the options of the sync flags.
+func runSync(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	verbose := verboseOutput(flags)
+	output, _ := flags.GetString("output")
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unknown output format %q: must be text or json", output)
+	}
+	opts, err := chartOptions(flags)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, writeOptions(flags)...)
+	opts = append(opts, pruneOptions(flags)...)
+	if repro, _ := flags.GetString("capture-repro"); repro != "" {
+		return captureRepro(args[0], repro, cmd.OutOrStdout(), opts...)
+	}
+	cacheFile, _ := flags.GetString("cache-file")
+	opts = append(opts, shcv.WithCacheFile(cacheFile))
+	var mode syncMode
+	mode.prune, _ = flags.GetBool("prune")
+	mode.dryRun, _ = flags.GetBool("dry-run")
+	mode.check, _ = flags.GetBool("check")
+	mode.patchFile, _ = flags.GetString("patch-file")
+	if mode.patchFile != "" && !mode.dryRun && !mode.check {
+		return errors.New("--patch-file requires --dry-run or --check")
+	}
+
+	// With JSON output, the report is the only output on stdout
+	out := cmd.OutOrStdout()
+	if output == "json" {
+		out = cmd.ErrOrStderr()
+	}
+	opts = append(opts, logOptions(flags, cmd.ErrOrStderr())...)
+	out = quietOutput(flags, out)
+	opts = append(opts, shcv.WithOutput(out))
+	if interactive, _ := flags.GetBool("interactive"); interactive {
+		opts = append(opts, shcv.WithValuePrompt(valuePrompt(cmd.InOrStdin(), out)))
+	}
+	reportFile, _ := flags.GetString("report-file")
+	var report *runReport
+	if reportFile != "" || output == "json" {
+		report = newRunReport(args[0])
+	}
+	err = syncChart(args[0], verbose, mode, out, report, opts...)
+	if err == nil {
+		if scaffold, _ := flags.GetString("kustomize-scaffold"); scaffold != "" {
+			err = writeKustomizeScaffold(args[0], scaffold, out)
+		}
+	}
+	if reportFile != "" {
+		if writeErr := report.write(reportFile, err); writeErr != nil {
+			return errors.Join(err, writeErr)
+		}
+	}
+	if output == "json" {
+		if encodeErr := report.encode(cmd.OutOrStdout(), err); encodeErr != nil {
+			return errors.Join(err, encodeErr)
+		}
+	}
+	return err
+}
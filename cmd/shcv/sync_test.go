@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// executeCommand runs the shcv command line with args and returns its output.
+// The flags of the command run are reset first, as cobra keeps their values
+// between executions.
+func executeCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := executeCommandOutputs(t, &out, &out, args...)
+	return out.String(), err
+}
+
+// executeCommandOutputs runs the shcv command line like executeCommand, writing
+// its standard output and error to out and errOut.
+func executeCommandOutputs(t *testing.T, out, errOut *bytes.Buffer, args ...string) error {
+	t.Helper()
+	root := syncCmd.Root()
+	registerCompletions(root)
+	// Completion requests complete the command line following __complete
+	findArgs := args
+	if len(args) > 0 && args[0] == cobra.ShellCompRequestCmd {
+		findArgs = args[1:]
+	}
+	cmd, _, err := root.Find(findArgs)
+	require.NoError(t, err)
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			require.NoError(t, slice.Replace(nil))
+		} else {
+			require.NoError(t, f.Value.Set(f.DefValue))
+		}
+		f.Changed = false
+	})
+
+	root.SetOut(out)
+	root.SetErr(errOut)
+	root.SetArgs(args)
+	t.Cleanup(func() {
+		root.SetOut(nil)
+		root.SetErr(nil)
+		root.SetArgs(nil)
+	})
+	_, err = root.ExecuteC()
+	return err
+}
+
+// writeCommandChart writes a chart referencing a value missing from its values file.
+func writeCommandChart(t *testing.T) string {
+	t.Helper()
+	chartDir := filepath.Join(t.TempDir(), "command-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("name: app\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/service.yaml"), []byte("port: {{ .Values.port }}\n"), 0644))
+	return chartDir
+}
+
+func TestSyncCommand(t *testing.T) {
+	for _, args := range [][]string{{"sync"}, {}} {
+		name := "shcv sync DIR"
+		if len(args) == 0 {
+			name = "shcv DIR"
+		}
+		t.Run(name, func(t *testing.T) {
+			chartDir := writeCommandChart(t)
+			_, err := executeCommand(t, append(args, "--placeholder", "TODO", chartDir)...)
+			require.NoError(t, err)
+
+			content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+			require.NoError(t, err)
+			assert.Equal(t, "name: app\nport: TODO\n", string(content))
+		})
+	}
+}
+
+func TestSyncDryRun(t *testing.T) {
+	chartDir := writeCommandChart(t)
+	out, err := executeCommand(t, "sync", "--dry-run", chartDir)
+	require.NoError(t, err)
+	assert.Equal(t, "--- a/values.yaml\n+++ b/values.yaml\n@@ -1 +1,2 @@\n name: app\n+port: \"\"\n", out)
+
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\n", string(content))
+
+	// After syncing, there is nothing left to change
+	_, err = executeCommand(t, chartDir)
+	require.NoError(t, err)
+	out, err = executeCommand(t, "sync", "--dry-run", chartDir)
+	require.NoError(t, err)
+	assert.Equal(t, "no changes\n", out)
+}
+
+func TestRunErrorsSilenceUsage(t *testing.T) {
+	for _, args := range [][]string{{"--check"}, {"sync", "--check"}, {"check"}} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			cmd, _, err := RootCmd.Find(args)
+			require.NoError(t, err)
+			// Earlier runs silence the usage of the command they ran
+			cmd.SilenceUsage = false
+
+			var out, errOut bytes.Buffer
+			err = executeCommandOutputs(t, &out, &errOut, append(args, writeCommandChart(t))...)
+			assert.Equal(t, exitOutOfSync, exitCode(err))
+			assert.NotContains(t, out.String()+errOut.String(), "Usage:")
+			// main prints the error
+			assert.NotContains(t, out.String()+errOut.String(), "Error:")
+		})
+	}
+}
+
+func TestSyncCheck(t *testing.T) {
+	chartDir := writeCommandChart(t)
+	out, err := executeCommand(t, "--check", chartDir)
+	require.EqualError(t, err, "chart is out of sync: 1 files would change")
+	assert.Equal(t, exitOutOfSync, exitCode(err))
+	assert.Contains(t, out, "+port: \"\"\n")
+
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\n", string(content))
+
+	// Errors exit with status 1
+	_, err = executeCommand(t, "sync", "--check", filepath.Join(chartDir, "nonexistent"))
+	require.Error(t, err)
+	assert.Equal(t, 1, exitCode(err))
+
+	_, err = executeCommand(t, chartDir)
+	require.NoError(t, err)
+	out, err = executeCommand(t, "sync", "--check", chartDir)
+	require.NoError(t, err)
+	assert.Equal(t, "no changes\n", out)
+}
+
+func TestSyncPatchFile(t *testing.T) {
+	chartDir := writeCommandChart(t)
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(filepath.Dir(chartDir)))
+	defer os.Chdir(wd)
+
+	// The paths of the patch are relative to the current directory
+	_, err = executeCommand(t, "sync", "--check", "--patch-file", "out.patch", "-f", "values-dev.yaml", "command-chart")
+	assert.Equal(t, exitOutOfSync, exitCode(err))
+	patch, err := os.ReadFile("out.patch")
+	require.NoError(t, err)
+	assert.Equal(t, `diff --git a/command-chart/values.yaml b/command-chart/values.yaml
+--- a/command-chart/values.yaml
++++ b/command-chart/values.yaml
+@@ -1 +1,2 @@
+ name: app
++port: ""
+diff --git a/command-chart/values-dev.yaml b/command-chart/values-dev.yaml
+new file mode 100644
+--- /dev/null
++++ b/command-chart/values-dev.yaml
+@@ -0,0 +1 @@
++port: ""
+`, string(patch))
+
+	if _, err := exec.LookPath("git"); err == nil {
+		require.NoError(t, exec.Command("git", "apply", "out.patch").Run())
+		content, err := os.ReadFile("command-chart/values-dev.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, "port: \"\"\n", string(content))
+		out, err := executeCommand(t, "sync", "--check", "-f", "values-dev.yaml", "command-chart")
+		require.NoError(t, err)
+		assert.Equal(t, "no changes\n", out)
+	}
+
+	_, err = executeCommand(t, "sync", "--patch-file", "out.patch", "command-chart")
+	assert.EqualError(t, err, "--patch-file requires --dry-run or --check")
+}
+
+func TestSyncValuesFiles(t *testing.T) {
+	chartDir := writeCommandChart(t)
+	prod := filepath.Join(t.TempDir(), "values-prod.yaml")
+	require.NoError(t, os.WriteFile(prod, []byte("name: prod\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values-dev.yaml"), []byte("name: dev\n"), 0644))
+
+	_, err := executeCommand(t, "-f", "values-dev.yaml", "--values", prod, chartDir)
+	require.NoError(t, err)
+	for _, path := range []string{filepath.Join(chartDir, "values-dev.yaml"), prod} {
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "port: \"\"\n")
+	}
+
+	// Layered values files only hold overrides
+	chartDir = writeCommandChart(t)
+	require.NoError(t, os.WriteFile(prod, []byte("name: prod\n"), 0644))
+	_, err = executeCommand(t, "sync", "-f", prod, "--layered", chartDir)
+	require.NoError(t, err)
+	content, err := os.ReadFile(prod)
+	require.NoError(t, err)
+	assert.Equal(t, "name: prod\n", string(content))
+	content, err = os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\nport: \"\"\n", string(content))
+}
+
+func TestSyncSetValues(t *testing.T) {
+	chartDir := writeCommandChart(t)
+	_, err := executeCommand(t, "--set", "port=8080,name=web", chartDir)
+	require.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\nport: 8080\n", string(content))
+
+	_, err = executeCommand(t, "--set", "port", chartDir)
+	assert.ErrorContains(t, err, `invalid set value: parsing "port": key "port" has no value`)
+}
+
+func TestSyncSortKeys(t *testing.T) {
+	chartDir := writeCommandChart(t)
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("zone: eu\nname: app\n"), 0644))
+	out, err := executeCommand(t, "--check", "--sort-keys", chartDir)
+	assert.Equal(t, exitOutOfSync, exitCode(err))
+	assert.Contains(t, out, "-zone: eu\n name: app\n+port: \"\"\n+zone: eu\n")
+
+	_, err = executeCommand(t, "--sort-keys", chartDir)
+	require.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\nport: \"\"\nzone: eu\n", string(content))
+
+	_, err = executeCommand(t, "--sort-keys", "--section-banner", "synced", chartDir)
+	assert.ErrorContains(t, err, "sorted keys and a section banner are mutually exclusive")
+}
+
+func TestSyncInjectStrategy(t *testing.T) {
+	chartDir := writeCommandChart(t)
+	templatePath := filepath.Join(chartDir, "templates", "deployment.yaml")
+	template := "kind: Deployment\nspec:\n  replicas: {{ .Values.replicas | default 1 }}\n"
+	require.NoError(t, os.WriteFile(templatePath, []byte(template), 0644))
+
+	// Templates are left alone by default
+	_, err := executeCommand(t, chartDir)
+	require.NoError(t, err)
+	content, err := os.ReadFile(templatePath)
+	require.NoError(t, err)
+	assert.Equal(t, template, string(content))
+	content, err = os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\nport: \"\"\nreplicas: 1\n", string(content))
+
+	_, err = executeCommand(t, "--inject-strategy", chartDir)
+	require.NoError(t, err)
+	content, err = os.ReadFile(templatePath)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "strategy:\n    type: {{ .Values.deployment.strategy.type }}")
+	content, err = os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "deployment:\n  strategy:\n")
+}
+
+func TestSyncOutputJSON(t *testing.T) {
+	chartDir := writeCommandChart(t)
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/deployment.yaml"),
+		[]byte("a: {{ .Values.port | default 80 }}\nb: {{ .Values.port | default 81 }}\n"), 0644))
+
+	var out, errOut bytes.Buffer
+	require.NoError(t, executeCommandOutputs(t, &out, &errOut, "--output", "json", chartDir))
+	assert.Contains(t, errOut.String(), "warning: conflicting defaults for .Values.port")
+	var report runReport
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.True(t, report.Success)
+	assert.Equal(t, 2, report.Templates)
+	assert.Equal(t, []listedReference{
+		{Path: "port", File: "templates/deployment.yaml", Line: 1, Default: "80"},
+		{Path: "port", File: "templates/deployment.yaml", Line: 2, Default: "81"},
+		{Path: "port", File: "templates/service.yaml", Line: 1},
+	}, report.Values)
+	assert.Equal(t, []reportAddition{{Path: "port", File: filepath.Join(chartDir, "values.yaml")}}, report.Added)
+	require.Len(t, report.Conflicts, 1)
+	assert.Contains(t, report.Conflicts[0], "conflicting defaults for .Values.port")
+	assert.Equal(t, []string{filepath.Join(chartDir, "values.yaml")}, report.Updated)
+
+	// Failures are reported in the document too
+	out.Reset()
+	err := executeCommandOutputs(t, &out, &errOut, "sync", "-o", "json", filepath.Join(chartDir, "nonexistent"))
+	require.Error(t, err)
+	report = runReport{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.False(t, report.Success)
+	assert.Equal(t, err.Error(), report.Error)
+
+	_, err = executeCommand(t, "--output", "yaml", chartDir)
+	assert.EqualError(t, err, `unknown output format "yaml": must be text or json`)
+}
+
+func TestSyncLogLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "default",
+			notWant: []string{"level=INFO", "level=DEBUG", "Found 1 value references"},
+		},
+		{
+			name:    "verbose",
+			args:    []string{"-v"},
+			want:    []string{`level=INFO msg="updated values" file=`, "Found 1 value references"},
+			notWant: []string{"level=DEBUG"},
+		},
+		{
+			name: "debug",
+			args: []string{"-vv"},
+			want: []string{`level=DEBUG msg="parsing template" file=`, "level=INFO"},
+		},
+		{
+			name:    "quiet",
+			args:    []string{"--quiet", "--dry-run"},
+			notWant: []string{"values.yaml", "level="},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chartDir := writeCommandChart(t)
+			var out, errOut bytes.Buffer
+			require.NoError(t, executeCommandOutputs(t, &out, &errOut, append(append([]string{"sync"}, tt.args...), chartDir)...))
+			all := out.String() + errOut.String()
+			for _, want := range tt.want {
+				assert.Contains(t, all, want)
+			}
+			for _, notWant := range tt.notWant {
+				assert.NotContains(t, all, notWant)
+			}
+			// The messages of the library are logged to stderr only
+			assert.NotContains(t, out.String(), "level=")
+		})
+	}
+}
+
+func TestCommandFlagGroups(t *testing.T) {
+	// Every chart command accepts the scan flags
+	for _, command := range []string{"sync", "check", "report"} {
+		t.Run(command, func(t *testing.T) {
+			chartDir := writeCommandChart(t)
+			require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/broken.yaml"), []byte("x: {{ .Values.x\n"), 0644))
+
+			_, err := executeCommand(t, command, "--exclude", "broken.yaml", "--strict", chartDir)
+			if command == "check" {
+				assert.EqualError(t, err, "chart is out of sync: 1 missing values")
+			} else {
+				assert.NoError(t, err)
+			}
+			_, err = executeCommand(t, command, "--strict", chartDir)
+			assert.ErrorContains(t, err, "malformed templates in strict mode")
+		})
+	}
+
+	t.Run("write flags are sync flags", func(t *testing.T) {
+		_, err := executeCommand(t, "check", "--backup", writeCommandChart(t))
+		assert.ErrorContains(t, err, "unknown flag: --backup")
+	})
+}
+
+func TestSyncCacheStrict(t *testing.T) {
+	chartDir := writeCommandChart(t)
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/broken.yaml"), []byte("x: {{ .Values.x\n"), 0644))
+	_, err := executeCommand(t, "sync", "--cache-file", ".shcv-cache.json", chartDir)
+	require.NoError(t, err)
+	out, err := executeCommand(t, "sync", "--cache-file", ".shcv-cache.json", chartDir)
+	require.NoError(t, err)
+	assert.Contains(t, out, "no changes")
+
+	// The recorded run does not hide the failures of strict mode
+	_, err = executeCommand(t, "sync", "--cache-file", ".shcv-cache.json", "--strict", chartDir)
+	assert.ErrorContains(t, err, "malformed templates in strict mode")
+}
+
+func TestReportCommand(t *testing.T) {
+	chartDir := writeCommandChart(t)
+	out, err := executeCommand(t, "report", chartDir)
+	require.NoError(t, err)
+
+	var got runReport
+	require.NoError(t, json.Unmarshal([]byte(out), &got))
+	require.Len(t, got.Missing, 1)
+	assert.Equal(t, "port", got.Missing[0].Path)
+}
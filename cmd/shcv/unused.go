@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// unusedCmd reports the values no template references
+var unusedCmd = &cobra.Command{
+	Use:   "unused [chart-directory]",
+	Short: "Report the values no template references",
+	Long: `unused lists the values the values files define that no template references, including
+keys nested in used maps, such as image.tag next to a referenced image.repository. A map
+that is not used at all is listed once, at its key. Values that may still be read, through
+tpl or by a template using .Values as a whole, are marked as possibly used. The chart is
+not modified; prune removes the unused values.`,
+	Example: `  # List the unused values
+  shcv unused ./my-helm-chart
+
+  # Export them as JSON
+  shcv unused --output json ./my-helm-chart`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		return unusedValues(args[0], output, cmd.OutOrStdout(), scanOptions(cmd.Flags())...)
+	},
+}
+
+func init() {
+	addScanFlags(unusedCmd.Flags())
+	unusedCmd.Flags().StringP("output", "o", "text", "output format: text or json")
+	RootCmd.AddCommand(unusedCmd)
+}
+
+func unusedValues(chartDir, output string, out io.Writer, opts ...shcv.Option) error {
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unknown output format %q: must be text or json", output)
+	}
+	chart, err := loadChart(chartDir, opts...)
+	if err != nil {
+		return err
+	}
+	unused := chart.UnusedValues()
+	for i := range unused {
+		if rel, err := filepath.Rel(chartDir, unused[i].File); err == nil {
+			unused[i].File = rel
+		}
+	}
+
+	if output == "json" {
+		if unused == nil {
+			unused = []shcv.ValuePath{}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(unused)
+	}
+	if len(unused) == 0 {
+		fmt.Fprintln(out, "no unused values")
+		return nil
+	}
+	for _, value := range unused {
+		fmt.Fprintf(out, "%s:%d: .Values.%s", value.File, value.LineNumber, value.Path)
+		if value.PossiblyUsed {
+			fmt.Fprint(out, " (possibly used)")
+		}
+		fmt.Fprintln(out)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnusedValues(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "unused-chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	values := "image:\n  repository: nginx\n  tag: v1\nsidecar:\n  image: busybox\nconfig: '{{ .Values.extra }}'\nextra: 1\n"
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte(values), 0644))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(chartDir, "templates/deployment.yaml"),
+		[]byte("image: {{ .Values.image.repository }}\nconfig: {{ tpl .Values.config . }}\n"),
+		0644,
+	))
+
+	var out bytes.Buffer
+	require.NoError(t, unusedValues(chartDir, "text", &out))
+	assert.Equal(t, "values.yaml:3: .Values.image.tag\nvalues.yaml:4: .Values.sidecar\nvalues.yaml:7: .Values.extra (possibly used)\n", out.String())
+
+	out.Reset()
+	require.NoError(t, unusedValues(chartDir, "json", &out))
+	var unused []shcv.ValuePath
+	require.NoError(t, json.Unmarshal(out.Bytes(), &unused))
+	assert.Equal(t, []shcv.ValuePath{
+		{Path: "image.tag", File: "values.yaml", LineNumber: 3},
+		{Path: "sidecar", File: "values.yaml", LineNumber: 4},
+		{Path: "extra", File: "values.yaml", LineNumber: 7, PossiblyUsed: true},
+	}, unused)
+
+	// The chart is not modified
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, values, string(content))
+
+	assert.EqualError(t, unusedValues(chartDir, "html", &out), `unknown output format "html": must be text or json`)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/spf13/cobra"
+)
+
+// versionCmd prints the build information of shcv
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version and build information of shcv",
+	Long: `version prints the version of shcv with the commit and date it was built from and the
+Go version used, to include in bug reports.`,
+	Example: `  # Print the build information
+  shcv version
+
+  # Print it as JSON
+  shcv version --output json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		return printVersion(shcv.GetBuildInfo(), output, cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	versionCmd.Flags().StringP("output", "o", "text", "output format: text or json")
+	RootCmd.AddCommand(versionCmd)
+}
+
+func printVersion(info shcv.BuildInfo, output string, out io.Writer) error {
+	switch output {
+	case "text":
+		fmt.Fprintf(out, "shcv %s\n", info.Version)
+		if info.Commit != "" {
+			fmt.Fprintf(out, "commit: %s\n", info.Commit)
+		}
+		if info.Date != "" {
+			fmt.Fprintf(out, "built:  %s\n", info.Date)
+		}
+		fmt.Fprintf(out, "go:     %s\n", info.GoVersion)
+		return nil
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	default:
+		return fmt.Errorf("unknown output format %q: must be text or json", output)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintVersion(t *testing.T) {
+	info := shcv.BuildInfo{Version: "1.2.3", Commit: "abc1234", Date: "2024-05-01T10:00:00Z", GoVersion: "go1.22.0"}
+
+	var out bytes.Buffer
+	require.NoError(t, printVersion(info, "text", &out))
+	assert.Equal(t, "shcv 1.2.3\ncommit: abc1234\nbuilt:  2024-05-01T10:00:00Z\ngo:     go1.22.0\n", out.String())
+
+	out.Reset()
+	require.NoError(t, printVersion(shcv.BuildInfo{Version: "1.2.3", GoVersion: "go1.22.0"}, "text", &out))
+	assert.Equal(t, "shcv 1.2.3\ngo:     go1.22.0\n", out.String())
+
+	out.Reset()
+	require.NoError(t, printVersion(info, "json", &out))
+	var decoded shcv.BuildInfo
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, info, decoded)
+
+	assert.ErrorContains(t, printVersion(info, "yaml", &out), `unknown output format "yaml"`)
+}
+
+func TestVersionCommand(t *testing.T) {
+	out, err := executeCommand(t, "version", "--output", "json")
+	require.NoError(t, err)
+	var info shcv.BuildInfo
+	require.NoError(t, json.Unmarshal([]byte(out), &info))
+	assert.Equal(t, shcv.Version, info.Version)
+}
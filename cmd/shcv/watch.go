@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/agentstation/shcv/pkg/shcv"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+// watchCmd syncs a chart whenever its templates change
+var watchCmd = &cobra.Command{
+	Use:   "watch [chart-directory]",
+	Short: "Sync the values files whenever the templates change",
+	Long: `watch syncs the chart like sync, then watches the templates directory and syncs it
+again whenever a template is created, modified or removed. Changes in quick succession,
+such as an editor saving several files, are synced once after --debounce. Errors are
+printed and watching goes on. watch stops cleanly on SIGINT (Ctrl+C) or SIGTERM.`,
+	Example: `  # Keep the values of a chart in sync while editing its templates
+  shcv watch ./my-helm-chart
+
+  # Wait a second after the last change before syncing
+  shcv watch --debounce 1s ./my-helm-chart`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		debounce, _ := flags.GetDuration("debounce")
+		opts, err := chartOptions(flags)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, writeOptions(flags)...)
+		out := quietOutput(flags, cmd.OutOrStdout())
+		opts = append(opts, logOptions(flags, cmd.ErrOrStderr())...)
+		opts = append(opts, shcv.WithOutput(out))
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return watchChart(ctx, args[0], debounce, verboseOutput(flags), out, cmd.ErrOrStderr(), opts...)
+	},
+}
+
+func init() {
+	addLogFlags(watchCmd.Flags())
+	addScanFlags(watchCmd.Flags())
+	addValuesFlags(watchCmd.Flags())
+	addWriteFlags(watchCmd.Flags())
+	watchCmd.Flags().Duration("debounce", 300*time.Millisecond, "how long to wait after the last template change before syncing")
+	RootCmd.AddCommand(watchCmd)
+}
+
+// watchChart syncs the chart, then again after every change to its templates
+// until ctx is done. The changes are debounced: a sync runs once no change
+// happened for the debounce duration. Sync errors are printed to errOut.
+func watchChart(ctx context.Context, chartDir string, debounce time.Duration, verbose bool, out, errOut io.Writer, opts ...shcv.Option) error {
+	if debounce < 0 {
+		return fmt.Errorf("debounce duration %s is negative", debounce)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error watching templates: %w", err)
+	}
+	defer watcher.Close()
+
+	templatesDir := filepath.Join(chartDir, "templates")
+	if err := watchTree(watcher, templatesDir); err != nil {
+		return fmt.Errorf("error watching templates: %w", err)
+	}
+
+	sync := func() {
+		if err := processChart(chartDir, verbose, out, opts...); err != nil {
+			fmt.Fprintf(errOut, "error: %v\n", err)
+		}
+	}
+	sync()
+	fmt.Fprintf(out, "watching %s for changes\n", templatesDir)
+
+	timer := time.NewTimer(debounce)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			fmt.Fprintln(out, "stopped watching")
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			// Directories created below the templates directory are watched too
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watchTree(watcher, event.Name); err != nil {
+						fmt.Fprintf(errOut, "error: watching %s: %v\n", event.Name, err)
+					}
+				}
+			}
+			if verbose {
+				fmt.Fprintf(out, "%s: %s\n", relPath(chartDir, event.Name), event.Op)
+			}
+			timer.Reset(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintf(errOut, "error: %v\n", err)
+		case <-timer.C:
+			fmt.Fprintln(out, "templates changed, syncing")
+			sync()
+		}
+	}
+}
+
+// watchTree watches dir and the directories below it.
+func watchTree(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path != dir {
+				return nil
+			}
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		return watcher.Add(path)
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchChart(t *testing.T) {
+	chartDir := writeCommandChart(t)
+	valuesFile := filepath.Join(chartDir, "values.yaml")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- watchChart(ctx, chartDir, 10*time.Millisecond, false, &out, &out)
+	}()
+
+	// The chart is synced right away
+	assert.Eventually(t, func() bool {
+		content, err := os.ReadFile(valuesFile)
+		return err == nil && string(content) == "name: app\nport: \"\"\n"
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return strings.Contains(out.String(), "watching ") }, 5*time.Second, 10*time.Millisecond)
+
+	// Templates created in new directories are synced too
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates/extra"), 0755))
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/extra/config.yaml"), []byte("host: {{ .Values.host | default \"example.com\" }}\n"), 0644))
+	assert.Eventually(t, func() bool {
+		content, err := os.ReadFile(valuesFile)
+		return err == nil && string(content) == "name: app\nport: \"\"\nhost: example.com\n"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Sync errors are printed without stopping the watch
+	require.NoError(t, os.WriteFile(valuesFile, []byte("- list\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates/other.yaml"), []byte("x: {{ .Values.other }}\n"), 0644))
+	assert.Eventually(t, func() bool { return strings.Contains(out.String(), "error: error loading values") }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not stop")
+	}
+	assert.Contains(t, out.String(), "templates changed, syncing\n")
+	assert.Contains(t, out.String(), "stopped watching\n")
+
+	assert.EqualError(t, watchChart(ctx, chartDir, -time.Second, false, &out, &out), "debounce duration -1s is negative")
+	assert.ErrorContains(t, watchChart(ctx, filepath.Join(chartDir, "nonexistent"), time.Second, false, &out, &out), "error watching templates")
+}
@@ -0,0 +1,211 @@
+package shcv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// renameFile moves staged files into place; tests replace it to make renames fail
+var renameFile = os.Rename
+
+// fileMode selects the permissions of the files written by writeFileAtomic and
+// writeFilesAtomic.
+type fileMode struct {
+	// perm is the mode of created files, and of replaced files with force
+	perm os.FileMode
+	// force gives replaced files perm instead of keeping their mode
+	force bool
+}
+
+// defaultFileMode creates files with mode 0644 and keeps the mode of replaced files
+var defaultFileMode = fileMode{perm: 0644}
+
+// fileWrite is the new content of a file written by writeFilesAtomic.
+type fileWrite struct {
+	path string
+	data []byte
+}
+
+// RollbackError reports a write of several files that failed after some of
+// them were replaced. The replaced files were restored to their previous
+// content, or removed if the write created them.
+type RollbackError struct {
+	// Err is the error that stopped the write, joined with the errors of the
+	// files that could not be restored
+	Err error
+	// Restored lists the files that were restored, in the order they were written
+	Restored []string
+}
+
+// Error describes the failure and the restored files.
+func (e *RollbackError) Error() string {
+	return fmt.Sprintf("%v; restored %s", e.Err, strings.Join(e.Restored, ", "))
+}
+
+// Unwrap returns the error that stopped the write.
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// writeFileAtomic replaces the content of a file so that readers, and a crash
+// midway, see either the old or the new content: the data is written and
+// synced to a temporary file in the same directory, which is then renamed over
+// the file. An existing file keeps its permissions unless mode forces them,
+// and where possible its owner, a new one gets the mode, and files that cannot
+// be written in place are not replaced either. Symbolic links are followed,
+// so the file they point to is replaced.
+func writeFileAtomic(path string, data []byte, mode fileMode) error {
+	path, err := resolveLink(path)
+	if err != nil {
+		return err
+	}
+	temp, err := stageFile(path, data, mode)
+	if err != nil {
+		return err
+	}
+	if err := renameFile(temp, path); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// writeFilesAtomic writes several files so that either every file is updated
+// or none is: all contents are staged to temporary files first, as
+// writeFileAtomic does, and only then renamed into place. If a rename fails,
+// the files already replaced are rolled back and a *RollbackError lists them.
+func writeFilesAtomic(writes []fileWrite, mode fileMode) error {
+	type stagedWrite struct {
+		path, target, temp string
+		before             []byte
+		beforeMode         os.FileMode
+		existed            bool
+	}
+
+	staged := make([]stagedWrite, 0, len(writes))
+	cleanup := func(from int) {
+		for _, s := range staged[from:] {
+			os.Remove(s.temp)
+		}
+	}
+	for _, write := range writes {
+		s := stagedWrite{path: write.path}
+		target, err := resolveLink(write.path)
+		if err == nil {
+			s.target = target
+			s.before, err = os.ReadFile(target)
+			s.existed = err == nil
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		}
+		if s.existed {
+			var info os.FileInfo
+			if info, err = os.Stat(s.target); err == nil {
+				s.beforeMode = info.Mode().Perm()
+			}
+		}
+		if err == nil {
+			s.temp, err = stageFile(s.target, write.data, mode)
+		}
+		if err != nil {
+			cleanup(0)
+			return fmt.Errorf("staging %s: %w", write.path, err)
+		}
+		staged = append(staged, s)
+	}
+
+	for i, s := range staged {
+		err := renameFile(s.temp, s.target)
+		if err == nil {
+			syncDir(filepath.Dir(s.target))
+			continue
+		}
+		cleanup(i)
+		err = fmt.Errorf("writing %s: %w", s.path, err)
+		if i == 0 {
+			return err
+		}
+
+		// Put back the files already replaced, the last one first
+		rollback := &RollbackError{Err: err}
+		for j := i - 1; j >= 0; j-- {
+			var restoreErr error
+			if staged[j].existed {
+				restoreErr = writeFileAtomic(staged[j].target, staged[j].before, fileMode{perm: staged[j].beforeMode, force: true})
+			} else {
+				restoreErr = os.Remove(staged[j].target)
+			}
+			if restoreErr != nil {
+				rollback.Err = errors.Join(rollback.Err, fmt.Errorf("restoring %s: %w", staged[j].path, restoreErr))
+				continue
+			}
+			rollback.Restored = append([]string{staged[j].path}, rollback.Restored...)
+		}
+		return rollback
+	}
+	return nil
+}
+
+// stageFile writes data to a synced temporary file next to path, with the
+// permissions and owner of path if it exists, and returns its name. Files
+// created, and with a forced mode all files, get the permissions of mode. It
+// fails if path exists but cannot be written.
+func stageFile(path string, data []byte, mode fileMode) (string, error) {
+	if f, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+		f.Close()
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	perm := mode.perm
+	info, err := os.Stat(path)
+	if err == nil && !mode.force {
+		perm = info.Mode().Perm()
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return "", err
+	}
+	_, err = temp.Write(data)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), perm)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return "", err
+	}
+	if info != nil {
+		keepOwner(temp.Name(), info)
+	}
+	return temp.Name(), nil
+}
+
+// resolveLink returns the file a symbolic link points to, or path itself if it
+// is not a link. Links to missing files are an error.
+func resolveLink(path string) (string, error) {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return path, nil
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+// syncDir flushes a rename in dir to disk. Errors are ignored, as not every
+// platform can sync directories and the rename itself has succeeded.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
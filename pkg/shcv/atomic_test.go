@@ -0,0 +1,147 @@
+package shcv
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Run("keeps the file mode", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "values.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("old\n"), 0600))
+		require.NoError(t, os.Chmod(path, 0640))
+
+		require.NoError(t, writeFileAtomic(path, []byte("new\n"), defaultFileMode))
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "new\n", string(content))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	})
+
+	t.Run("forced file mode", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "values.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("old\n"), 0644))
+
+		require.NoError(t, writeFileAtomic(path, []byte("new\n"), fileMode{perm: 0600, force: true}))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("new file", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "values.yaml")
+		require.NoError(t, writeFileAtomic(path, []byte("new\n"), defaultFileMode))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+		// No temporary file is left behind
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("follows symbolic links", func(t *testing.T) {
+		dir := t.TempDir()
+		target := filepath.Join(dir, "shared.yaml")
+		link := filepath.Join(dir, "values.yaml")
+		require.NoError(t, os.WriteFile(target, []byte("old\n"), 0644))
+		require.NoError(t, os.Symlink(target, link))
+
+		require.NoError(t, writeFileAtomic(link, []byte("new\n"), defaultFileMode))
+		info, err := os.Lstat(link)
+		require.NoError(t, err)
+		assert.NotZero(t, info.Mode()&os.ModeSymlink)
+		content, err := os.ReadFile(target)
+		require.NoError(t, err)
+		assert.Equal(t, "new\n", string(content))
+	})
+
+	t.Run("dangling symbolic link", func(t *testing.T) {
+		link := filepath.Join(t.TempDir(), "values.yaml")
+		require.NoError(t, os.Symlink(filepath.Join(t.TempDir(), "missing.yaml"), link))
+		assert.Error(t, writeFileAtomic(link, []byte("new\n"), defaultFileMode))
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		assert.Error(t, writeFileAtomic(filepath.Join(t.TempDir(), "missing", "values.yaml"), []byte("new\n"), defaultFileMode))
+	})
+}
+
+// failRename makes the renames of staged files into target fail during a test.
+func failRename(t *testing.T, target string) {
+	t.Helper()
+	renameFile = func(from, to string) error {
+		if to == target {
+			return errors.New("disk full")
+		}
+		return os.Rename(from, to)
+	}
+	t.Cleanup(func() { renameFile = os.Rename })
+}
+
+func TestWriteFilesAtomic(t *testing.T) {
+	setup := func(t *testing.T) (string, []fileWrite) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "values.yaml"), []byte("old\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "values-prod.yaml"), []byte("prod\n"), 0644))
+		return dir, []fileWrite{
+			{path: filepath.Join(dir, "values.yaml"), data: []byte("new\n")},
+			{path: filepath.Join(dir, "values-dev.yaml"), data: []byte("dev\n")},
+			{path: filepath.Join(dir, "values-prod.yaml"), data: []byte("new prod\n")},
+		}
+	}
+
+	t.Run("writes every file", func(t *testing.T) {
+		dir, writes := setup(t)
+		require.NoError(t, writeFilesAtomic(writes, defaultFileMode))
+		for _, write := range writes {
+			content, err := os.ReadFile(write.path)
+			require.NoError(t, err)
+			assert.Equal(t, string(write.data), string(content))
+		}
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 3, "temporary files should be removed")
+	})
+
+	t.Run("rolls back a failed rename", func(t *testing.T) {
+		dir, writes := setup(t)
+		failRename(t, filepath.Join(dir, "values-prod.yaml"))
+
+		err := writeFilesAtomic(writes, defaultFileMode)
+		var rollback *RollbackError
+		require.ErrorAs(t, err, &rollback)
+		assert.Equal(t, []string{filepath.Join(dir, "values.yaml"), filepath.Join(dir, "values-dev.yaml")}, rollback.Restored)
+		assert.ErrorContains(t, err, "writing "+filepath.Join(dir, "values-prod.yaml")+": disk full; restored ")
+
+		content, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "old\n", string(content))
+		content, err = os.ReadFile(filepath.Join(dir, "values-prod.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "prod\n", string(content))
+		assert.NoFileExists(t, filepath.Join(dir, "values-dev.yaml"), "created files should be removed")
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2, "temporary files should be removed")
+	})
+
+	t.Run("first rename fails", func(t *testing.T) {
+		dir, writes := setup(t)
+		failRename(t, filepath.Join(dir, "values.yaml"))
+
+		err := writeFilesAtomic(writes, defaultFileMode)
+		var rollback *RollbackError
+		assert.False(t, errors.As(err, &rollback), "nothing was replaced")
+		assert.ErrorContains(t, err, "disk full")
+	})
+}
@@ -0,0 +1,96 @@
+package shcv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// BackupSuffix is appended to the name of a file to name its backup
+const BackupSuffix = ".bak"
+
+// backup saves the content of a file next to it under BackupSuffix before the
+// run modifies it for the first time, if backups are enabled with WithBackup.
+// Files that do not exist yet are recorded as created by the run.
+func (c *Chart) backup(path string) error {
+	if !c.config.Backup {
+		return nil
+	}
+	if _, ok := c.backups[path]; ok {
+		return nil
+	}
+	if c.backups == nil {
+		c.backups = make(map[string]string)
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		c.backups[path] = ""
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("backing up %s: %w", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("backing up %s: %w", path, err)
+	}
+	backup := path + BackupSuffix
+	if err := writeFileAtomic(backup, data, fileMode{perm: info.Mode().Perm()}); err != nil {
+		return fmt.Errorf("writing backup %s: %w", backup, err)
+	}
+	c.backups[path] = backup
+
+	c.config.logger().Info("backed up file", "file", path, "backup", backup)
+	return nil
+}
+
+// RestoreBackups undoes the changes the run made to files backed up with
+// WithBackup, such as after a partial failure: modified files get the content
+// of their backup back and files created by the run are removed. It returns
+// the restored files in order, including when restoring others failed.
+func (c *Chart) RestoreBackups() ([]string, error) {
+	paths := make([]string, 0, len(c.backups))
+	for path := range c.backups {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var restored []string
+	var errs []error
+	err := c.withLock(func() error {
+		for _, path := range paths {
+			if err := restoreBackup(path, c.backups[path]); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			delete(c.backups, path)
+			restored = append(restored, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, errors.Join(errs...)
+}
+
+// restoreBackup gives a file the content of its backup back, or removes it if
+// it has no backup.
+func restoreBackup(path, backup string) error {
+	if backup == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %w", path, err)
+		}
+		return nil
+	}
+	data, err := os.ReadFile(backup)
+	if err == nil {
+		err = writeFileAtomic(path, data, defaultFileMode)
+	}
+	if err != nil {
+		return fmt.Errorf("restoring %s from %s: %w", path, backup, err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package shcv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackup(t *testing.T) {
+	dir := writeTestChart(t, "# values\nname: app\n", map[string]string{"service.yaml": "{{ .Values.port }}\n"})
+	valuesPath := filepath.Join(dir, "values.yaml")
+
+	chart := loadTestChart(t, dir, WithBackup(true))
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+
+	backup, err := os.ReadFile(valuesPath + BackupSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, "# values\nname: app\n", string(backup))
+	content, err := os.ReadFile(valuesPath)
+	require.NoError(t, err)
+	assert.Equal(t, "# values\nname: app\nport: \"\"\n", string(content))
+
+	restored, err := chart.RestoreBackups()
+	require.NoError(t, err)
+	assert.Equal(t, []string{valuesPath}, restored)
+	content, err = os.ReadFile(valuesPath)
+	require.NoError(t, err)
+	assert.Equal(t, "# values\nname: app\n", string(content))
+}
+
+func TestBackupDisabled(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{"service.yaml": "{{ .Values.port }}\n"})
+	chart := loadTestChart(t, dir)
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+	assert.NoFileExists(t, filepath.Join(dir, "values.yaml"+BackupSuffix))
+
+	restored, err := chart.RestoreBackups()
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+}
+
+func TestRestoreBackupsPartialFailure(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{"service.yaml": "{{ .Values.port }}\n"})
+	chart, err := NewChart(dir, WithBackup(true), WithValuesFileNames([]string{"values-prod.yaml", "values-dev.yaml"}))
+	require.NoError(t, err)
+	require.NoError(t, chart.LoadValueFiles())
+	require.NoError(t, chart.FindTemplates())
+	require.NoError(t, chart.ParseTemplates())
+	chart.ProcessReferences()
+
+	// The last values file cannot be written, so none is
+	devPath := filepath.Join(dir, "values-dev.yaml")
+	require.NoError(t, os.Mkdir(devPath, 0755))
+	require.Error(t, chart.UpdateValueFiles())
+	require.NoFileExists(t, filepath.Join(dir, "values-prod.yaml"))
+
+	restored, err := chart.RestoreBackups()
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "values-prod.yaml"), filepath.Join(dir, "values.yaml")}, restored)
+	content, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\n", string(content))
+	assert.NoFileExists(t, filepath.Join(dir, "values-prod.yaml"))
+}
@@ -0,0 +1,158 @@
+package shcv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runState is the fingerprint of a chart recorded after a successful run.
+type runState struct {
+	// Version is the shcv version that recorded the state
+	Version string `json:"version"`
+	// Config fingerprints the options affecting the result
+	Config string `json:"config"`
+	// Files maps template and values file paths to their stamps
+	Files map[string]fileStamp `json:"files"`
+}
+
+// fileStamp identifies the state of a file without reading its content.
+type fileStamp struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// cachePath returns the path of the cache file, or "" if caching is disabled.
+func (c *Chart) cachePath() string {
+	if c.config.CacheFile == "" {
+		return ""
+	}
+	if filepath.IsAbs(c.config.CacheFile) {
+		return c.config.CacheFile
+	}
+	return filepath.Join(c.Dir, c.config.CacheFile)
+}
+
+// currentState stamps the discovered templates, the values files and the values
+// schema. Files that don't exist are left out of the state, so creating one
+// changes it as well.
+func (c *Chart) currentState() (*runState, error) {
+	fingerprint, err := c.config.fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	state := &runState{Version: Version, Config: fingerprint, Files: make(map[string]fileStamp)}
+
+	paths := append([]string{}, c.Templates...)
+	for _, file := range c.ValuesFiles {
+		paths = append(paths, file.Path)
+	}
+	paths = append(paths, filepath.Join(c.Dir, SchemaFileName))
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading file state: %w", err)
+		}
+		state.Files[path] = fileStamp{Size: info.Size(), ModTime: info.ModTime().UTC()}
+	}
+	return state, nil
+}
+
+// fingerprint returns a digest of the options affecting the result of a run:
+// every serializable option except those only changing how the run is logged,
+// written or cached. The digest keeps values such as those of WithSetValues
+// out of the cache file.
+func (c *config) fingerprint() (string, error) {
+	options := *c
+	options.Verbose, options.CacheFile, options.Backup, options.DryRun, options.LockTimeout = false, "", false, false, 0
+	data, err := json.Marshal(&options)
+	if err != nil {
+		return "", fmt.Errorf("fingerprinting options: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// cacheable reports whether the options can be fingerprinted. Injectors, hooks
+// and a value prompt are code whose behavior a recorded run cannot capture.
+func (c *config) cacheable() bool {
+	hooks := c.Hooks
+	return len(c.Injectors) == 0 && c.ValuePrompt == nil && hooks.OnTemplateParsed == nil &&
+		hooks.OnReferenceFound == nil && hooks.OnValueAdded == nil && hooks.OnFileWritten == nil
+}
+
+// Unchanged reports whether no template, values file or values schema changed
+// since the run last recorded with RecordRun. It must be called after
+// FindTemplates and always returns false when caching is disabled, no run has
+// been recorded yet, or injectors, hooks or a value prompt are set.
+func (c *Chart) Unchanged() (bool, error) {
+	path := c.cachePath()
+	if path == "" || !c.config.cacheable() {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading cache file: %w", err)
+	}
+
+	var recorded runState
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		// A corrupt cache only disables the fast path
+		return false, nil
+	}
+
+	current, err := c.currentState()
+	if err != nil {
+		return false, err
+	}
+
+	if recorded.Version != current.Version || recorded.Config != current.Config ||
+		len(recorded.Files) != len(current.Files) {
+		return false, nil
+	}
+	for path, stamp := range current.Files {
+		previous, ok := recorded.Files[path]
+		if !ok || previous.Size != stamp.Size || !previous.ModTime.Equal(stamp.ModTime) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// RecordRun stores the current state of the chart files in the cache file so
+// the next run can detect that nothing changed. It does nothing when caching is
+// disabled.
+func (c *Chart) RecordRun() error {
+	path := c.cachePath()
+	if path == "" {
+		return nil
+	}
+
+	state, err := c.currentState()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding cache: %w", err)
+	}
+	if err := c.withLock(func() error { return writeFileAtomic(path, data, defaultFileMode) }); err != nil {
+		return fmt.Errorf("writing cache file: %w", err)
+	}
+
+	c.config.logger().Info("recorded run state", "file", path)
+	return nil
+}
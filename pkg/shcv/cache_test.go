@@ -0,0 +1,178 @@
+package shcv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChart_Unchanged(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		dir := writeTestChart(t, "key: value\n", map[string]string{"a.yaml": "{{ .Values.key }}\n"})
+		chart := loadTestChart(t, dir)
+		require.NoError(t, chart.RecordRun())
+
+		unchanged, err := chart.Unchanged()
+		require.NoError(t, err)
+		assert.False(t, unchanged)
+		assert.NoFileExists(t, filepath.Join(dir, ".shcv-cache.json"))
+	})
+
+	t.Run("no recorded run", func(t *testing.T) {
+		dir := writeTestChart(t, "key: value\n", map[string]string{"a.yaml": "{{ .Values.key }}\n"})
+		chart := loadTestChart(t, dir, WithCacheFile(".shcv-cache.json"))
+
+		unchanged, err := chart.Unchanged()
+		require.NoError(t, err)
+		assert.False(t, unchanged)
+	})
+
+	t.Run("recorded run", func(t *testing.T) {
+		dir := writeTestChart(t, "key: value\n", map[string]string{"a.yaml": "{{ .Values.key }}\n"})
+		chart := loadTestChart(t, dir, WithCacheFile(".shcv-cache.json"))
+		require.NoError(t, chart.RecordRun())
+		assert.FileExists(t, filepath.Join(dir, ".shcv-cache.json"))
+
+		next := loadTestChart(t, dir, WithCacheFile(".shcv-cache.json"))
+		unchanged, err := next.Unchanged()
+		require.NoError(t, err)
+		assert.True(t, unchanged)
+	})
+
+	modifications := map[string]func(t *testing.T, dir string){
+		"template modified": func(t *testing.T, dir string) {
+			path := filepath.Join(dir, "templates", "a.yaml")
+			require.NoError(t, os.WriteFile(path, []byte("{{ .Values.other }}\n"), 0644))
+			later := time.Now().Add(time.Minute)
+			require.NoError(t, os.Chtimes(path, later, later))
+		},
+		"template added": func(t *testing.T, dir string) {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "templates", "b.yaml"), []byte("{{ .Values.b }}\n"), 0644))
+		},
+		"values file removed": func(t *testing.T, dir string) {
+			require.NoError(t, os.Remove(filepath.Join(dir, "values.yaml")))
+		},
+		"schema added": func(t *testing.T, dir string) {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, SchemaFileName), []byte("{}\n"), 0644))
+		},
+		"corrupt cache": func(t *testing.T, dir string) {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, ".shcv-cache.json"), []byte("{"), 0644))
+		},
+	}
+
+	for name, modify := range modifications {
+		t.Run(name, func(t *testing.T) {
+			dir := writeTestChart(t, "key: value\n", map[string]string{"a.yaml": "{{ .Values.key }}\n"})
+			chart := loadTestChart(t, dir, WithCacheFile(".shcv-cache.json"))
+			require.NoError(t, chart.RecordRun())
+
+			modify(t, dir)
+
+			next := loadTestChart(t, dir, WithCacheFile(".shcv-cache.json"))
+			unchanged, err := next.Unchanged()
+			require.NoError(t, err)
+			assert.False(t, unchanged)
+		})
+	}
+
+	t.Run("schema edited", func(t *testing.T) {
+		dir := writeTestChart(t, "key: value\n", map[string]string{"a.yaml": "{{ .Values.key }}\n"})
+		schema := filepath.Join(dir, SchemaFileName)
+		require.NoError(t, os.WriteFile(schema, []byte("{}\n"), 0644))
+		chart := loadTestChart(t, dir, WithCacheFile(".shcv-cache.json"))
+		require.NoError(t, chart.RecordRun())
+
+		require.NoError(t, os.WriteFile(schema, []byte(`{"required": ["key"]}`+"\n"), 0644))
+		next := loadTestChart(t, dir, WithCacheFile(".shcv-cache.json"))
+		unchanged, err := next.Unchanged()
+		require.NoError(t, err)
+		assert.False(t, unchanged)
+
+		require.NoError(t, next.RecordRun())
+		require.NoError(t, os.Remove(schema))
+		unchanged, err = loadTestChart(t, dir, WithCacheFile(".shcv-cache.json")).Unchanged()
+		require.NoError(t, err)
+		assert.False(t, unchanged)
+	})
+
+	t.Run("different options", func(t *testing.T) {
+		dir := writeTestChart(t, "key: value\n", map[string]string{"a.yaml": "{{ .Values.key }}\n"})
+		chart := loadTestChart(t, dir, WithCacheFile(".shcv-cache.json"))
+		require.NoError(t, chart.RecordRun())
+
+		next := loadTestChart(t, dir, WithCacheFile(".shcv-cache.json"), WithValuesFileNames([]string{"values-prod.yaml"}))
+		unchanged, err := next.Unchanged()
+		require.NoError(t, err)
+		assert.False(t, unchanged)
+	})
+
+	// Every option changing the result of a run invalidates the cache
+	for name, opt := range map[string]Option{
+		"strict":           WithStrict(true),
+		"error mode":       WithErrorMode(ErrorModeCollect),
+		"set values":       WithSetValues("db.password=secret"),
+		"placeholder":      WithPlaceholder("TODO"),
+		"sort keys":        WithSortKeys(true),
+		"protected paths":  WithProtectedPaths("ci.*"),
+		"strategy":         WithDeploymentStrategyInjection(true),
+		"fail on conflict": WithFailOnConflict(true),
+	} {
+		t.Run("option "+name, func(t *testing.T) {
+			dir := writeTestChart(t, "key: value\n", map[string]string{"a.yaml": "{{ .Values.key }}\n"})
+			chart := loadTestChart(t, dir, WithCacheFile(".shcv-cache.json"))
+			require.NoError(t, chart.RecordRun())
+
+			next := loadTestChart(t, dir, WithCacheFile(".shcv-cache.json"), opt)
+			unchanged, err := next.Unchanged()
+			require.NoError(t, err)
+			assert.False(t, unchanged)
+		})
+	}
+
+	// Options that are code are not fingerprinted, so they always disable the cache
+	for name, opt := range map[string]Option{
+		"injectors":    WithInjectors(serviceTypeInjector{}),
+		"hooks":        WithHooks(Hooks{OnFileWritten: func(string) {}}),
+		"value prompt": WithValuePrompt(func(ValueRef) (any, bool) { return nil, false }),
+	} {
+		t.Run("option "+name+" in both runs", func(t *testing.T) {
+			dir := writeTestChart(t, "key: value\n", map[string]string{"a.yaml": "{{ .Values.key }}\n"})
+			chart := loadTestChart(t, dir, WithCacheFile(".shcv-cache.json"), opt)
+			require.NoError(t, chart.RecordRun())
+
+			next := loadTestChart(t, dir, WithCacheFile(".shcv-cache.json"), opt)
+			unchanged, err := next.Unchanged()
+			require.NoError(t, err)
+			assert.False(t, unchanged)
+		})
+	}
+
+	t.Run("logging options", func(t *testing.T) {
+		dir := writeTestChart(t, "key: value\n", map[string]string{"a.yaml": "{{ .Values.key }}\n"})
+		chart := loadTestChart(t, dir, WithCacheFile(".shcv-cache.json"))
+		require.NoError(t, chart.RecordRun())
+
+		next := loadTestChart(t, dir, WithCacheFile(".shcv-cache.json"), WithVerbose(true))
+		unchanged, err := next.Unchanged()
+		require.NoError(t, err)
+		assert.True(t, unchanged)
+	})
+
+	t.Run("absolute cache path", func(t *testing.T) {
+		dir := writeTestChart(t, "key: value\n", map[string]string{"a.yaml": "{{ .Values.key }}\n"})
+		cacheFile := filepath.Join(t.TempDir(), "cache.json")
+		chart := loadTestChart(t, dir, WithCacheFile(cacheFile))
+		require.NoError(t, chart.RecordRun())
+		assert.FileExists(t, cacheFile)
+	})
+
+	t.Run("unwritable cache", func(t *testing.T) {
+		dir := writeTestChart(t, "key: value\n", map[string]string{"a.yaml": "{{ .Values.key }}\n"})
+		chart := loadTestChart(t, dir, WithCacheFile(filepath.Join(dir, "missing", "cache.json")))
+		assert.ErrorContains(t, chart.RecordRun(), "writing cache file")
+	})
+}
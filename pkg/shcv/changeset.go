@@ -0,0 +1,175 @@
+package shcv
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+)
+
+// Reasons of a Change
+const (
+	// ChangeMissing adds the value of a template reference the values file
+	// does not define
+	ChangeMissing = "missing"
+	// ChangeInjected adds a value of an injector, such as the deployment
+	// strategy, see WithInjectors
+	ChangeInjected = "injected"
+	// ChangeUpdated replaces a value, or adds one no reference is missing,
+	// such as a linked value following its source, see WithValueLinks
+	ChangeUpdated = "updated"
+	// ChangeRemoved removes a value, such as an unused value, see PruneUnused
+	ChangeRemoved = "removed"
+)
+
+// Change is a change of the run to a value of a values file.
+type Change struct {
+	// File is the values file
+	File string `json:"file"`
+	// Path is the value path, such as image.tag
+	Path string `json:"path"`
+	// Old is the value as loaded, nil if the file did not define it
+	Old any `json:"old,omitempty"`
+	// New is the value written, nil if the value is removed
+	New any `json:"new,omitempty"`
+	// Reason is ChangeMissing, ChangeInjected, ChangeUpdated or ChangeRemoved
+	Reason string `json:"reason"`
+}
+
+// String describes the change.
+func (c Change) String() string {
+	switch c.Reason {
+	case ChangeRemoved:
+		return fmt.Sprintf("%s: remove %s (%v)", c.File, c.Path, c.Old)
+	case ChangeUpdated:
+		return fmt.Sprintf("%s: update %s from %v to %v", c.File, c.Path, c.Old, c.New)
+	}
+	return fmt.Sprintf("%s: add %s = %v (%s)", c.File, c.Path, c.New, c.Reason)
+}
+
+// PlanChanges returns the changes of the run to the values of the values
+// files, without writing them, such as for a user interface listing them for
+// review: the values ProcessReferences adds, in the order they were added,
+// followed by the other values the run replaced or removed, sorted by path. It
+// must be called after ProcessReferences, and PruneUnused if values are
+// pruned, with WithDryRun to keep the templates unchanged too. The changes,
+// or a selection of them, are written by Apply.
+func (c *Chart) PlanChanges() []Change {
+	var changes []Change
+	for i := range c.ValuesFiles {
+		file := &c.ValuesFiles[i]
+		added := make(map[string]bool, len(file.Added))
+		for _, path := range file.Added {
+			value, ok := lookupValue(file.Values, path)
+			if !ok || added[path] {
+				continue
+			}
+			added[path] = true
+			reason := ChangeMissing
+			if slices.Contains(file.injected, path) {
+				reason = ChangeInjected
+			}
+			changes = append(changes, Change{File: file.Path, Path: path, New: copyValue(value), Reason: reason})
+		}
+		changes = append(changes, diffValues(file.Path, "", file.original, file.Values, added)...)
+	}
+	return changes
+}
+
+// diffValues returns the changes from the old to the new values below prefix,
+// other than the added paths.
+func diffValues(file, prefix string, old, new map[string]any, added map[string]bool) []Change {
+	keys := make([]string, 0, len(old)+len(new))
+	for key := range old {
+		keys = append(keys, key)
+	}
+	for key := range new {
+		if _, ok := old[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []Change
+	for _, key := range keys {
+		path := appendKey(prefix, key)
+		if added[path] {
+			continue
+		}
+		o, inOld := old[key]
+		n, inNew := new[key]
+		oldMap, oldIsMap := o.(map[string]any)
+		newMap, newIsMap := n.(map[string]any)
+		switch {
+		case inOld && inNew && reflect.DeepEqual(o, n):
+		case newIsMap && (oldIsMap || !inOld):
+			changes = append(changes, diffValues(file, path, oldMap, newMap, added)...)
+		case !inNew:
+			changes = append(changes, Change{File: file, Path: path, Old: copyValue(o), Reason: ChangeRemoved})
+		default:
+			changes = append(changes, Change{File: file, Path: path, Old: copyValue(o), New: copyValue(n), Reason: ChangeUpdated})
+		}
+	}
+	return changes
+}
+
+// Apply writes a selection of the changes of PlanChanges, starting from the
+// values as loaded: the values files are written with only the given changes,
+// even in dry-run mode, as with UpdateValueFiles. The templates ProcessReferences
+// modified in dry-run mode for injectors, such as the deployment strategy
+// injector, are written along with the injected values. It is an error for a change to name a file that
+// is not a values file of the chart.
+func (c *Chart) Apply(changes []Change) error {
+	files := make(map[string]*ValueFile, len(c.ValuesFiles))
+	for i := range c.ValuesFiles {
+		files[c.ValuesFiles[i].Path] = &c.ValuesFiles[i]
+	}
+	for _, change := range changes {
+		if files[change.File] == nil {
+			return fmt.Errorf("applying change of %s: %s is not a values file of the chart", change.Path, change.File)
+		}
+	}
+
+	injected := false
+	for _, file := range files {
+		values, _ := copyValue(file.original).(map[string]any)
+		if values == nil {
+			values = make(map[string]any)
+		}
+		var added []string
+		for _, change := range changes {
+			if change.File != file.Path {
+				continue
+			}
+			switch change.Reason {
+			case ChangeRemoved:
+				deleteNestedValue(values, change.Path)
+			case ChangeMissing, ChangeInjected:
+				added = append(added, change.Path)
+				injected = injected || change.Reason == ChangeInjected
+				fallthrough
+			default:
+				setNestedValue(values, change.Path, copyValue(change.New))
+			}
+		}
+		file.Values, file.Changed = values, file.rewrite || !reflect.DeepEqual(values, file.original)
+		file.Added = slices.DeleteFunc(file.Added, func(path string) bool { return !slices.Contains(added, path) })
+		for path := range file.aliases {
+			if !slices.Contains(added, path) {
+				delete(file.aliases, path)
+			}
+		}
+	}
+
+	var templates []FileChange
+	if injected {
+		templates = c.templateChanges
+	}
+	if err := c.withLock(func() error { return c.writeFiles(templates) }); err != nil {
+		return err
+	}
+	if injected {
+		c.templateChanges = nil
+	}
+	return nil
+}
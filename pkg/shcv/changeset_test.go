@@ -0,0 +1,94 @@
+package shcv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanChanges(t *testing.T) {
+	values := "name: app\nold: 1\ngateway:\n  port: 8080\nservice:\n  port: 80\n"
+	dir := writeTestChart(t, values, map[string]string{
+		"deployment.yaml": "kind: Deployment\nspec:\n  replicas: {{ .Values.replicas | default 2 }}\n",
+		"service.yaml":    "name: {{ .Values.name }}\nport: {{ .Values.service.port }}\nimage: {{ .Values.image.tag }}\n{{ .Values.gateway.port }}\n",
+	})
+	valuesPath := filepath.Join(dir, "values.yaml")
+	chart := loadTestChart(t, dir, WithDryRun(true), WithDeploymentStrategyInjection(true), WithValueLinks(ValueLink{Path: "service.port", Source: "gateway.port"}))
+	chart.ProcessReferences()
+	_, err := chart.PruneUnused()
+	require.NoError(t, err)
+
+	changes := chart.PlanChanges()
+	assert.Equal(t, []Change{
+		{File: valuesPath, Path: strategyPath, New: map[string]any{
+			"type":          "RollingUpdate",
+			"rollingUpdate": map[string]any{"maxSurge": 1, "maxUnavailable": 0},
+		}, Reason: ChangeInjected},
+		{File: valuesPath, Path: "replicas", New: 2, Reason: ChangeMissing},
+		{File: valuesPath, Path: "image.tag", New: "", Reason: ChangeMissing},
+		{File: valuesPath, Path: "old", Old: float64(1), Reason: ChangeRemoved},
+		{File: valuesPath, Path: "service.port", Old: float64(80), New: float64(8080), Reason: ChangeUpdated},
+	}, changes)
+	assert.Equal(t, valuesPath+": add replicas = 2 (missing)", changes[1].String())
+	assert.Equal(t, valuesPath+": update service.port from 80 to 8080", changes[4].String())
+
+	// Nothing is written
+	content, err := os.ReadFile(valuesPath)
+	require.NoError(t, err)
+	assert.Equal(t, values, string(content))
+}
+
+func TestApply(t *testing.T) {
+	template := "kind: Deployment\nspec:\n  replicas: {{ .Values.replicas | default 2 }}\n  image: {{ .Values.image.tag }}\n"
+	dir := writeTestChart(t, "name: app\nold: 1\n", map[string]string{"deployment.yaml": template})
+	valuesPath := filepath.Join(dir, "values.yaml")
+	templatePath := filepath.Join(dir, "templates", "deployment.yaml")
+
+	plan := func() []Change {
+		chart := loadTestChart(t, dir, WithDryRun(true), WithDeploymentStrategyInjection(true))
+		chart.ProcessReferences()
+		_, err := chart.PruneUnused()
+		require.NoError(t, err)
+		return chart.PlanChanges()
+	}
+
+	// Only the selected changes are written
+	chart := loadTestChart(t, dir, WithDryRun(true), WithDeploymentStrategyInjection(true))
+	chart.ProcessReferences()
+	var selected []Change
+	for _, change := range chart.PlanChanges() {
+		if change.Path == "replicas" {
+			selected = append(selected, change)
+		}
+	}
+	require.Len(t, selected, 1)
+	require.NoError(t, chart.Apply(selected))
+	content, err := os.ReadFile(valuesPath)
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\nold: 1\nreplicas: 2\n", string(content))
+	content, err = os.ReadFile(templatePath)
+	require.NoError(t, err)
+	assert.Equal(t, template, string(content))
+	assert.Len(t, plan(), 4)
+
+	// The injected strategy is written with its template, and removals apply
+	chart = loadTestChart(t, dir, WithDryRun(true), WithDeploymentStrategyInjection(true))
+	chart.ProcessReferences()
+	_, err = chart.PruneUnused()
+	require.NoError(t, err)
+	require.NoError(t, chart.Apply(chart.PlanChanges()))
+	content, err = os.ReadFile(templatePath)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "strategy:")
+	content, err = os.ReadFile(valuesPath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "old:")
+	assert.Contains(t, string(content), "deployment:\n  strategy:\n")
+	assert.Empty(t, plan())
+
+	err = chart.Apply([]Change{{File: filepath.Join(dir, "other.yaml"), Path: "a", New: 1, Reason: ChangeMissing}})
+	assert.ErrorContains(t, err, "is not a values file of the chart")
+}
@@ -0,0 +1,139 @@
+package shcv
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// missingReference is a template reference whose path is not defined in a values file.
+type missingReference struct {
+	// File is the path of the values file missing the value
+	File string
+	// Ref is the first reference to the missing path
+	Ref ValueRef
+}
+
+// missingReferences returns, for every values file, the referenced paths it does
+// not define. Each path is reported once per file, at its first reference. With
+// layered values, the paths no layer defines are reported for the base layer.
+func (c *Chart) missingReferences() []missingReference {
+	var missing []missingReference
+	for i, file := range c.ValuesFiles {
+		if !c.receivesAdditions(i) {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, ref := range c.References {
+			if seen[ref.Path] || ref.Ignored || c.defined(i, ref.Path) {
+				continue
+			}
+			seen[ref.Path] = true
+			missing = append(missing, missingReference{File: file.Path, Ref: ref})
+		}
+	}
+	return missing
+}
+
+// MissingValues returns a finding for every referenced path a values file does
+// not define, reported once per file at its first reference. With layered
+// values, a path is only missing if no values file defines it. The values files
+// must have been loaded and the templates parsed.
+func (c *Chart) MissingValues() []Finding {
+	var findings []Finding
+	for _, m := range c.missingReferences() {
+		findings = append(findings, Finding{
+			Check:      CheckMissingValues,
+			Path:       m.Ref.Path,
+			SourceFile: m.Ref.SourceFile,
+			LineNumber: m.Ref.LineNumber,
+			Message:    fmt.Sprintf("%s does not define .Values.%s", c.relPath(m.File), m.Ref.Path),
+		})
+	}
+	return findings
+}
+
+// CheckChart fails the test when the chart in dir is out of sync, i.e. when a
+// template references a value that one of the values files doesn't define. It
+// never modifies the chart, so Go repositories embedding charts can enforce the
+// sync in their normal test suite:
+//
+//	func TestChartValues(t *testing.T) {
+//		shcv.CheckChart(t, "deploy/chart")
+//	}
+//
+// Findings of the chart coherence checks are logged without failing the test.
+func CheckChart(t testing.TB, dir string, opts ...Option) {
+	t.Helper()
+
+	chart, err := NewChart(dir, opts...)
+	if err != nil {
+		t.Fatalf("shcv: %v", err)
+		return
+	}
+	if err := chart.LoadValueFiles(); err != nil {
+		t.Fatalf("shcv: loading values: %v", err)
+		return
+	}
+	if err := chart.FindTemplates(); err != nil {
+		t.Fatalf("shcv: finding templates: %v", err)
+		return
+	}
+	if err := chart.ParseTemplates(); err != nil {
+		t.Fatalf("shcv: parsing templates: %v", err)
+		return
+	}
+
+	missing := chart.missingReferences()
+	for _, m := range missing {
+		t.Errorf("shcv: %s does not define .Values.%s (referenced at %s:%d)",
+			chart.relPath(m.File), m.Ref.Path, chart.relPath(m.Ref.SourceFile), m.Ref.LineNumber)
+	}
+	if len(missing) > 0 {
+		t.Logf("shcv: run `shcv %s` to sync the values files", dir)
+	}
+
+	findings, err := chart.RunChecks()
+	if err != nil {
+		t.Fatalf("shcv: checking chart: %v", err)
+		return
+	}
+	for _, finding := range findings {
+		finding.SourceFile = chart.relPath(finding.SourceFile)
+		t.Logf("shcv: warning: %s", finding)
+	}
+}
+
+// relPath returns path relative to the chart directory when possible.
+func (c *Chart) relPath(path string) string {
+	rel, err := filepath.Rel(c.Dir, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// receivesAdditions reports whether missing values are added to the values file
+// at index i: every file does, except the override layers of layered values.
+func (c *Chart) receivesAdditions(i int) bool {
+	return !c.layered() || i == 0
+}
+
+// defined reports whether path is defined for the values file at index i: by
+// the file itself, or with layered values by any layer.
+func (c *Chart) defined(i int, path string) bool {
+	if !c.layered() {
+		return valueExists(c.ValuesFiles[i].Values, path)
+	}
+	for _, file := range c.ValuesFiles {
+		if valueExists(file.Values, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// layered reports whether the values files are layered, see WithLayeredValues.
+func (c *Chart) layered() bool {
+	return c.config != nil && c.config.LayeredValues
+}
@@ -0,0 +1,136 @@
+package shcv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTB records the messages CheckChart reports instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	errors []string
+	logs   []string
+	fatal  bool
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Fatalf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+	r.fatal = true
+}
+
+func (r *recordingTB) Logf(format string, args ...any) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func TestCheckChart(t *testing.T) {
+	t.Run("in sync", func(t *testing.T) {
+		dir := writeTestChart(t, "name: app\nimage:\n  tag: v1\n", map[string]string{
+			"deployment.yaml": "name: {{ .Values.name }}\nimage: {{ .Values.image.tag }}\n",
+		})
+		CheckChart(t, dir)
+	})
+
+	t.Run("out of sync", func(t *testing.T) {
+		dir := writeTestChart(t, "name: app\n", map[string]string{
+			"deployment.yaml": "name: {{ .Values.name }}\nimage: {{ .Values.image.tag }}\ntag: {{ .Values.image.tag }}\n",
+		})
+		rec := &recordingTB{}
+		CheckChart(rec, dir)
+
+		assert.False(t, rec.fatal)
+		assert.Equal(t, []string{
+			"shcv: values.yaml does not define .Values.image.tag (referenced at templates/deployment.yaml:2)",
+		}, rec.errors)
+		assert.Contains(t, rec.logs[0], "to sync the values files")
+	})
+
+	t.Run("does not modify the chart", func(t *testing.T) {
+		dir := writeTestChart(t, "", map[string]string{"a.yaml": "{{ .Values.key }}\n"})
+		CheckChart(&recordingTB{}, dir)
+		assert.NoFileExists(t, dir+"/values.yaml")
+	})
+
+	t.Run("findings are logged", func(t *testing.T) {
+		dir := writeTestChart(t, "host: a\ntlsHost: b\n", map[string]string{
+			"ingress.yaml": "kind: Ingress\nspec:\n  tls:\n    - hosts: [{{ .Values.tlsHost }}]\n  rules:\n    - host: {{ .Values.host }}\n",
+		})
+		rec := &recordingTB{}
+		CheckChart(rec, dir)
+
+		assert.Empty(t, rec.errors)
+		assert.Len(t, rec.logs, 2)
+		assert.Contains(t, rec.logs[0], "shcv: warning: templates/ingress.yaml:")
+	})
+
+	t.Run("invalid chart", func(t *testing.T) {
+		rec := &recordingTB{}
+		CheckChart(rec, "")
+		assert.True(t, rec.fatal)
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		dir := writeTestChart(t, "invalid: : yaml\n", nil)
+		rec := &recordingTB{}
+		CheckChart(rec, dir)
+		assert.True(t, rec.fatal)
+		assert.Contains(t, rec.errors[0], "loading values")
+	})
+
+	t.Run("missing templates", func(t *testing.T) {
+		rec := &recordingTB{}
+		CheckChart(rec, t.TempDir())
+		assert.True(t, rec.fatal)
+		assert.Contains(t, rec.errors[0], "finding templates")
+	})
+}
+
+func TestMissingValues(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{
+		"deployment.yaml": "name: {{ .Values.name }}\nimage: {{ .Values.image.tag }}\ntag: {{ .Values.image.tag }}\n",
+	})
+	chart, err := NewChart(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, chart.LoadValueFiles())
+	assert.NoError(t, chart.FindTemplates())
+	assert.NoError(t, chart.ParseTemplates())
+
+	assert.Equal(t, []Finding{{
+		Check:      CheckMissingValues,
+		Path:       "image.tag",
+		SourceFile: dir + "/templates/deployment.yaml",
+		LineNumber: 2,
+		Message:    "values.yaml does not define .Values.image.tag",
+	}}, chart.MissingValues())
+}
+
+func TestMissingValuesLayered(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{
+		"deployment.yaml": "name: {{ .Values.name }}\nimage: {{ .Values.image.tag }}\nreplicas: {{ .Values.replicas }}\n",
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "values-prod.yaml"), []byte("replicas: 3\n"), 0644))
+
+	// Without layering, every values file must define every value
+	chart := loadTestChart(t, dir, WithValuesFileNames([]string{"values-prod.yaml"}))
+	assert.Len(t, chart.MissingValues(), 4)
+
+	// With layering, a value any layer defines is not missing
+	chart = loadTestChart(t, dir, WithValuesFileNames([]string{"values-prod.yaml"}), WithLayeredValues(true))
+	assert.Equal(t, []Finding{{
+		Check:      CheckMissingValues,
+		Path:       "image.tag",
+		SourceFile: filepath.Join(dir, "templates/deployment.yaml"),
+		LineNumber: 2,
+		Message:    "values.yaml does not define .Values.image.tag",
+	}}, chart.MissingValues())
+}
@@ -0,0 +1,426 @@
+package shcv
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Finding describes a potential chart misconfiguration reported by a check.
+type Finding struct {
+	// Check is the name of the check that produced the finding
+	Check string
+	// Path is the value path the finding refers to
+	Path string
+	// SourceFile is the template file where the problem was found
+	SourceFile string
+	// LineNumber is the line number in the source file
+	LineNumber int
+	// Message is a human-readable description of the problem
+	Message string
+	// Suggestion is the path Path should be renamed to, for findings that can
+	// be fixed with RenameValues
+	Suggestion string
+	// ValuesFile and ValuesLine locate the definition of the value in a values
+	// file, for findings about the values files as well as the templates
+	ValuesFile string
+	ValuesLine int
+}
+
+// String returns the finding formatted as file:line: message
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s", f.SourceFile, f.LineNumber, f.Message)
+}
+
+// Check names reported in Finding.Check
+const (
+	CheckIngressTLS = "ingress-tls"
+	CheckPorts      = "ports"
+	CheckPaths      = "paths"
+	CheckPostRender = "post-render"
+	CheckSchema     = "schema"
+	CheckTypeDrift  = "type-drift"
+
+	// CheckDefineValues is only run by RunChecks with WithDefineValuesPolicy
+	CheckDefineValues = "define-values"
+
+	// CheckNaming is only run by RunChecks with WithNamingPolicy
+	CheckNaming = "naming"
+
+	// CheckMissingValues is reported by MissingValues rather than RunChecks
+	CheckMissingValues = "missing-values"
+)
+
+// workloadKinds are the kinds whose pod templates declare container ports
+var workloadKinds = map[string]bool{
+	"Pod":         true,
+	"Deployment":  true,
+	"StatefulSet": true,
+	"DaemonSet":   true,
+	"ReplicaSet":  true,
+	"Job":         true,
+	"CronJob":     true,
+}
+
+// probeKeys are the container fields holding probes whose port must match a container port
+var probeKeys = []string{"livenessProbe", "readinessProbe", "startupProbe"}
+
+// RunChecks runs all chart coherence checks and returns their combined findings.
+// Opt-in policies run only when enabled by their option.
+func (c *Chart) RunChecks() ([]Finding, error) {
+	checks := []func() ([]Finding, error){
+		c.CheckIngressTLS,
+		c.CheckPorts,
+		c.CheckPaths,
+		c.CheckPostRender,
+		c.CheckSchema,
+		c.CheckTypeDrift,
+	}
+	if c.config.DefineValuesPolicy {
+		checks = append(checks, c.CheckDefineValues)
+	}
+	if c.config.NamingPolicy != nil {
+		checks = append(checks, c.CheckNaming)
+	}
+
+	var findings []Finding
+	for _, check := range checks {
+		found, err := check()
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, found...)
+	}
+	return findings, nil
+}
+
+// templateModel is a parsed template: its outline and the references found on each line.
+type templateModel struct {
+	path  string
+	lines []manifestLine
+	refs  map[int][]ValueRef
+}
+
+// loadTemplateModels reads and outlines all discovered templates.
+func (c *Chart) loadTemplateModels() ([]templateModel, error) {
+	models := make([]templateModel, 0, len(c.Templates))
+	for _, template := range c.Templates {
+		content, err := os.ReadFile(template)
+		if err != nil {
+			return nil, fmt.Errorf("reading template %s: %w", template, err)
+		}
+
+		model := templateModel{
+			path:  template,
+			lines: outlineManifest(string(content)),
+			refs:  make(map[int][]ValueRef),
+		}
+		for _, ref := range ParseFile(string(content), template) {
+			model.refs[ref.LineNumber] = append(model.refs[ref.LineNumber], ref)
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// resolvedValue returns the effective value for a reference: the first values file
+// defining the path wins, or the last one with layered values as with helm -f,
+// falling back to the template default.
+func (c *Chart) resolvedValue(ref ValueRef) (any, bool) {
+	for i := range c.ValuesFiles {
+		file := c.ValuesFiles[i]
+		if c.layered() {
+			file = c.ValuesFiles[len(c.ValuesFiles)-1-i]
+		}
+		if v, ok := lookupValue(file.Values, ref.Path); ok {
+			return v, true
+		}
+	}
+	if ref.DefaultValue != "" {
+		return ref.DefaultValue, true
+	}
+	return nil, false
+}
+
+// CheckIngressTLS cross-checks the hosts of Ingress manifests. When a TLS section
+// is configured, every host referenced in the rules via values must have a
+// matching TLS host and vice versa. Hosts match when they use the same value path
+// or resolve to the same value.
+func (c *Chart) CheckIngressTLS() ([]Finding, error) {
+	models, err := c.loadTemplateModels()
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, model := range models {
+		var ruleHosts, tlsHosts []ValueRef
+		hasTLS := false
+		doc := -1
+
+		// flush compares the hosts collected for the current document
+		flush := func() {
+			if hasTLS {
+				findings = append(findings, c.unmatchedHosts(ruleHosts, tlsHosts, "has no matching TLS host")...)
+				findings = append(findings, c.unmatchedHosts(tlsHosts, ruleHosts, "has no matching rule host")...)
+			}
+			ruleHosts, tlsHosts, hasTLS = nil, nil, false
+		}
+
+		for _, line := range model.lines {
+			if line.Doc != doc {
+				flush()
+				doc = line.Doc
+			}
+			if line.Kind != "Ingress" {
+				continue
+			}
+			if line.Key == "tls" || line.under("tls") {
+				hasTLS = true
+			}
+			for _, ref := range model.refs[line.Number] {
+				switch view := line.at(ref.Column); {
+				case view.Key == "tls" || view.under("tls"):
+					hasTLS = true
+					if view.Key == "hosts" || view.under("hosts") {
+						tlsHosts = append(tlsHosts, ref)
+					}
+				case view.Key == "host" && view.under("rules"):
+					ruleHosts = append(ruleHosts, ref)
+				}
+			}
+		}
+		flush()
+	}
+
+	return findings, nil
+}
+
+// unmatchedHosts reports every host in hosts that has no counterpart in others.
+func (c *Chart) unmatchedHosts(hosts, others []ValueRef, reason string) []Finding {
+	var findings []Finding
+	for _, host := range hosts {
+		if c.hostMatches(host, others) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Check:      CheckIngressTLS,
+			Path:       host.Path,
+			SourceFile: host.SourceFile,
+			LineNumber: host.LineNumber,
+			Message:    fmt.Sprintf("ingress host .Values.%s %s", host.Path, reason),
+		})
+	}
+	return findings
+}
+
+// hostMatches reports whether host refers to the same host as any of others.
+func (c *Chart) hostMatches(host ValueRef, others []ValueRef) bool {
+	value, resolved := c.resolvedValue(host)
+	resolved = resolved && fmt.Sprint(value) != ""
+	for _, other := range others {
+		if other.Path == host.Path {
+			return true
+		}
+		if otherValue, ok := c.resolvedValue(other); ok && resolved &&
+			fmt.Sprint(otherValue) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// portUse is a port field whose value comes from a value reference.
+type portUse struct {
+	role  string
+	ref   ValueRef
+	value int
+}
+
+// CheckPorts correlates the ports taken from values across the chart. Service
+// target ports and probe ports must match one of the container ports declared by
+// the chart's workloads; a finding is reported for every port whose effective
+// value does not. Named ports and ports without a resolvable numeric value are
+// ignored.
+func (c *Chart) CheckPorts() ([]Finding, error) {
+	models, err := c.loadTemplateModels()
+	if err != nil {
+		return nil, err
+	}
+
+	containerPorts := make(map[int]bool)
+	var uses []portUse
+	for _, model := range models {
+		for _, line := range model.lines {
+			for _, ref := range model.refs[line.Number] {
+				role := portRole(line.at(ref.Column))
+				if role == "" {
+					continue
+				}
+				value, ok := c.resolvedValue(ref)
+				if !ok {
+					continue
+				}
+				port, err := strconv.Atoi(fmt.Sprint(value))
+				if err != nil {
+					continue
+				}
+				if role == "containerPort" {
+					containerPorts[port] = true
+					continue
+				}
+				uses = append(uses, portUse{role: role, ref: ref, value: port})
+			}
+		}
+	}
+
+	if len(containerPorts) == 0 {
+		return nil, nil
+	}
+
+	sorted := make([]int, 0, len(containerPorts))
+	for port := range containerPorts {
+		sorted = append(sorted, port)
+	}
+	sort.Ints(sorted)
+	known := make([]string, len(sorted))
+	for i, port := range sorted {
+		known[i] = strconv.Itoa(port)
+	}
+
+	var findings []Finding
+	for _, use := range uses {
+		if containerPorts[use.value] {
+			continue
+		}
+		findings = append(findings, Finding{
+			Check:      CheckPorts,
+			Path:       use.ref.Path,
+			SourceFile: use.ref.SourceFile,
+			LineNumber: use.ref.LineNumber,
+			Message: fmt.Sprintf("%s .Values.%s (%d) does not match any containerPort (%s)",
+				use.role, use.ref.Path, use.value, strings.Join(known, ", ")),
+		})
+	}
+	return findings, nil
+}
+
+// portRole returns the role of the port declared on a manifest line, if any.
+func portRole(line manifestLine) string {
+	switch {
+	case line.Kind == "Service" && line.Key == "targetPort":
+		return "service targetPort"
+	case workloadKinds[line.Kind] && line.Key == "containerPort":
+		return "containerPort"
+	case workloadKinds[line.Kind] && line.Key == "port":
+		for _, probe := range probeKeys {
+			if line.under(probe) {
+				return probe + " port"
+			}
+		}
+	}
+	return ""
+}
+
+// builtinObjects are the objects Helm passes to templates next to .Values
+var builtinObjects = map[string]bool{
+	"Release": true, "Chart": true, "Capabilities": true, "Template": true, "Files": true, "Values": true,
+}
+
+// CheckPaths validates the referenced value paths against the rules Helm applies
+// when rendering, on top of the permissive parser. It reports keys that are not
+// valid template identifiers and must be accessed with index, top-level keys
+// named like Helm's built-in objects, and global used as a scalar although Helm
+// reserves it for a map shared with subcharts. Each path is reported once.
+func (c *Chart) CheckPaths() ([]Finding, error) {
+	var findings []Finding
+	seen := make(map[string]bool)
+	invalid := make(map[string]bool)
+	contents := make(map[string]string)
+	for _, ref := range c.References {
+		written, err := writtenPath(ref, contents)
+		if err != nil {
+			return nil, err
+		}
+		if seen[ref.Path] && (invalid[ref.Path] || written == "") {
+			continue
+		}
+
+		report := func(format string, args ...any) {
+			findings = append(findings, Finding{
+				Check:      CheckPaths,
+				Path:       ref.Path,
+				SourceFile: ref.SourceFile,
+				LineNumber: ref.LineNumber,
+				Message:    fmt.Sprintf(format, args...),
+			})
+		}
+
+		// Only keys written in dot form must be identifiers: index .Values "my-key"
+		// reads a key that .Values.my-key can't
+		parts := SplitValuePath(ref.Path)
+		if written != "" {
+			for i, part := range parts[:min(strings.Count(written, ".")+1, len(parts))] {
+				if !isIdentifier(part) {
+					parent := strings.Join(append([]string{"Values"}, parts[:i]...), ".")
+					report("key %q in .Values.%s is not a valid template identifier; use {{ index .%s %q }}",
+						part, ref.Path, parent, part)
+					invalid[ref.Path] = true
+					break
+				}
+			}
+		}
+		if seen[ref.Path] {
+			continue
+		}
+		seen[ref.Path] = true
+		switch {
+		case builtinObjects[parts[0]]:
+			report(".Values.%s is named like the built-in object .%s", ref.Path, parts[0])
+		case ref.Path == "global" && ref.Type != TypeUnknown && ref.Type != TypeMap:
+			report(".Values.global is used as a %s, but Helm reserves it for a map of values shared with subcharts", ref.Type)
+		}
+	}
+	return findings, nil
+}
+
+// writtenPath returns the part of the reference's path written in dot form in
+// the template, e.g. "labels" for get .Values.labels "app" and "" for
+// index .Values "app". Template contents are cached in contents.
+func writtenPath(ref ValueRef, contents map[string]string) (string, error) {
+	content, ok := contents[ref.SourceFile]
+	if !ok {
+		data, err := os.ReadFile(ref.SourceFile)
+		if err != nil {
+			return "", fmt.Errorf("reading template %s: %w", ref.SourceFile, err)
+		}
+		content = string(data)
+		contents[ref.SourceFile] = content
+	}
+
+	start := ref.Column - 1
+	if ref.LineNumber > 1 {
+		start += nthIndex(content, '\n', ref.LineNumber-1) + 1
+	}
+	if start < 0 || start > ref.EndOffset || ref.EndOffset > len(content) {
+		return ref.Path, nil
+	}
+	text := strings.TrimPrefix(content[start:ref.EndOffset], rootPrefix)
+	return strings.TrimPrefix(strings.TrimPrefix(text, strings.TrimSuffix(valuePrefix, ".")), "."), nil
+}
+
+// isIdentifier reports whether s is a valid identifier for template field access:
+// a letter or underscore followed by letters, digits and underscores.
+func isIdentifier(s string) bool {
+	if s == "" || isDigit(s[0]) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isAlphaNumeric(s[i]) && s[i] != '_' {
+			return false
+		}
+	}
+	return true
+}
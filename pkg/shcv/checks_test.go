@@ -0,0 +1,316 @@
+package shcv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestChart creates a chart directory with the given values and templates.
+func writeTestChart(t *testing.T, values string, templates map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "templates"), 0755))
+	if values != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "values.yaml"), []byte(values), 0644))
+	}
+	for name, content := range templates {
+		path := filepath.Join(dir, "templates", name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+// loadTestChart creates a chart and runs discovery and parsing on it.
+func loadTestChart(t *testing.T, dir string, opts ...Option) *Chart {
+	t.Helper()
+	chart, err := NewChart(dir, opts...)
+	require.NoError(t, err)
+	require.NoError(t, chart.LoadValueFiles())
+	require.NoError(t, chart.FindTemplates())
+	require.NoError(t, chart.ParseTemplates())
+	return chart
+}
+
+func TestCheckIngressTLS(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    string
+		template  string
+		wantPaths []string
+		wantLines []int
+	}{
+		{
+			name: "matching paths",
+			template: `kind: Ingress
+spec:
+  tls:
+    - hosts:
+        - {{ .Values.ingress.host }}
+  rules:
+    - host: {{ .Values.ingress.host }}`,
+		},
+		{
+			name: "matching values",
+			values: `ingress:
+  host: example.com
+  tlsHost: example.com
+`,
+			template: `kind: Ingress
+spec:
+  tls:
+    - hosts:
+        - {{ .Values.ingress.tlsHost }}
+  rules:
+    - host: {{ .Values.ingress.host }}`,
+		},
+		{
+			name: "rule host without tls",
+			template: `kind: Ingress
+spec:
+  tls:
+    - hosts:
+        - {{ .Values.ingress.host }}
+      secretName: tls
+  rules:
+    - host: {{ .Values.ingress.host }}
+    - host: {{ .Values.ingress.extraHost }}`,
+			wantPaths: []string{"ingress.extraHost"},
+			wantLines: []int{9},
+		},
+		{
+			name: "tls host without rule",
+			values: `ingress:
+  host: a.example.com
+  tlsHost: b.example.com
+`,
+			template: `kind: Ingress
+spec:
+  tls:
+  - hosts: [{{ .Values.ingress.tlsHost }}]
+  rules:
+  - host: {{ .Values.ingress.host }}`,
+			wantPaths: []string{"ingress.host", "ingress.tlsHost"},
+			wantLines: []int{6, 4},
+		},
+		{
+			name: "no tls configured",
+			template: `kind: Ingress
+spec:
+  rules:
+    - host: {{ .Values.ingress.host }}`,
+		},
+		{
+			name: "not an ingress",
+			template: `kind: ConfigMap
+data:
+  tls:
+    hosts: {{ .Values.other }}
+  rules:
+    host: {{ .Values.host }}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestChart(t, tt.values, map[string]string{"ingress.yaml": tt.template})
+			chart := loadTestChart(t, dir)
+
+			findings, err := chart.CheckIngressTLS()
+			require.NoError(t, err)
+			require.Len(t, findings, len(tt.wantPaths))
+			for i, finding := range findings {
+				assert.Equal(t, CheckIngressTLS, finding.Check)
+				assert.Equal(t, tt.wantPaths[i], finding.Path)
+				assert.Equal(t, tt.wantLines[i], finding.LineNumber)
+				assert.Contains(t, finding.String(), "ingress.yaml")
+			}
+		})
+	}
+
+	t.Run("unreadable template", func(t *testing.T) {
+		chart := &Chart{Templates: []string{filepath.Join(t.TempDir(), "missing.yaml")}, config: defaultConfig()}
+		_, err := chart.CheckIngressTLS()
+		assert.ErrorContains(t, err, "reading template")
+	})
+}
+
+func TestCheckPorts(t *testing.T) {
+	deployment := `kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          ports:
+            - containerPort: {{ .Values.app.port | default 8080 }}
+          livenessProbe:
+            httpGet:
+              path: /healthz
+              port: {{ .Values.probe.port | default 8080 }}
+          readinessProbe:
+            tcpSocket:
+              port: {{ .Values.readiness.port }}
+`
+	service := `kind: Service
+spec:
+  ports:
+    - port: {{ .Values.service.port | default 80 }}
+      targetPort: {{ .Values.service.targetPort | default 8080 }}
+`
+
+	tests := []struct {
+		name         string
+		values       string
+		templates    map[string]string
+		wantMessages []string
+	}{
+		{
+			name:      "coherent defaults",
+			templates: map[string]string{"deployment.yaml": deployment, "service.yaml": service},
+		},
+		{
+			name: "values disagree",
+			values: `service:
+  targetPort: 9090
+readiness:
+  port: 8081
+`,
+			templates: map[string]string{"deployment.yaml": deployment, "service.yaml": service},
+			wantMessages: []string{
+				"readinessProbe port .Values.readiness.port (8081) does not match any containerPort (8080)",
+				"service targetPort .Values.service.targetPort (9090) does not match any containerPort (8080)",
+			},
+		},
+		{
+			name:      "named target port",
+			values:    "service:\n  targetPort: http\n",
+			templates: map[string]string{"deployment.yaml": deployment, "service.yaml": service},
+		},
+		{
+			name:      "no container ports from values",
+			templates: map[string]string{"service.yaml": service},
+		},
+		{
+			name:   "flow style ports",
+			values: "service:\n  targetPort: 9090\n",
+			templates: map[string]string{
+				"deployment.yaml": `kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+        - {name: app, ports: [{containerPort: {{ .Values.app.port | default 8080 }}}]}
+`,
+				"service.yaml": `kind: Service
+spec:
+  ports: [{port: 80, targetPort: {{ .Values.service.targetPort | default 8080 }}}]
+`,
+			},
+			wantMessages: []string{
+				"service targetPort .Values.service.targetPort (9090) does not match any containerPort (8080)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestChart(t, tt.values, tt.templates)
+			chart := loadTestChart(t, dir)
+
+			findings, err := chart.CheckPorts()
+			require.NoError(t, err)
+			messages := make([]string, len(findings))
+			for i, finding := range findings {
+				assert.Equal(t, CheckPorts, finding.Check)
+				messages[i] = finding.Message
+			}
+			assert.ElementsMatch(t, tt.wantMessages, messages)
+		})
+	}
+}
+
+func TestCheckPaths(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{
+		"deployment.yaml": `name: {{ .Values.app.my-name }}
+again: {{ .Values.app.my-name }}
+port: {{ .Values.ports.8080 }}
+release: {{ .Values.Release.Name }}
+env: {{ .Values.global | quote }}
+{{- with .Values.global }}{{ end }}
+ok: {{ .Values.image.pull_policy }}
+indexed: {{ index .Values "my-key" }}
+{{ index .Values.labels "app-name" }} {{ .Values.labels.app-name }}
+`,
+	})
+	chart := loadTestChart(t, dir)
+
+	findings, err := chart.CheckPaths()
+	require.NoError(t, err)
+	messages := make([]string, len(findings))
+	for i, finding := range findings {
+		assert.Equal(t, CheckPaths, finding.Check)
+		messages[i] = fmt.Sprintf("%d: %s", finding.LineNumber, finding.Message)
+	}
+	assert.Equal(t, []string{
+		`1: key "my-name" in .Values.app.my-name is not a valid template identifier; use {{ index .Values.app "my-name" }}`,
+		`3: key "8080" in .Values.ports.8080 is not a valid template identifier; use {{ index .Values.ports "8080" }}`,
+		"4: .Values.Release.Name is named like the built-in object .Release",
+		"5: .Values.global is used as a string, but Helm reserves it for a map of values shared with subcharts",
+		`9: key "app-name" in .Values.labels.app-name is not a valid template identifier; use {{ index .Values.labels "app-name" }}`,
+	}, messages)
+}
+
+func TestIsIdentifier(t *testing.T) {
+	for _, s := range []string{"a", "_a", "camelCase", "snake_case", "a1"} {
+		assert.True(t, isIdentifier(s), s)
+	}
+	for _, s := range []string{"", "1a", "my-key", "a.b", "a b"} {
+		assert.False(t, isIdentifier(s), s)
+	}
+}
+
+func TestRunChecks(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{
+		"ingress.yaml":    "kind: Ingress\nspec:\n  tls:\n    - hosts: [{{ .Values.tlsHost }}]\n  rules:\n    - host: {{ .Values.host }}\n",
+		"deployment.yaml": "kind: Deployment\nspec:\n  template:\n    spec:\n      containers:\n        - ports:\n            - containerPort: {{ .Values.port | default 80 }}\n",
+		"service.yaml":    "kind: Service\nspec:\n  ports:\n    - targetPort: {{ .Values.targetPort | default 81 }}\n",
+	})
+	chart := loadTestChart(t, dir)
+
+	findings, err := chart.RunChecks()
+	require.NoError(t, err)
+	checks := make([]string, len(findings))
+	for i, finding := range findings {
+		checks[i] = finding.Check
+	}
+	assert.ElementsMatch(t, []string{CheckIngressTLS, CheckIngressTLS, CheckPorts}, checks)
+
+	chart.Templates = append(chart.Templates, filepath.Join(dir, "missing.yaml"))
+	_, err = chart.RunChecks()
+	assert.Error(t, err)
+}
+
+func TestResolvedValueLayered(t *testing.T) {
+	dir := writeTestChart(t, "replicas: 1\nname: app\n", map[string]string{"a.yaml": "{{ .Values.replicas }}"})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "values-prod.yaml"), []byte("replicas: 3\n"), 0644))
+	ref := ValueRef{Path: "replicas"}
+
+	chart := loadTestChart(t, dir, WithValuesFileNames([]string{"values-prod.yaml"}))
+	value, ok := chart.resolvedValue(ref)
+	assert.True(t, ok)
+	assert.Equal(t, float64(1), value)
+
+	// Later layers override earlier ones, as with helm -f
+	chart = loadTestChart(t, dir, WithValuesFileNames([]string{"values-prod.yaml"}), WithLayeredValues(true))
+	value, ok = chart.resolvedValue(ref)
+	assert.True(t, ok)
+	assert.Equal(t, float64(3), value)
+	value, _ = chart.resolvedValue(ValueRef{Path: "name"})
+	assert.Equal(t, "app", value)
+}
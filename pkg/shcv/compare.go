@@ -0,0 +1,65 @@
+package shcv
+
+import "sort"
+
+// Kinds of ReferenceChange
+const (
+	// ReferenceAdded is a value path only the new revision references
+	ReferenceAdded = "added"
+	// ReferenceRemoved is a value path only the old revision references
+	ReferenceRemoved = "removed"
+	// ReferenceDefaultChanged is a value path both revisions reference with
+	// different defaults
+	ReferenceDefaultChanged = "default-changed"
+)
+
+// ReferenceChange is a value path whose references differ between two
+// revisions of a chart.
+type ReferenceChange struct {
+	// Path is the value path, such as image.tag
+	Path string `json:"path"`
+	// Kind is ReferenceAdded, ReferenceRemoved or ReferenceDefaultChanged
+	Kind string `json:"kind"`
+	// OldDefault is the default of the value in the old revision, if any
+	OldDefault string `json:"oldDefault,omitempty"`
+	// NewDefault is the default of the value in the new revision, if any
+	NewDefault string `json:"newDefault,omitempty"`
+}
+
+// CompareReferences compares the template references of two revisions of a
+// chart, whose templates must have been parsed, for release notes and upgrade
+// reviews. It returns the value paths that only one revision references, and
+// those whose default changed, sorted by path. The default of a value is the
+// one a sync writes, the first default of its references.
+func CompareReferences(from, to *Chart) []ReferenceChange {
+	fromDefaults, toDefaults := referenceDefaults(from), referenceDefaults(to)
+	var changes []ReferenceChange
+	for path, def := range fromDefaults {
+		newDef, ok := toDefaults[path]
+		switch {
+		case !ok:
+			changes = append(changes, ReferenceChange{Path: path, Kind: ReferenceRemoved, OldDefault: def})
+		case newDef != def:
+			changes = append(changes, ReferenceChange{Path: path, Kind: ReferenceDefaultChanged, OldDefault: def, NewDefault: newDef})
+		}
+	}
+	for path, def := range toDefaults {
+		if _, ok := fromDefaults[path]; !ok {
+			changes = append(changes, ReferenceChange{Path: path, Kind: ReferenceAdded, NewDefault: def})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// referenceDefaults returns the referenced value paths of a chart with their
+// first default, or "" for the values without a default.
+func referenceDefaults(chart *Chart) map[string]string {
+	defaults := make(map[string]string, len(chart.References))
+	for _, ref := range chart.References {
+		if defaults[ref.Path] == "" {
+			defaults[ref.Path] = ref.DefaultValue
+		}
+	}
+	return defaults
+}
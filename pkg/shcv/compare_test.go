@@ -0,0 +1,34 @@
+package shcv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareReferences(t *testing.T) {
+	from := loadTestChart(t, writeTestChart(t, "", map[string]string{
+		"deployment.yaml": `image: {{ .Values.image.repository | default "nginx" }}
+tag: {{ .Values.image.tag | default "1.0" }}
+replicas: {{ .Values.replicas }}
+port: {{ .Values.port }}
+`,
+	}))
+	to := loadTestChart(t, writeTestChart(t, "", map[string]string{
+		"deployment.yaml": `image: {{ .Values.image.repository | default "nginx" }}
+tag: {{ .Values.image.tag | default "2.0" }}
+port: {{ .Values.port | default 8080 }}
+`,
+		"service.yaml": `type: {{ .Values.service.type | default "ClusterIP" }}
+tag: {{ .Values.image.tag }}
+`,
+	}))
+
+	assert.Equal(t, []ReferenceChange{
+		{Path: "image.tag", Kind: ReferenceDefaultChanged, OldDefault: "1.0", NewDefault: "2.0"},
+		{Path: "port", Kind: ReferenceDefaultChanged, NewDefault: "8080"},
+		{Path: "replicas", Kind: ReferenceRemoved},
+		{Path: "service.type", Kind: ReferenceAdded, NewDefault: "ClusterIP"},
+	}, CompareReferences(from, to))
+	assert.Empty(t, CompareReferences(from, from))
+}
@@ -1,5 +1,19 @@
 package shcv
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
 // config configures the behavior of Chart processing.
 // It allows customization of file locations and default values.
 type config struct {
@@ -7,14 +21,111 @@ type config struct {
 	ValuesFileName []string
 	// TemplatesDir is the name of the templates directory (default: "templates")
 	TemplatesDir string
-	// Verbose indicates whether to print verbose messages
+	// Templates lists the templates to scan instead of discovering them, relative
+	// to the chart directory (default: all templates of TemplatesDir)
+	Templates []string
+	// ExcludePatterns are glob patterns of templates excluded from scanning
+	ExcludePatterns []string
+	// TemplateExtensions are the extensions of the files scanned for references
+	// (default: ".yaml", ".yml" and ".tpl"); NOTES.txt is always scanned
+	TemplateExtensions []string
+	// LayeredValues treats the values files as an ordered override chain, the
+	// first file being the base layer
+	LayeredValues bool
+	// ValuesRootKey is the key a values file root that is not a map is wrapped
+	// under (default: disabled, such files are an error)
+	ValuesRootKey string
+	// Verbose logs the debug messages to Output if no Logger is set
 	Verbose bool
+	// Output receives the human-readable output of the library, if set
+	// (default: standard error); it is left out of reproduction bundles
+	Output io.Writer `json:"-"`
+	// Logger logs the messages of the library (default: none are logged); it
+	// is left out of reproduction bundles
+	Logger *slog.Logger `json:"-"`
+	// CacheFile is the path of the run-state cache file (default: disabled)
+	CacheFile string
+	// Placeholder is written for missing scalar values without a default,
+	// if placeholderSet (default: the zero value of their inferred type)
+	Placeholder any
+	// SetValues are the set expressions giving values to write for missing
+	// values instead of their default, see WithSetValues
+	SetValues []string
+	// ValuePrompt asks for the values of missing values without a default; it
+	// is left out of reproduction bundles
+	ValuePrompt ValuePrompt `json:"-"`
+	// Hooks are called as the run progresses; they are left out of
+	// reproduction bundles
+	Hooks Hooks `json:"-"`
+	// Progress receives the progress of the phases of a run; it is left out
+	// of reproduction bundles
+	Progress ProgressFunc `json:"-"`
+	// Injectors add values for the templates they detect; they are left out
+	// of reproduction bundles
+	Injectors []Injector `json:"-"`
+	// NullValues writes null for all missing values without a default
+	NullValues bool
+	// StringDefaults writes unquoted template defaults such as 8080 as strings
+	StringDefaults bool
+	// Strict makes ParseTemplates fail on malformed template actions
+	Strict bool
+	// ErrorMode selects whether ParseTemplates stops at the first error
+	// (default: ErrorModeFailFast)
+	ErrorMode ErrorMode
+	// DefineValuesPolicy makes RunChecks report .Values references in define bodies
+	DefineValuesPolicy bool
+	// NamingPolicy makes RunChecks report value keys breaking it (default: disabled)
+	NamingPolicy *NamingPolicy
+	// LintPolicy configures the rules of Lint (default: all rules)
+	LintPolicy *LintPolicy
+	// FailOnConflict makes ParseTemplates fail on value paths with differing defaults
+	FailOnConflict bool
+	// ProvenanceComments writes a comment naming the template above added values
+	ProvenanceComments bool
+	// SectionBanner is the comment added top-level values are written below
+	SectionBanner string
+	// SortKeys writes the keys of the values files sorted alphabetically
+	SortKeys bool
+	// InjectDeploymentStrategy adds a strategy block to Deployment templates
+	InjectDeploymentStrategy bool
+	// ValueLinks are the value paths kept in sync with other paths
+	ValueLinks []ValueLink
+	// ProtectedPaths are the value paths PruneUnused never removes, along with
+	// the paths nested below them
+	ProtectedPaths []string
+
+	// Backup saves files to a backup before they are modified
+	Backup bool
+	// DryRun keeps the chart files unchanged, see Chart.Changes
+	DryRun bool
+	// MaxFileSize is the size in bytes above which values files and templates
+	// are not read, if set (default: no limit)
+	MaxFileSize int64
+	// FileMode is the mode of the values files and templates written, if set
+	// (default: replaced files keep their mode, new files get 0644)
+	FileMode fs.FileMode
+	// LockTimeout is how long to wait for the chart lock held by another run,
+	// if lockTimeoutSet (default: 30 seconds)
+	LockTimeout time.Duration
+
+	// placeholderSet records that Placeholder was configured, as nil is a valid placeholder
+	placeholderSet bool
+	// lockTimeoutSet records that LockTimeout was configured, as zero is a valid timeout
+	lockTimeoutSet bool
+
+	// deprecations lists the warnings recorded by deprecated options
+	deprecations []string
+	// outputLogger logs all messages to Output, see WithVerbose
+	outputLogger *slog.Logger
 }
 
 // newConfig creates a new config with the default options.
 func newConfig(opts []Option) *config {
 	c := defaultConfig()
 	c.apply(opts)
+	if c.Output != nil {
+		c.outputLogger = slog.New(slog.NewTextHandler(c.Output, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
 	return c
 }
 
@@ -22,9 +133,10 @@ func newConfig(opts []Option) *config {
 // This includes standard file locations and common default values.
 func defaultConfig() *config {
 	return &config{
-		ValuesFileName: []string{"values.yaml"},
-		TemplatesDir:   "templates",
-		Verbose:        false,
+		ValuesFileName:     []string{"values.yaml"},
+		TemplatesDir:       "templates",
+		TemplateExtensions: []string{".yaml", ".yml", ".tpl"},
+		Verbose:            false,
 	}
 }
 
@@ -35,16 +147,171 @@ func (c *config) apply(opts []Option) {
 	}
 }
 
+// validate checks the options for invalid values and conflicts, so that they are
+// rejected before any file is touched.
+func (c *config) validate() error {
+	var errs []error
+	if c.TemplatesDir == "" {
+		errs = append(errs, errors.New("templates directory is empty"))
+	}
+
+	for _, ext := range c.TemplateExtensions {
+		if strings.TrimPrefix(ext, ".") == "" {
+			errs = append(errs, errors.New("template extension is empty"))
+		}
+	}
+
+	for _, template := range c.Templates {
+		if template == "" {
+			errs = append(errs, errors.New("template path is empty"))
+		}
+	}
+
+	for _, pattern := range c.ExcludePatterns {
+		if _, err := filepath.Match(strings.TrimSuffix(pattern, "/"), ""); err != nil || pattern == "" {
+			errs = append(errs, fmt.Errorf("invalid exclude pattern %q", pattern))
+		}
+	}
+
+	valuesFiles := make(map[string]bool, len(c.ValuesFileName))
+	for _, name := range c.ValuesFileName {
+		if name == "" {
+			errs = append(errs, errors.New("values file name is empty"))
+			continue
+		}
+		valuesFiles[filepath.Clean(name)] = true
+	}
+	if c.CacheFile != "" && valuesFiles[filepath.Clean(c.CacheFile)] {
+		errs = append(errs, fmt.Errorf("cache file %s is also a values file", c.CacheFile))
+	}
+	for _, path := range c.ProtectedPaths {
+		if path == "" {
+			errs = append(errs, errors.New("protected value path is empty"))
+		}
+	}
+	for _, link := range c.ValueLinks {
+		switch {
+		case link.Path == "" || link.Source == "":
+			errs = append(errs, fmt.Errorf("link %q mirrors %q: value path is empty", link.Path, link.Source))
+		case link.Path == link.Source:
+			errs = append(errs, fmt.Errorf("link %s mirrors itself", link.Path))
+		case strings.HasPrefix(link.Path, link.Source+"."), strings.HasPrefix(link.Source, link.Path+"."):
+			errs = append(errs, fmt.Errorf("link %s mirrors %s: one path is nested below the other", link.Path, link.Source))
+		}
+	}
+	if c.ErrorMode != "" && c.ErrorMode != ErrorModeFailFast && c.ErrorMode != ErrorModeCollect {
+		errs = append(errs, fmt.Errorf("unknown error mode %q: must be %s or %s", c.ErrorMode, ErrorModeFailFast, ErrorModeCollect))
+	}
+	if policy := c.NamingPolicy; policy != nil {
+		if policy.Case != "" && caseKeys[policy.Case] == nil {
+			errs = append(errs, fmt.Errorf("unknown key case %q: must be %s or %s", policy.Case, CaseCamel, CaseKebab))
+		}
+		if policy.MaxSegmentLength < 0 {
+			errs = append(errs, fmt.Errorf("maximum key length %d is negative", policy.MaxSegmentLength))
+		}
+	}
+	if policy := c.LintPolicy; policy != nil {
+		for _, rule := range policy.Disabled {
+			if !slices.Contains(LintRules, rule) {
+				errs = append(errs, fmt.Errorf("unknown lint rule %q: must be one of %s", rule, strings.Join(LintRules, ", ")))
+			}
+		}
+		if policy.MaxDepth < 0 {
+			errs = append(errs, fmt.Errorf("maximum lint depth %d is negative", policy.MaxDepth))
+		}
+	}
+	if strings.ContainsAny(c.SectionBanner, "\r\n") {
+		errs = append(errs, fmt.Errorf("section banner %q spans several lines", c.SectionBanner))
+	}
+	if c.SortKeys && c.SectionBanner != "" {
+		errs = append(errs, errors.New("sorted keys and a section banner are mutually exclusive"))
+	}
+	for i, injector := range c.Injectors {
+		if injector == nil {
+			errs = append(errs, fmt.Errorf("injector %d is nil", i))
+		}
+	}
+	if c.MaxFileSize < 0 {
+		errs = append(errs, fmt.Errorf("maximum file size %d is negative", c.MaxFileSize))
+	}
+	if c.FileMode&^fs.ModePerm != 0 {
+		errs = append(errs, fmt.Errorf("file mode %s is not a permission mode", c.FileMode))
+	}
+	if c.LockTimeout < 0 {
+		errs = append(errs, fmt.Errorf("lock timeout %s is negative", c.LockTimeout))
+	}
+	if _, err := parseSetValues(c.SetValues); err != nil {
+		errs = append(errs, fmt.Errorf("invalid set value: %w", err))
+	}
+	if c.NullValues && c.placeholderSet {
+		errs = append(errs, errors.New("null values and a placeholder are mutually exclusive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// warningOutput is where deprecation warnings are written without WithOutput
+var warningOutput io.Writer = os.Stderr
+
+// warnDeprecations writes a warning for every deprecated option in use.
+func (c *config) warnDeprecations() {
+	out := warningOutput
+	if c.Output != nil {
+		out = c.Output
+	}
+	for _, notice := range c.deprecations {
+		fmt.Fprintf(out, "warning: %s\n", notice)
+	}
+}
+
 // Option is a functional option for configuring the Chart processing.
 type Option func(*config)
 
-// WithValuesFileNames sets the values file names.
+// deprecated marks an option as deprecated since the given version. Using the
+// option still applies it but records a warning naming the replacement, if any,
+// until the option is removed in a later major version.
+func deprecated(name, since, replacement string, option Option) Option {
+	return func(c *config) {
+		notice := fmt.Sprintf("%s is deprecated since %s and will be removed in a future release", name, since)
+		if replacement != "" {
+			notice += "; use " + replacement + " instead"
+		}
+		c.deprecations = append(c.deprecations, notice)
+		option(c)
+	}
+}
+
+// WithValuesFileNames adds values files to values.yaml, in the order given. The
+// names are relative to the chart directory; absolute paths may point outside
+// of it, such as to the values of an environment kept in another repository.
 func WithValuesFileNames(names []string) Option {
 	return func(c *config) {
 		c.ValuesFileName = append(c.ValuesFileName, names...)
 	}
 }
 
+// WithLayeredValues treats the values files as an ordered override chain, like
+// helm install -f values.yaml -f values-prod.yaml: a value defined by any file
+// counts as defined, later files override earlier ones when values are checked,
+// and missing values are only added to the first file, the base layer. The
+// other files only hold overrides and are never modified. Without it, every
+// values file must define every referenced value on its own.
+func WithLayeredValues(enabled bool) Option {
+	return func(c *config) {
+		c.LayeredValues = enabled
+	}
+}
+
+// WithValuesRootKey wraps the root of values files that are not a map, such
+// as a sequence or a scalar, under the given key instead of failing to load
+// them. Wrapped files are rewritten as a map; ValueFile.RootKind records the
+// kind of the original root.
+func WithValuesRootKey(key string) Option {
+	return func(c *config) {
+		c.ValuesRootKey = key
+	}
+}
+
 // WithTemplatesDir sets the templates directory.
 func WithTemplatesDir(dir string) Option {
 	return func(c *config) {
@@ -52,9 +319,388 @@ func WithTemplatesDir(dir string) Option {
 	}
 }
 
-// WithVerbose sets the verbose flag.
+// WithTemplateExtensions adds file extensions to scan in the templates directory,
+// such as ".txt" or ".json". The leading dot is optional.
+func WithTemplateExtensions(exts ...string) Option {
+	return func(c *config) {
+		for _, ext := range exts {
+			if ext != "" && !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			c.TemplateExtensions = append(c.TemplateExtensions, ext)
+		}
+	}
+}
+
+// WithTemplates scans only the given templates, relative to the chart
+// directory, instead of discovering all templates, for fast targeted checks.
+// The values files are loaded as usual, so references of the templates are
+// still compared against all values. Exclude patterns and template extensions
+// do not apply to the listed templates.
+func WithTemplates(templates []string) Option {
+	return func(c *config) {
+		c.Templates = append(c.Templates, templates...)
+	}
+}
+
+// WithExcludePatterns excludes templates matching any of the glob patterns from
+// scanning. Patterns are matched against the path relative to the templates
+// directory, or against the file name if they contain no slash, so "_*.tpl"
+// excludes all helper templates. A trailing slash matches directories only:
+// "tests/" excludes the tests directory and everything below it.
+func WithExcludePatterns(patterns []string) Option {
+	return func(c *config) {
+		c.ExcludePatterns = append(c.ExcludePatterns, patterns...)
+	}
+}
+
+// WithVerbose logs the messages of the library, down to the debug messages, to
+// standard error or the writer of WithOutput, unless a logger is set with
+// WithLogger.
 func WithVerbose(verbose bool) Option {
 	return func(c *config) {
 		c.Verbose = verbose
 	}
 }
+
+// WithLogger logs the messages of the library to logger: the files written at
+// the info level, such as updated values files and backups, the progress of a
+// run at the debug level, and the problems not failing the run at the warn
+// level. Without a logger, the library writes nothing to standard output or
+// standard error, except for the warnings of deprecated options, see WithOutput.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		c.Logger = logger
+	}
+}
+
+// WithOutput writes the human-readable output of the library to w instead of
+// standard error: the warnings of deprecated options, and the messages of
+// WithVerbose. Programs embedding the package pass their own writer to keep
+// the process's standard streams to themselves.
+func WithOutput(w io.Writer) Option {
+	return func(c *config) {
+		c.Output = w
+	}
+}
+
+// discardLogger drops all messages
+var discardLogger = slog.New(discardHandler{})
+
+// verboseLogger logs all messages to standard error, see WithVerbose
+var verboseLogger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+// logger returns the logger of the library messages.
+func (c *config) logger() *slog.Logger {
+	switch {
+	case c == nil:
+		return discardLogger
+	case c.Logger != nil:
+		return c.Logger
+	case c.Verbose && c.outputLogger != nil:
+		return c.outputLogger
+	case c.Verbose:
+		return verboseLogger
+	}
+	return discardLogger
+}
+
+// discardHandler is a slog.Handler dropping all records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// PathMarker is replaced by the value path in string placeholders
+const PathMarker = "<path>"
+
+// WithPlaceholder sets the value written for missing values without a default
+// instead of the zero value of their inferred type, such as nil or "CHANGEME".
+// PathMarker in a string placeholder is replaced by the value path, so
+// "TODO: <path>" writes "TODO: image.tag" for .Values.image.tag. Maps and
+// lists inferred from usage keep their empty value so templates iterating
+// over them still render.
+func WithPlaceholder(value any) Option {
+	return func(c *config) {
+		c.Placeholder = value
+		c.placeholderSet = true
+	}
+}
+
+// WithSetValues gives the values written for missing values, overriding their
+// template default or placeholder, with expressions like those of helm install
+// --set, such as "image.tag=1.2.3" or "hosts={a.example.com,b.example.com}":
+// dots separate nested keys, keys may index lists as in "hosts[0]=a", commas
+// separate assignments, and a backslash escapes the next character. true,
+// false, null and integers are typed as Helm types them. Later expressions
+// override earlier ones. Values the values files already define are kept.
+func WithSetValues(exprs ...string) Option {
+	return func(c *config) {
+		c.SetValues = append(c.SetValues, exprs...)
+	}
+}
+
+// WithHooks calls the hooks as the run progresses: when a template is parsed
+// and a reference found, before a missing value is added, which the hook can
+// veto, and when a file is written, see Hooks.
+func WithHooks(hooks Hooks) Option {
+	return func(c *config) {
+		c.Hooks = hooks
+	}
+}
+
+// ValuePrompt asks for the value written for a missing reference without a
+// template default, such as by prompting the user. ok is false to write the
+// value written without a prompt instead.
+type ValuePrompt func(ref ValueRef) (value any, ok bool)
+
+// WithValuePrompt makes ProcessReferences call prompt for every missing value
+// without a template default or set value, see WithSetValues, once per value
+// path, in the order the paths were first referenced. The reference carries
+// the type inferred for the value, or declared by values.schema.json.
+func WithValuePrompt(prompt ValuePrompt) Option {
+	return func(c *config) {
+		c.ValuePrompt = prompt
+	}
+}
+
+// WithNullValues writes null for missing values without a default, including
+// the maps and lists inferred from usage, instead of the zero value of their
+// type. Null values render as empty with helm template and stand out in the
+// values file as needing attention.
+func WithNullValues(enabled bool) Option {
+	return func(c *config) {
+		c.NullValues = enabled
+	}
+}
+
+// WithStringDefaults writes template defaults to values files as strings, as
+// earlier versions did, instead of converting unquoted numbers and booleans
+// such as `default 8080` to their native types.
+func WithStringDefaults(enabled bool) Option {
+	return func(c *config) {
+		c.StringDefaults = enabled
+	}
+}
+
+// WithStrict makes ParseTemplates return an error listing the diagnostics of
+// malformed template actions instead of only recording them.
+func WithStrict(strict bool) Option {
+	return func(c *config) {
+		c.Strict = strict
+	}
+}
+
+// ErrorMode selects how ParseTemplates handles errors.
+type ErrorMode string
+
+const (
+	// ErrorModeFailFast stops at the first template that cannot be read
+	ErrorModeFailFast ErrorMode = "fail-fast"
+	// ErrorModeCollect parses every template that can be read and returns all
+	// errors together, so that a CI run reports them at once
+	ErrorModeCollect ErrorMode = "collect"
+)
+
+// WithErrorMode selects whether ParseTemplates stops at the first template
+// that cannot be read, with ErrorModeFailFast, the default, or parses all of
+// them and returns every error together, with ErrorModeCollect.
+func WithErrorMode(mode ErrorMode) Option {
+	return func(c *config) {
+		c.ErrorMode = mode
+	}
+}
+
+// WithFailOnConflict makes ParseTemplates return an error listing the value
+// paths given differing defaults by their references, see DefaultConflicts,
+// instead of writing the first default found.
+func WithFailOnConflict(enabled bool) Option {
+	return func(c *config) {
+		c.FailOnConflict = enabled
+	}
+}
+
+// WithNamingPolicy makes RunChecks run CheckNaming, reporting the value keys
+// that break the policy.
+func WithNamingPolicy(policy NamingPolicy) Option {
+	return func(c *config) {
+		c.NamingPolicy = &policy
+	}
+}
+
+// WithLintPolicy configures the rules run by Lint, such as the rules disabled.
+func WithLintPolicy(policy LintPolicy) Option {
+	return func(c *config) {
+		c.LintPolicy = &policy
+	}
+}
+
+// WithDefineValuesPolicy makes RunChecks run CheckDefineValues, reporting named
+// templates that read .Values directly instead of taking values as arguments.
+func WithDefineValuesPolicy(enabled bool) Option {
+	return func(c *config) {
+		c.DefineValuesPolicy = enabled
+	}
+}
+
+// WithProvenanceComments writes a comment such as
+// "# added by shcv from templates/deployment.yaml:42" above every value added
+// for a template reference, naming the reference it was added for, so that
+// reviewers see why the value exists. Values files that cannot be patched and
+// are marshaled as a new document are written without the comments.
+func WithProvenanceComments(enabled bool) Option {
+	return func(c *config) {
+		c.ProvenanceComments = enabled
+	}
+}
+
+// WithSectionBanner writes the top-level values added to a values file in a
+// block at its end, below a "# banner" comment such as
+// "# --- synced by shcv ---", instead of after the other values, so that they
+// are easy to review and to clean up. The banner is written once, and values
+// added by later runs are appended below the block. Values added to existing
+// maps, such as image.pullPolicy next to image.repository, stay in their map,
+// as YAML cannot define the map twice. An empty banner disables the block.
+func WithSectionBanner(banner string) Option {
+	return func(c *config) {
+		c.SectionBanner = banner
+	}
+}
+
+// WithSortKeys rewrites the values files a run writes with the keys of all
+// their mappings sorted alphabetically, for teams that want a canonical
+// layout, instead of keeping the order of the existing keys and adding new
+// keys after them. Values files whose keys are not sorted are rewritten even
+// if no value is missing. Comments stay with their keys, but blank lines are
+// not kept. It cannot be combined with WithSectionBanner.
+func WithSortKeys(enabled bool) Option {
+	return func(c *config) {
+		c.SortKeys = enabled
+	}
+}
+
+// WithDeploymentStrategyInjection rewrites the Deployment templates of the
+// chart that set no strategy to read it from deployment.strategy, and adds a
+// RollingUpdate strategy to the values files, see DeploymentStrategyInjector.
+// It is disabled by default, as it changes templates the chart authors wrote.
+func WithDeploymentStrategyInjection(enabled bool) Option {
+	return func(c *config) {
+		c.InjectDeploymentStrategy = enabled
+	}
+}
+
+// WithProgress calls progress as the phases of a run progress, such as to show
+// a progress bar for charts with many templates: for every template found and
+// parsed, and for every file written, see ProgressFunc. It is called from the
+// goroutine running the chart.
+func WithProgress(progress ProgressFunc) Option {
+	return func(c *config) {
+		c.Progress = progress
+	}
+}
+
+// WithInjectors adds injectors ProcessReferences runs on every template before
+// adding the missing values, after the deployment strategy injector if it is
+// enabled, see Injector. Injectors detecting the same template rewrite it in
+// turn.
+func WithInjectors(injectors ...Injector) Option {
+	return func(c *config) {
+		c.Injectors = append(c.Injectors, injectors...)
+	}
+}
+
+// WithMaxFileSize fails LoadValueFiles and ParseTemplates with a
+// *FileTooLargeError for values files and templates larger than bytes, before
+// reading them, to guard against generated or binary files that would be read
+// into memory whole. There is no limit by default, or with 0.
+func WithMaxFileSize(bytes int64) Option {
+	return func(c *config) {
+		c.MaxFileSize = bytes
+	}
+}
+
+// checkFileSize returns a *FileTooLargeError if a file of size bytes exceeds
+// the limit of WithMaxFileSize.
+func (c *config) checkFileSize(path string, size int64) error {
+	if c == nil || c.MaxFileSize == 0 || size <= c.MaxFileSize {
+		return nil
+	}
+	return &FileTooLargeError{File: path, Size: size, Limit: c.MaxFileSize}
+}
+
+// WithFileMode gives the values files and templates the run writes the
+// permissions mode, such as 0600, whether they exist or are created. Without
+// it, replaced files keep their mode and new files get 0644. Replaced files
+// keep their owner and group where the process may set them.
+func WithFileMode(mode fs.FileMode) Option {
+	return func(c *config) {
+		c.FileMode = mode
+	}
+}
+
+// fileMode returns the mode of the values files and templates written.
+func (c *config) fileMode() fileMode {
+	if c == nil || c.FileMode == 0 {
+		return defaultFileMode
+	}
+	return fileMode{perm: c.FileMode, force: true}
+}
+
+// WithValueLinks keeps value paths in sync with the paths they mirror, such as
+// service.port with gateway.port, see ValueLink.
+func WithValueLinks(links ...ValueLink) Option {
+	return func(c *config) {
+		c.ValueLinks = append(c.ValueLinks, links...)
+	}
+}
+
+// WithProtectedPaths protects value paths and the paths nested below them from
+// PruneUnused, for values read by something other than the chart's templates,
+// such as CI scripts. A trailing ".*" is accepted, as in "global.*".
+func WithProtectedPaths(paths ...string) Option {
+	return func(c *config) {
+		for _, path := range paths {
+			c.ProtectedPaths = append(c.ProtectedPaths, strings.TrimSuffix(path, ".*"))
+		}
+	}
+}
+
+// WithBackup saves the content of every values file and template to a file
+// named after it with BackupSuffix, such as values.yaml.bak, before the run
+// modifies it. RestoreBackups undoes the changes of a run from the backups.
+func WithBackup(enabled bool) Option {
+	return func(c *config) {
+		c.Backup = enabled
+	}
+}
+
+// WithDryRun keeps every chart file unchanged: ProcessReferences records the
+// templates it would modify instead of writing them, and UpdateValueFiles does
+// not write the values files. Changes returns what the run would change.
+func WithDryRun(enabled bool) Option {
+	return func(c *config) {
+		c.DryRun = enabled
+	}
+}
+
+// WithLockTimeout sets how long to wait for the chart lock, see LockFileName,
+// when another run holds it. A timeout of zero fails right away. Writes fail
+// with ErrLocked when the timeout expires.
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.LockTimeout = timeout
+		c.lockTimeoutSet = true
+	}
+}
+
+// WithCacheFile enables the run-state cache stored at the given path.
+// Relative paths are resolved against the chart directory. The cache never
+// skips a run with injectors, hooks or a value prompt set, see Chart.Unchanged,
+// as their behavior cannot be recorded.
+func WithCacheFile(path string) Option {
+	return func(c *config) {
+		c.CacheFile = path
+	}
+}
@@ -0,0 +1,206 @@
+package shcv
+
+import (
+	"bytes"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr []string
+	}{
+		{name: "defaults"},
+		{
+			name:    "empty templates directory",
+			opts:    []Option{WithTemplatesDir("")},
+			wantErr: []string{"templates directory is empty"},
+		},
+		{
+			name:    "empty values file name",
+			opts:    []Option{WithValuesFileNames([]string{""})},
+			wantErr: []string{"values file name is empty"},
+		},
+		{
+			name:    "cache file overwriting a values file",
+			opts:    []Option{WithCacheFile("./values.yaml")},
+			wantErr: []string{"cache file ./values.yaml is also a values file"},
+		},
+		{
+			name:    "empty template extension",
+			opts:    []Option{WithTemplateExtensions(".")},
+			wantErr: []string{"template extension is empty"},
+		},
+		{
+			name:    "invalid exclude pattern",
+			opts:    []Option{WithExcludePatterns([]string{"[a-"})},
+			wantErr: []string{`invalid exclude pattern "[a-"`},
+		},
+		{
+			name:    "null values with a placeholder",
+			opts:    []Option{WithNullValues(true), WithPlaceholder("CHANGEME")},
+			wantErr: []string{"null values and a placeholder are mutually exclusive"},
+		},
+		{
+			name:    "empty template path",
+			opts:    []Option{WithTemplates([]string{"templates/deployment.yaml", ""})},
+			wantErr: []string{"template path is empty"},
+		},
+		{
+			name:    "invalid naming policy",
+			opts:    []Option{WithNamingPolicy(NamingPolicy{Case: "snake_case", MaxSegmentLength: -1})},
+			wantErr: []string{`unknown key case "snake_case": must be camelCase or kebab-case`, "maximum key length -1 is negative"},
+		},
+		{
+			name:    "invalid set value",
+			opts:    []Option{WithSetValues("image.tag=1.2.3", "hosts")},
+			wantErr: []string{`invalid set value: parsing "hosts": key "hosts" has no value`},
+		},
+		{
+			name:    "invalid lint policy",
+			opts:    []Option{WithLintPolicy(LintPolicy{Disabled: []string{"single-use", "typo"}, MaxDepth: -1})},
+			wantErr: []string{`unknown lint rule "typo": must be one of no-default, conflicting-defaults, mixed-case, deep-path, single-use`, "maximum lint depth -1 is negative"},
+		},
+		{
+			name:    "invalid file mode",
+			opts:    []Option{WithFileMode(fs.ModeDir | 0755)},
+			wantErr: []string{"file mode drwxr-xr-x is not a permission mode"},
+		},
+		{
+			name:    "sorted keys with a section banner",
+			opts:    []Option{WithSortKeys(true), WithSectionBanner("--- synced by shcv ---")},
+			wantErr: []string{"sorted keys and a section banner are mutually exclusive"},
+		},
+		{
+			name:    "unknown error mode",
+			opts:    []Option{WithErrorMode("ignore")},
+			wantErr: []string{`unknown error mode "ignore": must be fail-fast or collect`},
+		},
+		{
+			name:    "empty protected path",
+			opts:    []Option{WithProtectedPaths("global.*", "")},
+			wantErr: []string{"protected value path is empty"},
+		},
+		{
+			name:    "multi-line section banner",
+			opts:    []Option{WithSectionBanner("synced\nby shcv")},
+			wantErr: []string{`section banner "synced\nby shcv" spans several lines`},
+		},
+		{
+			name:    "negative lock timeout",
+			opts:    []Option{WithLockTimeout(-time.Second)},
+			wantErr: []string{"lock timeout -1s is negative"},
+		},
+		{
+			name:    "link mirroring itself",
+			opts:    []Option{WithValueLinks(ValueLink{Path: "service.port", Source: "service.port"})},
+			wantErr: []string{"link service.port mirrors itself"},
+		},
+		{
+			name:    "nested link",
+			opts:    []Option{WithValueLinks(ValueLink{Path: "gateway.service", Source: "gateway"})},
+			wantErr: []string{"link gateway.service mirrors gateway: one path is nested below the other"},
+		},
+		{
+			name:    "link without a source",
+			opts:    []Option{WithValueLinks(ValueLink{Path: "service.port"})},
+			wantErr: []string{`link "service.port" mirrors "": value path is empty`},
+		},
+		{
+			name:    "all problems are reported",
+			opts:    []Option{WithTemplatesDir(""), WithValuesFileNames([]string{""})},
+			wantErr: []string{"templates directory is empty", "values file name is empty"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newConfig(tt.opts).validate()
+			if len(tt.wantErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.ErrorContains(t, err, want)
+			}
+		})
+	}
+
+	t.Run("rejected by NewChart", func(t *testing.T) {
+		_, err := NewChart(t.TempDir(), WithTemplatesDir(""))
+		assert.ErrorContains(t, err, "invalid options: templates directory is empty")
+	})
+}
+
+func TestDeprecatedOption(t *testing.T) {
+	var out bytes.Buffer
+	previous := warningOutput
+	warningOutput = &out
+	defer func() { warningOutput = previous }()
+
+	withOldDir := deprecated("WithOldDir", "v1.1.0", "WithTemplatesDir", WithTemplatesDir("old"))
+	chart, err := NewChart(t.TempDir(), withOldDir)
+	require.NoError(t, err)
+
+	// The option still applies until it is removed
+	assert.Equal(t, "old", chart.config.TemplatesDir)
+	warning := "warning: WithOldDir is deprecated since v1.1.0 and will be removed in a future release; use WithTemplatesDir instead\n"
+	assert.Equal(t, warning, out.String())
+
+	// WithOutput receives the warnings instead
+	var own bytes.Buffer
+	_, err = NewChart(t.TempDir(), withOldDir, WithOutput(&own))
+	require.NoError(t, err)
+	assert.Equal(t, warning, own.String())
+	assert.Equal(t, warning, out.String())
+}
+
+func TestWithOutput(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{"service.yaml": "port: {{ .Values.port }}\n"})
+	var out bytes.Buffer
+	chart := loadTestChart(t, dir, WithVerbose(true), WithOutput(&out))
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+	assert.Contains(t, out.String(), "level=INFO msg=\"updated values\" file="+filepath.Join(dir, "values.yaml")+"\n")
+	assert.Contains(t, out.String(), "level=DEBUG")
+
+	// A logger wins over the output
+	out.Reset()
+	chart = loadTestChart(t, dir, WithVerbose(true), WithOutput(&out), WithLogger(discardLogger))
+	chart.ProcessReferences()
+	assert.Empty(t, out.String())
+}
+
+func TestWithLogger(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{"service.yaml": "port: {{ .Values.port }}\n"})
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	}))
+	chart := loadTestChart(t, dir, WithLogger(logger), WithBackup(true))
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+
+	values := filepath.Join(dir, "values.yaml")
+	assert.Equal(t, "level=INFO msg=\"backed up file\" file="+values+" backup="+values+BackupSuffix+"\n"+
+		"level=INFO msg=\"updated values\" file="+values+"\n", out.String())
+
+	// Without a logger, nothing is logged
+	assert.Same(t, discardLogger, (&config{}).logger())
+	assert.Same(t, verboseLogger, (&config{Verbose: true}).logger())
+}
@@ -0,0 +1,51 @@
+package shcv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultConflict is a value path given differing defaults by its references in
+// the templates. ProcessReferences writes the first default found, so the
+// others silently lose.
+type DefaultConflict struct {
+	// Path is the value path, such as image.tag
+	Path string
+	// Refs holds the first reference of every distinct default, in the order
+	// the references were found; Refs[0] holds the default that is written
+	Refs []ValueRef
+}
+
+// String formats the conflict with the default and location of every reference.
+func (d DefaultConflict) String() string {
+	defaults := make([]string, 0, len(d.Refs))
+	for _, ref := range d.Refs {
+		defaults = append(defaults, fmt.Sprintf("%s (%s:%d)", writtenDefault(ref), ref.SourceFile, ref.LineNumber))
+	}
+	return fmt.Sprintf("conflicting defaults for .Values.%s: %s; %s is used",
+		d.Path, strings.Join(defaults, ", "), writtenDefault(d.Refs[0]))
+}
+
+// writtenDefault returns the default of ref as written in the template, quoted
+// unless it was written unquoted.
+func writtenDefault(ref ValueRef) string {
+	if ref.DefaultUnquoted {
+		return ref.DefaultValue
+	}
+	return fmt.Sprintf("%q", ref.DefaultValue)
+}
+
+// DefaultConflicts returns the value paths whose references have differing
+// defaults, in the order the paths were first referenced. A quoted and an
+// unquoted default with the same text conflict, as they are written with
+// different types. The templates must have been parsed.
+func (c *Chart) DefaultConflicts() []DefaultConflict {
+	index := c.Index()
+	var conflicts []DefaultConflict
+	for _, path := range index.Paths {
+		if refs := index.Values[path].Conflicts; refs != nil {
+			conflicts = append(conflicts, DefaultConflict{Path: path, Refs: refs})
+		}
+	}
+	return conflicts
+}
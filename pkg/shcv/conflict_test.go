@@ -0,0 +1,78 @@
+package shcv
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultConflicts(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates map[string]string
+		want      []string
+	}{
+		{
+			name: "same default",
+			templates: map[string]string{
+				"a.yaml": "tag: {{ .Values.image.tag | default \"1.0\" }}\n",
+				"b.yaml": "tag: {{ .Values.image.tag | default \"1.0\" }}\n",
+			},
+		},
+		{
+			name: "default and no default",
+			templates: map[string]string{
+				"a.yaml": "tag: {{ .Values.image.tag }}\n",
+				"b.yaml": "tag: {{ .Values.image.tag | default \"1.0\" }}\n",
+			},
+		},
+		{
+			name: "differing defaults",
+			templates: map[string]string{
+				"a.yaml": "tag: {{ .Values.image.tag | default \"1.0\" }}\n",
+				"b.yaml": "name: {{ .Values.name }}\ntag: {{ .Values.image.tag | default \"2.0\" }}\n",
+				"c.yaml": "tag: {{ .Values.image.tag | default \"1.0\" }}\ntag: {{ .Values.image.tag | default \"3.0\" }}\n",
+			},
+			want: []string{`conflicting defaults for .Values.image.tag: "1.0" (templates/a.yaml:1), "2.0" (templates/b.yaml:2), "3.0" (templates/c.yaml:2); "1.0" is used`},
+		},
+		{
+			name: "quoted and unquoted",
+			templates: map[string]string{
+				"a.yaml": "port: {{ .Values.port | default 80 }}\nport: {{ .Values.port | default \"80\" }}\n",
+			},
+			want: []string{`conflicting defaults for .Values.port: 80 (templates/a.yaml:1), "80" (templates/a.yaml:2); 80 is used`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestChart(t, "", tt.templates)
+			chart := loadTestChart(t, dir)
+
+			var got []string
+			for _, conflict := range chart.DefaultConflicts() {
+				for i := range conflict.Refs {
+					conflict.Refs[i].SourceFile = chart.relPath(conflict.Refs[i].SourceFile)
+				}
+				got = append(got, conflict.String())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseTemplatesFailOnConflict(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{
+		"a.yaml": "tag: {{ .Values.image.tag | default \"1.0\" }}\n",
+		"b.yaml": "tag: {{ .Values.image.tag | default \"2.0\" }}\n",
+	})
+
+	chart, err := NewChart(dir, WithFailOnConflict(true))
+	require.NoError(t, err)
+	require.NoError(t, chart.FindTemplates())
+	err = chart.ParseTemplates()
+	assert.ErrorContains(t, err, "1 value paths have conflicting defaults")
+	assert.ErrorContains(t, err, `"1.0" (`+filepath.Join(dir, "templates/a.yaml")+`:1), "2.0" (`+filepath.Join(dir, "templates/b.yaml")+`:1)`)
+}
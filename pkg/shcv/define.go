@@ -0,0 +1,110 @@
+package shcv
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// AllowValuesAnnotation in a comment directly before a define action or inside
+// its body documents that the named template reads .Values directly, which
+// suppresses the CheckDefineValues findings for it.
+const AllowValuesAnnotation = "shcv:allow-values"
+
+// defineSpan is the body of a named template.
+type defineSpan struct {
+	name       string
+	start, end int
+	allowed    bool
+}
+
+// CheckDefineValues reports references to .Values inside define bodies. Named
+// templates reading values directly are coupled to the values layout of the
+// chart in a way their callers cannot see; passing the values as arguments,
+// such as with include "name" (dict "image" .Values.image), makes the contract
+// explicit. Named templates annotated with AllowValuesAnnotation are skipped.
+//
+// The check is opt-in: RunChecks only runs it with WithDefineValuesPolicy.
+func (c *Chart) CheckDefineValues() ([]Finding, error) {
+	var findings []Finding
+	for _, template := range c.Templates {
+		content, err := os.ReadFile(template)
+		if err != nil {
+			return nil, fmt.Errorf("reading template %s: %w", template, err)
+		}
+
+		defines := defineSpans(string(content))
+		if len(defines) == 0 {
+			continue
+		}
+		reported := make(map[string]bool)
+		for _, ref := range ParseFile(string(content), template) {
+			for _, define := range defines {
+				if ref.EndOffset <= define.start || ref.EndOffset > define.end || define.allowed {
+					continue
+				}
+				if key := define.name + "\x00" + ref.Path; !reported[key] {
+					reported[key] = true
+					findings = append(findings, Finding{
+						Check:      CheckDefineValues,
+						Path:       ref.Path,
+						SourceFile: template,
+						LineNumber: ref.LineNumber,
+						Message: fmt.Sprintf("named template %q reads .Values.%s directly; pass the value as an argument or document the dependency with {{/* %s */}}",
+							define.name, ref.Path, AllowValuesAnnotation),
+					})
+				}
+			}
+		}
+	}
+	return findings, nil
+}
+
+// defineSpans returns the named templates defined in template content, from
+// their define action to the matching end.
+func defineSpans(content string) []defineSpan {
+	type block struct {
+		define bool
+		span   defineSpan
+	}
+
+	var spans []defineSpan
+	var stack []block
+	annotated := -1 // end offset of the last annotation comment
+	for _, action := range scanActions(content) {
+		if action.comment {
+			if !strings.Contains(action.body, AllowValuesAnnotation) {
+				continue
+			}
+			annotated = action.end
+			for i := range stack {
+				if stack[i].define {
+					stack[i].span.allowed = true
+				}
+			}
+			continue
+		}
+
+		keyword, rest, _ := strings.Cut(action.body, " ")
+		switch {
+		case keyword == "define":
+			name, _, _ := strings.Cut(strings.TrimSpace(rest), " ")
+			allowed := annotated >= 0 && strings.TrimSpace(content[annotated:action.start]) == ""
+			stack = append(stack, block{define: true, span: defineSpan{
+				name:    strings.Trim(name, "\"`"),
+				start:   action.start,
+				allowed: allowed,
+			}})
+		case blockKeywords[keyword]:
+			stack = append(stack, block{})
+		case keyword == "end" && len(stack) > 0:
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if top.define {
+				top.span.end = action.end
+				spans = append(spans, top.span)
+			}
+		}
+	}
+	return spans
+}
@@ -0,0 +1,83 @@
+package shcv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDefineValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     []string
+	}{
+		{
+			name:     "values passed as arguments",
+			template: "{{- define \"app.image\" -}}\n{{ .repository }}:{{ .tag }}\n{{- end }}\nimage: {{ include \"app.image\" .Values.image }}\n",
+		},
+		{
+			name:     "values read in the body",
+			template: "{{- define \"app.image\" -}}\n{{- if .Values.image.digest }}\n{{ .Values.image.repository }}@{{ .Values.image.digest }}\n{{- end }}\n{{- end }}\nname: {{ .Values.name }}\n",
+			want: []string{
+				`templates/_helpers.tpl:2: named template "app.image" reads .Values.image.digest directly; pass the value as an argument or document the dependency with {{/* shcv:allow-values */}}`,
+				`templates/_helpers.tpl:3: named template "app.image" reads .Values.image.repository directly; pass the value as an argument or document the dependency with {{/* shcv:allow-values */}}`,
+			},
+		},
+		{
+			name:     "root values in range",
+			template: "{{ define \"app.env\" }}{{ range .Values.env }}{{ $.Values.prefix }}{{ end }}{{ end }}\n",
+			want: []string{
+				`templates/_helpers.tpl:1: named template "app.env" reads .Values.env directly; pass the value as an argument or document the dependency with {{/* shcv:allow-values */}}`,
+				`templates/_helpers.tpl:1: named template "app.env" reads .Values.prefix directly; pass the value as an argument or document the dependency with {{/* shcv:allow-values */}}`,
+			},
+		},
+		{
+			name:     "annotated before the define",
+			template: "{{/*\nRenders the image reference.\nshcv:allow-values\n*/}}\n{{- define \"app.image\" -}}\n{{ .Values.image.repository }}\n{{- end }}\n",
+		},
+		{
+			name:     "annotated in the body",
+			template: "{{- define \"app.image\" -}}\n{{- /* shcv:allow-values */ -}}\n{{ .Values.image.repository }}\n{{- end }}\n",
+		},
+		{
+			name:     "annotation applies to one helper",
+			template: "{{/* shcv:allow-values */}}\n{{ define \"a\" }}{{ .Values.a }}{{ end }}\n{{ define \"b\" }}{{ .Values.b }}{{ end }}\n",
+			want:     []string{`templates/_helpers.tpl:3: named template "b" reads .Values.b directly; pass the value as an argument or document the dependency with {{/* shcv:allow-values */}}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestChart(t, "", map[string]string{"_helpers.tpl": tt.template})
+			chart := loadTestChart(t, dir)
+
+			findings, err := chart.CheckDefineValues()
+			require.NoError(t, err)
+			var got []string
+			for _, f := range findings {
+				assert.Equal(t, CheckDefineValues, f.Check)
+				f.SourceFile = chart.relPath(f.SourceFile)
+				got = append(got, f.String())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRunChecksDefineValuesPolicy(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{
+		"_helpers.tpl": "{{ define \"app.name\" }}{{ .Values.name }}{{ end }}\n",
+	})
+
+	findings, err := loadTestChart(t, dir).RunChecks()
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+
+	findings, err = loadTestChart(t, dir, WithDefineValuesPolicy(true)).RunChecks()
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	assert.Equal(t, CheckDefineValues, findings[0].Check)
+	assert.Equal(t, "name", findings[0].Path)
+}
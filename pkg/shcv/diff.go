@@ -0,0 +1,210 @@
+package shcv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// noNewlineMarker follows the last line of a file not ending with a newline.
+// It is appended to the text of that line, so that the line differs from the
+// same line ending with a newline.
+const noNewlineMarker = "\n\\ No newline at end of file"
+
+// diffOp is a single line operation of a diff.
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// unifiedDiff returns a unified diff turning before into after, or "" if they are equal.
+func unifiedDiff(fromName, toName string, before, after []byte) string {
+	if string(before) == string(after) {
+		return ""
+	}
+
+	a, b := splitLines(before), splitLines(after)
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Group the operations into hunks separated by more than twice the context
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end += min(diffContext, run-end)
+				break
+			}
+			end = run
+		}
+
+		// Compute the line ranges covered by the hunk
+		oldStart, newStart := 1, 1
+		for _, op := range ops[:start] {
+			if op.kind != '+' {
+				oldStart++
+			}
+			if op.kind != '-' {
+				newStart++
+			}
+		}
+		oldLines, newLines := 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				oldLines++
+			}
+			if op.kind != '-' {
+				newLines++
+			}
+		}
+		if oldLines == 0 {
+			oldStart--
+		}
+		if newLines == 0 {
+			newStart--
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldLines), hunkRange(newStart, newLines))
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+		}
+		i = end
+	}
+
+	return out.String()
+}
+
+// hunkRange formats a hunk line range
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// splitLines splits content into lines without their line endings; a last
+// line without a newline ends with noNewlineMarker
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if content[len(content)-1] != '\n' {
+		lines[len(lines)-1] += noNewlineMarker
+	}
+	return lines
+}
+
+// diffLines computes a line diff of a and b based on their longest common
+// subsequence. It uses Hirschberg's algorithm, so memory stays linear in the
+// number of lines however large the files are.
+func diffLines(a, b []string) []diffOp {
+	return appendDiff(make([]diffOp, 0, len(a)+len(b)), a, b)
+}
+
+// appendDiff appends the line diff of a and b to ops.
+func appendDiff(ops []diffOp, a, b []string) []diffOp {
+	// Unchanged lines at the start and end are kept without searching
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = appendChanged(ops, a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// appendChanged appends the line diff of a and b, which neither start nor end
+// with the same line, to ops. Removed lines come before the lines added in
+// their place.
+func appendChanged(ops []diffOp, a, b []string) []diffOp {
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+		return ops
+	case len(b) == 0:
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+		return ops
+	case len(a) == 1:
+		for j, line := range b {
+			if line == a[0] {
+				ops = appendChanged(ops, nil, b[:j])
+				ops = append(ops, diffOp{' ', line})
+				return appendChanged(ops, nil, b[j+1:])
+			}
+		}
+		ops = append(ops, diffOp{'-', a[0]})
+		return appendChanged(ops, nil, b)
+	}
+
+	// Split b where the subsequences common with both halves of a are longest
+	mid := len(a) / 2
+	forward := lcsLengths(a[:mid], b, false)
+	backward := lcsLengths(a[mid:], b, true)
+	split := 0
+	for j := range forward {
+		if forward[j]+backward[len(b)-j] > forward[split]+backward[len(b)-split] {
+			split = j
+		}
+	}
+	ops = appendDiff(ops, a[:mid], b[:split])
+	return appendDiff(ops, a[mid:], b[split:])
+}
+
+// lcsLengths returns the lengths of the longest common subsequences of a and
+// each prefix of b, indexed by the prefix length. With reverse, a and b are
+// read from their end, so the lengths are those of the suffixes of b.
+func lcsLengths(a, b []string, reverse bool) []int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			x, y := a[i], b[j]
+			if reverse {
+				x, y = a[len(a)-1-i], b[len(b)-1-j]
+			}
+			if x == y {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
@@ -0,0 +1,165 @@
+package shcv
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   string
+	}{
+		{
+			name:   "equal",
+			before: "a\nb\n",
+			after:  "a\nb\n",
+			want:   "",
+		},
+		{
+			name:   "changed line",
+			before: "a\nb\nc\n",
+			after:  "a\nB\nc\n",
+			want:   "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:   "new file",
+			before: "",
+			after:  "a\nb\n",
+			want:   "--- a/f\n+++ b/f\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:   "appended line",
+			before: "a\n",
+			after:  "a\nb\n",
+			want:   "--- a/f\n+++ b/f\n@@ -1 +1,2 @@\n a\n+b\n",
+		},
+		{
+			name:   "no newline at end of file",
+			before: "a\nb",
+			after:  "a\nb\n",
+			want:   "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name:   "separate hunks",
+			before: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			after:  "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			want: "--- a/f\n+++ b/f\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n" +
+				"@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+		{
+			name:   "close changes share a hunk",
+			before: "1\n2\n3\n4\n5\n",
+			after:  "one\n2\n3\n4\nfive\n",
+			want:   "--- a/f\n+++ b/f\n@@ -1,5 +1,5 @@\n-1\n+one\n 2\n 3\n 4\n-5\n+five\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unifiedDiff("a/f", "b/f", []byte(tt.before), []byte(tt.after))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	ops := diffLines(strings.Split("a b c d", " "), strings.Split("a c d e", " "))
+	var kinds strings.Builder
+	for _, op := range ops {
+		kinds.WriteByte(op.kind)
+	}
+	assert.Equal(t, " -  +", kinds.String())
+}
+
+// applyDiff returns the old and new lines described by ops and the number of
+// unchanged lines.
+func applyDiff(ops []diffOp) (before, after []string, unchanged int) {
+	for _, op := range ops {
+		if op.kind != '+' {
+			before = append(before, op.text)
+		}
+		if op.kind != '-' {
+			after = append(after, op.text)
+		}
+		if op.kind == ' ' {
+			unchanged++
+		}
+	}
+	return before, after, unchanged
+}
+
+func TestDiffLinesLongestCommonSubsequence(t *testing.T) {
+	// lcs is the quadratic reference the diff must match
+	lcs := func(a, b []string) int {
+		lengths := make([][]int, len(a)+1)
+		for i := range lengths {
+			lengths[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lengths[i][j] = lengths[i+1][j+1] + 1
+				} else {
+					lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+				}
+			}
+		}
+		return lengths[0][0]
+	}
+	lines := func(r *rand.Rand) []string {
+		out := make([]string, r.Intn(12))
+		for i := range out {
+			out[i] = string(rune('a' + r.Intn(4)))
+		}
+		return out
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		a, b := lines(r), lines(r)
+		before, after, unchanged := applyDiff(diffLines(a, b))
+		require.Equal(t, strings.Join(a, " "), strings.Join(before, " "))
+		require.Equal(t, strings.Join(b, " "), strings.Join(after, " "))
+		require.Equal(t, lcs(a, b), unchanged, "diff of %q and %q", a, b)
+	}
+}
+
+// largeDiffInput returns two files of n lines each whose halves are rewritten
+// differently, so that little can be trimmed as common prefix or suffix.
+func largeDiffInput(n int) ([]string, []string) {
+	a, b := make([]string, n), make([]string, n)
+	for i := range a {
+		a[i] = fmt.Sprintf("key%d: %d", i, i)
+		b[i] = a[i]
+		if i%2 == 0 {
+			b[i] = fmt.Sprintf("key%d: changed", i)
+		}
+	}
+	b[0], b[n-1] = "first", "last"
+	return a, b
+}
+
+func TestDiffLinesLargeInput(t *testing.T) {
+	// A quadratic matrix for these files would take close to a gigabyte
+	a, b := largeDiffInput(10000)
+	before, after, unchanged := applyDiff(diffLines(a, b))
+	assert.Equal(t, a, before)
+	assert.Equal(t, b, after)
+	assert.Equal(t, 4999, unchanged)
+}
+
+func BenchmarkDiffLines(b *testing.B) {
+	before, after := largeDiffInput(2000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		diffLines(before, after)
+	}
+}
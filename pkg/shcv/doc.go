@@ -14,28 +14,23 @@ Basic usage:
 	}
 
 	// Process the chart
-	if err := chart.LoadValueFiles(); err != nil {
-		log.Fatal(err)
-	}
-	if err := chart.FindTemplates(); err != nil {
-		log.Fatal(err)
-	}
-	if err := chart.ParseTemplates(); err != nil {
-		log.Fatal(err)
-	}
-	if err := chart.ProcessReferences(); err != nil {
+	report, err := chart.Sync(context.Background())
+	if err != nil {
 		log.Fatal(err)
 	}
-	if err := chart.UpdateValueFiles(); err != nil {
-		log.Fatal(err)
+	for _, warning := range report.Warnings {
+		log.Println(warning)
 	}
 
+Sync runs LoadValueFiles, FindTemplates, ParseTemplates, ProcessReferences and
+UpdateValueFiles in order, which can also be called one by one.
+
 Configuration options:
 
 	chart, err := shcv.NewChart("./my-chart",
 		shcv.WithValuesFileNames([]string{"values.yaml", "values-prod.yaml"}),
 		shcv.WithTemplatesDir("custom-templates"),
-		shcv.WithVerbose(true),
+		shcv.WithLogger(slog.Default()),
 	)
 
 Key features:
@@ -43,9 +38,17 @@ Key features:
   - Supports multiple values files
   - Supports nested value structures
   - Handles default values in templates
+  - Skips references marked with a shcv:ignore directive
   - Creates missing values with their default values
+  - Scaffolds the values file and schema of a chart without values
   - Preserves existing values, structure, and data types (e.g., numbers, strings)
   - Provides line number and source file tracking
+  - Cross-checks ingress rule hosts against TLS hosts
+  - Cross-checks Service and probe ports against container ports
+  - Follows the value types declared by values.schema.json
+  - Reports values whose type contradicts their use in the templates
+  - Lints the values layout with rules that can be disabled one by one
+  - Compares the value references of two revisions of a chart
   - Uses atomic file operations
   - Provides robust error handling
 
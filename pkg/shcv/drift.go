@@ -0,0 +1,132 @@
+package shcv
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// intFunc matches the functions converting a value to an integer
+	intFunc = regexp.MustCompile(`\b(int|int64|atoi)\b`)
+	// serializeFunc matches the functions rendering a map or a list as a document
+	serializeFunc = regexp.MustCompile(`\b(toYaml|toJson|toPrettyJson)\b`)
+)
+
+// CheckTypeDrift reports values whose type in the values files contradicts how
+// the templates use them: a value that is not a number converted to one, such
+// as replicas: "three" with | int, a scalar rendered with toYaml or toJson, or
+// a string or a boolean iterated with range. These render as 0, as a bare
+// scalar, or fail, without any error from shcv. The findings name both the
+// template reference and the values file line defining the value.
+//
+// Only explicit conversions are checked: usages tolerating any type, such as
+// with, if and defaults, are not reported. The values files must have been
+// loaded and the templates parsed.
+func (c *Chart) CheckTypeDrift() ([]Finding, error) {
+	contents := make(map[string][]templateAction)
+	var findings []Finding
+	for _, ref := range c.References {
+		if ref.Type != TypeInt && ref.Type != TypeMap && ref.Type != TypeList {
+			continue
+		}
+		file, line, value, ok := c.definition(ref.Path)
+		if !ok || value == nil {
+			continue
+		}
+
+		if _, ok := contents[ref.SourceFile]; !ok {
+			content, err := os.ReadFile(ref.SourceFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading template %s: %w", ref.SourceFile, err)
+			}
+			contents[ref.SourceFile] = scanActions(string(content))
+		}
+		usage := driftUsage(ref, actionAt(contents[ref.SourceFile], ref.EndOffset), value)
+		if usage == "" {
+			continue
+		}
+		findings = append(findings, Finding{
+			Check:      CheckTypeDrift,
+			Path:       ref.Path,
+			SourceFile: ref.SourceFile,
+			LineNumber: ref.LineNumber,
+			ValuesFile: file,
+			ValuesLine: line,
+			Message: fmt.Sprintf(".Values.%s is used with %s, but %s:%d defines it as a %s",
+				ref.Path, usage, file, line, yamlKind(value)),
+		})
+	}
+	return findings, nil
+}
+
+// driftUsage returns the usage of a reference in the body of its action that
+// value contradicts, such as "int", or "" if there is none.
+func driftUsage(ref ValueRef, body string, value any) string {
+	switch ref.Type {
+	case TypeInt:
+		if match := intFunc.FindString(body); match != "" && !isNumber(value) {
+			return match
+		}
+	case TypeMap:
+		_, isMap := value.(map[string]any)
+		_, isList := value.([]any)
+		if match := serializeFunc.FindString(body); match != "" && !isMap && !isList {
+			return match
+		}
+	case TypeList:
+		switch value.(type) {
+		case string, bool:
+			if strings.HasPrefix(body, "range ") {
+				return "range"
+			}
+		}
+	}
+	return ""
+}
+
+// isNumber reports whether a value is a number or a string holding one, which
+// the conversion functions accept.
+func isNumber(value any) bool {
+	switch v := value.(type) {
+	case int, int64, float64:
+		return true
+	case string:
+		_, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return err == nil
+	}
+	return false
+}
+
+// actionAt returns the body of the action containing offset, or "" if there is none.
+func actionAt(actions []templateAction, offset int) string {
+	for _, action := range actions {
+		if !action.comment && action.start < offset && offset <= action.end {
+			return action.body
+		}
+	}
+	return ""
+}
+
+// definition returns the values file and line defining the value at path and
+// the value, taken from the file resolvedValue takes it from.
+func (c *Chart) definition(path string) (file string, line int, value any, ok bool) {
+	for i := range c.ValuesFiles {
+		f := c.ValuesFiles[i]
+		if c.layered() {
+			f = c.ValuesFiles[len(c.ValuesFiles)-1-i]
+		}
+		if value, ok = lookupValue(f.Values, path); ok {
+			for _, key := range valueKeys(f.source) {
+				if key.path == path {
+					line = key.line
+					break
+				}
+			}
+			return f.Path, line, value, true
+		}
+	}
+	return "", 0, nil, false
+}
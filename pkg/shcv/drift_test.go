@@ -0,0 +1,72 @@
+package shcv
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTypeDrift(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   string
+		template string
+		want     []string
+	}{
+		{
+			name:     "matching types",
+			values:   "replicas: 3\nport: \"8080\"\nresources:\n  cpu: 1\nhosts: [a]\nenv: {A: b}\n",
+			template: "replicas: {{ .Values.replicas | int }}\nport: {{ atoi .Values.port }}\n{{ toYaml .Values.resources }}\n{{ .Values.hosts | toJson }}\n{{- range $k, $v := .Values.env }}{{ $k }}{{ end }}\n",
+		},
+		{
+			name:     "string converted to an integer",
+			values:   "# replica count\nreplicas: three\n",
+			template: "spec:\n  replicas: {{ .Values.replicas | int }}\n",
+			want:     []string{"templates/deployment.yaml:2: .Values.replicas is used with int, but values.yaml:2 defines it as a string"},
+		},
+		{
+			name:     "scalar rendered with toYaml",
+			values:   "resources:\n  limits: small\n",
+			template: "resources:\n  {{- toYaml .Values.resources.limits | nindent 2 }}\n",
+			want:     []string{"templates/deployment.yaml:2: .Values.resources.limits is used with toYaml, but values.yaml:2 defines it as a string"},
+		},
+		{
+			name:     "string iterated with range",
+			values:   "hosts: a.example.com\n",
+			template: "{{- range .Values.hosts }}\n- {{ . }}\n{{- end }}\n",
+			want:     []string{"templates/deployment.yaml:1: .Values.hosts is used with range, but values.yaml:1 defines it as a string"},
+		},
+		{
+			name:     "tolerant usages",
+			values:   "nameOverride: web\nport: http\nenabled: \"yes\"\n",
+			template: "{{ with .Values.nameOverride }}{{ . }}{{ end }}\nport: {{ .Values.port | default 8080 }}\n{{ if .Values.enabled }}on{{ end }}\n",
+		},
+		{
+			name:     "null and missing values",
+			values:   "replicas: ~\n",
+			template: "{{ .Values.replicas | int }} {{ .Values.missing | int }}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestChart(t, tt.values, map[string]string{"deployment.yaml": tt.template})
+			chart := loadTestChart(t, dir)
+
+			findings, err := chart.CheckTypeDrift()
+			require.NoError(t, err)
+			var got []string
+			for _, f := range findings {
+				assert.Equal(t, CheckTypeDrift, f.Check)
+				assert.Equal(t, filepath.Join(dir, "values.yaml"), f.ValuesFile)
+				f.SourceFile = chart.relPath(f.SourceFile)
+				f.Message = strings.ReplaceAll(f.Message, dir+string(filepath.Separator), "")
+				got = append(got, f.String())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package shcv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// textEncoding is the encoding a values file was saved with.
+type textEncoding struct {
+	// bom is the byte order mark the file starts with, if any
+	bom []byte
+	// utf16 is the byte order of UTF-16 files, nil for UTF-8
+	utf16 binary.ByteOrder
+	// crlf indicates Windows line endings
+	crlf bool
+}
+
+// Byte order marks
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// decodeText converts file content saved by common editors to UTF-8 with Unix
+// line endings and returns the detected encoding. UTF-8 and UTF-16 files are
+// recognized by their byte order mark; UTF-16 files without one are recognized
+// by the zero bytes of ASCII characters.
+func decodeText(data []byte) ([]byte, textEncoding, error) {
+	var enc textEncoding
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		enc.bom, data = bomUTF8, data[len(bomUTF8):]
+	case bytes.HasPrefix(data, bomUTF16LE):
+		enc.bom, enc.utf16, data = bomUTF16LE, binary.LittleEndian, data[len(bomUTF16LE):]
+	case bytes.HasPrefix(data, bomUTF16BE):
+		enc.bom, enc.utf16, data = bomUTF16BE, binary.BigEndian, data[len(bomUTF16BE):]
+	case len(data) >= 2 && data[0] != 0 && data[1] == 0:
+		enc.utf16 = binary.LittleEndian
+	case len(data) >= 2 && data[0] == 0 && data[1] != 0:
+		enc.utf16 = binary.BigEndian
+	}
+
+	if enc.utf16 != nil {
+		if len(data)%2 != 0 {
+			return nil, enc, fmt.Errorf("invalid UTF-16 content: odd length")
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = enc.utf16.Uint16(data[2*i:])
+		}
+		var decoded bytes.Buffer
+		for _, r := range utf16.Decode(units) {
+			decoded.WriteRune(r)
+		}
+		data = decoded.Bytes()
+	} else if !utf8.Valid(data) {
+		return nil, enc, fmt.Errorf("invalid UTF-8 content")
+	}
+
+	if bytes.Contains(data, []byte("\r\n")) {
+		enc.crlf = true
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	}
+	return data, enc, nil
+}
+
+// encode converts UTF-8 content with Unix line endings back to the encoding.
+func (enc textEncoding) encode(data []byte) []byte {
+	if enc.crlf {
+		data = bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+	}
+	if enc.utf16 != nil {
+		units := utf16.Encode(bytes.Runes(data))
+		encoded := make([]byte, 2*len(units))
+		for i, unit := range units {
+			enc.utf16.PutUint16(encoded[2*i:], unit)
+		}
+		data = encoded
+	}
+	return append(append([]byte{}, enc.bom...), data...)
+}
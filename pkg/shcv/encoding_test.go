@@ -0,0 +1,91 @@
+package shcv
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeUTF16 encodes s as UTF-16 in the given byte order
+func encodeUTF16(s string, order binary.ByteOrder) []byte {
+	units := utf16.Encode([]rune(s))
+	data := make([]byte, 2*len(units))
+	for i, unit := range units {
+		order.PutUint16(data[2*i:], unit)
+	}
+	return data
+}
+
+func TestDecodeText(t *testing.T) {
+	const text = "name: café\nport: 80\n"
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "utf-8", data: []byte(text)},
+		{name: "utf-8 with bom", data: append([]byte{0xEF, 0xBB, 0xBF}, text...)},
+		{name: "crlf", data: []byte("name: café\r\nport: 80\r\n")},
+		{name: "utf-16le with bom", data: append([]byte{0xFF, 0xFE}, encodeUTF16(text, binary.LittleEndian)...)},
+		{name: "utf-16be with bom", data: append([]byte{0xFE, 0xFF}, encodeUTF16(text, binary.BigEndian)...)},
+		{name: "utf-16le without bom", data: encodeUTF16(text, binary.LittleEndian)},
+		{name: "utf-16le with bom and crlf", data: append([]byte{0xFF, 0xFE}, encodeUTF16("name: café\r\nport: 80\r\n", binary.LittleEndian)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, enc, err := decodeText(tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, text, string(decoded))
+
+			// Encoding the decoded text restores the original bytes
+			assert.Equal(t, tt.data, enc.encode(decoded))
+		})
+	}
+
+	t.Run("invalid utf-8", func(t *testing.T) {
+		_, _, err := decodeText([]byte{'a', 0xFF, 'b'})
+		assert.ErrorContains(t, err, "invalid UTF-8")
+	})
+
+	t.Run("odd utf-16 length", func(t *testing.T) {
+		_, _, err := decodeText([]byte{0xFF, 0xFE, 'a'})
+		assert.ErrorContains(t, err, "invalid UTF-16")
+	})
+}
+
+func TestValuesFileLayoutPreserved(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{"deployment.yaml": "{{ .Values.image.tag }}\n{{ .Values.service.port }}\n"})
+	valuesPath := filepath.Join(dir, "values.yaml")
+	require.NoError(t, os.WriteFile(valuesPath, []byte("image:\r\n    repository: nginx\r\n"), 0644))
+
+	chart := loadTestChart(t, dir)
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+
+	content, err := os.ReadFile(valuesPath)
+	require.NoError(t, err)
+	assert.Equal(t, "image:\r\n    repository: nginx\r\n    tag: \"\"\r\nservice:\r\n    port: \"\"\r\n", string(content))
+}
+
+func TestValuesFileEncodingPreserved(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{"deployment.yaml": "{{ .Values.name }}\n{{ .Values.port }}\n"})
+	original := append([]byte{0xFF, 0xFE}, encodeUTF16("name: app\r\n", binary.LittleEndian)...)
+	valuesPath := filepath.Join(dir, "values.yaml")
+	require.NoError(t, os.WriteFile(valuesPath, original, 0644))
+
+	chart := loadTestChart(t, dir)
+	assert.Equal(t, "app", chart.ValuesFiles[0].Values["name"])
+
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+
+	content, err := os.ReadFile(valuesPath)
+	require.NoError(t, err)
+	want := append([]byte{0xFF, 0xFE}, encodeUTF16("name: app\r\nport: \"\"\r\n", binary.LittleEndian)...)
+	assert.Equal(t, want, content)
+}
@@ -0,0 +1,74 @@
+package shcv
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// The errors of the chart operations, to be tested with errors.Is. ErrLocked
+// and the typed errors, such as InvalidValuesError, FileTooLargeError, RollbackError and
+// ScalarConflictError, are tested with errors.Is and errors.As as well.
+var (
+	// ErrChartNotFound is returned by NewChart when the chart directory does not exist
+	ErrChartNotFound = errors.New("chart directory not found")
+	// ErrTemplatesDirMissing is returned by FindTemplates when the chart has no
+	// templates directory
+	ErrTemplatesDirMissing = errors.New("templates directory not found")
+	// ErrWriteConflict is returned by UpdateValueFiles when a values file was
+	// modified since it was loaded, such as by an editor or another tool, as
+	// writing it would lose those changes. The file is left unchanged
+	ErrWriteConflict = errors.New("values file was modified since it was loaded")
+)
+
+// InvalidValuesError is returned by LoadValueFiles when a values file is not
+// valid YAML.
+type InvalidValuesError struct {
+	// File is the values file
+	File string
+	// Line is the line of the syntax error, or 0 if it is not known
+	Line int
+	// Err is the error of the YAML parser
+	Err error
+}
+
+// Error describes the syntax error.
+func (e *InvalidValuesError) Error() string {
+	return fmt.Sprintf("parsing values file %s: %v", e.File, e.Err)
+}
+
+// Unwrap returns the error of the YAML parser.
+func (e *InvalidValuesError) Unwrap() error {
+	return e.Err
+}
+
+// FileTooLargeError is returned by LoadValueFiles and ParseTemplates for a
+// values file or template larger than the limit of WithMaxFileSize. The file
+// is not read.
+type FileTooLargeError struct {
+	// File is the values file or template
+	File string
+	// Size is the size of the file in bytes
+	Size int64
+	// Limit is the maximum size in bytes
+	Limit int64
+}
+
+// Error describes the size of the file and the limit.
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("%s is %d bytes, more than the limit of %d bytes", e.File, e.Size, e.Limit)
+}
+
+// yamlErrorLine matches the line number in the errors of the YAML parser
+var yamlErrorLine = regexp.MustCompile(`\bline (\d+)\b`)
+
+// invalidValues returns the InvalidValuesError of a values file the YAML
+// parser failed on.
+func invalidValues(file string, err error) *InvalidValuesError {
+	invalid := &InvalidValuesError{File: file, Err: err}
+	if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		invalid.Line, _ = strconv.Atoi(m[1])
+	}
+	return invalid
+}
@@ -0,0 +1,90 @@
+package shcv
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrChartNotFound(t *testing.T) {
+	_, err := NewChart(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, err, ErrChartNotFound)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestErrTemplatesDirMissing(t *testing.T) {
+	chart, err := NewChart(t.TempDir())
+	require.NoError(t, err)
+	err = chart.FindTemplates()
+	assert.ErrorIs(t, err, ErrTemplatesDirMissing)
+	assert.ErrorContains(t, err, "templates directory not found")
+}
+
+func TestInvalidValuesError(t *testing.T) {
+	dir := writeTestChart(t, "name: app\nimage:\n  tag: [v1\n", nil)
+	chart, err := NewChart(dir)
+	require.NoError(t, err)
+	err = chart.LoadValueFiles()
+
+	var invalid *InvalidValuesError
+	require.True(t, errors.As(err, &invalid))
+	assert.Equal(t, filepath.Join(dir, "values.yaml"), invalid.File)
+	assert.Equal(t, 3, invalid.Line)
+	assert.ErrorContains(t, err, "parsing values file "+invalid.File)
+}
+
+func TestFileTooLargeError(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{"a.yaml": "a: {{ .Values.a }}\n", "b.yaml": "b: {{ .Values.b }}\n" + strings.Repeat("#", 100)})
+	chart, err := NewChart(dir, WithMaxFileSize(50))
+	require.NoError(t, err)
+	require.NoError(t, chart.LoadValueFiles())
+	require.NoError(t, chart.FindTemplates())
+	err = chart.ParseTemplates()
+
+	var tooLarge *FileTooLargeError
+	require.True(t, errors.As(err, &tooLarge))
+	assert.Equal(t, FileTooLargeError{File: filepath.Join(dir, "templates", "b.yaml"), Size: 119, Limit: 50}, *tooLarge)
+	assert.EqualError(t, err, tooLarge.File+" is 119 bytes, more than the limit of 50 bytes")
+
+	chart, err = NewChart(dir, WithMaxFileSize(5))
+	require.NoError(t, err)
+	err = chart.LoadValueFiles()
+	require.True(t, errors.As(err, &tooLarge))
+	assert.Equal(t, filepath.Join(dir, "values.yaml"), tooLarge.File)
+}
+
+func TestErrWriteConflict(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{"a.yaml": "{{ .Values.port }}\n"})
+	valuesPath := filepath.Join(dir, "values.yaml")
+	chart := loadTestChart(t, dir)
+	chart.ProcessReferences()
+
+	// An edit made after loading is not overwritten
+	require.NoError(t, os.WriteFile(valuesPath, []byte("name: web\n"), 0644))
+	assert.ErrorIs(t, chart.UpdateValueFiles(), ErrWriteConflict)
+	content, err := os.ReadFile(valuesPath)
+	require.NoError(t, err)
+	assert.Equal(t, "name: web\n", string(content))
+
+	// Neither is a file created after loading
+	require.NoError(t, os.Remove(valuesPath))
+	chart = loadTestChart(t, dir)
+	chart.ProcessReferences()
+	require.NoError(t, os.WriteFile(valuesPath, []byte("name: web\n"), 0644))
+	assert.ErrorIs(t, chart.UpdateValueFiles(), ErrWriteConflict)
+
+	// The chart's own writes are not conflicts
+	chart = loadTestChart(t, dir)
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+	chart.ValuesFiles[0].Values["zone"] = "eu"
+	require.NoError(t, chart.UpdateValueFiles())
+	content, err = os.ReadFile(valuesPath)
+	require.NoError(t, err)
+	assert.Equal(t, "name: web\nport: \"\"\nzone: eu\n", string(content))
+}
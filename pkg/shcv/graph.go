@@ -0,0 +1,100 @@
+package shcv
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Edge kinds of a ReferenceGraph
+const (
+	// EdgeReference connects a template to a value it references
+	EdgeReference = "references"
+	// EdgeDefault connects a value to the value its default is taken from, as
+	// in {{ .Values.b | default .Values.a }}
+	EdgeDefault = "defaults-to"
+)
+
+// GraphEdge is an edge of a ReferenceGraph.
+type GraphEdge struct {
+	// From is a template for EdgeReference and a value path for EdgeDefault
+	From string `json:"from"`
+	// To is a value path
+	To string `json:"to"`
+	// Kind is EdgeReference or EdgeDefault
+	Kind string `json:"kind"`
+}
+
+// ReferenceGraph describes which templates reference which values and which
+// values depend on others through their defaults.
+type ReferenceGraph struct {
+	// Templates lists the templates referencing values, relative to the chart directory
+	Templates []string `json:"templates"`
+	// Values lists the referenced value paths, sorted
+	Values []string `json:"values"`
+	// Edges lists the edges in the order the references were found
+	Edges []GraphEdge `json:"edges"`
+}
+
+// ReferenceGraph returns the reference graph of the parsed templates.
+func (c *Chart) ReferenceGraph() *ReferenceGraph {
+	graph := &ReferenceGraph{Templates: []string{}, Values: []string{}, Edges: []GraphEdge{}}
+	templates := make(map[string]bool)
+	values := make(map[string]bool)
+	edges := make(map[GraphEdge]bool)
+	addEdge := func(edge GraphEdge) {
+		if !edges[edge] {
+			edges[edge] = true
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+
+	for _, ref := range c.References {
+		template := c.relPath(ref.SourceFile)
+		if !templates[template] {
+			templates[template] = true
+			graph.Templates = append(graph.Templates, template)
+		}
+		values[ref.Path] = true
+		addEdge(GraphEdge{From: template, To: ref.Path, Kind: EdgeReference})
+		if ref.DefaultPath != "" {
+			values[ref.DefaultPath] = true
+			addEdge(GraphEdge{From: ref.Path, To: ref.DefaultPath, Kind: EdgeDefault})
+		}
+	}
+	for path := range values {
+		graph.Values = append(graph.Values, path)
+	}
+	sort.Strings(graph.Values)
+	return graph
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language. Templates are drawn
+// as boxes and default dependencies as dashed edges.
+func (g *ReferenceGraph) WriteDOT(w io.Writer) error {
+	node := func(kind, name string) string {
+		if kind == "template" {
+			return fmt.Sprintf("%q", name)
+		}
+		return fmt.Sprintf("%q", valuePrefix+name)
+	}
+
+	if _, err := fmt.Fprintln(w, "digraph values {\n\trankdir=LR;"); err != nil {
+		return err
+	}
+	for _, template := range g.Templates {
+		fmt.Fprintf(w, "\t%s [shape=box];\n", node("template", template))
+	}
+	for _, path := range g.Values {
+		fmt.Fprintf(w, "\t%s;\n", node("value", path))
+	}
+	for _, edge := range g.Edges {
+		if edge.Kind == EdgeDefault {
+			fmt.Fprintf(w, "\t%s -> %s [style=dashed, label=\"default\"];\n", node("value", edge.From), node("value", edge.To))
+			continue
+		}
+		fmt.Fprintf(w, "\t%s -> %s;\n", node("template", edge.From), node("value", edge.To))
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
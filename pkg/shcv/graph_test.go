@@ -0,0 +1,48 @@
+package shcv
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferenceGraph(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{
+		"a.yaml": "tag: {{ .Values.image.tag | default .Values.global.tag }}\nname: {{ .Values.name }}\n",
+		"b.yaml": "tag: {{ default .Values.global.tag .Values.image.tag }}\n",
+	})
+	chart := loadTestChart(t, dir)
+
+	graph := chart.ReferenceGraph()
+	assert.Equal(t, &ReferenceGraph{
+		Templates: []string{"templates/a.yaml", "templates/b.yaml"},
+		Values:    []string{"global.tag", "image.tag", "name"},
+		Edges: []GraphEdge{
+			{From: "templates/a.yaml", To: "image.tag", Kind: EdgeReference},
+			{From: "image.tag", To: "global.tag", Kind: EdgeDefault},
+			{From: "templates/a.yaml", To: "global.tag", Kind: EdgeReference},
+			{From: "templates/a.yaml", To: "name", Kind: EdgeReference},
+			{From: "templates/b.yaml", To: "image.tag", Kind: EdgeReference},
+			{From: "templates/b.yaml", To: "global.tag", Kind: EdgeReference},
+		},
+	}, graph)
+
+	var out bytes.Buffer
+	assert.NoError(t, graph.WriteDOT(&out))
+	assert.Equal(t, `digraph values {
+	rankdir=LR;
+	"templates/a.yaml" [shape=box];
+	"templates/b.yaml" [shape=box];
+	".Values.global.tag";
+	".Values.image.tag";
+	".Values.name";
+	"templates/a.yaml" -> ".Values.image.tag";
+	".Values.image.tag" -> ".Values.global.tag" [style=dashed, label="default"];
+	"templates/a.yaml" -> ".Values.global.tag";
+	"templates/a.yaml" -> ".Values.name";
+	"templates/b.yaml" -> ".Values.image.tag";
+	"templates/b.yaml" -> ".Values.global.tag";
+}
+`, out.String())
+}
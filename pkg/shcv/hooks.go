@@ -0,0 +1,51 @@
+package shcv
+
+// Hooks are functions called as a run progresses, such as to stream progress
+// to a user interface or collect metrics, see WithHooks. Every hook is
+// optional. The hooks are called from the goroutine running the chart.
+type Hooks struct {
+	// OnTemplateParsed is called by ParseTemplates after parsing a template,
+	// with the references found in it
+	OnTemplateParsed func(template string, refs []ValueRef)
+	// OnReferenceFound is called by ParseTemplates for every reference found,
+	// before OnTemplateParsed is called for its template
+	OnReferenceFound func(ref ValueRef)
+	// OnValueAdded is called by ProcessReferences before adding the value of a
+	// missing reference to a values file. Returning false vetoes the change:
+	// the value is not added
+	OnValueAdded func(file string, ref ValueRef, value any) bool
+	// OnFileWritten is called after a values file or template is written
+	OnFileWritten func(path string)
+}
+
+// hooks returns the hooks of the run.
+func (c *config) hooks() Hooks {
+	if c == nil {
+		return Hooks{}
+	}
+	return c.Hooks
+}
+
+// templateParsed calls the OnReferenceFound and OnTemplateParsed hooks.
+func (h Hooks) templateParsed(template string, refs []ValueRef) {
+	if h.OnReferenceFound != nil {
+		for _, ref := range refs {
+			h.OnReferenceFound(ref)
+		}
+	}
+	if h.OnTemplateParsed != nil {
+		h.OnTemplateParsed(template, refs)
+	}
+}
+
+// valueAdded calls the OnValueAdded hook and reports whether the value is added.
+func (h Hooks) valueAdded(file string, ref ValueRef, value any) bool {
+	return h.OnValueAdded == nil || h.OnValueAdded(file, ref, value)
+}
+
+// fileWritten calls the OnFileWritten hook.
+func (h Hooks) fileWritten(path string) {
+	if h.OnFileWritten != nil {
+		h.OnFileWritten(path)
+	}
+}
@@ -0,0 +1,53 @@
+package shcv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooks(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{
+		"a.yaml": "{{ .Values.name }} {{ .Values.port | default 80 }}\n",
+		"b.yaml": "{{ .Values.secret }}\n",
+	})
+	valuesPath := filepath.Join(dir, "values.yaml")
+
+	var events []string
+	hooks := Hooks{
+		OnTemplateParsed: func(template string, refs []ValueRef) {
+			events = append(events, "parsed "+filepath.Base(template))
+		},
+		OnReferenceFound: func(ref ValueRef) {
+			events = append(events, "found "+ref.Path)
+		},
+		OnValueAdded: func(file string, ref ValueRef, value any) bool {
+			assert.Equal(t, valuesPath, file)
+			events = append(events, "add "+ref.Path)
+			// Secrets are given at install time
+			return ref.Path != "secret"
+		},
+		OnFileWritten: func(path string) {
+			events = append(events, "wrote "+filepath.Base(path))
+		},
+	}
+	chart, err := NewChart(dir, WithHooks(hooks))
+	require.NoError(t, err)
+	report, err := chart.Sync(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"found name", "found port", "parsed a.yaml",
+		"found secret", "parsed b.yaml",
+		"add port", "add secret",
+		"wrote values.yaml",
+	}, events)
+	assert.Equal(t, map[string][]string{valuesPath: {"port"}}, report.Added)
+	content, err := os.ReadFile(valuesPath)
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\nport: 80\n", string(content))
+}
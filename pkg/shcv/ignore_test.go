@@ -0,0 +1,78 @@
+package shcv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreDirectives(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     []string
+	}{
+		{
+			name:     "same line",
+			template: "password: {{ .Values.db.password }} # shcv:ignore\nname: {{ .Values.name }}\n",
+			want:     []string{"db.password"},
+		},
+		{
+			name:     "YAML comment on the line before",
+			template: "# shcv:ignore\npassword: {{ .Values.db.password }}\nname: {{ .Values.name }}\n",
+			want:     []string{"db.password"},
+		},
+		{
+			name:     "template comment on the line before",
+			template: "{{- /* shcv:ignore */ -}}\npassword: {{ .Values.db.password }}\n",
+			want:     []string{"db.password"},
+		},
+		{
+			name:     "listed paths",
+			template: "{{/* shcv:ignore db.user,db.port */}}\ndsn: {{ .Values.db.user }}@{{ .Values.db.host }}\n",
+			want:     []string{"db.user"},
+		},
+		{
+			name:     "directive on a line with content does not apply to the next line",
+			template: "a: {{ .Values.a }} # shcv:ignore\nb: {{ .Values.b }}\n",
+			want:     []string{"a"},
+		},
+		{
+			name:     "other words",
+			template: "# shcv:ignored\na: {{ .Values.a }}\n",
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ignored []string
+			for _, ref := range ParseFile(tt.template, "test.yaml") {
+				if ref.Ignored {
+					ignored = append(ignored, ref.Path)
+				}
+			}
+			assert.Equal(t, tt.want, ignored)
+		})
+	}
+}
+
+func TestIgnoredReferencesNotSynced(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{
+		"a.yaml": "password: {{ .Values.db.password }} # shcv:ignore\nname: {{ .Values.name }}\n",
+		"b.yaml": "{{/* shcv:ignore */}}\nhost: {{ .Values.db.host }}\nregion: {{ .Values.db.host }}\n",
+	})
+	chart := loadTestChart(t, dir)
+	var missing []string
+	for _, finding := range chart.MissingValues() {
+		missing = append(missing, finding.Path)
+	}
+	assert.ElementsMatch(t, []string{"name", "db.host"}, missing)
+
+	changes, err := chart.ScaffoldValues(false)
+	require.NoError(t, err)
+	assert.NotContains(t, string(changes[0].After), "password")
+
+	chart.ProcessReferences()
+	assert.ElementsMatch(t, []string{"name", "db.host"}, chart.ValuesFiles[0].Added)
+}
@@ -0,0 +1,81 @@
+package shcv
+
+// ReferenceIndex is the references of the templates grouped by value path,
+// see Chart.Index.
+type ReferenceIndex struct {
+	// Paths lists the value paths in the order they were first referenced
+	Paths []string
+	// Values maps every value path to its references
+	Values map[string]*IndexedValue
+}
+
+// IndexedValue is a value path of a ReferenceIndex with all its references.
+type IndexedValue struct {
+	// Path is the value path, such as image.tag
+	Path string
+	// Occurrences holds every reference to the path, in the order found
+	Occurrences []ValueRef
+	// DefaultValue is the default written for the value: the default of the
+	// first reference giving one, empty if none does
+	DefaultValue string
+	// DefaultUnquoted records that DefaultValue was an unquoted literal
+	DefaultUnquoted bool
+	// Type is the type of the first reference whose type is known
+	Type ValueType
+	// Conflicts holds the first reference of every distinct default when the
+	// references give differing defaults, nil otherwise, see DefaultConflict
+	Conflicts []ValueRef
+	// Ignored records that every reference is ignored, see IgnoreDirective
+	Ignored bool
+}
+
+// Index returns the references of the templates, which must have been parsed,
+// grouped by value path, with the default and type chosen for every value and
+// the conflicting defaults. The index is built from References when called.
+func (c *Chart) Index() *ReferenceIndex {
+	type literal struct {
+		value    string
+		unquoted bool
+	}
+
+	index := &ReferenceIndex{Values: make(map[string]*IndexedValue)}
+	seen := make(map[string]map[literal]bool)
+	for _, ref := range c.References {
+		value, ok := index.Values[ref.Path]
+		if !ok {
+			value = &IndexedValue{Path: ref.Path, Ignored: true}
+			index.Values[ref.Path] = value
+			index.Paths = append(index.Paths, ref.Path)
+		}
+		value.Occurrences = append(value.Occurrences, ref)
+		value.Ignored = value.Ignored && ref.Ignored
+		if value.Type == TypeUnknown {
+			value.Type = ref.Type
+		}
+		if ref.DefaultValue == "" {
+			continue
+		}
+		if value.DefaultValue == "" {
+			value.DefaultValue, value.DefaultUnquoted = ref.DefaultValue, ref.DefaultUnquoted
+		}
+		if seen[ref.Path] == nil {
+			seen[ref.Path] = make(map[literal]bool)
+		}
+		if lit := (literal{ref.DefaultValue, ref.DefaultUnquoted}); !seen[ref.Path][lit] {
+			seen[ref.Path][lit] = true
+			value.Conflicts = append(value.Conflicts, ref)
+		}
+	}
+	for _, value := range index.Values {
+		if len(value.Conflicts) < 2 {
+			value.Conflicts = nil
+		}
+	}
+	return index
+}
+
+// Lookup returns the indexed value of a path.
+func (x *ReferenceIndex) Lookup(path string) (*IndexedValue, bool) {
+	value, ok := x.Values[path]
+	return value, ok
+}
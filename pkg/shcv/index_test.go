@@ -0,0 +1,44 @@
+package shcv
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{
+		"a.yaml": "port: {{ .Values.port }}\ntag: {{ .Values.image.tag | default \"1.0\" }}\n",
+		"b.yaml": "port: {{ .Values.port | int }}\ntag: {{ .Values.image.tag | default \"2.0\" }}\n" +
+			"secret: {{ .Values.secret }} # shcv:ignore\n",
+		"c.yaml": "tag: {{ .Values.image.tag | default \"1.0\" }}\n",
+	})
+	chart := loadTestChart(t, dir)
+	index := chart.Index()
+	assert.Equal(t, []string{"port", "image.tag", "secret"}, index.Paths)
+
+	port, ok := index.Lookup("port")
+	require.True(t, ok)
+	assert.Len(t, port.Occurrences, 2)
+	assert.Equal(t, TypeInt, port.Type)
+	assert.Empty(t, port.DefaultValue)
+	assert.Nil(t, port.Conflicts)
+	assert.False(t, port.Ignored)
+
+	tag, ok := index.Lookup("image.tag")
+	require.True(t, ok)
+	assert.Len(t, tag.Occurrences, 3)
+	assert.Equal(t, "1.0", tag.DefaultValue)
+	require.Len(t, tag.Conflicts, 2)
+	assert.Equal(t, filepath.Join(dir, "templates", "a.yaml"), tag.Conflicts[0].SourceFile)
+	assert.Equal(t, "2.0", tag.Conflicts[1].DefaultValue)
+
+	secret, ok := index.Lookup("secret")
+	require.True(t, ok)
+	assert.True(t, secret.Ignored)
+
+	_, ok = index.Lookup("missing")
+	assert.False(t, ok)
+}
@@ -0,0 +1,207 @@
+package shcv
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Injector adds values to the values files for the templates it detects, and
+// rewrites those templates to use them, such as DeploymentStrategyInjector,
+// see WithInjectors.
+type Injector interface {
+	// Detect reports whether the injector applies to a template, given its
+	// content
+	Detect(template []byte) bool
+	// ValuesPatch returns the values to add, keyed by value path, such as
+	// deployment.strategy. Values the values files already define are kept
+	ValuesPatch() map[string]any
+	// RewriteTemplate returns the content of a detected template rewritten to
+	// use the values of the patch. It is called when a value was added
+	RewriteTemplate(template []byte) []byte
+}
+
+// injectors returns the injectors of the run: the deployment strategy injector
+// when it is enabled, followed by those of WithInjectors.
+func (c *config) injectors() []Injector {
+	if c == nil {
+		return nil
+	}
+	var injectors []Injector
+	if c.InjectDeploymentStrategy {
+		injectors = append(injectors, DeploymentStrategyInjector{})
+	}
+	return append(injectors, c.Injectors...)
+}
+
+// inject runs the injectors on a template: every injector detecting it adds
+// its values patch to the values files missing them, and rewrites the template
+// if a value was added. The template is written once, after all injectors ran,
+// or recorded as a template change in dry-run mode.
+func (c *Chart) inject(templatePath string, injectors []Injector) error {
+	content, err := os.ReadFile(templatePath)
+	if err != nil {
+		return fmt.Errorf("reading template: %w", err)
+	}
+
+	updated := content
+	for _, injector := range injectors {
+		if !injector.Detect(updated) {
+			continue
+		}
+		c.config.logger().Debug("injecting values", "file", templatePath, "injector", fmt.Sprintf("%T", injector))
+		if c.injectValues(injector.ValuesPatch()) {
+			updated = injector.RewriteTemplate(updated)
+		}
+	}
+	if bytes.Equal(updated, content) {
+		return nil
+	}
+
+	if c.config.DryRun {
+		c.recordTemplateChange(FileChange{Path: templatePath, Before: content, After: updated})
+		return nil
+	}
+	err = c.withLock(func() error {
+		if err := c.backup(templatePath); err != nil {
+			return err
+		}
+		return writeFileAtomic(templatePath, updated, c.config.fileMode())
+	})
+	if err != nil {
+		return fmt.Errorf("updating template: %w", err)
+	}
+	c.config.hooks().fileWritten(templatePath)
+	return nil
+}
+
+// injectValues adds the values of a patch to the values files receiving
+// additions that do not define them, and reports whether a value was added.
+func (c *Chart) injectValues(patch map[string]any) bool {
+	paths := make([]string, 0, len(patch))
+	for path := range patch {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	added := false
+	for i := range c.ValuesFiles {
+		if !c.receivesAdditions(i) {
+			continue
+		}
+		file := &c.ValuesFiles[i]
+		if file.Values == nil {
+			file.Values = make(map[string]any)
+		}
+		for _, path := range paths {
+			// An override layer defining the value overrides the base layer
+			if c.defined(i, path) {
+				c.config.logger().Debug("injected value already exists", "file", file.Path, "path", path)
+				continue
+			}
+			setNestedValue(file.Values, path, copyValue(patch[path]))
+			file.Changed = true
+			file.Added = append(file.Added, path)
+			file.injected = append(file.injected, path)
+			added = true
+		}
+	}
+	return added
+}
+
+// strategyPath is the value path of the injected deployment strategy
+const strategyPath = "deployment.strategy"
+
+// DeploymentStrategyInjector is the Injector of WithDeploymentStrategyInjection:
+// it adds a RollingUpdate strategy at deployment.strategy, and a strategy block
+// reading it to the spec of Deployment templates setting no strategy.
+type DeploymentStrategyInjector struct{}
+
+// Detect reports whether the template is a Deployment with a block spec.
+func (DeploymentStrategyInjector) Detect(template []byte) bool {
+	// Detect deployments from the manifest outline, which copes with template
+	// actions, flow style and tab indentation that a YAML parser would reject
+	outline := outlineManifest(string(template))
+	if !hasKind(outline, "Deployment") {
+		return false
+	}
+	spec, ok := deploymentSpec(outline)
+	return !ok || isBlockKey(spec)
+}
+
+// ValuesPatch returns the default deployment strategy.
+func (DeploymentStrategyInjector) ValuesPatch() map[string]any {
+	return map[string]any{
+		strategyPath: map[string]any{
+			"type": "RollingUpdate",
+			"rollingUpdate": map[string]any{
+				"maxSurge":       1,
+				"maxUnavailable": 0,
+			},
+		},
+	}
+}
+
+// RewriteTemplate adds the strategy block to the spec of the deployment.
+func (DeploymentStrategyInjector) RewriteTemplate(template []byte) []byte {
+	return updateDeploymentTemplate(template)
+}
+
+// updateDeploymentTemplate adds the strategy configuration to a deployment template
+func updateDeploymentTemplate(content []byte) []byte {
+	lines := strings.Split(string(content), "\n")
+
+	// Find the spec: line of the deployment and check for an existing strategy
+	outline := outlineManifest(string(content))
+	spec, ok := deploymentSpec(outline)
+	if !ok || !isBlockKey(spec) {
+		return content
+	}
+	for _, line := range outline {
+		if line.Doc == spec.Doc && line.Key == "strategy" && len(line.Parents) == 1 && line.Parents[0] == "spec" {
+			return content
+		}
+	}
+	specIndex := spec.Number - 1
+	specIndent := lines[specIndex][:len(lines[specIndex])-len(strings.TrimLeft(lines[specIndex], " \t"))]
+
+	// Find the indentation of the first item under spec, keeping its tabs or spaces
+	baseIndent := ""
+	indentUnit := "  " // Default indent
+	for i := specIndex + 1; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "{{") {
+			continue
+		}
+		if len(line) > len(trimmed) {
+			baseIndent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			if strings.HasPrefix(baseIndent, specIndent) && len(baseIndent) > len(specIndent) {
+				indentUnit = baseIndent[len(specIndent):]
+			}
+			break
+		}
+	}
+	if baseIndent == "" {
+		baseIndent = specIndent + indentUnit
+	}
+
+	// Create the strategy section with proper indentation
+	strategySection := []string{
+		baseIndent + "strategy:",
+		baseIndent + indentUnit + "type: {{ .Values.deployment.strategy.type }}",
+		baseIndent + indentUnit + "rollingUpdate:",
+		baseIndent + strings.Repeat(indentUnit, 2) + "maxSurge: {{ .Values.deployment.strategy.rollingUpdate.maxSurge }}",
+		baseIndent + strings.Repeat(indentUnit, 2) + "maxUnavailable: {{ .Values.deployment.strategy.rollingUpdate.maxUnavailable }}",
+	}
+
+	// Insert the strategy section right after spec:
+	result := make([]string, 0, len(lines)+len(strategySection))
+	result = append(result, lines[:specIndex+1]...)
+	result = append(result, strategySection...)
+	result = append(result, lines[specIndex+1:]...)
+
+	return []byte(strings.Join(result, "\n"))
+}
@@ -0,0 +1,72 @@
+package shcv
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serviceTypeInjector sets the type of Services from service.type.
+type serviceTypeInjector struct{}
+
+func (serviceTypeInjector) Detect(template []byte) bool {
+	return bytes.Contains(template, []byte("kind: Service\n"))
+}
+
+func (serviceTypeInjector) ValuesPatch() map[string]any {
+	return map[string]any{"service.type": "ClusterIP"}
+}
+
+func (serviceTypeInjector) RewriteTemplate(template []byte) []byte {
+	return bytes.Replace(template, []byte("spec:\n"), []byte("spec:\n  type: {{ .Values.service.type }}\n"), 1)
+}
+
+func TestInjectors(t *testing.T) {
+	service := "kind: Service\nspec:\n  port: {{ .Values.port }}\n"
+	dir := writeTestChart(t, "port: 80\n", map[string]string{
+		"service.yaml":    service,
+		"deployment.yaml": "kind: Deployment\nspec:\n  replicas: 1\n",
+	})
+	servicePath := filepath.Join(dir, "templates", "service.yaml")
+	deploymentPath := filepath.Join(dir, "templates", "deployment.yaml")
+
+	// Only the detected templates are rewritten, and the values are injected
+	chart := loadTestChart(t, dir, WithDryRun(true), WithInjectors(serviceTypeInjector{}))
+	chart.ProcessReferences()
+	assert.Equal(t, []Change{
+		{File: filepath.Join(dir, "values.yaml"), Path: "service.type", New: "ClusterIP", Reason: ChangeInjected},
+	}, chart.PlanChanges())
+	changes, err := chart.Changes()
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, servicePath, changes[0].Path)
+	assert.Equal(t, "kind: Service\nspec:\n  type: {{ .Values.service.type }}\n  port: {{ .Values.port }}\n", string(changes[0].After))
+
+	// Custom injectors run after the deployment strategy injector
+	chart = loadTestChart(t, dir, WithDeploymentStrategyInjection(true), WithInjectors(serviceTypeInjector{}))
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+	assert.Equal(t, []string{strategyPath, "service.type"}, chart.ValuesFiles[0].Added)
+	content, err := os.ReadFile(deploymentPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "strategy:\n    type: {{ .Values.deployment.strategy.type }}")
+	content, err = os.ReadFile(servicePath)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "type: {{ .Values.service.type }}")
+
+	// Templates are not rewritten when the values files define the values
+	require.NoError(t, os.WriteFile(servicePath, []byte(service), 0644))
+	chart = loadTestChart(t, dir, WithInjectors(serviceTypeInjector{}))
+	chart.ProcessReferences()
+	assert.Empty(t, chart.ValuesFiles[0].Added)
+	content, err = os.ReadFile(servicePath)
+	require.NoError(t, err)
+	assert.Equal(t, service, string(content))
+
+	_, err = NewChart(dir, WithInjectors(nil))
+	assert.ErrorContains(t, err, "injector 0 is nil")
+}
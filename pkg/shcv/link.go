@@ -0,0 +1,95 @@
+package shcv
+
+import "reflect"
+
+// ValueLink keeps the value at Path the same as the value at Source in every
+// values file, for values that must match, such as the port of a service and
+// of the gateway routing to it. When Source is defined and Path is missing or
+// differs, ProcessReferences sets Path to a copy of Source; when only Path is
+// defined, it is copied to Source instead.
+type ValueLink struct {
+	// Path is the value path mirroring Source, such as service.port
+	Path string
+	// Source is the value path mirrored, such as gateway.port
+	Source string
+	// Anchor writes a missing path as a YAML alias of the value it mirrors,
+	// which is given an anchor if it has none. Paths below a missing map, and
+	// values that cannot be anchored, are written as a copy.
+	Anchor bool
+}
+
+// valueLinks returns the configured value links; a nil config has none.
+func (c *config) valueLinks() []ValueLink {
+	if c == nil {
+		return nil
+	}
+	return c.ValueLinks
+}
+
+// missingLinks returns the link paths and sources a values file does not define.
+func (c *Chart) missingLinks(file *ValueFile) map[string]bool {
+	missing := make(map[string]bool)
+	for _, link := range c.config.valueLinks() {
+		for _, path := range []string{link.Path, link.Source} {
+			if !valueExists(file.Values, path) {
+				missing[path] = true
+			}
+		}
+	}
+	return missing
+}
+
+// linkValues applies the value links to a values file. Paths in missing were
+// not defined when the file was loaded, so anchor links write them as aliases.
+func (c *Chart) linkValues(file *ValueFile, missing map[string]bool) {
+	for _, link := range c.config.valueLinks() {
+		from, to := link.Source, link.Path
+		value, ok := lookupValue(file.Values, from)
+		if !ok {
+			if value, ok = lookupValue(file.Values, to); !ok {
+				continue
+			}
+			from, to = to, from
+		}
+		if current, ok := lookupValue(file.Values, to); ok && reflect.DeepEqual(current, value) {
+			continue
+		}
+		if err := scalarConflict(file, to); err != nil {
+			file.Conflicts = append(file.Conflicts, err)
+			continue
+		}
+
+		if link.Anchor && missing[to] {
+			if file.aliases == nil {
+				file.aliases = make(map[string]string)
+			}
+			file.aliases[to] = from
+		}
+		if !valueExists(file.Values, to) {
+			file.Added = append(file.Added, to)
+		}
+		setNestedValue(file.Values, to, copyValue(value))
+		file.Changed = true
+	}
+}
+
+// copyValue returns a deep copy of a decoded value, so that linked values do
+// not share maps and lists.
+func copyValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(v))
+		for key, nested := range v {
+			copied[key] = copyValue(nested)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(v))
+		for i, nested := range v {
+			copied[i] = copyValue(nested)
+		}
+		return copied
+	default:
+		return value
+	}
+}
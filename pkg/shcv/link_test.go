@@ -0,0 +1,122 @@
+package shcv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessReferencesLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  map[string]any
+		refs    []ValueRef
+		want    map[string]any
+		changed bool
+	}{
+		{
+			name:   "missing path is copied from the source",
+			values: map[string]any{"gateway": map[string]any{"port": 8080.0}},
+			want: map[string]any{
+				"gateway": map[string]any{"port": 8080.0},
+				"service": map[string]any{"port": 8080.0},
+			},
+			changed: true,
+		},
+		{
+			name: "differing path follows the source",
+			values: map[string]any{
+				"gateway": map[string]any{"port": 8080.0},
+				"service": map[string]any{"port": 80.0},
+			},
+			want: map[string]any{
+				"gateway": map[string]any{"port": 8080.0},
+				"service": map[string]any{"port": 8080.0},
+			},
+			changed: true,
+		},
+		{
+			name:   "missing source is copied from the path",
+			values: map[string]any{"service": map[string]any{"port": 80.0}},
+			want: map[string]any{
+				"gateway": map[string]any{"port": 80.0},
+				"service": map[string]any{"port": 80.0},
+			},
+			changed: true,
+		},
+		{
+			name:   "source added from a template default",
+			values: map[string]any{},
+			refs:   []ValueRef{{Path: "gateway.port", DefaultValue: "443", DefaultUnquoted: true, Type: TypeInt}},
+			want: map[string]any{
+				"gateway": map[string]any{"port": 443},
+				"service": map[string]any{"port": 443},
+			},
+			changed: true,
+		},
+		{
+			name:   "neither is defined",
+			values: map[string]any{"name": "app"},
+			want:   map[string]any{"name": "app"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := &Chart{
+				References:  tt.refs,
+				ValuesFiles: []ValueFile{{Path: "values.yaml", Values: tt.values}},
+				config:      newConfig([]Option{WithValueLinks(ValueLink{Path: "service.port", Source: "gateway.port"})}),
+			}
+			chart.ProcessReferences()
+			assert.Equal(t, tt.want, chart.ValuesFiles[0].Values)
+			assert.Equal(t, tt.changed, chart.ValuesFiles[0].Changed)
+		})
+	}
+}
+
+func TestProcessReferencesLinkConflict(t *testing.T) {
+	chart := &Chart{
+		ValuesFiles: []ValueFile{{Path: "values.yaml", Values: map[string]any{
+			"gateway": map[string]any{"port": 8080.0},
+			"service": "web",
+		}}},
+		config: newConfig([]Option{WithValueLinks(ValueLink{Path: "service.port", Source: "gateway.port"})}),
+	}
+	chart.ProcessReferences()
+
+	file := chart.ValuesFiles[0]
+	assert.Equal(t, "web", file.Values["service"])
+	assert.False(t, file.Changed)
+	assert.Empty(t, file.Added)
+	require.Len(t, file.Conflicts, 1)
+	assert.Equal(t, "service.port", file.Conflicts[0].Path)
+}
+
+func TestLinkedValuesAnchor(t *testing.T) {
+	dir := writeTestChart(t, "gateway:\n  port: 8080 # public port\nservice:\n  name: web\n", nil)
+	chart := loadTestChart(t, dir, WithValueLinks(ValueLink{Path: "service.port", Source: "gateway.port", Anchor: true}))
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+
+	data, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "gateway:\n  port: &gateway-port 8080 # public port\nservice:\n  name: web\n  port: *gateway-port\n", string(data))
+
+	// The alias keeps the values equal, so a second run changes nothing
+	chart = loadTestChart(t, dir, WithValueLinks(ValueLink{Path: "service.port", Source: "gateway.port", Anchor: true}))
+	chart.ProcessReferences()
+	assert.False(t, chart.ValuesFiles[0].Changed)
+}
+
+func TestCopyValue(t *testing.T) {
+	original := map[string]any{"hosts": []any{map[string]any{"name": "a"}}}
+	copied := copyValue(original).(map[string]any)
+	assert.Equal(t, original, copied)
+
+	copied["hosts"].([]any)[0].(map[string]any)["name"] = "b"
+	assert.Equal(t, "a", original["hosts"].([]any)[0].(map[string]any)["name"])
+}
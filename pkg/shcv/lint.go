@@ -0,0 +1,263 @@
+package shcv
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Lint rule names, reported in Finding.Check
+const (
+	// LintNoDefault reports values without a template default that no values
+	// file defines, which a sync adds as empty placeholders
+	LintNoDefault = "no-default"
+	// LintConflictingDefaults reports values given differing defaults by their
+	// references, see DefaultConflicts
+	LintConflictingDefaults = "conflicting-defaults"
+	// LintMixedCase reports the keys written in the less common of camelCase
+	// and kebab-case, when the chart uses both
+	LintMixedCase = "mixed-case"
+	// LintDeepPath reports value paths nested deeper than LintPolicy.MaxDepth
+	LintDeepPath = "deep-path"
+	// LintSingleUse reports values referenced by a single template reference
+	LintSingleUse = "single-use"
+)
+
+// LintRules lists the lint rules, in the order Lint runs them
+var LintRules = []string{LintNoDefault, LintConflictingDefaults, LintMixedCase, LintDeepPath, LintSingleUse}
+
+// DefaultLintMaxDepth is the deepest nesting LintDeepPath accepts by default
+const DefaultLintMaxDepth = 4
+
+// LintPolicy configures the rules run by Lint.
+type LintPolicy struct {
+	// Disabled lists the rules not run, such as LintSingleUse
+	Disabled []string
+	// MaxDepth is the number of keys a value path may have before LintDeepPath
+	// reports it (default: DefaultLintMaxDepth)
+	MaxDepth int
+}
+
+// enabled reports whether the policy runs a rule.
+func (p *LintPolicy) enabled(rule string) bool {
+	return !slices.Contains(p.Disabled, rule)
+}
+
+// maxDepth returns the deepest nesting accepted by LintDeepPath.
+func (p *LintPolicy) maxDepth() int {
+	if p.MaxDepth == 0 {
+		return DefaultLintMaxDepth
+	}
+	return p.MaxDepth
+}
+
+// Lint checks the hygiene of the chart's values layout with the rules of the
+// lint policy, see LintRules, and returns their findings: every value path is
+// reported at most once per rule, at its first reference, or at its definition
+// for keys only the values files hold. Unlike RunChecks, the rules report
+// matters of style rather than misconfigurations. All rules run unless disabled
+// with WithLintPolicy.
+//
+// The values files must have been loaded and the templates parsed.
+func (c *Chart) Lint() ([]Finding, error) {
+	policy := &LintPolicy{}
+	if c.config != nil && c.config.LintPolicy != nil {
+		policy = c.config.LintPolicy
+	}
+
+	rules := map[string]func(*LintPolicy) []Finding{
+		LintNoDefault:           c.lintNoDefault,
+		LintConflictingDefaults: c.lintConflictingDefaults,
+		LintMixedCase:           c.lintMixedCase,
+		LintDeepPath:            c.lintDeepPath,
+		LintSingleUse:           c.lintSingleUse,
+	}
+	var findings []Finding
+	for _, rule := range LintRules {
+		if policy.enabled(rule) {
+			findings = append(findings, rules[rule](policy)...)
+		}
+	}
+	return findings, nil
+}
+
+// firstReferences returns the first reference of every value path, in the
+// order the paths were first referenced, and the number of references of each.
+func (c *Chart) firstReferences() ([]ValueRef, map[string]int) {
+	var first []ValueRef
+	counts := make(map[string]int)
+	for _, ref := range c.References {
+		if counts[ref.Path] == 0 {
+			first = append(first, ref)
+		}
+		counts[ref.Path]++
+	}
+	return first, counts
+}
+
+// lintNoDefault reports the values that neither a template default nor a values
+// file defines.
+func (c *Chart) lintNoDefault(*LintPolicy) []Finding {
+	defaults := make(map[string]bool)
+	for _, ref := range c.References {
+		if ref.DefaultValue != "" {
+			defaults[ref.Path] = true
+		}
+	}
+	first, _ := c.firstReferences()
+	var findings []Finding
+	for _, ref := range first {
+		if defaults[ref.Path] {
+			continue
+		}
+		if _, ok := c.resolvedValue(ref); ok {
+			continue
+		}
+		findings = append(findings, Finding{
+			Check:      LintNoDefault,
+			Path:       ref.Path,
+			SourceFile: ref.SourceFile,
+			LineNumber: ref.LineNumber,
+			Message:    fmt.Sprintf(".Values.%s has no default in the templates or the values files", ref.Path),
+		})
+	}
+	return findings
+}
+
+// lintConflictingDefaults reports the values whose references have differing
+// defaults, at their first reference.
+func (c *Chart) lintConflictingDefaults(*LintPolicy) []Finding {
+	var findings []Finding
+	for _, conflict := range c.DefaultConflicts() {
+		ref := conflict.Refs[0]
+		findings = append(findings, Finding{
+			Check:      LintConflictingDefaults,
+			Path:       conflict.Path,
+			SourceFile: ref.SourceFile,
+			LineNumber: ref.LineNumber,
+			Message:    conflict.String(),
+		})
+	}
+	return findings
+}
+
+// keyCase returns the case a key is written in, CaseCamel or CaseKebab, or ""
+// for keys fitting both, such as "image", or neither.
+func keyCase(key string) string {
+	switch {
+	case strings.Contains(key, "-") && caseKeys[CaseKebab].MatchString(key):
+		return CaseKebab
+	case strings.ToLower(key) != key && caseKeys[CaseCamel].MatchString(key):
+		return CaseCamel
+	}
+	return ""
+}
+
+// lintMixedCase reports the keys written in the less common case when the
+// keys of the chart are written both in camelCase and in kebab-case. The keys
+// of the templates' references and of the values files are counted, except
+// for the data below values used as a whole. On a tie, the kebab-case keys are
+// reported, as Helm recommends camelCase.
+func (c *Chart) lintMixedCase(*LintPolicy) []Finding {
+	type location struct {
+		path, file string
+		line       int
+	}
+	var keys []location
+	referenced := make(map[string]bool, len(c.References))
+	for _, ref := range c.References {
+		referenced[ref.Path] = true
+		keys = append(keys, location{ref.Path, ref.SourceFile, ref.LineNumber})
+	}
+	for _, file := range c.ValuesFiles {
+		for _, key := range valueKeys(file.source) {
+			if !usedWhole(referenced, key.path) {
+				keys = append(keys, location{key.path, file.Path, key.line})
+			}
+		}
+	}
+
+	// Every key is counted and reported once, at its first occurrence
+	seen := make(map[string]bool)
+	counts := make(map[string]int)
+	var cased []location
+	for _, loc := range keys {
+		parts := SplitValuePath(loc.path)
+		for i := range parts {
+			prefix := JoinValuePath(parts[:i+1]...)
+			if seen[prefix] {
+				continue
+			}
+			seen[prefix] = true
+			if style := keyCase(parts[i]); style != "" {
+				counts[style]++
+				cased = append(cased, location{prefix, loc.file, loc.line})
+			}
+		}
+	}
+	if counts[CaseCamel] == 0 || counts[CaseKebab] == 0 {
+		return nil
+	}
+
+	minority, majority := CaseKebab, CaseCamel
+	if counts[CaseKebab] > counts[CaseCamel] {
+		minority, majority = CaseCamel, CaseKebab
+	}
+	var findings []Finding
+	for _, loc := range cased {
+		parts := SplitValuePath(loc.path)
+		key := parts[len(parts)-1]
+		if keyCase(key) != minority {
+			continue
+		}
+		suggestion := JoinValuePath(append(append([]string{}, parts[:len(parts)-1]...), convertCase(key, majority))...)
+		findings = append(findings, Finding{
+			Check:      LintMixedCase,
+			Path:       loc.path,
+			SourceFile: loc.file,
+			LineNumber: loc.line,
+			Message: fmt.Sprintf("key %q of .Values.%s is %s, but %d of the %d cased keys are %s; rename it to .Values.%s",
+				key, loc.path, minority, counts[majority], counts[minority]+counts[majority], majority, suggestion),
+			Suggestion: suggestion,
+		})
+	}
+	return findings
+}
+
+// lintDeepPath reports the referenced value paths nested deeper than the
+// maximum depth of the policy.
+func (c *Chart) lintDeepPath(policy *LintPolicy) []Finding {
+	first, _ := c.firstReferences()
+	var findings []Finding
+	for _, ref := range first {
+		if depth := len(SplitValuePath(ref.Path)); depth > policy.maxDepth() {
+			findings = append(findings, Finding{
+				Check:      LintDeepPath,
+				Path:       ref.Path,
+				SourceFile: ref.SourceFile,
+				LineNumber: ref.LineNumber,
+				Message:    fmt.Sprintf(".Values.%s is nested %d keys deep, more than %d", ref.Path, depth, policy.maxDepth()),
+			})
+		}
+	}
+	return findings
+}
+
+// lintSingleUse reports the values referenced only once in the templates,
+// which could often be written in the template instead.
+func (c *Chart) lintSingleUse(*LintPolicy) []Finding {
+	first, counts := c.firstReferences()
+	var findings []Finding
+	for _, ref := range first {
+		if counts[ref.Path] == 1 {
+			findings = append(findings, Finding{
+				Check:      LintSingleUse,
+				Path:       ref.Path,
+				SourceFile: ref.SourceFile,
+				LineNumber: ref.LineNumber,
+				Message:    fmt.Sprintf(".Values.%s is only used once", ref.Path),
+			})
+		}
+	}
+	return findings
+}
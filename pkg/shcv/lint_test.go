@@ -0,0 +1,90 @@
+package shcv
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   *LintPolicy
+		values   string
+		template string
+		want     []string
+	}{
+		{
+			name:     "clean chart",
+			values:   "image:\n  pullPolicy: Always\n",
+			template: "a: {{ .Values.image.pullPolicy }}\nb: {{ .Values.image.pullPolicy }}\n",
+		},
+		{
+			name:     "values without defaults",
+			values:   "name: app\n",
+			template: "a: {{ .Values.name }} {{ .Values.port }} {{ .Values.tag | default \"v1\" }}\nb: {{ .Values.name }} {{ .Values.port }} {{ .Values.tag }}\n",
+			want:     []string{`no-default: templates/deployment.yaml:1: .Values.port has no default in the templates or the values files`},
+		},
+		{
+			name:     "conflicting defaults",
+			template: "a: {{ .Values.port | default 80 }}\nb: {{ .Values.port | default 81 }}\n",
+			want:     []string{`conflicting-defaults: templates/deployment.yaml:1: conflicting defaults for .Values.port: 80 (templates/deployment.yaml:1), 81 (templates/deployment.yaml:2); 80 is used`},
+		},
+		{
+			name:     "mixed camelCase and kebab-case",
+			values:   "serviceAccount:\n  create: true\nimage-pull-secrets: []\npodLabels: {}\n",
+			template: "a: {{ .Values.serviceAccount.create }} {{ .Values.serviceAccount.create }}\n",
+			policy:   &LintPolicy{Disabled: []string{LintSingleUse}},
+			want: []string{
+				`mixed-case: values.yaml:3: key "image-pull-secrets" of .Values.image-pull-secrets is kebab-case, but 2 of the 3 cased keys are camelCase; rename it to .Values.imagePullSecrets`,
+			},
+		},
+		{
+			name:     "deep paths",
+			policy:   &LintPolicy{MaxDepth: 2, Disabled: []string{LintNoDefault, LintSingleUse}},
+			template: "a: {{ .Values.a.b }} {{ .Values.a.b.c }}\n",
+			want:     []string{`deep-path: templates/deployment.yaml:1: .Values.a.b.c is nested 3 keys deep, more than 2`},
+		},
+		{
+			name:     "default depth",
+			policy:   &LintPolicy{Disabled: []string{LintNoDefault, LintSingleUse}},
+			template: "a: {{ .Values.a.b.c.d }} {{ .Values.a.b.c.d.e }}\n",
+			want:     []string{`deep-path: templates/deployment.yaml:1: .Values.a.b.c.d.e is nested 5 keys deep, more than 4`},
+		},
+		{
+			name:     "values used once",
+			values:   "name: app\nport: 80\n",
+			template: "a: {{ .Values.name }}\nb: {{ .Values.port }} {{ .Values.name }}\n",
+			want:     []string{`single-use: templates/deployment.yaml:2: .Values.port is only used once`},
+		},
+		{
+			name:     "disabled rules",
+			policy:   &LintPolicy{Disabled: []string{LintNoDefault, LintSingleUse}},
+			template: "a: {{ .Values.port }}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestChart(t, tt.values, map[string]string{"deployment.yaml": tt.template})
+			var opts []Option
+			if tt.policy != nil {
+				opts = append(opts, WithLintPolicy(*tt.policy))
+			}
+			chart := loadTestChart(t, dir, opts...)
+
+			findings, err := chart.Lint()
+			require.NoError(t, err)
+			var got []string
+			for _, f := range findings {
+				f.SourceFile = chart.relPath(f.SourceFile)
+				f.Message = strings.ReplaceAll(f.Message, dir+string(filepath.Separator), "")
+				got = append(got, f.Check+": "+f.String())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package shcv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LockFileName is the name of the lock file created in the chart directory.
+// Runs hold an advisory lock on it while writing values files and templates,
+// so concurrent runs on the same chart, such as parallel CI jobs, write one
+// after the other. The file is kept after the run and can be ignored.
+const LockFileName = ".shcv.lock"
+
+// defaultLockTimeout is how long a run waits for the chart lock by default
+const defaultLockTimeout = 30 * time.Second
+
+// lockRetryInterval is how often a run retries to acquire a held lock
+const lockRetryInterval = 50 * time.Millisecond
+
+// ErrLocked is returned when the chart lock is still held by another run when
+// the lock timeout expires.
+var ErrLocked = errors.New("chart is locked by another run")
+
+// withLock runs fn while holding the chart lock.
+func (c *Chart) withLock(fn func() error) error {
+	return c.withLockContext(context.Background(), fn)
+}
+
+// withLockContext runs fn while holding the chart lock, unless ctx is done
+// before the lock is acquired.
+func (c *Chart) withLockContext(ctx context.Context, fn func() error) error {
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return err
+	}
+	err = fn()
+	if unlockErr := unlock(); err == nil {
+		err = unlockErr
+	}
+	return err
+}
+
+// lock acquires the chart lock, waiting up to the lock timeout or until ctx
+// is done, and returns the function releasing it.
+func (c *Chart) lock(ctx context.Context) (func() error, error) {
+	path := filepath.Join(c.Dir, LockFileName)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(c.config.lockTimeout())
+	for {
+		locked, err := lockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("locking %s: %w", path, err)
+		}
+		if locked {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("waiting for %s: %w", path, ErrLocked)
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, fmt.Errorf("waiting for %s: %w", path, ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	c.config.logger().Debug("locked chart", "file", path)
+	return func() error {
+		err := unlockFile(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("unlocking %s: %w", path, err)
+		}
+		return nil
+	}, nil
+}
+
+// lockTimeout returns how long to wait for the chart lock.
+func (c *config) lockTimeout() time.Duration {
+	if c.lockTimeoutSet {
+		return c.LockTimeout
+	}
+	return defaultLockTimeout
+}
@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package shcv
+
+import "os"
+
+// lockFile reports the lock as acquired, as files cannot be locked on this platform.
+func lockFile(f *os.File) (bool, error) {
+	return true, nil
+}
+
+// unlockFile does nothing, as files cannot be locked on this platform.
+func unlockFile(f *os.File) error {
+	return nil
+}
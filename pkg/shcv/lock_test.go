@@ -0,0 +1,70 @@
+package shcv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChartLock(t *testing.T) {
+	dir := t.TempDir()
+	holder := &Chart{Dir: dir, config: newConfig(nil)}
+	unlock, err := holder.lock(context.Background())
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, LockFileName))
+
+	t.Run("fails when held", func(t *testing.T) {
+		other := &Chart{Dir: dir, config: newConfig([]Option{WithLockTimeout(0)})}
+		called := false
+		err := other.withLock(func() error {
+			called = true
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrLocked)
+		assert.False(t, called)
+	})
+
+	t.Run("waits for the holder", func(t *testing.T) {
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			unlock()
+		}()
+		other := &Chart{Dir: dir, config: newConfig([]Option{WithLockTimeout(5 * time.Second)})}
+		called := false
+		require.NoError(t, other.withLock(func() error {
+			called = true
+			return nil
+		}))
+		assert.True(t, called)
+	})
+}
+
+func TestUpdateValueFilesLocked(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{"service.yaml": "{{ .Values.port }}\n"})
+	holder := &Chart{Dir: dir, config: newConfig(nil)}
+	unlock, err := holder.lock(context.Background())
+	require.NoError(t, err)
+	defer unlock()
+
+	chart := loadTestChart(t, dir, WithLockTimeout(0))
+	chart.ProcessReferences()
+	assert.ErrorIs(t, chart.UpdateValueFiles(), ErrLocked)
+	content, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\n", string(content))
+
+	// Waiting for the lock stops at the deadline of the context
+	chart = loadTestChart(t, dir, WithLockTimeout(time.Minute))
+	chart.ProcessReferences()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, chart.UpdateValueFilesContext(ctx), context.DeadlineExceeded)
+	content, err = os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\n", string(content))
+}
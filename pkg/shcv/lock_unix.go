@@ -0,0 +1,24 @@
+//go:build unix
+
+package shcv
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile tries to acquire an exclusive lock on f without blocking and
+// reports whether it did.
+func lockFile(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlockFile releases the lock on f.
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
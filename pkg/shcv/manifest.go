@@ -0,0 +1,294 @@
+package shcv
+
+import (
+	"regexp"
+	"strings"
+)
+
+// manifestLine is a single line of a template annotated with its position in
+// the YAML structure of the manifest document it belongs to.
+type manifestLine struct {
+	// Number is the 1-based line number in the template
+	Number int
+	// Doc is the 0-based index of the YAML document containing the line
+	Doc int
+	// Kind is the Kubernetes kind of the document containing the line
+	Kind string
+	// Key is the mapping key declared on the line, if any
+	Key string
+	// Parents lists the enclosing mapping keys, outermost first
+	Parents []string
+	// Text is the raw line content
+	Text string
+}
+
+// at returns the line as seen from the given 1-based column: inside a flow
+// collection such as resources: {limits: {cpu: X}}, the flow keys enclosing the
+// column extend the key path of the line.
+func (l manifestLine) at(column int) manifestLine {
+	keys := flowKeys(l.Text, column-1)
+	if len(keys) == 0 {
+		return l
+	}
+	parents := append([]string{}, l.Parents...)
+	if l.Key != "" {
+		parents = append(parents, l.Key)
+	}
+	l.Parents = append(parents, keys[:len(keys)-1]...)
+	l.Key = keys[len(keys)-1]
+	return l
+}
+
+// under reports whether the line is nested below the given mapping key.
+func (l manifestLine) under(key string) bool {
+	for _, parent := range l.Parents {
+		if parent == key {
+			return true
+		}
+	}
+	return false
+}
+
+// outlineEntry is a mapping key on the outline stack.
+type outlineEntry struct {
+	indent int
+	key    string
+}
+
+// outlineManifest splits template content into YAML documents and annotates
+// every line with the document kind and its key path. Lines consisting only of
+// template actions do not affect the structure, so conditionals wrapped around
+// blocks keep the surrounding nesting intact.
+func outlineManifest(content string) []manifestLine {
+	lines := strings.Split(content, "\n")
+	result := make([]manifestLine, 0, len(lines))
+
+	doc := 0
+	kind := documentKind(lines, 0)
+	var stack []outlineEntry
+
+	for i, text := range lines {
+		trimmed := strings.TrimSpace(text)
+
+		// Document separators reset the structure
+		if trimmed == "---" {
+			doc++
+			kind = documentKind(lines, i+1)
+			stack = nil
+			result = append(result, manifestLine{Number: i + 1, Doc: doc, Kind: kind, Text: text})
+			continue
+		}
+
+		line := manifestLine{Number: i + 1, Doc: doc, Kind: kind, Text: text}
+
+		// Blank lines, comments and action-only lines keep the current nesting
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || isActionOnly(trimmed) {
+			line.Parents = stackKeys(stack)
+			result = append(result, line)
+			continue
+		}
+
+		indent := indentWidth(text)
+		body := trimmed
+		popIndent := indent
+
+		// List items nest their keys below the dash
+		if body == "-" || strings.HasPrefix(body, "- ") {
+			rest := strings.TrimLeft(strings.TrimPrefix(body, "-"), " ")
+			indent += len(body) - len(rest)
+			body = rest
+			popIndent++
+		}
+
+		// Leave the blocks this line is no longer part of
+		for len(stack) > 0 && stack[len(stack)-1].indent >= popIndent {
+			stack = stack[:len(stack)-1]
+		}
+
+		line.Parents = stackKeys(stack)
+		if key, ok := mappingKey(body); ok {
+			line.Key = key
+			stack = append(stack, outlineEntry{indent: indent, key: key})
+		}
+		result = append(result, line)
+	}
+
+	return result
+}
+
+// documentKind returns the kind declared in the document starting at the given line.
+// Both block style (kind: Deployment) and flow style documents
+// ({apiVersion: v1, kind: Service}) are recognized.
+func documentKind(lines []string, start int) string {
+	for i := start; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "---" {
+			break
+		}
+		if strings.HasPrefix(line, "kind:") {
+			return scalarValue(strings.TrimPrefix(line, "kind:"))
+		}
+		if strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, openBrace) {
+			if m := flowKind.FindStringSubmatch(trimmed); m != nil {
+				return m[1]
+			}
+		}
+	}
+	return ""
+}
+
+// flowKind matches the kind of a flow style document
+var flowKind = regexp.MustCompile(`[{,]\s*kind:\s*["']?([A-Za-z0-9]+)`)
+
+// scalarValue returns the plain scalar following a mapping key, without quotes
+// or a trailing comment.
+func scalarValue(s string) string {
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.Trim(strings.TrimSpace(s), `"'`)
+}
+
+// tabWidth is the width of a tab in indentation
+const tabWidth = 8
+
+// indentWidth returns the width of the leading whitespace of a line, with tabs
+// advancing to the next tab stop.
+func indentWidth(text string) int {
+	width := 0
+	for _, ch := range text {
+		switch ch {
+		case ' ':
+			width++
+		case '\t':
+			width += tabWidth - width%tabWidth
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// mappingKey returns the mapping key declared at the start of a line body.
+// Templated keys are not reported.
+func mappingKey(body string) (string, bool) {
+	for i := 0; i < len(body); i++ {
+		if body[i] != ':' || (i+1 < len(body) && body[i+1] != ' ' && body[i+1] != '\t') {
+			continue
+		}
+		key := strings.TrimSpace(body[:i])
+		if key == "" || strings.Contains(key, openBrace) || strings.ContainsAny(key, "[]{},") {
+			return "", false
+		}
+		return strings.Trim(key, `"'`), true
+	}
+	return "", false
+}
+
+// isActionOnly reports whether a trimmed line consists of a single template action.
+func isActionOnly(trimmed string) bool {
+	return strings.HasPrefix(trimmed, openBrace) &&
+		strings.HasSuffix(trimmed, closeBrace) &&
+		strings.Count(trimmed, openBrace) == 1
+}
+
+// stackKeys returns a copy of the keys on the outline stack.
+func stackKeys(stack []outlineEntry) []string {
+	keys := make([]string, len(stack))
+	for i, entry := range stack {
+		keys[i] = entry.key
+	}
+	return keys
+}
+
+// flowKeys returns the keys of the flow mappings enclosing the given offset of a
+// line, outermost first. Template actions and quoted strings are skipped.
+func flowKeys(text string, offset int) []string {
+	// The stack holds the open flow keys, indented by their nesting depth
+	var stack []outlineEntry
+	depth := 0
+	// pop leaves the keys whose value ended at the given depth
+	pop := func(depth int) {
+		for len(stack) > 0 && stack[len(stack)-1].indent >= depth {
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	tokenStart := -1
+	for i := 0; i < len(text) && i < offset; i++ {
+		switch ch := text[i]; {
+		case strings.HasPrefix(text[i:], openBrace):
+			end := strings.Index(text[i:], closeBrace)
+			if end < 0 {
+				return stackKeys(stack)
+			}
+			if tokenStart < 0 {
+				tokenStart = i
+			}
+			i += end + len(closeBrace) - 1
+		case ch == '"' || ch == '\'':
+			end := strings.IndexByte(text[i+1:], ch)
+			if end < 0 {
+				return stackKeys(stack)
+			}
+			if tokenStart < 0 {
+				tokenStart = i
+			}
+			i += end + 1
+		case ch == '{' || ch == '[':
+			depth++
+			tokenStart = -1
+		case ch == '}' || ch == ']':
+			pop(depth)
+			depth--
+			tokenStart = -1
+		case ch == ',':
+			pop(depth)
+			tokenStart = -1
+		case ch == ':' && depth > 0 && (i+1 == len(text) || strings.ContainsRune(" \t,}]", rune(text[i+1]))):
+			if tokenStart >= 0 {
+				key := strings.Trim(strings.TrimSpace(text[tokenStart:i]), `"'`)
+				if key != "" && !strings.Contains(key, openBrace) {
+					pop(depth)
+					stack = append(stack, outlineEntry{indent: depth, key: key})
+				}
+			}
+			tokenStart = -1
+		case ch == ' ' || ch == '\t':
+		default:
+			if tokenStart < 0 {
+				tokenStart = i
+			}
+		}
+	}
+	return stackKeys(stack)
+}
+
+// hasKind reports whether any document of the outline has the given kind.
+func hasKind(lines []manifestLine, kind string) bool {
+	for _, line := range lines {
+		if line.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// deploymentSpec returns the top-level spec line of the first Deployment document.
+func deploymentSpec(lines []manifestLine) (manifestLine, bool) {
+	for _, line := range lines {
+		if line.Kind == "Deployment" && line.Key == "spec" && len(line.Parents) == 0 {
+			return line, true
+		}
+	}
+	return manifestLine{}, false
+}
+
+// isBlockKey reports whether the key declared on a line opens a block mapping,
+// as opposed to a flow collection or templated value on the same line.
+func isBlockKey(line manifestLine) bool {
+	rest := strings.TrimSpace(line.Text)
+	rest = strings.TrimSpace(rest[strings.Index(rest, ":")+1:])
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
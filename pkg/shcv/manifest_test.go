@@ -0,0 +1,168 @@
+package shcv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutlineManifest(t *testing.T) {
+	content := `apiVersion: networking.k8s.io/v1
+kind: Ingress
+spec:
+  {{- if .Values.ingress.tls }}
+  tls:
+    - hosts:
+        - {{ .Values.ingress.host }}
+  {{- end }}
+  rules:
+  - host: {{ .Values.ingress.host }}
+    http:
+      paths: []
+---
+kind: Service
+spec:
+  ports:
+    - port: 80`
+
+	lines := outlineManifest(content)
+	assert.Len(t, lines, 17)
+
+	tests := []struct {
+		number  int
+		doc     int
+		kind    string
+		key     string
+		parents []string
+	}{
+		{number: 2, doc: 0, kind: "Ingress", key: "kind", parents: []string{}},
+		{number: 4, doc: 0, kind: "Ingress", key: "", parents: []string{"spec"}},
+		{number: 5, doc: 0, kind: "Ingress", key: "tls", parents: []string{"spec"}},
+		{number: 6, doc: 0, kind: "Ingress", key: "hosts", parents: []string{"spec", "tls"}},
+		{number: 7, doc: 0, kind: "Ingress", key: "", parents: []string{"spec", "tls", "hosts"}},
+		{number: 10, doc: 0, kind: "Ingress", key: "host", parents: []string{"spec", "rules"}},
+		{number: 11, doc: 0, kind: "Ingress", key: "http", parents: []string{"spec", "rules"}},
+		{number: 12, doc: 0, kind: "Ingress", key: "paths", parents: []string{"spec", "rules", "http"}},
+		{number: 14, doc: 1, kind: "Service", key: "kind", parents: []string{}},
+		{number: 17, doc: 1, kind: "Service", key: "port", parents: []string{"spec", "ports"}},
+	}
+
+	for _, tt := range tests {
+		line := lines[tt.number-1]
+		assert.Equal(t, tt.number, line.Number)
+		assert.Equal(t, tt.doc, line.Doc, "line %d", tt.number)
+		assert.Equal(t, tt.kind, line.Kind, "line %d", tt.number)
+		assert.Equal(t, tt.key, line.Key, "line %d", tt.number)
+		assert.Equal(t, tt.parents, line.Parents, "line %d", tt.number)
+	}
+
+	assert.True(t, lines[6].under("tls"))
+	assert.False(t, lines[9].under("tls"))
+}
+
+func TestMappingKey(t *testing.T) {
+	tests := []struct {
+		body   string
+		want   string
+		wantOK bool
+	}{
+		{body: "host: example.com", want: "host", wantOK: true},
+		{body: "spec:", want: "spec", wantOK: true},
+		{body: `"quoted": value`, want: "quoted", wantOK: true},
+		{body: `image: "{{ .Values.repo }}:{{ .Values.tag }}"`, want: "image", wantOK: true},
+		{body: "{{ .Values.key }}: value", wantOK: false},
+		{body: "{{ .Values.host }}", wantOK: false},
+		{body: "url: http://example.com", want: "url", wantOK: true},
+		{body: "http://example.com", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			got, ok := mappingKey(tt.body)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOutlineManifestStyles(t *testing.T) {
+	t.Run("tab indentation", func(t *testing.T) {
+		lines := outlineManifest("kind:\tDeployment\nspec:\n\ttemplate:\n\t\tspec:\n\t\t\tcontainers: []\n\treplicas: 1")
+		assert.Equal(t, "Deployment", lines[0].Kind)
+		assert.Equal(t, []string{"spec", "template", "spec"}, lines[4].Parents)
+		assert.Equal(t, "replicas", lines[5].Key)
+		assert.Equal(t, []string{"spec"}, lines[5].Parents)
+	})
+
+	t.Run("flow style document", func(t *testing.T) {
+		lines := outlineManifest(`{apiVersion: v1, kind: "Service", spec: {ports: [{port: 80}]}}`)
+		assert.Equal(t, "Service", lines[0].Kind)
+	})
+
+	t.Run("templated keys", func(t *testing.T) {
+		lines := outlineManifest("kind: ConfigMap\ndata:\n  {{ .Values.key }}: value\n  other: {{ .Values.other }}")
+		assert.Equal(t, "", lines[2].Key)
+		assert.Equal(t, []string{"data"}, lines[2].Parents)
+		assert.Equal(t, "other", lines[3].Key)
+		assert.Equal(t, []string{"data"}, lines[3].Parents)
+	})
+}
+
+func TestDocumentKind(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{content: "kind: Deployment", want: "Deployment"},
+		{content: "kind:\tDeployment", want: "Deployment"},
+		{content: `kind: "Deployment" # the workload`, want: "Deployment"},
+		{content: "{apiVersion: apps/v1, kind: Deployment}", want: "Deployment"},
+		{content: "{{ include \"kind: Deployment\" . }}", want: ""},
+		{content: "metadata:\n  kind: Deployment", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			assert.Equal(t, tt.want, documentKind(strings.Split(tt.content, "\n"), 0))
+		})
+	}
+}
+
+func TestManifestLineAt(t *testing.T) {
+	text := `  resources: {limits: {cpu: {{ .Values.cpu }}, memory: "{{ .Values.memory }}"}, requests: {cpu: 1}}`
+	line := manifestLine{Key: "resources", Parents: []string{"spec"}, Text: text}
+
+	cpu := line.at(strings.Index(text, ".Values.cpu") + 1)
+	assert.Equal(t, "cpu", cpu.Key)
+	assert.Equal(t, []string{"spec", "resources", "limits"}, cpu.Parents)
+
+	memory := line.at(strings.Index(text, ".Values.memory") + 1)
+	assert.Equal(t, "memory", memory.Key)
+	assert.Equal(t, []string{"spec", "resources", "limits"}, memory.Parents)
+
+	// Outside any flow mapping the line is unchanged
+	assert.Equal(t, line, line.at(3))
+}
+
+func TestFlowKeys(t *testing.T) {
+	tests := []struct {
+		text   string
+		marker string
+		want   []string
+	}{
+		{text: "ports: [{name: http, containerPort: X}]", marker: "X", want: []string{"containerPort"}},
+		{text: "- {name: web, port: X}", marker: "X", want: []string{"port"}},
+		{text: "a: {b: {c: 1}, d: X}", marker: "X", want: []string{"d"}},
+		{text: "a: {b: [1, 2], c: {d: X}}", marker: "X", want: []string{"c", "d"}},
+		{text: `a: {"b,c": {d: X}}`, marker: "X", want: []string{"b,c", "d"}},
+		{text: "a: { {{ .Values.key }}: X}", marker: "X", want: []string{}},
+		{text: "a: X", marker: "X", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			assert.Equal(t, tt.want, flowKeys(tt.text, strings.Index(tt.text, tt.marker)))
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package shcv
+
+// MergeValues returns the effective values of values layers, as Helm computes
+// them for the values files of helm install -f a.yaml -f b.yaml: the layers
+// are merged in order, the later ones having precedence. Maps are merged key
+// by key, recursively, other values such as scalars and lists replace the
+// value of the earlier layers, and a null value deletes the key. The layers
+// are not modified, and the result shares no maps or lists with them.
+func MergeValues(layers ...map[string]any) map[string]any {
+	merged := make(map[string]any)
+	for _, layer := range layers {
+		mergeValues(merged, layer)
+	}
+	return merged
+}
+
+// mergeValues merges src into dst, whose maps are not shared with src.
+func mergeValues(dst, src map[string]any) {
+	for key, value := range src {
+		switch v := value.(type) {
+		case nil:
+			delete(dst, key)
+		case map[string]any:
+			nested, ok := dst[key].(map[string]any)
+			if !ok {
+				nested = make(map[string]any, len(v))
+				dst[key] = nested
+			}
+			mergeValues(nested, v)
+		default:
+			dst[key] = copyValue(value)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package shcv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeValues(t *testing.T) {
+	base := map[string]any{
+		"name":  "app",
+		"image": map[string]any{"repository": "nginx", "tag": "1.0"},
+		"ports": []any{80, 443},
+		"debug": map[string]any{"enabled": true},
+		"tls":   true,
+	}
+	prod := map[string]any{
+		"image": map[string]any{"tag": "2.0", "pullPolicy": nil},
+		"ports": []any{8080},
+		"debug": nil,
+		"tls":   map[string]any{"secret": "prod-tls"},
+		"extra": map[string]any{"keep": 1, "drop": nil},
+	}
+	merged := MergeValues(base, prod)
+	assert.Equal(t, map[string]any{
+		"name":  "app",
+		"image": map[string]any{"repository": "nginx", "tag": "2.0"},
+		"ports": []any{8080},
+		"tls":   map[string]any{"secret": "prod-tls"},
+		"extra": map[string]any{"keep": 1},
+	}, merged)
+
+	// The layers are left unchanged and not shared
+	merged["image"].(map[string]any)["tag"] = "3.0"
+	merged["ports"].([]any)[0] = 1
+	assert.Equal(t, "1.0", base["image"].(map[string]any)["tag"])
+	assert.Equal(t, []any{8080}, prod["ports"])
+	assert.Contains(t, prod, "debug")
+
+	// A later layer can set a value again after a null
+	assert.Equal(t, map[string]any{"debug": false}, MergeValues(base, prod, map[string]any{"debug": false}, map[string]any{
+		"name": nil, "image": nil, "ports": nil, "tls": nil, "extra": nil,
+	}))
+	assert.Empty(t, MergeValues())
+}
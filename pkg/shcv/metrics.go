@@ -0,0 +1,132 @@
+package shcv
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// TemplateMetrics describes the complexity of a template file, to help find
+// templates that should be split or simplified.
+type TemplateMetrics struct {
+	// File is the template file
+	File string `json:"file"`
+	// Actions is the number of template actions, excluding comments
+	Actions int `json:"actions"`
+	// Values is the number of distinct value paths the template references
+	Values int `json:"values"`
+	// MaxDepth is the deepest nesting of control structures such as if, with and range
+	MaxDepth int `json:"maxDepth"`
+	// Includes is the number of distinct named templates the template includes
+	Includes int `json:"includes"`
+}
+
+// blockKeywords are the actions opening a control structure closed by end
+var blockKeywords = map[string]bool{
+	"if": true, "with": true, "range": true, "define": true, "block": true,
+}
+
+// includeCall matches the name of a template passed to include or template
+var includeCall = regexp.MustCompile(`\b(?:include|template)\s+"([^"]+)"`)
+
+// Metrics returns the complexity metrics of every discovered template, in the
+// order of discovery.
+func (c *Chart) Metrics() ([]TemplateMetrics, error) {
+	metrics := make([]TemplateMetrics, 0, len(c.Templates))
+	for _, template := range c.Templates {
+		content, err := os.ReadFile(template)
+		if err != nil {
+			return nil, fmt.Errorf("reading template %s: %w", template, err)
+		}
+		m := templateMetrics(string(content))
+		m.File = template
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+// templateMetrics computes the metrics of template content.
+func templateMetrics(content string) TemplateMetrics {
+	var m TemplateMetrics
+	depth := 0
+	includes := make(map[string]bool)
+	for _, action := range templateActions(content) {
+		m.Actions++
+		switch keyword, _, _ := strings.Cut(action, " "); {
+		case blockKeywords[keyword]:
+			depth++
+			m.MaxDepth = max(m.MaxDepth, depth)
+		case keyword == "end" && depth > 0:
+			depth--
+		}
+		for _, match := range includeCall.FindAllStringSubmatch(action, -1) {
+			includes[match[1]] = true
+		}
+	}
+	m.Includes = len(includes)
+
+	paths := make(map[string]bool)
+	for _, ref := range ParseFile(content, "") {
+		paths[ref.Path] = true
+	}
+	m.Values = len(paths)
+	return m
+}
+
+// templateAction is an action of template content.
+type templateAction struct {
+	// start and end are the offsets of the opening and past the closing braces
+	start, end int
+	// body is the action without its braces, trim markers and surrounding whitespace
+	body string
+	// comment indicates that the action is a comment
+	comment bool
+}
+
+// templateActions returns the bodies of the actions in template content without
+// their braces, trim markers and surrounding whitespace. Comments are skipped,
+// and scanning stops at an unclosed action.
+func templateActions(content string) []string {
+	var bodies []string
+	for _, action := range scanActions(content) {
+		if !action.comment {
+			bodies = append(bodies, action.body)
+		}
+	}
+	return bodies
+}
+
+// scanActions returns the actions of template content including comments, in
+// order. Scanning stops at an unclosed action.
+func scanActions(content string) []templateAction {
+	var actions []templateAction
+	for pos := 0; ; {
+		open := strings.Index(content[pos:], openBrace)
+		if open < 0 {
+			return actions
+		}
+		start := pos + open + len(openBrace)
+		comment := strings.HasPrefix(strings.TrimLeft(strings.TrimPrefix(content[start:], trimMarker), " \t\n"), commentOpen)
+		end := start
+		for end < len(content) && !strings.HasPrefix(content[end:], closeBrace) {
+			if ch := content[end]; !comment && (ch == '"' || ch == '\'' || ch == '`') {
+				end, _ = stringEnd(content, end)
+				continue
+			}
+			end++
+		}
+		if end >= len(content) {
+			return actions
+		}
+		pos = end + len(closeBrace)
+
+		body := strings.TrimPrefix(content[start:end], trimMarker)
+		actions = append(actions, templateAction{
+			start:   start - len(openBrace),
+			end:     pos,
+			body:    strings.TrimSpace(strings.TrimSuffix(body, trimMarker)),
+			comment: comment,
+		})
+	}
+}
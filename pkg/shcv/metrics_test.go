@@ -0,0 +1,81 @@
+package shcv
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    TemplateMetrics
+	}{
+		{
+			name:    "empty",
+			content: "kind: ConfigMap\n",
+		},
+		{
+			name:    "values",
+			content: "a: {{ .Values.a }}\nb: {{ .Values.b | default \"}}\" }}\nc: {{ .Values.a }}\n",
+			want:    TemplateMetrics{Actions: 3, Values: 2},
+		},
+		{
+			name: "nesting",
+			content: `{{- if .Values.enabled }}
+{{- range .Values.hosts }}
+{{- with .tls }}
+tls: true
+{{- end }}
+{{- end }}
+{{- else }}
+{{- end }}
+{{- with .Values.extra }}
+{{- end }}`,
+			want: TemplateMetrics{Actions: 9, Values: 3, MaxDepth: 3},
+		},
+		{
+			name:    "includes",
+			content: "{{ include \"app.labels\" . }}\n{{ template \"app.name\" . }}\n{{ include \"app.labels\" (dict \"x\" (include \"app.name\" .)) }}\n",
+			want:    TemplateMetrics{Actions: 3, Includes: 2},
+		},
+		{
+			name:    "comments are not actions",
+			content: "{{/* don't count {{ .Values.a }} */}}\n{{- /* trimmed */ -}}\n{{ .Values.b }}\n",
+			want:    TemplateMetrics{Actions: 1, Values: 1},
+		},
+		{
+			name:    "unclosed action",
+			content: "{{ .Values.a }}\n{{ .Values.b\n",
+			want:    TemplateMetrics{Actions: 1, Values: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, templateMetrics(tt.content))
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{
+		"a.yaml": "{{ if .Values.a }}{{ include \"x\" . }}{{ end }}\n",
+		"b.yaml": "b: {{ .Values.b }}\n",
+	})
+	chart := loadTestChart(t, dir)
+
+	metrics, err := chart.Metrics()
+	require.NoError(t, err)
+	assert.Equal(t, []TemplateMetrics{
+		{File: filepath.Join(dir, "templates", "a.yaml"), Actions: 3, Values: 1, MaxDepth: 1, Includes: 1},
+		{File: filepath.Join(dir, "templates", "b.yaml"), Actions: 1, Values: 1},
+	}, metrics)
+
+	chart.Templates = append(chart.Templates, filepath.Join(dir, "missing.yaml"))
+	_, err = chart.Metrics()
+	assert.ErrorContains(t, err, "reading template")
+}
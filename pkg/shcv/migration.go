@@ -0,0 +1,96 @@
+package shcv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// migrationHeader introduces a marshaled ValuesMigration to the users of the chart
+const migrationHeader = `# Values renamed by this version of the chart. Apply the renames to your own
+# values files, in order, with:
+#
+#   shcv migrate-values values-migration.yaml my-values.yaml...
+`
+
+// ValueRename renames the value at From, along with the values nested below
+// it, to To.
+type ValueRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ValuesMigration lists the values renamed by a version of a chart, so that
+// its users can apply the renames to their own values files with
+// MigrateValues. It is usually shipped as values-migration.yaml.
+type ValuesMigration struct {
+	// Renames are applied in order, from the deepest path up
+	Renames []ValueRename `json:"renames"`
+}
+
+// NewValuesMigration returns the migration applying renames, given as for
+// RenameValues, in the order RenameValues applies them.
+func NewValuesMigration(renames map[string]string) (ValuesMigration, error) {
+	ordered, err := orderRenames(renames)
+	if err != nil {
+		return ValuesMigration{}, err
+	}
+	return ValuesMigration{Renames: ordered}, nil
+}
+
+// Marshal returns the migration as a YAML document, with a comment explaining
+// how to apply it.
+func (m ValuesMigration) Marshal() ([]byte, error) {
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling migration: %w", err)
+	}
+	return append([]byte(migrationHeader), data...), nil
+}
+
+// ReadValuesMigration reads a migration written by ValuesMigration.Marshal.
+func ReadValuesMigration(path string) (ValuesMigration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ValuesMigration{}, fmt.Errorf("reading migration: %w", err)
+	}
+	var m ValuesMigration
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return ValuesMigration{}, fmt.Errorf("parsing migration %s: %w", path, err)
+	}
+	for _, r := range m.Renames {
+		if r.From == "" || r.To == "" {
+			return ValuesMigration{}, fmt.Errorf("migration %s renames %q to %q: value path is empty", path, r.From, r.To)
+		}
+	}
+	return m, nil
+}
+
+// MigrateValues applies a migration to values files outside of a chart, such
+// as the override files of its users, keeping their comments and layout. The
+// changes are returned without being written, for the files defining renamed
+// values only; use WriteChanges to apply them.
+func MigrateValues(m ValuesMigration, paths ...string) ([]FileChange, error) {
+	c := &Chart{config: defaultConfig()}
+	var changes []FileChange
+	for _, path := range paths {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("values file %s does not exist", path)
+		}
+		file := ValueFile{Path: path}
+		if err := c.loadValueFile(&file); err != nil {
+			return nil, err
+		}
+		change, err := renameInValuesFile(&file, m.Renames)
+		if err != nil {
+			return nil, err
+		}
+		if change != nil && !bytes.Equal(change.Before, change.After) {
+			changes = append(changes, *change)
+		}
+	}
+	return changes, nil
+}
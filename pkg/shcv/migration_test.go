@@ -0,0 +1,58 @@
+package shcv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValuesMigration(t *testing.T) {
+	migration, err := NewValuesMigration(map[string]string{"Image": "image", "Image.Tag": "Image.tag"})
+	require.NoError(t, err)
+	assert.Equal(t, []ValueRename{{From: "Image.Tag", To: "Image.tag"}, {From: "Image", To: "image"}}, migration.Renames)
+
+	_, err = NewValuesMigration(map[string]string{"image": "image"})
+	assert.EqualError(t, err, "cannot rename .Values.image to itself")
+
+	// The migration round-trips through its file
+	data, err := migration.Marshal()
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "# Values renamed by this version of the chart")
+	path := filepath.Join(t.TempDir(), "values-migration.yaml")
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	read, err := ReadValuesMigration(path)
+	require.NoError(t, err)
+	assert.Equal(t, migration, read)
+
+	require.NoError(t, os.WriteFile(path, []byte("renames:\n- from: image\n  too: img\n"), 0644))
+	_, err = ReadValuesMigration(path)
+	assert.ErrorContains(t, err, "parsing migration")
+	require.NoError(t, os.WriteFile(path, []byte("renames:\n- from: image\n"), 0644))
+	_, err = ReadValuesMigration(path)
+	assert.EqualError(t, err, `migration `+path+` renames "image" to "": value path is empty`)
+}
+
+func TestMigrateValues(t *testing.T) {
+	dir := t.TempDir()
+	prod := filepath.Join(dir, "values-prod.yaml")
+	require.NoError(t, os.WriteFile(prod, []byte("# Production\nImage:\n  Tag: v2 # pinned\nreplicas: 3\n"), 0644))
+	dev := filepath.Join(dir, "values-dev.yaml")
+	require.NoError(t, os.WriteFile(dev, []byte("replicas: 1\n"), 0644))
+
+	migration := ValuesMigration{Renames: []ValueRename{{From: "Image.Tag", To: "Image.tag"}, {From: "Image", To: "image"}}}
+	changes, err := MigrateValues(migration, prod, dev)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, prod, changes[0].Path)
+	assert.Equal(t, "# Production\nimage:\n  tag: v2 # pinned\nreplicas: 3\n", string(changes[0].After))
+
+	_, err = MigrateValues(migration, filepath.Join(dir, "values-missing.yaml"))
+	assert.EqualError(t, err, "values file "+filepath.Join(dir, "values-missing.yaml")+" does not exist")
+
+	require.NoError(t, os.WriteFile(dev, []byte("Image:\n  Tag: v1\nimage: {}\n"), 0644))
+	_, err = MigrateValues(migration, dev)
+	assert.EqualError(t, err, "cannot rename .Values.Image to .Values.image: "+dev+" already defines it")
+}
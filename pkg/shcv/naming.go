@@ -0,0 +1,205 @@
+package shcv
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Key cases of a NamingPolicy
+const (
+	CaseCamel = "camelCase"
+	CaseKebab = "kebab-case"
+)
+
+// NamingPolicy constrains the keys of value paths, checked by CheckNaming.
+type NamingPolicy struct {
+	// Case is the case every key must be written in, CaseCamel or CaseKebab
+	// (default: any case)
+	Case string
+	// MaxSegmentLength is the maximum length of a key (default: no limit)
+	MaxSegmentLength int
+	// LowercaseTopLevel forbids uppercase letters in top-level keys
+	LowercaseTopLevel bool
+	// Ignore lists value paths exempt from the policy, along with the paths
+	// nested below them
+	Ignore []string
+}
+
+// caseKeys matches the keys written in each case
+var caseKeys = map[string]*regexp.Regexp{
+	CaseCamel: regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`),
+	CaseKebab: regexp.MustCompile(`^[a-z][a-z0-9]*(-[a-z0-9]+)*$`),
+}
+
+// CheckNaming reports the keys of value paths breaking the naming policy, both
+// in the paths referenced by the templates and in the keys the values files
+// define. Every key is reported once, at its first reference or definition,
+// with the path it should be renamed to in Finding.Suggestion when the key can
+// be corrected automatically; RenameValues applies the suggestions. Keys below
+// a value the templates use as a whole, such as annotations passed to toYaml,
+// are data rather than part of the values layout and are not checked.
+//
+// The check is opt-in: RunChecks only runs it with WithNamingPolicy.
+func (c *Chart) CheckNaming() ([]Finding, error) {
+	policy := c.config.NamingPolicy
+	if policy == nil {
+		return nil, nil
+	}
+
+	var findings []Finding
+	checked := make(map[string]bool)
+	check := func(path, file string, line int) {
+		for i, parts := 0, SplitValuePath(path); i < len(parts); i++ {
+			prefix := JoinValuePath(parts[:i+1]...)
+			if checked[prefix] || selectsPath(policy.Ignore, prefix) {
+				continue
+			}
+			checked[prefix] = true
+			if finding, ok := policy.check(parts[:i+1]); ok {
+				finding.SourceFile, finding.LineNumber = file, line
+				findings = append(findings, finding)
+			}
+		}
+	}
+
+	referenced := make(map[string]bool, len(c.References))
+	for _, ref := range c.References {
+		referenced[ref.Path] = true
+		check(ref.Path, ref.SourceFile, ref.LineNumber)
+	}
+	for _, file := range c.ValuesFiles {
+		for _, key := range valueKeys(file.source) {
+			if !usedWhole(referenced, key.path) {
+				check(key.path, file.Path, key.line)
+			}
+		}
+	}
+	return findings, nil
+}
+
+// check returns the finding for the last key of a path if it breaks the policy.
+func (p *NamingPolicy) check(parts []string) (Finding, bool) {
+	key := parts[len(parts)-1]
+	fixed := key
+	var problems []string
+	if pattern := caseKeys[p.Case]; pattern != nil && !pattern.MatchString(key) {
+		problems = append(problems, "is not "+p.Case)
+		fixed = convertCase(key, p.Case)
+	}
+	if len(parts) == 1 && p.LowercaseTopLevel && strings.ToLower(key) != key {
+		problems = append(problems, "is a top-level key with uppercase letters")
+		fixed = strings.ToLower(fixed)
+	}
+	if p.MaxSegmentLength > 0 && len(key) > p.MaxSegmentLength {
+		problems = append(problems, fmt.Sprintf("is longer than %d characters", p.MaxSegmentLength))
+	}
+	if len(problems) == 0 {
+		return Finding{}, false
+	}
+
+	path := JoinValuePath(parts...)
+	finding := Finding{
+		Check:   CheckNaming,
+		Path:    path,
+		Message: fmt.Sprintf("key %q of .Values.%s %s", key, path, strings.Join(problems, " and ")),
+	}
+	// Only suggest keys that pass the policy themselves
+	if fixed != key && fixed != "" {
+		suggested := append(append([]string{}, parts[:len(parts)-1]...), fixed)
+		if _, fails := p.check(suggested); !fails {
+			finding.Suggestion = JoinValuePath(suggested...)
+			finding.Message += fmt.Sprintf("; rename it to .Values.%s", finding.Suggestion)
+		}
+	}
+	return finding, true
+}
+
+// convertCase rewrites a key in a case, splitting it into words at dashes,
+// underscores, spaces and case changes.
+func convertCase(key, keyCase string) string {
+	words := splitWords(key)
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if keyCase == CaseCamel && i > 0 {
+			word = strings.ToUpper(word[:1]) + word[1:]
+		}
+		words[i] = word
+	}
+	if keyCase == CaseKebab {
+		return strings.Join(words, "-")
+	}
+	return strings.Join(words, "")
+}
+
+// splitWords splits a key into its words: imagePullPolicy, image-pull-policy,
+// image_pull_policy and IMAGE_PULL_POLICY all have the words image, pull and
+// policy. Runs of capitals are one word, as in httpURL.
+func splitWords(key string) []string {
+	var words []string
+	runes := []rune(key)
+	start := 0
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && !strings.ContainsRune("-_ .", runes[i]) {
+			if i > start && unicode.IsUpper(runes[i]) && (!unicode.IsUpper(runes[i-1]) ||
+				i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+			continue
+		}
+		if i > start {
+			words = append(words, string(runes[start:i]))
+		}
+		start = i + 1
+	}
+	return words
+}
+
+// usedWhole reports whether a parent of path is referenced by the templates,
+// which then use the keys below it as data.
+func usedWhole(referenced map[string]bool, path string) bool {
+	keys := SplitValuePath(path)
+	for i := len(keys) - 1; i > 0; i-- {
+		if referenced[JoinValuePath(keys[:i]...)] {
+			return true
+		}
+	}
+	return false
+}
+
+// valueKey is a key of a values document with the line it is defined on.
+type valueKey struct {
+	path string
+	line int
+}
+
+// valueKeys returns the paths of the mapping keys of a values document in
+// document order. Keys inside lists are not value paths and are skipped.
+func valueKeys(source []byte) []valueKey {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(source, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	var keys []valueKey
+	var walk func(node *yamlv3.Node, prefix string)
+	walk = func(node *yamlv3.Node, parent string) {
+		if node.Kind != yamlv3.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yamlv3.ScalarNode || key.Value == "<<" {
+				continue
+			}
+			path := appendKey(parent, key.Value)
+			keys = append(keys, valueKey{path: path, line: key.Line})
+			walk(node.Content[i+1], path)
+		}
+	}
+	walk(doc.Content[0], "")
+	return keys
+}
@@ -0,0 +1,139 @@
+package shcv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckNaming(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   NamingPolicy
+		values   string
+		template string
+		want     []string
+		renames  map[string]string
+	}{
+		{
+			name:     "camelCase keys",
+			policy:   NamingPolicy{Case: CaseCamel},
+			template: "image: {{ .Values.image.pullPolicy }}\nport: {{ .Values.service.port }}\n",
+		},
+		{
+			name:     "snake and kebab keys to camelCase",
+			policy:   NamingPolicy{Case: CaseCamel},
+			template: "a: {{ .Values.image.pull_policy }}\nb: {{ index .Values \"service-account\" \"name\" }}\n",
+			want: []string{
+				`templates/deployment.yaml:1: key "pull_policy" of .Values.image.pull_policy is not camelCase; rename it to .Values.image.pullPolicy`,
+				`templates/deployment.yaml:2: key "service-account" of .Values.service-account is not camelCase; rename it to .Values.serviceAccount`,
+			},
+			renames: map[string]string{"image.pull_policy": "image.pullPolicy", "service-account": "serviceAccount"},
+		},
+		{
+			name:     "camelCase keys to kebab-case",
+			policy:   NamingPolicy{Case: CaseKebab},
+			template: "a: {{ .Values.serviceAccount.HTTPPort }}\n",
+			want: []string{
+				`templates/deployment.yaml:1: key "serviceAccount" of .Values.serviceAccount is not kebab-case; rename it to .Values.service-account`,
+				`templates/deployment.yaml:1: key "HTTPPort" of .Values.serviceAccount.HTTPPort is not kebab-case; rename it to .Values.serviceAccount.http-port`,
+			},
+			renames: map[string]string{"serviceAccount": "service-account", "serviceAccount.HTTPPort": "serviceAccount.http-port"},
+		},
+		{
+			name:     "uppercase top-level keys",
+			policy:   NamingPolicy{LowercaseTopLevel: true},
+			template: "a: {{ .Values.Image.Tag }}\n",
+			want:     []string{`templates/deployment.yaml:1: key "Image" of .Values.Image is a top-level key with uppercase letters; rename it to .Values.image`},
+			renames:  map[string]string{"Image": "image"},
+		},
+		{
+			name:     "long keys are not corrected",
+			policy:   NamingPolicy{Case: CaseCamel, MaxSegmentLength: 10},
+			template: "a: {{ .Values.persistence.storage_class_name }}\n",
+			want: []string{
+				`templates/deployment.yaml:1: key "persistence" of .Values.persistence is longer than 10 characters`,
+				`templates/deployment.yaml:1: key "storage_class_name" of .Values.persistence.storage_class_name is not camelCase and is longer than 10 characters`,
+			},
+			renames: map[string]string{},
+		},
+		{
+			name:     "values keys",
+			policy:   NamingPolicy{Case: CaseCamel},
+			values:   "image:\n  repository: nginx\n  Pull_Policy: Always\nlegacy_setting: true\nlist:\n  - some_key: 1\n",
+			template: "a: {{ .Values.image.repository }}\n",
+			want: []string{
+				`values.yaml:3: key "Pull_Policy" of .Values.image.Pull_Policy is not camelCase; rename it to .Values.image.pullPolicy`,
+				`values.yaml:4: key "legacy_setting" of .Values.legacy_setting is not camelCase; rename it to .Values.legacySetting`,
+			},
+			renames: map[string]string{"image.Pull_Policy": "image.pullPolicy", "legacy_setting": "legacySetting"},
+		},
+		{
+			name:     "keys of values used as a whole",
+			policy:   NamingPolicy{Case: CaseCamel},
+			values:   "podAnnotations:\n  app.kubernetes.io/name: app\nenv:\n  LOG_LEVEL: debug\n",
+			template: "annotations: {{ toYaml .Values.podAnnotations }}\nenv: {{ toYaml .Values.env }}\n",
+		},
+		{
+			name:     "ignored paths",
+			policy:   NamingPolicy{Case: CaseCamel, Ignore: []string{"legacy_config"}},
+			values:   "legacy_config:\n  some_key: 1\n",
+			template: "a: {{ .Values.legacy_config.other_key }}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestChart(t, tt.values, map[string]string{"deployment.yaml": tt.template})
+			chart := loadTestChart(t, dir, WithNamingPolicy(tt.policy))
+
+			findings, err := chart.CheckNaming()
+			require.NoError(t, err)
+			var got []string
+			renames := make(map[string]string)
+			for _, f := range findings {
+				assert.Equal(t, CheckNaming, f.Check)
+				f.SourceFile = chart.relPath(f.SourceFile)
+				got = append(got, f.String())
+				if f.Suggestion != "" {
+					renames[f.Path] = f.Suggestion
+				}
+			}
+			assert.Equal(t, tt.want, got)
+			if tt.renames != nil {
+				assert.Equal(t, tt.renames, renames)
+			}
+		})
+	}
+}
+
+func TestRunChecksNamingPolicy(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{"deployment.yaml": "a: {{ .Values.image_tag }}\n"})
+
+	findings, err := loadTestChart(t, dir).RunChecks()
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+
+	findings, err = loadTestChart(t, dir, WithNamingPolicy(NamingPolicy{Case: CaseCamel})).RunChecks()
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	assert.Equal(t, CheckNaming, findings[0].Check)
+	assert.Equal(t, "imageTag", findings[0].Suggestion)
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := map[string][]string{
+		"image":             {"image"},
+		"imagePullPolicy":   {"image", "Pull", "Policy"},
+		"image-pull-policy": {"image", "pull", "policy"},
+		"IMAGE_PULL_POLICY": {"IMAGE", "PULL", "POLICY"},
+		"httpURL":           {"http", "URL"},
+		"HTTPPort":          {"HTTP", "Port"},
+		"tls2Secret":        {"tls2", "Secret"},
+		"--a__b":            {"a", "b"},
+	}
+	for key, want := range tests {
+		assert.Equal(t, want, splitWords(key), key)
+	}
+}
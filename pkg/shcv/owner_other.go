@@ -0,0 +1,8 @@
+//go:build !unix
+
+package shcv
+
+import "os"
+
+// keepOwner does nothing, as files have no numeric owner on this platform.
+func keepOwner(path string, info os.FileInfo) {}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package shcv
+
+import (
+	"os"
+	"syscall"
+)
+
+// keepOwner gives the file at path the owner and group of info, if they differ
+// and the process may change them. Errors are ignored, as only privileged
+// processes can give files away.
+func keepOwner(path string, info os.FileInfo) {
+	want, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if have, ok := current.Sys().(*syscall.Stat_t); ok && have.Uid == want.Uid && have.Gid == want.Gid {
+		return
+	}
+	os.Chown(path, int(want.Uid), int(want.Gid))
+}
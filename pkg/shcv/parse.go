@@ -0,0 +1,81 @@
+package shcv
+
+import (
+	"io"
+
+	"github.com/agentstation/shcv/pkg/shcv/parser"
+)
+
+// Template syntax the chart transforms match and rewrite
+const (
+	openBrace   = "{{"
+	closeBrace  = "}}"
+	valuePrefix = ".Values."
+	rootPrefix  = "$"
+	defaultPipe = "|"
+	commentOpen = "/*"
+	trimMarker  = "-"
+)
+
+// controlKeywords are the actions whose pipeline may start with a value reference
+var controlKeywords = []string{"else if", "else with", "if", "with", "range"}
+
+// ParseFile parses a template file and returns all value references
+func ParseFile(content, templatePath string) []ValueRef {
+	refs, _ := parser.Parse(content, templatePath)
+	return refs
+}
+
+// ParseFileWithDiagnostics parses a template file and returns all value
+// references along with the problems found in malformed actions, see
+// parser.Parse.
+func ParseFileWithDiagnostics(content, templatePath string) ([]ValueRef, []Diagnostic) {
+	return parser.Parse(content, templatePath)
+}
+
+// ParseBytes is a convenience wrapper of ParseFileWithDiagnostics for content
+// held as bytes, which it copies into a string.
+func ParseBytes(content []byte, templatePath string) ([]ValueRef, []Diagnostic) {
+	return parser.ParseBytes(content, templatePath)
+}
+
+// ParseReader parses the template read from r, such as a network stream, and
+// returns its value references and diagnostics, see parser.ParseReader.
+func ParseReader(r io.Reader, templatePath string) ([]ValueRef, []Diagnostic, error) {
+	return parser.ParseReader(r, templatePath)
+}
+
+// nthIndex returns the index of the nth occurrence of ch in s, or -1
+func nthIndex(s string, ch byte, n int) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == ch {
+			if n--; n == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// stringEnd returns the offset just past the string literal starting at start
+// and whether the literal is closed; unclosed literals extend to the end of input.
+func stringEnd(input string, start int) (int, bool) {
+	quote := input[start]
+	for i := start + 1; i < len(input); i++ {
+		switch ch := input[i]; {
+		case ch == '\\' && quote != '`':
+			i++
+		case ch == quote:
+			return i + 1, true
+		}
+	}
+	return len(input), false
+}
+
+func isAlphaNumeric(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// IgnoreDirective is the comment marking value references that are not synced,
+// such as values only given at install time. Written on the line of the
+// references, or alone on the line before them, as a YAML comment
+// "# shcv:ignore" or a template comment "{{/* shcv:ignore */}}", it ignores
+// all references of the line; followed by value paths separated by commas, as
+// in "# shcv:ignore secret.password,secret.user", only those paths.
+const IgnoreDirective = "shcv:ignore"
+
+// markIgnored sets Ignored on the references an ignore directive applies to.
+func markIgnored(content string, refs []ValueRef) {
+	if !strings.Contains(content, IgnoreDirective) {
+		return
+	}
+	lines := strings.Split(content, "\n")
+	for i := range refs {
+		ref := &refs[i]
+		line := ref.LineNumber - 1
+		if line < 0 || line >= len(lines) {
+			continue
+		}
+		paths, ok := ignoreDirective(lines[line])
+		if !ok && line > 0 && isCommentLine(lines[line-1]) {
+			paths, ok = ignoreDirective(lines[line-1])
+		}
+		ref.Ignored = ok && (len(paths) == 0 || slices.Contains(paths, ref.Path))
+	}
+}
+
+// ignoreDirective reports whether a line has an ignore directive, and returns
+// the value paths it is limited to, if any.
+func ignoreDirective(line string) ([]string, bool) {
+	_, rest, ok := strings.Cut(line, IgnoreDirective)
+	if !ok || (rest != "" && !unicode.IsSpace(rune(rest[0])) && !strings.HasPrefix(rest, "*/")) {
+		return nil, false
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "*/") || strings.HasPrefix(fields[0], "-*/") {
+		return nil, true
+	}
+	return strings.Split(fields[0], ","), true
+}
+
+// isCommentLine reports whether a line holds only a YAML or template comment.
+func isCommentLine(line string) bool {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "#") {
+		return true
+	}
+	line = strings.TrimPrefix(strings.TrimPrefix(line, "{{"), "-")
+	return strings.HasPrefix(strings.TrimSpace(line), "/*") && strings.HasSuffix(line, "}}")
+}
@@ -0,0 +1,1006 @@
+// Package parser finds the .Values references of Helm templates, for tools
+// such as linters and documentation generators that need the references of a
+// template without loading a chart.
+//
+//	refs, diagnostics := parser.Parse(content, "templates/deployment.yaml")
+//	for _, ref := range refs {
+//		fmt.Printf("%s:%d: .Values.%s\n", ref.SourceFile, ref.LineNumber, ref.Path)
+//	}
+//
+// The package is the parser shcv itself uses, without the chart machinery of
+// package shcv, which aliases its types. Its names stay the same within a
+// major version.
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// parser represents a Helm template parser
+type parser struct {
+	input       string
+	pos         int
+	lineNum     int
+	template    string
+	diagnostics []Diagnostic
+}
+
+// Diagnostic is a recoverable problem found while parsing a template, such as an
+// unclosed action or an invalid value path. The parser skips the malformed part
+// and continues with the rest of the template.
+type Diagnostic struct {
+	// SourceFile is the template file where the problem was found
+	SourceFile string
+	// LineNumber is the line number in the source file
+	LineNumber int
+	// Column is the 1-based byte column on LineNumber where the problem starts
+	Column int
+	// Message is a human-readable description of the problem
+	Message string
+}
+
+// String returns the diagnostic formatted as file:line:column: message
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.SourceFile, d.LineNumber, d.Column, d.Message)
+}
+
+// Token types for parsing
+const (
+	openBrace    = "{{"
+	closeBrace   = "}}"
+	valuePrefix  = ".Values."
+	rootPrefix   = "$"
+	defaultPipe  = "|"
+	defaultFunc  = "default"
+	commentOpen  = "/*"
+	commentClose = "*/"
+	trimMarker   = "-"
+	tplFunc      = "tpl"
+)
+
+// Parse returns the value references of a template and the problems found in
+// its malformed actions. References an ignore directive applies to are marked
+// Ignored, see IgnoreDirective. The path is recorded as the SourceFile of the
+// references and diagnostics; the content is not read from it.
+func Parse(content, path string) ([]ValueRef, []Diagnostic) {
+	parser := newParser(content, path)
+	refs := parser.parse()
+	markIgnored(content, refs)
+	return refs, parser.diagnostics
+}
+
+// ParseBytes is a convenience wrapper of Parse for content held as bytes,
+// which it copies into a string.
+func ParseBytes(content []byte, path string) ([]ValueRef, []Diagnostic) {
+	return Parse(string(content), path)
+}
+
+// ParseReader parses the template read from r, such as a network stream, and
+// returns its value references and diagnostics. Line endings are normalized,
+// so "\r\n" lines give the same references, whose EndOffset is that in the
+// content read. The error is that of reading r.
+func ParseReader(r io.Reader, path string) ([]ValueRef, []Diagnostic, error) {
+	content, crlf, err := readLines(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading template %s: %w", path, err)
+	}
+	refs, diagnostics := Parse(content, path)
+	rawOffsets(content, crlf, refs)
+	return refs, diagnostics, nil
+}
+
+// readLines reads the lines of a template of any length. Lines end with "\n",
+// including the last one and those ended by "\r\n", whose 0-based indexes are
+// returned in order.
+func readLines(r io.Reader) (string, []int, error) {
+	reader := bufio.NewReader(r)
+	var content strings.Builder
+	var crlf []int
+	for index := 0; ; index++ {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			trimmed := strings.TrimSuffix(line, "\n")
+			if strings.HasSuffix(trimmed, "\r") && len(trimmed) < len(line) {
+				crlf = append(crlf, index)
+			}
+			content.WriteString(strings.TrimSuffix(trimmed, "\r"))
+			content.WriteString("\n")
+		}
+		if err == io.EOF {
+			return content.String(), crlf, nil
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+}
+
+// rawOffsets converts the end offsets of refs parsed from content read by
+// readLines to offsets in the file read, whose crlf lines ended with "\r\n",
+// so that they can be applied to the file as it is on disk. Columns are the
+// same in both.
+func rawOffsets(content string, crlf []int, refs []ValueRef) {
+	if len(crlf) == 0 {
+		return
+	}
+	var newlines []int
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			newlines = append(newlines, i)
+		}
+	}
+	for i := range refs {
+		line := sort.SearchInts(newlines, refs[i].EndOffset)
+		refs[i].EndOffset += sort.SearchInts(crlf, line)
+	}
+}
+
+// newParser creates a new parser instance
+func newParser(input, template string) *parser {
+	return &parser{
+		input:    input,
+		pos:      0,
+		lineNum:  1,
+		template: template,
+	}
+}
+
+// parse parses the entire input and returns all value references
+func (p *parser) parse() []ValueRef {
+	var refs []ValueRef
+	for p.pos < len(p.input) {
+		if p.match(openBrace) {
+			if p.skipComment() {
+				continue
+			}
+			start, startLine := p.pos, p.lineNum
+			p.checkAction(start - len(openBrace))
+			ref := p.parseValueRef()
+			if ref != nil {
+				refs = append(refs, *ref)
+			}
+			if ref == nil && p.pos != start {
+				// The action starts with a reference but is malformed
+				continue
+			}
+			end, endLine := p.pos, p.lineNum
+
+			// Pick up the references nested anywhere else in the action, such as
+			// the arguments of {{ include "x" (dict "image" .Values.image) }}
+			p.pos, p.lineNum = start, startLine
+			refs = append(refs, p.parseNestedRefs(ref)...)
+			if p.pos < end {
+				p.pos, p.lineNum = end, endLine
+			}
+		} else {
+			if p.current() == '\n' {
+				p.lineNum++
+			}
+			p.pos++
+		}
+	}
+	return refs
+}
+
+// skipComment skips a {{/* ... */}} comment action, which may span multiple lines.
+// It must be called right after the opening braces and reports whether a comment
+// was skipped. An unclosed comment consumes the rest of the input.
+func (p *parser) skipComment() bool {
+	start, startLine := p.pos, p.lineNum
+
+	// Allow a trim marker before the comment: {{- /* ... */ -}}
+	if p.match(trimMarker) {
+		p.skipWhitespace()
+	}
+	if !p.match(commentOpen) {
+		p.pos, p.lineNum = start, startLine
+		return false
+	}
+
+	for p.pos < len(p.input) {
+		if p.match(commentClose) {
+			p.skipWhitespace()
+			p.match(trimMarker)
+			if p.match(closeBrace) {
+				return true
+			}
+			continue
+		}
+		if p.current() == '\n' {
+			p.lineNum++
+		}
+		p.pos++
+	}
+	p.warn(start-len(openBrace), "unclosed comment")
+	return true
+}
+
+// checkAction reports an action starting at the given offset that isn't closed
+// before the next action or the end of the input, or whose parentheses are unbalanced.
+func (p *parser) checkAction(open int) {
+	depth := 0
+	for i := open + len(openBrace); i < len(p.input); i++ {
+		switch ch := p.input[i]; {
+		case ch == '"' || ch == '\'' || ch == '`':
+			end, closed := stringEnd(p.input, i)
+			if !closed {
+				p.warn(i, "unterminated string in action")
+				return
+			}
+			i = end - 1
+		case strings.HasPrefix(p.input[i:], closeBrace):
+			if depth != 0 {
+				p.warn(open, "unbalanced parentheses in action")
+			}
+			return
+		case strings.HasPrefix(p.input[i:], openBrace):
+			p.warn(open, "unclosed action")
+			return
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		}
+	}
+	p.warn(open, "unclosed action")
+}
+
+// warn records a diagnostic at the given offset, once per position and message.
+func (p *parser) warn(offset int, format string, args ...any) {
+	d := Diagnostic{
+		SourceFile: p.template,
+		LineNumber: strings.Count(p.input[:offset], "\n") + 1,
+		Column:     p.column(offset),
+		Message:    fmt.Sprintf(format, args...),
+	}
+	for _, existing := range p.diagnostics {
+		if existing == d {
+			return
+		}
+	}
+	p.diagnostics = append(p.diagnostics, d)
+}
+
+// checkPath reports a value path starting at the given offset that is empty,
+// has consecutive or trailing dots, or is followed by a character that can't
+// end it. It must be called right after parsing the path.
+func (p *parser) checkPath(refStart int, path string) {
+	if path == "" {
+		end := refStart
+		for end < len(p.input) && !isWhitespace(p.input[end]) && !strings.ContainsRune("|()}", rune(p.input[end])) {
+			end++
+		}
+		p.warn(refStart, "invalid value path %q", p.input[refStart:end])
+		return
+	}
+	// A path at the end of the input is reported as an unclosed action
+	if p.pos < len(p.input) && !p.endsPath() {
+		p.warn(refStart, "invalid character %q after .Values.%s", p.current(), path)
+	}
+}
+
+// endsPath reports whether the current character may follow a value path
+func (p *parser) endsPath() bool {
+	return isWhitespace(p.current()) || p.current() == ')' || p.current() == '|' || p.atClose()
+}
+
+// controlKeywords are the actions whose pipeline may start with a value reference
+var controlKeywords = []string{"else if", "else with", "if", "with", "range"}
+
+// keywordTypes maps control keywords to the type of the value they operate on
+var keywordTypes = map[string]ValueType{
+	"if":        TypeBool,
+	"else if":   TypeBool,
+	"with":      TypeMap,
+	"else with": TypeMap,
+	"range":     TypeList,
+}
+
+// functionTypes maps template functions to the type of the value they expect
+var functionTypes = map[string]ValueType{
+	"int":          TypeInt,
+	"int64":        TypeInt,
+	"atoi":         TypeInt,
+	"quote":        TypeString,
+	"squote":       TypeString,
+	"upper":        TypeString,
+	"lower":        TypeString,
+	"title":        TypeString,
+	"trim":         TypeString,
+	"b64enc":       TypeString,
+	"toString":     TypeString,
+	"toYaml":       TypeMap,
+	"toJson":       TypeMap,
+	"toPrettyJson": TypeMap,
+	"not":          TypeBool,
+}
+
+// accessorFuncs are the functions reading a nested key of a value
+var accessorFuncs = map[string]bool{
+	"dig": true, "hasKey": true, "get": true, "index": true,
+}
+
+// comparisonFuncs are the functions comparing a value against a literal
+var comparisonFuncs = map[string]bool{
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true,
+}
+
+// parseValueRef parses a single value reference
+func (p *parser) parseValueRef() *ValueRef {
+	start, startLine := p.pos, p.lineNum
+
+	// Skip an opening trim marker and whitespace after {{
+	if p.match(trimMarker) && !isWhitespace(p.current()) {
+		p.pos, p.lineNum = start, startLine
+		return nil
+	}
+	p.skipWhitespace()
+	keyword := p.parseControlKeyword()
+	depth := p.openParens()
+	function := p.parseLeadingFunction()
+
+	// Look for default value, either as a function call before the value
+	// ({{ default "x" .Values.key }}) or piped after it
+	var arg defaultArg
+	var digKeys []string
+	if function == "dig" {
+		digKeys, arg.value, arg.unquoted = p.parseDigArgs()
+		arg.literal = digKeys != nil
+	} else if function == "" && p.matchWord(defaultFunc) {
+		p.parseDefault(&arg)
+		if arg.path != "" {
+			// Skip the value the default is taken from: {{ default .Values.a .Values.b }}
+			p.match(rootPrefix)
+			p.match(valuePrefix)
+			p.parseValuePath()
+		}
+		p.skipWhitespace()
+	}
+
+	// Check for .Values. prefix, also accepting the root variable form $.Values.
+	// used inside range and with blocks
+	refStart := p.pos
+	line := p.lineNum
+	var path string
+	if function != "index" || !p.matchValuesRoot() {
+		if !p.match(valuePrefix) && !p.match(rootPrefix+valuePrefix) {
+			p.pos, p.lineNum = start, startLine // Continue scanning right after {{
+			return nil
+		}
+
+		// Parse the value path
+		path = p.parseValuePath()
+		p.checkPath(refStart, path)
+		if path == "" {
+			return nil
+		}
+	}
+	refEnd := p.pos
+
+	// Accessors address a nested key of the value: dig "a" "b" "x" .Values.root
+	// reads root.a.b, hasKey .Values.features "beta" reads features.beta
+	switch function {
+	case "dig":
+		if len(digKeys) == 0 {
+			return nil
+		}
+		path = appendKeys(path, digKeys...)
+	case "index":
+		// index .Values "a" "b" and index .Values.a "b" both read a.b, so
+		// they are unified with the dot form .Values.a.b
+		path = appendKeys(path, p.parseIndexKeys()...)
+		if path == "" {
+			return nil
+		}
+	case "hasKey", "get":
+		p.skipWhitespace()
+		if quote := p.current(); quote == '"' || quote == '\'' {
+			if key := p.parseDefaultValue(); key != "" {
+				path = appendKey(path, key)
+			}
+		}
+	}
+
+	// Infer the type from the function or keyword the value is passed to
+	valueType := keywordTypes[keyword]
+	if t, ok := functionTypes[function]; ok {
+		valueType = t
+	}
+	if comparisonFuncs[function] {
+		p.skipWhitespace()
+		valueType = p.literalType()
+	}
+	if function != "" || arg.value != "" {
+		p.skipArguments()
+	}
+
+	// Handle pipe operations, then close sub-expressions from the inside out
+	var pipeType ValueType
+	p.parsePipes(&arg, &pipeType)
+	for ; depth > 0; depth-- {
+		p.skipArguments()
+		if !p.match(")") {
+			return nil
+		}
+
+		// A field access on the sub-expression extends the path: (.Values.a).b
+		if p.current() == '.' && p.pos+1 < len(p.input) && isValidPathChar(p.input[p.pos+1]) {
+			p.pos++
+			field := p.parseValuePath()
+			if field == "" {
+				return nil
+			}
+			path += "." + field
+			refEnd = p.pos
+		}
+		p.parsePipes(&arg, &pipeType)
+	}
+	if pipeType != TypeUnknown {
+		valueType = pipeType
+	}
+
+	// Ensure proper closing
+	p.skipWhitespace()
+	if !p.matchClose() {
+		return nil
+	}
+
+	return &ValueRef{
+		Path:            path,
+		DefaultValue:    arg.value,
+		HasDefault:      arg.literal,
+		DefaultUnquoted: arg.unquoted,
+		DefaultPath:     arg.path,
+		SourceFile:      p.template,
+		LineNumber:      line,
+		Column:          p.column(refStart),
+		EndOffset:       refEnd,
+		Type:            valueType,
+	}
+}
+
+// parseNestedRefs scans the rest of an action for value references that are not
+// at the start of its pipeline, such as function and include arguments. It must be
+// called right after the opening braces and leaves the parser after the closing
+// braces. The primary reference already parsed for the action is skipped, and
+// nothing is returned for actions that are unclosed or have unbalanced
+// parentheses. Nested references carry a default only when they start a
+// parenthesized pipeline: (.Values.key | default "x").
+func (p *parser) parseNestedRefs(primary *ValueRef) []ValueRef {
+	var refs []ValueRef
+	depth := 0
+	for p.pos < len(p.input) {
+		switch ch := p.current(); {
+		case ch == '"' || ch == '`':
+			if p.followsWord(tplFunc) {
+				// The string is a template itself: {{ tpl "{{ .Values.name }}-svc" . }}
+				refs = append(refs, p.parseTemplateString()...)
+				continue
+			}
+			p.skipString()
+			continue
+		case ch == '\'':
+			p.skipString()
+			continue
+		case p.atClose():
+			p.matchClose()
+			if depth != 0 {
+				return nil
+			}
+			return refs
+		case strings.HasPrefix(p.input[p.pos:], openBrace):
+			// Unclosed action: let the main loop handle the next one
+			return nil
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		case ch == '\n':
+			p.lineNum++
+		case strings.HasPrefix(p.input[p.pos:], valuePrefix) || strings.HasPrefix(p.input[p.pos:], rootPrefix+valuePrefix):
+			if ref := p.parseNestedRef(); ref != nil {
+				if primary == nil || ref.LineNumber != primary.LineNumber || ref.Column != primary.Column {
+					refs = append(refs, *ref)
+				}
+			}
+			continue
+		}
+		p.pos++
+	}
+	return refs
+}
+
+// followsWord reports whether the text before the current position ends with
+// the given word, ignoring whitespace.
+func (p *parser) followsWord(word string) bool {
+	before := strings.TrimRight(p.input[:p.pos], " \t\n\r")
+	if !strings.HasSuffix(before, word) {
+		return false
+	}
+	rest := before[:len(before)-len(word)]
+	return rest == "" || !isAlphaNumeric(rest[len(rest)-1])
+}
+
+// parseTemplateString parses the references in a string literal rendered as a
+// template, leaving the parser after the closing quote. The positions of the
+// references point into the literal.
+func (p *parser) parseTemplateString() []ValueRef {
+	start, startLine := p.pos, p.lineNum
+	p.skipString()
+	end := p.pos - 1
+	if end <= start || p.input[end] != p.input[start] {
+		return nil
+	}
+
+	// Drop the escape characters, remembering where each byte of the content
+	// comes from in the input
+	quote := p.input[start]
+	var content strings.Builder
+	offsets := make([]int, 0, end-start)
+	for i := start + 1; i < end; i++ {
+		if quote == '"' && p.input[i] == '\\' {
+			i++
+		}
+		content.WriteByte(p.input[i])
+		offsets = append(offsets, i)
+	}
+
+	sub := newParser(content.String(), p.template)
+	refs := sub.parse()
+
+	// offset returns the input offset of a line and column of the content
+	offset := func(line, column int) int {
+		lineStart := 0
+		if line > 1 {
+			lineStart = nthIndex(content.String(), '\n', line-1) + 1
+		}
+		return offsets[lineStart+column-1]
+	}
+	for i := range refs {
+		ref := &refs[i]
+		begin := offset(ref.LineNumber, ref.Column)
+		ref.LineNumber = startLine + strings.Count(p.input[start:begin], "\n")
+		ref.Column = p.column(begin)
+		ref.EndOffset = offsets[ref.EndOffset-1] + 1
+	}
+	for _, d := range sub.diagnostics {
+		p.warn(offset(d.LineNumber, d.Column), "%s", d.Message)
+	}
+	return refs
+}
+
+// nthIndex returns the index of the nth occurrence of ch in s, or -1
+func nthIndex(s string, ch byte, n int) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == ch {
+			if n--; n == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseNestedRef parses a value reference found inside an action, leaving the
+// parser after its path. References through other variables or fields, such as
+// $ctx.Values.key, are not value references of the chart.
+func (p *parser) parseNestedRef() *ValueRef {
+	refStart := p.pos
+	if refStart > 0 {
+		if prev := p.input[refStart-1]; isValidPathChar(prev) || prev == ')' || prev == '$' {
+			p.pos += len(valuePrefix)
+			return nil
+		}
+	}
+	p.match(rootPrefix)
+	p.match(valuePrefix)
+
+	path := p.parseValuePath()
+	p.checkPath(refStart, path)
+	if path == "" || !p.endsPath() {
+		return nil
+	}
+	ref := &ValueRef{
+		Path:       path,
+		SourceFile: p.template,
+		LineNumber: p.lineNum,
+		Column:     p.column(refStart),
+		EndOffset:  p.pos,
+	}
+
+	// Only a reference starting a sub-expression has its own pipes
+	if before := strings.TrimRight(p.input[:refStart], " \t\n\r"); strings.HasSuffix(before, "(") {
+		var arg defaultArg
+		p.parsePipes(&arg, &ref.Type)
+		ref.DefaultValue, ref.HasDefault, ref.DefaultUnquoted, ref.DefaultPath = arg.value, arg.literal, arg.unquoted, arg.path
+		if arg.path != "" {
+			// Continue scanning at the default, which is a reference itself
+			p.pos, p.lineNum = arg.start, arg.line
+		}
+	}
+	return ref
+}
+
+// parsePipes parses the pipe operations applied to a value, recording the
+// default value and the type implied by the first conversion function
+func (p *parser) parsePipes(arg *defaultArg, pipeType *ValueType) {
+	for p.pos < len(p.input) {
+		p.skipWhitespace()
+		if !p.match(defaultPipe) {
+			break
+		}
+
+		p.skipWhitespace()
+		if p.match(defaultFunc) {
+			p.parseDefault(arg)
+		} else if t, ok := functionTypes[p.parseIdentifier()]; ok && *pipeType == TypeUnknown {
+			// The first conversion applied to the value determines its type
+			*pipeType = t
+		}
+		// Skip other functions until next pipe or closing brace
+		p.skipArguments()
+	}
+}
+
+// openParens skips the opening parentheses of sub-expressions and returns their count
+func (p *parser) openParens() int {
+	depth := 0
+	for p.match("(") {
+		depth++
+		p.skipWhitespace()
+	}
+	return depth
+}
+
+// column returns the 1-based column of the given offset on its line
+func (p *parser) column(offset int) int {
+	return offset - strings.LastIndexByte(p.input[:offset], '\n')
+}
+
+// parseControlKeyword parses a control keyword such as if or range at the start of an action
+func (p *parser) parseControlKeyword() string {
+	for _, keyword := range controlKeywords {
+		if p.matchWord(keyword) {
+			p.skipWhitespace()
+			return keyword
+		}
+	}
+	return ""
+}
+
+// parseLeadingFunction parses a known function called with the value as argument,
+// such as toYaml in {{ toYaml .Values.resources }}
+func (p *parser) parseLeadingFunction() string {
+	start := p.pos
+	name := p.parseIdentifier()
+	_, typed := functionTypes[name]
+	if (typed || comparisonFuncs[name] || accessorFuncs[name]) && isWhitespace(p.current()) {
+		p.skipWhitespace()
+		return name
+	}
+	p.pos = start
+	return ""
+}
+
+// parseDigArgs parses the literal arguments of dig preceding the value: the keys
+// to descend into followed by the fallback value and whether it is unquoted
+func (p *parser) parseDigArgs() ([]string, string, bool) {
+	var args []string
+	unquoted := false
+	for {
+		p.skipWhitespace()
+		start := p.pos
+		if ch := p.current(); ch == '.' || ch == '$' || ch == '(' {
+			break
+		}
+		arg := p.parseDefaultValue()
+		if p.pos == start {
+			break
+		}
+		args = append(args, arg)
+		unquoted = p.input[start] != '"' && p.input[start] != '\''
+	}
+	if len(args) < 2 {
+		return nil, "", false
+	}
+	return args[:len(args)-1], args[len(args)-1], unquoted && args[len(args)-1] != ""
+}
+
+// defaultArg is the argument of the default function.
+type defaultArg struct {
+	// value is the literal argument, such as "x" or 8080
+	value string
+	// unquoted reports whether value is an unquoted literal such as 8080 or true
+	unquoted bool
+	// literal reports whether the argument is a literal, even an empty one
+	literal bool
+	// path is the value path of an argument that is another value: default .Values.a
+	path string
+	// start and line locate the argument with path in the input
+	start, line int
+}
+
+// parseDefault parses the argument of the default function, recording whether
+// it is an unquoted literal such as 8080 or true, or the path of the value it
+// is taken from. Arguments that are values are not consumed, as they are
+// references themselves.
+func (p *parser) parseDefault(arg *defaultArg) {
+	p.skipWhitespace()
+	start, line := p.pos, p.lineNum
+	quote := p.current()
+	arg.value = p.parseDefaultValue()
+	arg.unquoted = arg.value != "" && quote != '"' && quote != '\''
+	arg.literal = p.pos != start
+	if p.pos == start {
+		if path := p.peekValuePath(); path != "" {
+			arg.path, arg.start, arg.line = path, start, line
+		}
+	}
+}
+
+// peekValuePath returns the path of the value reference at the current
+// position, allowing for opening parentheses, without consuming it.
+func (p *parser) peekValuePath() string {
+	start, startLine := p.pos, p.lineNum
+	defer func() { p.pos, p.lineNum = start, startLine }()
+	p.openParens()
+	if !p.match(valuePrefix) && !p.match(rootPrefix+valuePrefix) {
+		return ""
+	}
+	return p.parseValuePath()
+}
+
+// matchValuesRoot matches the values root itself, .Values or $.Values, when not
+// followed by a path
+func (p *parser) matchValuesRoot() bool {
+	start := p.pos
+	root := strings.TrimSuffix(valuePrefix, ".")
+	if (p.match(root) || p.match(rootPrefix+root)) && isWhitespace(p.current()) {
+		return true
+	}
+	p.pos = start
+	return false
+}
+
+// parseIndexKeys parses the literal string keys passed to index. Parsing stops
+// at the first argument that is not a string literal, such as a list index or
+// a variable.
+func (p *parser) parseIndexKeys() []string {
+	var keys []string
+	for {
+		start, startLine := p.pos, p.lineNum
+		p.skipWhitespace()
+		if quote := p.current(); quote != '"' && quote != '\'' {
+			p.pos, p.lineNum = start, startLine
+			break
+		}
+		key := p.parseDefaultValue()
+		if key == "" {
+			p.pos, p.lineNum = start, startLine
+			break
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// parseIdentifier parses a function or keyword name
+func (p *parser) parseIdentifier() string {
+	start := p.pos
+	for p.pos < len(p.input) && isAlphaNumeric(p.current()) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+// matchWord matches s when it is followed by whitespace
+func (p *parser) matchWord(s string) bool {
+	end := p.pos + len(s)
+	if end < len(p.input) && p.input[p.pos:end] == s && isWhitespace(p.input[end]) {
+		p.pos = end
+		return true
+	}
+	return false
+}
+
+// literalType returns the type of the literal at the current position without consuming it
+func (p *parser) literalType() ValueType {
+	switch ch := p.current(); {
+	case isDigit(ch) || ch == '-':
+		return TypeInt
+	case ch == '"' || ch == '\'' || ch == '`':
+		return TypeString
+	case p.matchWord("true") || p.matchWord("false"):
+		return TypeBool
+	}
+	return TypeUnknown
+}
+
+// skipArguments skips the remaining arguments of a command until the next pipe,
+// the closing parenthesis of the enclosing sub-expression, or the closing brace.
+// Quoted strings and nested sub-expressions are skipped as a whole.
+func (p *parser) skipArguments() {
+	nested := 0
+	for p.pos < len(p.input) {
+		switch ch := p.current(); {
+		case ch == '"' || ch == '\'' || ch == '`':
+			p.skipString()
+			continue
+		case ch == '(':
+			nested++
+		case ch == ')':
+			if nested == 0 {
+				return
+			}
+			nested--
+		case nested == 0 && (ch == '|' || p.atClose()):
+			return
+		case ch == '\n':
+			p.lineNum++
+		}
+		p.pos++
+	}
+}
+
+// skipString skips a quoted string literal, leaving the parser after the closing quote
+func (p *parser) skipString() {
+	end, _ := stringEnd(p.input, p.pos)
+	p.lineNum += strings.Count(p.input[p.pos:end], "\n")
+	p.pos = end
+}
+
+// stringEnd returns the offset just past the string literal starting at start
+// and whether the literal is closed; unclosed literals extend to the end of input.
+func stringEnd(input string, start int) (int, bool) {
+	quote := input[start]
+	for i := start + 1; i < len(input); i++ {
+		switch ch := input[i]; {
+		case ch == '\\' && quote != '`':
+			i++
+		case ch == quote:
+			return i + 1, true
+		}
+	}
+	return len(input), false
+}
+
+// atClose reports whether the parser is at the closing braces, with or without a trim marker
+func (p *parser) atClose() bool {
+	rest := p.input[p.pos:]
+	return strings.HasPrefix(rest, closeBrace) || strings.HasPrefix(rest, trimMarker+closeBrace)
+}
+
+// matchClose matches the closing braces, including an optional trim marker
+func (p *parser) matchClose() bool {
+	return p.match(trimMarker+closeBrace) || p.match(closeBrace)
+}
+
+// parseValuePath parses the dot-notation path after .Values.
+func (p *parser) parseValuePath() string {
+	var path strings.Builder
+	lastWasDot := true // Start with true to prevent leading dot
+
+	for p.pos < len(p.input) {
+		ch := p.current()
+		if ch == '.' {
+			if lastWasDot {
+				return "" // Invalid: consecutive dots
+			}
+			lastWasDot = true
+		} else if isValidPathChar(ch) {
+			lastWasDot = false
+		} else {
+			break
+		}
+
+		path.WriteByte(ch)
+		p.pos++
+	}
+
+	// Check if path ends with a dot
+	if lastWasDot {
+		return ""
+	}
+
+	return path.String()
+}
+
+// parseDefaultValue parses the default value after the default function
+func (p *parser) parseDefaultValue() string {
+	p.skipWhitespace()
+
+	// Handle quoted strings
+	switch p.current() {
+	case '"', '\'':
+		quote := p.current()
+		p.pos++
+		var value strings.Builder
+		escaped := false
+
+		for p.pos < len(p.input) {
+			ch := p.current()
+			if escaped {
+				value.WriteByte(ch)
+				escaped = false
+			} else if ch == '\\' {
+				escaped = true
+			} else if ch == quote && !escaped {
+				p.pos++ // Skip closing quote
+				return value.String()
+			} else {
+				value.WriteByte(ch)
+			}
+			p.pos++
+		}
+		return "" // Unclosed quote
+
+	// Handle boolean and numeric values
+	default:
+		for _, literal := range []string{"true", "false"} {
+			if p.matchWord(literal) {
+				return literal
+			}
+		}
+		var value strings.Builder
+		if p.current() == '-' && p.pos+1 < len(p.input) && isDigit(p.input[p.pos+1]) {
+			value.WriteByte('-')
+			p.pos++
+		}
+		if !isDigit(p.current()) {
+			return "" // Not a literal, e.g. another reference
+		}
+		for p.pos < len(p.input) && (isDigit(p.current()) || p.current() == '.') {
+			value.WriteByte(p.current())
+			p.pos++
+		}
+		return value.String()
+	}
+}
+
+// Helper methods
+func (p *parser) current() byte {
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *parser) match(s string) bool {
+	if p.pos+len(s) > len(p.input) {
+		return false
+	}
+	if p.input[p.pos:p.pos+len(s)] == s {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *parser) skipWhitespace() {
+	for p.pos < len(p.input) && isWhitespace(p.current()) {
+		if p.current() == '\n' {
+			p.lineNum++
+		}
+		p.pos++
+	}
+}
+
+func isValidPathChar(ch byte) bool {
+	return isAlphaNumeric(ch) || ch == '.' || ch == '-' || ch == '_'
+}
+
+func isWhitespace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
+func isAlphaNumeric(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
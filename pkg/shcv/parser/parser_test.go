@@ -0,0 +1,1062 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseFile returns the references of a template, leaving out its diagnostics.
+func parseFile(content, path string) []ValueRef {
+	refs, _ := Parse(content, path)
+	return refs
+}
+
+func TestParse(t *testing.T) {
+	content := "image: {{ .Values.image.tag | default \"latest\" }}\nreplicas: {{ .Values.replicas | int }}\nname: {{ .Values.name\n"
+	refs, diagnostics := Parse(content, "templates/deployment.yaml")
+	require.Len(t, refs, 2)
+	assert.Equal(t, "image.tag", refs[0].Path)
+	assert.Equal(t, "latest", refs[0].DefaultValue)
+	assert.Equal(t, "templates/deployment.yaml", refs[0].SourceFile)
+	assert.Equal(t, 1, refs[0].LineNumber)
+	assert.Equal(t, "replicas", refs[1].Path)
+	assert.Equal(t, TypeInt, refs[1].Type)
+
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "templates/deployment.yaml:3:7: unclosed action", diagnostics[0].String())
+}
+
+func TestParseIgnoreDirective(t *testing.T) {
+	refs, _ := Parse("password: {{ .Values.password }} # "+IgnoreDirective+"\n", "secret.yaml")
+	require.Len(t, refs, 1)
+	assert.True(t, refs[0].Ignored)
+}
+
+func TestSplitValuePath(t *testing.T) {
+	assert.Equal(t, []string{"labels", "app.kubernetes.io/name"}, SplitValuePath(`labels."app.kubernetes.io/name"`))
+}
+
+func TestParseLineBasic(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		template string
+		want     []ValueRef
+	}{
+		{
+			name:     "simple value reference",
+			input:    "{{ .Values.simple }}",
+			template: "test.yaml",
+			want: []ValueRef{
+				{Path: "simple", SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 17},
+			},
+		},
+		{
+			name:     "value with default",
+			input:    "{{ .Values.key | default \"defaultValue\" }}",
+			template: "test.yaml",
+			want: []ValueRef{
+				{Path: "key", DefaultValue: "defaultValue", HasDefault: true, SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 14},
+			},
+		},
+		{
+			name:     "multiple values in one line",
+			input:    "{{ .Values.first }} and {{ .Values.second }}",
+			template: "test.yaml",
+			want: []ValueRef{
+				{Path: "first", SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 16},
+				{Path: "second", SourceFile: "test.yaml", LineNumber: 1, Column: 28, EndOffset: 41},
+			},
+		},
+		{
+			name:     "nested path",
+			input:    "{{ .Values.parent.child }}",
+			template: "test.yaml",
+			want: []ValueRef{
+				{Path: "parent.child", SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 23},
+			},
+		},
+		{
+			name:     "numeric default value",
+			input:    "{{ .Values.port | default 8080 }}",
+			template: "test.yaml",
+			want: []ValueRef{
+				{Path: "port", DefaultValue: "8080", HasDefault: true, DefaultUnquoted: true, SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 15},
+			},
+		},
+		{
+			name:     "multiple lines",
+			input:    "{{ .Values.first }}\n{{ .Values.second }}",
+			template: "test.yaml",
+			want: []ValueRef{
+				{Path: "first", SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 16},
+				{Path: "second", SourceFile: "test.yaml", LineNumber: 2, Column: 4, EndOffset: 37},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newParser(tt.input, tt.template)
+			got := p.parse()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLine() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLineEdgeCases(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		template string
+		want     []ValueRef
+	}{
+		{
+			name:     "empty input",
+			input:    "",
+			template: "test.yaml",
+			want:     nil,
+		},
+		{
+			name:     "no values reference",
+			input:    "{{ .Chart.Name }}",
+			template: "test.yaml",
+			want:     nil,
+		},
+		{
+			name:     "incomplete braces",
+			input:    "{{ .Values.incomplete",
+			template: "test.yaml",
+			want:     nil,
+		},
+		{
+			name:     "whitespace variations",
+			input:    "{{    .Values.spaced   |   default   \"value\"    }}",
+			template: "test.yaml",
+			want: []ValueRef{
+				{Path: "spaced", DefaultValue: "value", HasDefault: true, SourceFile: "test.yaml", LineNumber: 1, Column: 7, EndOffset: 20},
+			},
+		},
+		{
+			name:     "special characters in path",
+			input:    "{{ .Values.my-key_name.sub-key }}",
+			template: "test.yaml",
+			want: []ValueRef{
+				{Path: "my-key_name.sub-key", SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 30},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newParser(tt.input, tt.template)
+			got := p.parse()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLine() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseValueRef(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		template string
+		want     *ValueRef
+	}{
+		{
+			name:     "simple value",
+			input:    "{{ .Values.key }}",
+			template: "test.yaml",
+			want:     &ValueRef{Path: "key", SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 14},
+		},
+		{
+			name:     "with default string",
+			input:    "{{ .Values.key | default \"value\" }}",
+			template: "test.yaml",
+			want:     &ValueRef{Path: "key", DefaultValue: "value", HasDefault: true, SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 14},
+		},
+		{
+			name:     "with single quotes",
+			input:    "{{ .Values.key | default 'value' }}",
+			template: "test.yaml",
+			want:     &ValueRef{Path: "key", DefaultValue: "value", HasDefault: true, SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 14},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newParser(tt.input, tt.template)
+			p.match("{{") // Move past opening braces
+			got := p.parseValueRef()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseValueRef() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLineAdvancedCases(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		template string
+		want     []ValueRef
+	}{
+		{
+			name:     "escaped quotes in default",
+			input:    `{{ .Values.key | default "value \"quoted\" here" }}`,
+			template: "test.yaml",
+			want: []ValueRef{
+				{Path: "key", DefaultValue: `value "quoted" here`, HasDefault: true, SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 14},
+			},
+		},
+		{
+			name:     "very long path",
+			input:    "{{ .Values.this.is.a.very.long.nested.path.that.should.still.work }}",
+			template: "test.yaml",
+			want: []ValueRef{
+				{Path: "this.is.a.very.long.nested.path.that.should.still.work", SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 65},
+			},
+		},
+		{
+			name:     "multiple pipes",
+			input:    "{{ .Values.key | default \"\" | quote }}",
+			template: "test.yaml",
+			want: []ValueRef{
+				{Path: "key", DefaultValue: "", HasDefault: true, SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 14, Type: TypeString},
+			},
+		},
+		{
+			name:     "mixed quotes",
+			input:    "{{ .Values.key | default \"value's here\" }}",
+			template: "test.yaml",
+			want: []ValueRef{
+				{Path: "key", DefaultValue: "value's here", HasDefault: true, SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 14},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newParser(tt.input, tt.template)
+			got := p.parse()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLine() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLineMalformedCases(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		template string
+		want     []ValueRef
+	}{
+		{
+			name:     "unclosed quotes",
+			input:    `{{ .Values.key | default "unclosed }}`,
+			template: "test.yaml",
+			want:     nil,
+		},
+		{
+			name:     "missing closing brace",
+			input:    "{{ .Values.key | default \"value\" }",
+			template: "test.yaml",
+			want:     nil,
+		},
+		{
+			name:     "invalid path characters",
+			input:    "{{ .Values.key@invalid }}",
+			template: "test.yaml",
+			want:     nil,
+		},
+		{
+			name:     "empty path",
+			input:    "{{ .Values. }}",
+			template: "test.yaml",
+			want:     nil,
+		},
+		{
+			name:     "multiple consecutive dots",
+			input:    "{{ .Values..key }}",
+			template: "test.yaml",
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newParser(tt.input, tt.template)
+			got := p.parse()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLine() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		templatePath string
+		want         []ValueRef
+	}{
+		{
+			name:         "simple value reference",
+			content:      "{{ .Values.simple }}",
+			templatePath: "test.yaml",
+			want: []ValueRef{
+				{
+					Path:       "simple",
+					SourceFile: "test.yaml",
+					LineNumber: 1,
+					Column:     4,
+					EndOffset:  17,
+				},
+			},
+		},
+		{
+			name:         "value with default",
+			content:      "{{ .Values.withDefault | default \"defaultValue\" }}",
+			templatePath: "test.yaml",
+			want: []ValueRef{
+				{
+					Path:         "withDefault",
+					SourceFile:   "test.yaml",
+					LineNumber:   1,
+					Column:       4,
+					EndOffset:    22,
+					DefaultValue: "defaultValue",
+					HasDefault:   true,
+				},
+			},
+		},
+		{
+			name:         "multiple values",
+			content:      "{{ .Values.first }}\n{{ .Values.second }}",
+			templatePath: "test.yaml",
+			want: []ValueRef{
+				{
+					Path:       "first",
+					SourceFile: "test.yaml",
+					LineNumber: 1,
+					Column:     4,
+					EndOffset:  16,
+				},
+				{
+					Path:       "second",
+					SourceFile: "test.yaml",
+					LineNumber: 2,
+					Column:     4,
+					EndOffset:  37,
+				},
+			},
+		},
+		{
+			name:         "nested value",
+			content:      "{{ .Values.parent.child }}",
+			templatePath: "test.yaml",
+			want: []ValueRef{
+				{
+					Path:       "parent.child",
+					SourceFile: "test.yaml",
+					LineNumber: 1,
+					Column:     4,
+					EndOffset:  23,
+				},
+			},
+		},
+		{
+			name:         "no values",
+			content:      "just some text\nwithout any values",
+			templatePath: "test.yaml",
+			want:         nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseFile(tt.content, tt.templatePath)
+			if len(got) == 0 && tt.want == nil {
+				return // both empty, test passes
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	content := "image: {{ .Values.image.tag | default \"latest\" }}\r\nport: {{ .Values.port }}\r\nname: {{ .Values.name\r\n"
+	want, wantDiagnostics := Parse(strings.ReplaceAll(content, "\r\n", "\n"), "deployment.yaml")
+
+	// The content arrives a byte at a time, as from a slow network stream
+	refs, diagnostics, err := ParseReader(iotest.OneByteReader(strings.NewReader(content)), "deployment.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, wantDiagnostics, diagnostics)
+	require.Len(t, refs, 2)
+	assert.Equal(t, "image.tag", refs[0].Path)
+	assert.Equal(t, want[0], refs[0])
+
+	// End offsets count the carriage returns of the lines before them
+	assert.Equal(t, "port", refs[1].Path)
+	assert.Equal(t, want[1].EndOffset+1, refs[1].EndOffset)
+	assert.True(t, strings.HasSuffix(content[:refs[1].EndOffset], ".Values.port"))
+
+	errRead := errors.New("connection reset")
+	_, _, err = ParseReader(iotest.ErrReader(errRead), "deployment.yaml")
+	assert.ErrorIs(t, err, errRead)
+	assert.ErrorContains(t, err, "reading template deployment.yaml")
+}
+
+func TestParseBytes(t *testing.T) {
+	content := "replicas: {{ .Values.replicas | int }}\n"
+	refs, diagnostics := ParseBytes([]byte(content), "deployment.yaml")
+	want, wantDiagnostics := Parse(content, "deployment.yaml")
+	assert.Equal(t, want, refs)
+	assert.Equal(t, wantDiagnostics, diagnostics)
+}
+
+func TestParserHelpers(t *testing.T) {
+	t.Run("current", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			input string
+			pos   int
+			want  byte
+		}{
+			{
+				name:  "first character",
+				input: "test",
+				pos:   0,
+				want:  't',
+			},
+			{
+				name:  "middle character",
+				input: "test",
+				pos:   2,
+				want:  's',
+			},
+			{
+				name:  "last character",
+				input: "test",
+				pos:   3,
+				want:  't',
+			},
+			{
+				name:  "beyond end",
+				input: "test",
+				pos:   4,
+				want:  0,
+			},
+			{
+				name:  "empty input",
+				input: "",
+				pos:   0,
+				want:  0,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				p := &parser{input: tt.input, pos: tt.pos}
+				got := p.current()
+				assert.Equal(t, tt.want, got)
+			})
+		}
+	})
+
+	t.Run("skipWhitespace", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			input     string
+			startPos  int
+			wantPos   int
+			wantLines int
+		}{
+			{
+				name:      "no whitespace",
+				input:     "test",
+				startPos:  0,
+				wantPos:   0,
+				wantLines: 1,
+			},
+			{
+				name:      "spaces",
+				input:     "   test",
+				startPos:  0,
+				wantPos:   3,
+				wantLines: 1,
+			},
+			{
+				name:      "tabs",
+				input:     "\t\ttest",
+				startPos:  0,
+				wantPos:   2,
+				wantLines: 1,
+			},
+			{
+				name:      "newlines",
+				input:     "\n\ntest",
+				startPos:  0,
+				wantPos:   2,
+				wantLines: 3,
+			},
+			{
+				name:      "mixed whitespace",
+				input:     " \t\n\r test",
+				startPos:  0,
+				wantPos:   5,
+				wantLines: 2,
+			},
+			{
+				name:      "at end",
+				input:     "test   ",
+				startPos:  4,
+				wantPos:   7,
+				wantLines: 1,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				p := &parser{input: tt.input, pos: tt.startPos, lineNum: 1}
+				p.skipWhitespace()
+				assert.Equal(t, tt.wantPos, p.pos)
+				assert.Equal(t, tt.wantLines, p.lineNum)
+			})
+		}
+	})
+
+	t.Run("character checks", func(t *testing.T) {
+		// Test isValidPathChar
+		validPathChars := []byte("abcZY09.-_")
+		invalidPathChars := []byte("!@#$%^&*()")
+		for _, ch := range validPathChars {
+			assert.True(t, isValidPathChar(ch), "char %c should be valid", ch)
+		}
+		for _, ch := range invalidPathChars {
+			assert.False(t, isValidPathChar(ch), "char %c should be invalid", ch)
+		}
+
+		// Test isWhitespace
+		whitespaceChars := []byte{' ', '\t', '\n', '\r'}
+		nonWhitespaceChars := []byte{'a', '1', '.', '-'}
+		for _, ch := range whitespaceChars {
+			assert.True(t, isWhitespace(ch), "char %c should be whitespace", ch)
+		}
+		for _, ch := range nonWhitespaceChars {
+			assert.False(t, isWhitespace(ch), "char %c should not be whitespace", ch)
+		}
+
+		// Test isAlphaNumeric
+		alphaNumChars := []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+		nonAlphaNumChars := []byte("!@#$%^&*()_+-=[]{}|;:,.<>?")
+		for _, ch := range alphaNumChars {
+			assert.True(t, isAlphaNumeric(ch), "char %c should be alphanumeric", ch)
+		}
+		for _, ch := range nonAlphaNumChars {
+			assert.False(t, isAlphaNumeric(ch), "char %c should not be alphanumeric", ch)
+		}
+
+		// Test isDigit
+		digitChars := []byte("0123456789")
+		nonDigitChars := []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ!@#$%^&*()")
+		for _, ch := range digitChars {
+			assert.True(t, isDigit(ch), "char %c should be digit", ch)
+		}
+		for _, ch := range nonDigitChars {
+			assert.False(t, isDigit(ch), "char %c should not be digit", ch)
+		}
+	})
+}
+
+func TestParseComments(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []ValueRef
+	}{
+		{
+			name:  "single line comment",
+			input: "{{/* {{ .Values.commented }} */}}",
+			want:  nil,
+		},
+		{
+			name:  "comment with trim markers",
+			input: "{{- /* .Values.commented */ -}}\n{{ .Values.kept }}",
+			want: []ValueRef{
+				{Path: "kept", SourceFile: "test.yaml", LineNumber: 2, Column: 4, EndOffset: 47},
+			},
+		},
+		{
+			name:  "multi-line comment",
+			input: "{{/*\nexample:\n  {{ .Values.example | default \"x\" }}\n*/}}\n{{ .Values.kept }}",
+			want: []ValueRef{
+				{Path: "kept", SourceFile: "test.yaml", LineNumber: 5, Column: 4, EndOffset: 72},
+			},
+		},
+		{
+			name:  "comment between references",
+			input: "{{ .Values.first }} {{/* .Values.skipped */}} {{ .Values.second }}",
+			want: []ValueRef{
+				{Path: "first", SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 16},
+				{Path: "second", SourceFile: "test.yaml", LineNumber: 1, Column: 50, EndOffset: 63},
+			},
+		},
+		{
+			name:  "unclosed comment",
+			input: "{{/* {{ .Values.never }}",
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseFile(tt.input, "test.yaml")
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseTrimMarkers(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []ValueRef
+	}{
+		{
+			name:  "trim both sides",
+			input: "{{- .Values.trimmed -}}",
+			want: []ValueRef{
+				{Path: "trimmed", SourceFile: "test.yaml", LineNumber: 1, Column: 5, EndOffset: 19},
+			},
+		},
+		{
+			name:  "trim with default",
+			input: "{{- .Values.key | default \"value\" -}}",
+			want: []ValueRef{
+				{Path: "key", DefaultValue: "value", HasDefault: true, SourceFile: "test.yaml", LineNumber: 1, Column: 5, EndOffset: 15},
+			},
+		},
+		{
+			name:  "trim with pipe function",
+			input: "{{- .Values.key | quote -}}",
+			want: []ValueRef{
+				{Path: "key", SourceFile: "test.yaml", LineNumber: 1, Column: 5, EndOffset: 15, Type: TypeString},
+			},
+		},
+		{
+			name:  "chained control actions",
+			input: "{{- if .Values.enabled -}}\n{{- with .Values.config }}\n{{- range .Values.items -}}\n{{- else if .Values.fallback }}\n{{- end }}",
+			want: []ValueRef{
+				{Path: "enabled", SourceFile: "test.yaml", LineNumber: 1, Column: 8, EndOffset: 22, Type: TypeBool},
+				{Path: "config", SourceFile: "test.yaml", LineNumber: 2, Column: 10, EndOffset: 50, Type: TypeMap},
+				{Path: "items", SourceFile: "test.yaml", LineNumber: 3, Column: 11, EndOffset: 77, Type: TypeList},
+				{Path: "fallback", SourceFile: "test.yaml", LineNumber: 4, Column: 13, EndOffset: 110, Type: TypeBool},
+			},
+		},
+		{
+			name:  "line numbers after failed actions spanning lines",
+			input: "{{ \n \n.Chart.Name }}\n{{- .Values.after }}",
+			want: []ValueRef{
+				{Path: "after", SourceFile: "test.yaml", LineNumber: 4, Column: 5, EndOffset: 38},
+			},
+		},
+		{
+			name:  "reference on a later line of the action",
+			input: "{{-\n  .Values.multiline\n  | default \"x\"\n-}}\n{{ .Values.next }}",
+			want: []ValueRef{
+				{Path: "multiline", DefaultValue: "x", HasDefault: true, SourceFile: "test.yaml", LineNumber: 2, Column: 3, EndOffset: 23},
+				{Path: "next", SourceFile: "test.yaml", LineNumber: 5, Column: 4, EndOffset: 59},
+			},
+		},
+		{
+			name:  "dash without whitespace is not a trim marker",
+			input: "{{-.Values.key }}",
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseFile(tt.input, "test.yaml")
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseSpans(t *testing.T) {
+	content := "metadata:\n  name: {{ .Values.name }}\nspec:\n  replicas: {{- if .Values.scale }}{{ .Values.replicas | default 2 }}{{ end }}\n"
+
+	refs := parseFile(content, "test.yaml")
+	require.Len(t, refs, 3)
+
+	lines := strings.Split(content, "\n")
+	for _, ref := range refs {
+		token := valuePrefix + ref.Path
+		start := ref.EndOffset - len(token)
+		assert.Equal(t, token, content[start:ref.EndOffset], "span of %s", ref.Path)
+
+		line := lines[ref.LineNumber-1]
+		assert.True(t, strings.HasPrefix(line[ref.Column-1:], token), "column of %s", ref.Path)
+	}
+}
+
+func TestParseTypeInference(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  ValueType
+	}{
+		{name: "no usage context", input: "{{ .Values.key }}", want: TypeUnknown},
+		{name: "int pipe", input: "{{ .Values.key | int }}", want: TypeInt},
+		{name: "atoi pipe", input: "{{ .Values.key | atoi }}", want: TypeInt},
+		{name: "quote pipe", input: "{{ .Values.key | quote }}", want: TypeString},
+		{name: "toYaml pipe", input: "{{ .Values.key | toYaml | nindent 4 }}", want: TypeMap},
+		{name: "first conversion wins", input: "{{ .Values.key | int | quote }}", want: TypeInt},
+		{name: "default then conversion", input: "{{ .Values.key | default 3 | int }}", want: TypeInt},
+		{name: "untyped pipe", input: "{{ .Values.key | nindent 4 }}", want: TypeUnknown},
+		{name: "toYaml function", input: "{{- toYaml .Values.key | nindent 10 }}", want: TypeMap},
+		{name: "quote function", input: "{{ quote .Values.key }}", want: TypeString},
+		{name: "if block", input: "{{ if .Values.key }}", want: TypeBool},
+		{name: "if not", input: "{{ if not .Values.key }}", want: TypeBool},
+		{name: "range block", input: "{{ range .Values.key }}", want: TypeList},
+		{name: "with block", input: "{{ with .Values.key }}", want: TypeMap},
+		{name: "numeric comparison", input: "{{ if gt .Values.key 1 }}", want: TypeInt},
+		{name: "negative comparison", input: "{{ if eq .Values.key -1 }}", want: TypeInt},
+		{name: "string comparison", input: `{{ if eq .Values.key "prod" }}`, want: TypeString},
+		{name: "bool comparison", input: "{{ if ne .Values.key true }}", want: TypeBool},
+		{name: "pipe overrides block", input: "{{ if .Values.key | int }}", want: TypeInt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refs := parseFile(tt.input, "test.yaml")
+			require.Len(t, refs, 1)
+			assert.Equal(t, "key", refs[0].Path)
+			assert.Equal(t, tt.want, refs[0].Type)
+		})
+	}
+}
+
+func TestParseSubExpressions(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantPath    string
+		wantDefault string
+		wantType    ValueType
+	}{
+		{name: "default function", input: `{{ default "x" .Values.a }}`, wantPath: "a", wantDefault: "x"},
+		{name: "default in parentheses", input: `{{ (default "x" .Values.a) | quote }}`, wantPath: "a", wantDefault: "x", wantType: TypeString},
+		{name: "field of sub-expression", input: `{{ (.Values.a).b }}`, wantPath: "a.b"},
+		{name: "nested parentheses", input: `{{ ((.Values.a).b).c | int }}`, wantPath: "a.b.c", wantType: TypeInt},
+		{name: "pipe inside parentheses", input: `{{ (.Values.a | default 3) | int }}`, wantPath: "a", wantDefault: "3", wantType: TypeInt},
+		{name: "comparison in parentheses", input: `{{ if (gt .Values.a 1) }}`, wantPath: "a", wantType: TypeInt},
+		{name: "parenthesized argument", input: `{{ .Values.a | default (printf "%s|%s" "x" "y") | quote }}`, wantPath: "a", wantType: TypeString},
+		{name: "bool default function", input: `{{ default true .Values.a }}`, wantPath: "a", wantDefault: "true"},
+		{name: "negative default", input: `{{ .Values.a | default -1 }}`, wantPath: "a", wantDefault: "-1"},
+		{name: "quoted parenthesis", input: `{{ (default ")" .Values.a) }}`, wantPath: "a", wantDefault: ")"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refs := parseFile(tt.input, "test.yaml")
+			require.Len(t, refs, 1)
+			assert.Equal(t, tt.wantPath, refs[0].Path)
+			assert.Equal(t, tt.wantDefault, refs[0].DefaultValue)
+			assert.Equal(t, tt.wantType, refs[0].Type)
+		})
+	}
+
+	t.Run("unbalanced parentheses", func(t *testing.T) {
+		assert.Empty(t, parseFile(`{{ (.Values.a }}`, "test.yaml"))
+	})
+}
+
+func TestParseAccessors(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		wantPath     string
+		wantDefault  string
+		wantUnquoted bool
+	}{
+		{name: "dig", input: `{{ dig "a" "b" "fallback" .Values.root }}`, wantPath: "root.a.b", wantDefault: "fallback"},
+		{name: "dig single key", input: `{{ dig "enabled" "" .Values.feature | quote }}`, wantPath: "feature.enabled"},
+		{name: "dig numeric fallback", input: `{{ dig "port" 8080 .Values.service }}`, wantPath: "service.port", wantDefault: "8080", wantUnquoted: true},
+		{name: "hasKey", input: `{{ if hasKey .Values.features "beta" }}`, wantPath: "features.beta"},
+		{name: "get", input: `{{ get .Values.labels 'app' }}`, wantPath: "labels.app"},
+		{name: "get without literal key", input: `{{ get .Values.labels $key }}`, wantPath: "labels"},
+		{name: "dig bool fallback", input: `{{ dig "enabled" false .Values.feature }}`, wantPath: "feature.enabled", wantDefault: "false", wantUnquoted: true},
+		{name: "dig in parentheses", input: `{{ (dig "a" "x" .Values.root) | upper }}`, wantPath: "root.a", wantDefault: "x"},
+		{name: "index values root", input: `{{ index .Values "image" "tag" }}`, wantPath: "image.tag"},
+		{name: "index root variable", input: `{{ index $.Values "my-key" }}`, wantPath: "my-key"},
+		{name: "index path", input: `{{ index .Values.image  'tag' | quote }}`, wantPath: "image.tag"},
+		{name: "index stops at list index", input: `{{ index .Values.hosts 0 "name" }}`, wantPath: "hosts"},
+		{name: "index dotted key", input: `{{ index .Values.annotations "example.com/owner" }}`, wantPath: `annotations."example.com/owner"`},
+		{name: "get dotted key", input: `{{ get .Values.labels "app.kubernetes.io/name" }}`, wantPath: `labels."app.kubernetes.io/name"`},
+		{name: "index with default", input: `{{ index .Values "port" | default 80 }}`, wantPath: "port", wantDefault: "80", wantUnquoted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refs := parseFile(tt.input, "test.yaml")
+			require.Len(t, refs, 1)
+			assert.Equal(t, tt.wantPath, refs[0].Path)
+			assert.Equal(t, tt.wantDefault, refs[0].DefaultValue)
+			assert.Equal(t, tt.wantUnquoted, refs[0].DefaultUnquoted)
+		})
+	}
+
+	t.Run("dig without fallback", func(t *testing.T) {
+		assert.Empty(t, parseFile(`{{ dig "a" .Values.root }}`, "test.yaml"))
+	})
+
+	t.Run("index without literal keys", func(t *testing.T) {
+		assert.Empty(t, parseFile(`{{ index .Values $key }}`, "test.yaml"))
+	})
+
+	t.Run("index and dot form share the path", func(t *testing.T) {
+		refs := parseFile("a: {{ index .Values \"image\" \"tag\" }}\nb: {{ .Values.image.tag }}\n", "test.yaml")
+		assert.Equal(t, []ValueRef{
+			{Path: "image.tag", SourceFile: "test.yaml", LineNumber: 1, Column: 13, EndOffset: 19},
+			{Path: "image.tag", SourceFile: "test.yaml", LineNumber: 2, Column: 7, EndOffset: 60},
+		}, refs)
+	})
+}
+
+func TestParseRootReferences(t *testing.T) {
+	content := `{{- range .Values.items }}
+- name: {{ .name }}
+  env: {{ $.Values.global.env | default "prod" }}
+  {{- with $.Values.global.labels }}
+  labels: {{ toYaml . }}
+  {{- end }}
+{{- end }}`
+
+	got := parseFile(content, "test.yaml")
+	assert.Equal(t, []ValueRef{
+		{Path: "items", SourceFile: "test.yaml", LineNumber: 1, Column: 11, EndOffset: 23, Type: TypeList},
+		{Path: "global.env", DefaultValue: "prod", HasDefault: true, SourceFile: "test.yaml", LineNumber: 3, Column: 11, EndOffset: 76, Type: TypeUnknown},
+		{Path: "global.labels", SourceFile: "test.yaml", LineNumber: 4, Column: 12, EndOffset: 130, Type: TypeMap},
+	}, got)
+
+	t.Run("variables are not values", func(t *testing.T) {
+		assert.Empty(t, parseFile("{{ $values.Values.x }}{{ $.Chart.Name }}", "test.yaml"))
+	})
+}
+
+func TestParseNestedReferences(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []ValueRef
+	}{
+		{
+			name:  "include arguments",
+			input: `{{ include "mychart.image" (dict "image" .Values.image "ctx" $) }}`,
+			want:  []ValueRef{{Path: "image", SourceFile: "test.yaml", LineNumber: 1, Column: 42, EndOffset: 54}},
+		},
+		{
+			name:  "several arguments",
+			input: `{{- if and .Values.a.enabled (not $.Values.b) -}}`,
+			want: []ValueRef{
+				{Path: "a.enabled", SourceFile: "test.yaml", LineNumber: 1, Column: 12, EndOffset: 28},
+				{Path: "b", SourceFile: "test.yaml", LineNumber: 1, Column: 35, EndOffset: 44},
+			},
+		},
+		{
+			name:  "default argument after primary reference",
+			input: `{{ .Values.a | default .Values.b }}`,
+			want: []ValueRef{
+				{Path: "a", DefaultPath: "b", SourceFile: "test.yaml", LineNumber: 1, Column: 4, EndOffset: 12},
+				{Path: "b", SourceFile: "test.yaml", LineNumber: 1, Column: 24, EndOffset: 32},
+			},
+		},
+		{
+			name:  "default argument before primary reference",
+			input: `{{ default .Values.b .Values.a }}`,
+			want: []ValueRef{
+				{Path: "a", DefaultPath: "b", SourceFile: "test.yaml", LineNumber: 1, Column: 22, EndOffset: 30},
+				{Path: "b", SourceFile: "test.yaml", LineNumber: 1, Column: 12, EndOffset: 20},
+			},
+		},
+		{
+			name:  "default argument in parenthesized pipeline",
+			input: `{{ include "x" (.Values.a | default (.Values.b | int)) }}`,
+			want: []ValueRef{
+				{Path: "a", DefaultPath: "b", SourceFile: "test.yaml", LineNumber: 1, Column: 17, EndOffset: 25},
+				{Path: "b", SourceFile: "test.yaml", LineNumber: 1, Column: 38, EndOffset: 46, Type: TypeInt},
+			},
+		},
+		{
+			name:  "parenthesized pipeline",
+			input: "{{ include \"x\" (dict\n  \"port\" (.Values.port | default 80 | int)) }}",
+			want:  []ValueRef{{Path: "port", DefaultValue: "80", HasDefault: true, DefaultUnquoted: true, SourceFile: "test.yaml", LineNumber: 2, Column: 11, EndOffset: 43, Type: TypeInt}},
+		},
+		{
+			name:  "quoted and variable references are ignored",
+			input: `{{ include "x" (dict "a" ".Values.a" "b" $ctx.Values.b) }}`,
+		},
+		{
+			name:  "unclosed action",
+			input: `{{ include "x" .Values.a`,
+		},
+		{
+			name:  "unbalanced parentheses",
+			input: `{{ include "x" (dict "a" .Values.a }}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseFile(tt.input, "test.yaml"))
+		})
+	}
+}
+
+func TestParseTemplateFunctions(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []ValueRef
+	}{
+		{
+			name:  "printf arguments",
+			input: `name: {{ printf "%s-%s" .Values.prefix .Release.Name }}`,
+			want:  []ValueRef{{Path: "prefix", SourceFile: "test.yaml", LineNumber: 1, Column: 25, EndOffset: 38}},
+		},
+		{
+			name:  "tpl value",
+			input: `{{ tpl .Values.config . }}`,
+			want:  []ValueRef{{Path: "config", SourceFile: "test.yaml", LineNumber: 1, Column: 8, EndOffset: 21}},
+		},
+		{
+			name:  "tpl template string",
+			input: "a: 1\nhost: {{ tpl \"{{ .Values.name | default \\\"app\\\" }}-svc\" $ }}",
+			want:  []ValueRef{{Path: "name", DefaultValue: "app", HasDefault: true, SourceFile: "test.yaml", LineNumber: 2, Column: 18, EndOffset: 34}},
+		},
+		{
+			name:  "tpl raw string",
+			input: "{{ tpl `{{ .Values.a }}\n{{ .Values.b }}` . }}",
+			want: []ValueRef{
+				{Path: "a", SourceFile: "test.yaml", LineNumber: 1, Column: 12, EndOffset: 20},
+				{Path: "b", SourceFile: "test.yaml", LineNumber: 2, Column: 4, EndOffset: 36},
+			},
+		},
+		{
+			name:  "other strings are not templates",
+			input: `{{ printf "{{ .Values.a }}" }}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseFile(tt.input, "test.yaml"))
+		})
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Diagnostic
+	}{
+		{
+			name:  "well-formed",
+			input: "{{ .Values.a | default \"}}\" }}\n{{ include \"x\" (dict \"a\" .Values.b) }}\n",
+		},
+		{
+			name:  "unclosed action",
+			input: "a: {{ .Values.a\nb: {{ .Values.b }}\n",
+			want:  []Diagnostic{{SourceFile: "test.yaml", LineNumber: 1, Column: 4, Message: "unclosed action"}},
+		},
+		{
+			name:  "unclosed action at end of input",
+			input: "a: {{ .Values.a",
+			want:  []Diagnostic{{SourceFile: "test.yaml", LineNumber: 1, Column: 4, Message: "unclosed action"}},
+		},
+		{
+			name:  "unclosed comment",
+			input: "{{/* .Values.a }}\n",
+			want:  []Diagnostic{{SourceFile: "test.yaml", LineNumber: 1, Column: 1, Message: "unclosed comment"}},
+		},
+		{
+			name:  "unterminated string",
+			input: `{{ .Values.key | default "unclosed }}`,
+			want:  []Diagnostic{{SourceFile: "test.yaml", LineNumber: 1, Column: 26, Message: "unterminated string in action"}},
+		},
+		{
+			name:  "unbalanced parentheses",
+			input: `{{ include "x" (dict "a" .Values.a }}`,
+			want:  []Diagnostic{{SourceFile: "test.yaml", LineNumber: 1, Column: 1, Message: "unbalanced parentheses in action"}},
+		},
+		{
+			name:  "invalid path character",
+			input: "x: 1\n{{ .Values.key@invalid }}",
+			want:  []Diagnostic{{SourceFile: "test.yaml", LineNumber: 2, Column: 4, Message: "invalid character '@' after .Values.key"}},
+		},
+		{
+			name:  "invalid nested path",
+			input: `{{ include "x" .Values.a..b }}`,
+			want:  []Diagnostic{{SourceFile: "test.yaml", LineNumber: 1, Column: 16, Message: `invalid value path ".Values.a..b"`}},
+		},
+		{
+			name:  "empty path",
+			input: "{{ .Values. }}",
+			want:  []Diagnostic{{SourceFile: "test.yaml", LineNumber: 1, Column: 4, Message: `invalid value path ".Values."`}},
+		},
+		{
+			name:  "tpl template string",
+			input: "a: 1\nb: {{ tpl \"{{ .Values.a@b }}\" . }}",
+			want:  []Diagnostic{{SourceFile: "test.yaml", LineNumber: 2, Column: 15, Message: "invalid character '@' after .Values.a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got := Parse(tt.input, "test.yaml")
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseDefaultQuoting(t *testing.T) {
+	tests := []struct {
+		input        string
+		wantDefault  string
+		wantUnquoted bool
+	}{
+		{input: `{{ .Values.port | default 8080 }}`, wantDefault: "8080", wantUnquoted: true},
+		{input: `{{ .Values.port | default "8080" }}`, wantDefault: "8080"},
+		{input: `{{ .Values.ratio | default 0.5 }}`, wantDefault: "0.5", wantUnquoted: true},
+		{input: `{{ .Values.debug | default false }}`, wantDefault: "false", wantUnquoted: true},
+		{input: `{{ .Values.debug | default 'false' }}`, wantDefault: "false"},
+		{input: `{{ default -1 .Values.offset }}`, wantDefault: "-1", wantUnquoted: true},
+		{input: `{{ .Values.name | default .Values.other }}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			refs := parseFile(tt.input, "test.yaml")
+			require.NotEmpty(t, refs)
+			assert.Equal(t, tt.wantDefault, refs[0].DefaultValue)
+			assert.Equal(t, tt.wantUnquoted, refs[0].DefaultUnquoted)
+		})
+	}
+}
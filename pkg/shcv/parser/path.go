@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Value paths join their keys with dots, as in image.tag. Keys that contain a
+// dot or start with a double quote, such as the annotation key of
+// index .Values.annotations "nginx.ingress.kubernetes.io/rewrite-target", are
+// written as double-quoted Go strings:
+// annotations."nginx.ingress.kubernetes.io/rewrite-target".
+
+// SplitValuePath returns the keys of a value path, unquoting quoted keys.
+func SplitValuePath(path string) []string {
+	var keys []string
+	for {
+		if strings.HasPrefix(path, `"`) {
+			if quoted, err := strconv.QuotedPrefix(path); err == nil {
+				if rest := path[len(quoted):]; rest == "" || rest[0] == '.' {
+					key, _ := strconv.Unquote(quoted)
+					keys = append(keys, key)
+					if rest == "" {
+						return keys
+					}
+					path = rest[1:]
+					continue
+				}
+			}
+		}
+		key, rest, found := strings.Cut(path, ".")
+		keys = append(keys, key)
+		if !found {
+			return keys
+		}
+		path = rest
+	}
+}
+
+// JoinValuePath returns the value path of keys, quoting the keys that contain a
+// dot or start with a double quote.
+func JoinValuePath(keys ...string) string {
+	quoted := make([]string, len(keys))
+	for i, key := range keys {
+		quoted[i] = key
+		if strings.Contains(key, ".") || strings.HasPrefix(key, `"`) {
+			quoted[i] = strconv.Quote(key)
+		}
+	}
+	return strings.Join(quoted, ".")
+}
+
+// appendKeys returns the path of keys below parent, the empty path being the root.
+func appendKeys(parent string, keys ...string) string {
+	if len(keys) == 0 {
+		return parent
+	}
+	if parent == "" {
+		return JoinValuePath(keys...)
+	}
+	return parent + "." + JoinValuePath(keys...)
+}
+
+// appendKey returns the path of key below parent, the empty path being the root.
+func appendKey(parent, key string) string {
+	return appendKeys(parent, key)
+}
@@ -0,0 +1,54 @@
+package parser
+
+import "fmt"
+
+// ValueRef represents a Helm value reference found in templates.
+// It tracks where values are used in templates and their default values if specified.
+type ValueRef struct {
+	// Path is the full dot-notation path to the value (e.g. "gateway.domain"),
+	// with keys containing dots quoted, see SplitValuePath
+	Path string
+	// DefaultValue is the value specified in the template using the default function
+	DefaultValue string
+	// HasDefault reports whether the reference has a literal default, including
+	// an empty one such as default "" that DefaultValue cannot tell from none
+	HasDefault bool
+	// DefaultUnquoted reports whether DefaultValue is an unquoted literal such as
+	// 8080 or true, which is written to values files with its native type
+	DefaultUnquoted bool
+	// DefaultPath is the value path the default is taken from when it is another
+	// value rather than a literal, as in {{ .Values.b | default .Values.a }}
+	DefaultPath string
+	// SourceFile is the template file where this reference was found
+	SourceFile string
+	// LineNumber is the line number in the source file where the reference appears
+	LineNumber int
+	// Column is the 1-based byte column on LineNumber where the reference starts
+	Column int
+	// EndOffset is the byte offset in the source file just past the end of the
+	// reference, counting the "\r" of lines ended by "\r\n"
+	EndOffset int
+	// Type is the value type inferred from how the template uses the reference
+	Type ValueType
+	// Ignored reports that an ignore directive excludes the reference from
+	// syncing, see IgnoreDirective: no value is added for it
+	Ignored bool
+}
+
+// ValueType is the type of a value inferred from its usage in templates
+type ValueType string
+
+// Value types inferred from template usage
+const (
+	TypeUnknown ValueType = ""
+	TypeString  ValueType = "string"
+	TypeInt     ValueType = "int"
+	TypeBool    ValueType = "bool"
+	TypeMap     ValueType = "map"
+	TypeList    ValueType = "list"
+)
+
+// ID returns a unique identifier for the value reference
+func (v *ValueRef) ID() string {
+	return fmt.Sprintf("%s:%d:%s", v.Path, v.LineNumber, v.SourceFile)
+}
@@ -0,0 +1,705 @@
+package shcv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	yamlv3 "gopkg.in/yaml.v3"
+	"sigs.k8s.io/yaml"
+	yamlv2 "sigs.k8s.io/yaml/goyaml.v2"
+)
+
+// render returns the content of the values file with its current values. The
+// changes are patched into the text the file was loaded from, so comments, key
+// order, blank lines and anchors of the existing document are kept and only
+// the changed keys differ. Files that were empty, and changes that cannot be
+// patched in, such as a replaced list, are marshaled as a new
+// document instead, keeping the original order of the existing keys. Both
+// keep the indentation width of the file. Only the first document of a file
+// holding several is updated; the documents after it are kept byte for byte.
+// With WithSortKeys, the keys of the first document are then sorted.
+func (f *ValueFile) render() ([]byte, error) {
+	data, err := f.renderDocument()
+	if err == nil && f.sortKeys {
+		data, err = sortKeys(data, f.Values)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encoding values: %w", err)
+	}
+	return append(data, f.documents...), nil
+}
+
+// renderDocument returns the first document of the values file with its
+// current values, see render.
+func (f *ValueFile) renderDocument() ([]byte, error) {
+	// Empty files are only patched to write the provenance comments and banner
+	if strings.TrimSpace(string(f.source)) != "" || len(f.provenance) > 0 || f.banner != "" {
+		if patched, ok := patchValues(f.source, f.Values, f.aliases, f.provenance, f.banner); ok {
+			return patched, nil
+		}
+	}
+	data, err := marshalOrdered(f.Values, f.source)
+	if err != nil {
+		return nil, err
+	}
+	return reindent(data, detectIndent(f.source))
+}
+
+// defaultIndent is the indentation width of the documents yaml.Marshal writes
+const defaultIndent = 2
+
+// detectIndent returns the indentation width of the nested mappings of a YAML
+// document, or defaultIndent if it has none.
+func detectIndent(source []byte) int {
+	var doc yamlv3.Node
+	if yamlv3.Unmarshal(source, &doc) != nil {
+		return defaultIndent
+	}
+	var walk func(node *yamlv3.Node) int
+	walk = func(node *yamlv3.Node) int {
+		if node.Kind == yamlv3.MappingNode && node.Style&yamlv3.FlowStyle == 0 {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				if value.Kind == yamlv3.MappingNode && value.Style&yamlv3.FlowStyle == 0 &&
+					len(value.Content) > 0 && value.Line > key.Line {
+					if width := value.Content[0].Column - key.Column; width > 0 {
+						return width
+					}
+				}
+			}
+		}
+		for _, child := range node.Content {
+			if width := walk(child); width > 0 {
+				return width
+			}
+		}
+		return 0
+	}
+	if width := walk(&doc); width > 0 {
+		return width
+	}
+	return defaultIndent
+}
+
+// reindent rewrites a document written by yaml.Marshal with the indentation
+// width. Sequences nested in mappings are then indented as well.
+func reindent(data []byte, width int) ([]byte, error) {
+	if width == defaultIndent || len(data) == 0 {
+		return data, nil
+	}
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	enc := yamlv3.NewEncoder(&out)
+	enc.SetIndent(width)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// marshalOrdered marshals values like yaml.Marshal, except that the keys found
+// in the mappings of the source document keep their order there, followed by
+// the new keys in alphabetical order.
+func marshalOrdered(values map[string]any, source []byte) ([]byte, error) {
+	var doc yamlv3.Node
+	if yamlv3.Unmarshal(source, &doc) != nil || len(doc.Content) == 0 {
+		return yaml.Marshal(values)
+	}
+
+	// Convert the values the way yaml.Marshal does, through JSON, which keeps
+	// the number types, but into ordered mappings
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	var ordered yamlv2.MapSlice
+	if err := yamlv2.Unmarshal(data, &ordered); err != nil {
+		return nil, err
+	}
+	return yamlv2.Marshal(orderKeys(ordered, doc.Content[0]))
+}
+
+// orderKeys orders the items of a mapping by the order of their keys in the
+// mapping node; keys not in the node keep their order after them.
+func orderKeys(items yamlv2.MapSlice, node *yamlv3.Node) yamlv2.MapSlice {
+	if node == nil || node.Kind != yamlv3.MappingNode {
+		return items
+	}
+	rank := make(map[string]int)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if _, ok := rank[node.Content[i].Value]; !ok {
+			rank[node.Content[i].Value] = i / 2
+		}
+	}
+	position := func(item yamlv2.MapItem) int {
+		if r, ok := rank[fmt.Sprint(item.Key)]; ok {
+			return r
+		}
+		return len(rank)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return position(items[i]) < position(items[j])
+	})
+	for i, item := range items {
+		if nested, ok := item.Value.(yamlv2.MapSlice); ok {
+			items[i].Value = orderKeys(nested, mappingValue(node, fmt.Sprint(item.Key)))
+		}
+	}
+	return items
+}
+
+// lineEdit replaces count lines starting at line with text.
+type lineEdit struct {
+	line  int
+	count int
+	text  string
+	// depth orders edits at the same line: insertions into deeper mappings
+	// come first, removals last
+	depth int
+}
+
+// valuesPatch collects the line edits turning a document into its new values.
+type valuesPatch struct {
+	lines []string
+	edits []lineEdit
+	root  *yamlv3.Node
+	// aliases maps the added paths written as aliases to the paths they mirror
+	aliases map[string]string
+	// comments holds the comments written above added paths
+	comments map[string]string
+	// banner is the comment added top-level keys are written below, if any
+	banner string
+	// anchors holds the anchors added to nodes, edited holds the replaced lines
+	anchors map[*yamlv3.Node]string
+	edited  map[int]bool
+	// width is the indentation width of the document
+	width int
+}
+
+// patchValues applies the differences between the values of source and values
+// to the text of source. Added keys whose path is in aliases are written as an
+// alias of the node at the mirrored path, which gets an anchor if needed, and
+// added keys whose path is in comments are written below that comment. Added
+// top-level keys are written below the banner comment, which is added at the
+// end of the document unless it has it already, if banner is not empty. It
+// reports false if the differences are not limited to added and removed keys
+// of block mappings and replaced single-line scalars, or if the patched
+// document does not decode to values.
+func patchValues(source []byte, values map[string]any, aliases, comments map[string]string, banner string) ([]byte, bool) {
+	var root any
+	if err := yaml.Unmarshal(source, &root); err != nil {
+		return nil, false
+	}
+	original, ok := root.(map[string]any)
+	if root == nil {
+		original, ok = map[string]any{}, true
+	}
+	if !ok {
+		return nil, false
+	}
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(source, &doc); err != nil {
+		return nil, false
+	}
+	var mapping *yamlv3.Node
+	if len(doc.Content) > 0 {
+		mapping = doc.Content[0]
+	}
+
+	text := string(source)
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	p := &valuesPatch{
+		lines:    strings.SplitAfter(text, "\n"),
+		root:     mapping,
+		aliases:  aliases,
+		comments: comments,
+		banner:   banner,
+		width:    detectIndent(source),
+		anchors:  make(map[*yamlv3.Node]string),
+		edited:   make(map[int]bool),
+	}
+	p.lines = p.lines[:len(p.lines)-1] // drop the empty string after the final newline
+	if !p.diff(original, values, mapping, nil) {
+		return nil, false
+	}
+	patched := []byte(p.apply())
+
+	// The patch must not change the meaning of the document
+	if !decodesTo(patched, values) {
+		return nil, false
+	}
+	return patched, true
+}
+
+// decodesTo reports whether a document decodes to values.
+func decodesTo(document []byte, values map[string]any) bool {
+	var got, want any
+	expected, err := yaml.Marshal(values)
+	if err != nil || yaml.Unmarshal(expected, &want) != nil || yaml.Unmarshal(document, &got) != nil {
+		return false
+	}
+	if got == nil {
+		got = map[string]any{}
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+// renameKey renames the last key of path to key in the text of source, keeping
+// the entry in place. It reports false if the key is not a scalar written on a
+// single line or the renamed document does not decode to the renamed values.
+func renameKey(source []byte, path, key string) ([]byte, bool) {
+	var root any
+	if err := yaml.Unmarshal(source, &root); err != nil {
+		return nil, false
+	}
+	values, ok := root.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(source, &doc); err != nil || len(doc.Content) == 0 {
+		return nil, false
+	}
+
+	var keyNode *yamlv3.Node
+	node := doc.Content[0]
+	for _, part := range SplitValuePath(path) {
+		if keyNode, node = mappingEntry(node, part); keyNode == nil {
+			return nil, false
+		}
+	}
+	lines := strings.SplitAfter(string(source), "\n")
+	line := lines[keyNode.Line-1]
+	start := byteOffset(line, keyNode.Column)
+	end := scalarEnd(line, start, keyNode)
+	text, err := keyText(key)
+	if end < 0 || err != nil {
+		return nil, false
+	}
+	lines[keyNode.Line-1] = line[:start] + text + line[end:]
+	renamed := []byte(strings.Join(lines, ""))
+
+	value, _ := lookupValue(values, path)
+	deleteNestedValue(values, path)
+	parent, _ := splitPath(path)
+	setNestedValue(values, appendKey(parent, key), value)
+	if !decodesTo(renamed, values) {
+		return nil, false
+	}
+	return renamed, true
+}
+
+// diff records the edits turning the mapping node at path holding original
+// into values. A nil node is an empty document.
+func (p *valuesPatch) diff(original, values map[string]any, node *yamlv3.Node, path []string) bool {
+	if reflect.DeepEqual(original, values) {
+		return true
+	}
+	if node != nil && (node.Kind != yamlv3.MappingNode || node.Style&yamlv3.FlowStyle != 0 || len(node.Content) == 0) {
+		return false
+	}
+
+	added := make(map[string]any)
+	for key, value := range values {
+		if _, ok := original[key]; !ok {
+			added[key] = value
+		}
+	}
+	for key, value := range original {
+		newValue, ok := values[key]
+		if !ok {
+			if !p.remove(node, key) {
+				return false
+			}
+			continue
+		}
+		if reflect.DeepEqual(value, newValue) {
+			continue
+		}
+		nestedOriginal, ok1 := value.(map[string]any)
+		nestedValues, ok2 := newValue.(map[string]any)
+		if !ok1 || !ok2 {
+			if !p.replace(mappingValue(node, key), newValue) {
+				return false
+			}
+			continue
+		}
+		child := mappingValue(node, key)
+		if child == nil || !p.diff(nestedOriginal, nestedValues, child, append(path[:len(path):len(path)], key)) {
+			return false
+		}
+	}
+	if len(added) > 0 {
+		return p.add(node, added, path)
+	}
+	return true
+}
+
+// add records the insertion of keys at the end of the mapping node at path.
+func (p *valuesPatch) add(node *yamlv3.Node, keys map[string]any, path []string) bool {
+	names := make([]string, 0, len(keys))
+	for key := range keys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	line, indent := len(p.lines), 0
+	if node != nil {
+		indent = node.Content[0].Column - 1
+		line = p.contentEnd(lastLine(node), indent)
+	}
+
+	var text strings.Builder
+	if len(path) == 0 && p.banner != "" && p.bannerLine() < 0 {
+		if line > 0 && strings.TrimSpace(p.lines[line-1]) != "" {
+			text.WriteString("\n")
+		}
+		fmt.Fprintf(&text, "# %s\n", p.banner)
+	}
+	for _, key := range names {
+		keyPath := JoinValuePath(append(path[:len(path):len(path)], key)...)
+		// Aliases must follow their anchor, otherwise the value is duplicated
+		if source := p.aliases[keyPath]; source != "" {
+			if anchor, ok := p.anchor(source, line); ok {
+				name, err := keyText(key)
+				if err != nil {
+					return false
+				}
+				p.comment(&text, keyPath)
+				fmt.Fprintf(&text, "%s: *%s\n", name, anchor)
+				continue
+			}
+		}
+		if err := p.entry(&text, key, keys[key], keyPath); err != nil {
+			return false
+		}
+	}
+	p.edits = append(p.edits, lineEdit{line: line, text: indentLines(text.String(), indent), depth: len(path)})
+	return true
+}
+
+// entry writes an added key with its value, nested keys included, each below
+// its comment if it has one.
+func (p *valuesPatch) entry(w *strings.Builder, key string, value any, path string) error {
+	p.comment(w, path)
+	nested, ok := value.(map[string]any)
+	if !ok || len(nested) == 0 {
+		data, err := yaml.Marshal(map[string]any{key: value})
+		if err == nil {
+			data, err = reindent(data, p.width)
+		}
+		if err != nil {
+			return err
+		}
+		w.Write(data)
+		return nil
+	}
+
+	name, err := keyText(key)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(nested))
+	for k := range nested {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var body strings.Builder
+	for _, k := range names {
+		if err := p.entry(&body, k, nested[k], appendKey(path, k)); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "%s:\n%s", name, indentLines(body.String(), p.width))
+	return nil
+}
+
+// comment writes the comment lines of an added path, if any.
+func (p *valuesPatch) comment(w *strings.Builder, path string) {
+	if comment := p.comments[path]; comment != "" {
+		for _, line := range strings.Split(comment, "\n") {
+			fmt.Fprintf(w, "# %s\n", line)
+		}
+	}
+}
+
+// bannerLine returns the index of the banner comment line, or -1 if the
+// document has none.
+func (p *valuesPatch) bannerLine() int {
+	if p.banner == "" {
+		return -1
+	}
+	for i, line := range p.lines {
+		if strings.TrimSpace(line) == "# "+p.banner {
+			return i
+		}
+	}
+	return -1
+}
+
+// keyText returns a mapping key as written by yaml.Marshal.
+func keyText(key string) (string, error) {
+	data, err := yaml.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// anchor returns the anchor of the node at path, recording the edit adding one
+// named after the path if the node has none. It reports false if the node is
+// not in the document, starts after line, or cannot be given an anchor.
+func (p *valuesPatch) anchor(path string, line int) (string, bool) {
+	var key *yamlv3.Node
+	node := p.root
+	for _, part := range SplitValuePath(path) {
+		if node == nil || node.Kind != yamlv3.MappingNode {
+			return "", false
+		}
+		key, node = mappingEntry(node, part)
+	}
+	if node == nil || node.Kind == yamlv3.AliasNode || key.Line > line {
+		return "", false
+	}
+	if node.Anchor != "" {
+		return node.Anchor, true
+	}
+	if name, ok := p.anchors[node]; ok {
+		return name, true
+	}
+
+	// Inline values get the anchor before them, block collections after the key
+	name := strings.ReplaceAll(strings.Join(SplitValuePath(path), "-"), ".", "-")
+	var edited, offset int
+	var property string
+	switch {
+	case node.Kind == yamlv3.ScalarNode || node.Style&yamlv3.FlowStyle != 0:
+		edited = node.Line - 1
+		offset = byteOffset(p.lines[edited], node.Column)
+		property = "&" + name + " "
+	case key.Style == 0 && key.Line != node.Line:
+		edited = key.Line - 1
+		offset = byteOffset(p.lines[edited], key.Column) + len(key.Value)
+		if !strings.HasPrefix(p.lines[edited][offset:], ":") {
+			return "", false
+		}
+		offset++
+		property = " &" + name
+	default:
+		return "", false
+	}
+	if p.edited[edited] || hasAnchor(p.root, name) {
+		return "", false
+	}
+	p.edited[edited] = true
+	p.anchors[node] = name
+	text := p.lines[edited]
+	p.edits = append(p.edits, lineEdit{line: edited, count: 1, text: text[:offset] + property + text[offset:], depth: -1})
+	return name, true
+}
+
+// replace records the replacement of a scalar written on a single line with
+// value, keeping the rest of the line such as a trailing comment.
+func (p *valuesPatch) replace(node *yamlv3.Node, value any) bool {
+	if node == nil || node.Kind != yamlv3.ScalarNode || node.Anchor != "" ||
+		node.Style&(yamlv3.LiteralStyle|yamlv3.FoldedStyle|yamlv3.TaggedStyle) != 0 {
+		return false
+	}
+	line := node.Line - 1
+	if p.edited[line] {
+		return false
+	}
+	text := p.lines[line]
+	start := byteOffset(text, node.Column)
+	end := scalarEnd(text, start, node)
+	if end < 0 {
+		return false
+	}
+	data, err := yaml.Marshal(value)
+	if err != nil || strings.Count(string(data), "\n") != 1 {
+		return false
+	}
+	p.edited[line] = true
+	p.edits = append(p.edits, lineEdit{line: line, count: 1, text: text[:start] + strings.TrimSuffix(string(data), "\n") + text[end:], depth: -1})
+	return true
+}
+
+// remove records the removal of a key of a mapping node with its value and the
+// comment lines directly above it.
+func (p *valuesPatch) remove(node *yamlv3.Node, key string) bool {
+	if node == nil {
+		return false
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k, v := node.Content[i], node.Content[i+1]
+		if k.Kind != yamlv3.ScalarNode || k.Value != key {
+			continue
+		}
+		indent := k.Column - 1
+		start := k.Line - 1
+		// The comments above the key go with it, but the banner stays
+		for start > 0 && indentWidth(p.lines[start-1]) == indent && strings.HasPrefix(strings.TrimSpace(p.lines[start-1]), "#") &&
+			start-1 != p.bannerLine() {
+			start--
+		}
+		end := p.contentEnd(max(k.Line, lastLine(v)), indent)
+		p.edits = append(p.edits, lineEdit{line: start, count: end - start, depth: -1})
+		return true
+	}
+	return false
+}
+
+// contentEnd returns the index of the line after the content starting at the
+// 1-based line last, which continues on the lines indented deeper than indent.
+// Trailing blank lines are not part of the content.
+func (p *valuesPatch) contentEnd(last, indent int) int {
+	end := last
+	for i := last; i < len(p.lines); i++ {
+		if strings.TrimSpace(p.lines[i]) == "" {
+			continue
+		}
+		if indentWidth(p.lines[i]) <= indent {
+			break
+		}
+		end = i + 1
+	}
+	return end
+}
+
+// apply returns the text with the edits applied.
+func (p *valuesPatch) apply() string {
+	sort.SliceStable(p.edits, func(i, j int) bool {
+		if p.edits[i].line != p.edits[j].line {
+			return p.edits[i].line < p.edits[j].line
+		}
+		return p.edits[i].depth > p.edits[j].depth
+	})
+
+	var out strings.Builder
+	next := 0
+	for _, edit := range p.edits {
+		if edit.line < next {
+			continue // removed along with an enclosing key
+		}
+		for ; next < edit.line; next++ {
+			out.WriteString(p.lines[next])
+		}
+		out.WriteString(edit.text)
+		next = edit.line + edit.count
+	}
+	for ; next < len(p.lines); next++ {
+		out.WriteString(p.lines[next])
+	}
+	return out.String()
+}
+
+// mappingValue returns the value node of a key of a mapping node, or nil.
+func mappingValue(node *yamlv3.Node, key string) *yamlv3.Node {
+	_, value := mappingEntry(node, key)
+	return value
+}
+
+// mappingEntry returns the key and value nodes of a key of a mapping node, or nil.
+func mappingEntry(node *yamlv3.Node, key string) (*yamlv3.Node, *yamlv3.Node) {
+	if node == nil {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if k := node.Content[i]; k.Kind == yamlv3.ScalarNode && k.Value == key {
+			return k, node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// hasAnchor reports whether a node or any node below it has the anchor name.
+func hasAnchor(node *yamlv3.Node, name string) bool {
+	if node == nil {
+		return false
+	}
+	if node.Anchor == name {
+		return true
+	}
+	for _, child := range node.Content {
+		if hasAnchor(child, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// byteOffset returns the byte offset of a 1-based character column of a line.
+func byteOffset(line string, column int) int {
+	offset := 0
+	for i := 1; i < column && offset < len(line); i++ {
+		_, size := utf8.DecodeRuneInString(line[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// scalarEnd returns the byte offset past a scalar node starting at start on a
+// line, or -1 if it does not end on the line.
+func scalarEnd(line string, start int, node *yamlv3.Node) int {
+	switch {
+	case node.Style&yamlv3.DoubleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1
+			}
+		}
+	case node.Style&yamlv3.SingleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			if line[i] == '\'' {
+				if i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+	case strings.HasPrefix(line[start:], node.Value):
+		return start + len(node.Value)
+	}
+	return -1
+}
+
+// lastLine returns the last line a node or any node below it starts on.
+func lastLine(node *yamlv3.Node) int {
+	line := node.Line
+	for _, child := range node.Content {
+		line = max(line, lastLine(child))
+	}
+	return line
+}
+
+// indentLines indents the non-empty lines of text by n spaces.
+func indentLines(text string, n int) string {
+	if n == 0 {
+		return text
+	}
+	lines := strings.SplitAfter(text, "\n")
+	prefix := strings.Repeat(" ", n)
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,313 @@
+package shcv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestPatchValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		change   func(values map[string]any)
+		aliases  map[string]string
+		comments map[string]string
+		banner   string
+		want     string
+	}{
+		{
+			name:   "keeps comments, blank lines and key order",
+			source: "# Chart values\nname: app # the release name\n\n# Image settings\nimage:\n  # the registry\n  repository: nginx\n\nzone: b\n",
+			change: func(v map[string]any) {
+				setNestedValue(v, "image.tag", "latest")
+				setNestedValue(v, "service.port", 80)
+			},
+			want: "# Chart values\nname: app # the release name\n\n# Image settings\nimage:\n  # the registry\n  repository: nginx\n  tag: latest\n\nzone: b\nservice:\n  port: 80\n",
+		},
+		{
+			name:   "keeps anchors",
+			source: "base: &base\n  cpu: 1\nworker:\n  resources: *base\n",
+			change: func(v map[string]any) { setNestedValue(v, "worker.replicas", 2) },
+			want:   "base: &base\n  cpu: 1\nworker:\n  resources: *base\n  replicas: 2\n",
+		},
+		{
+			name:   "after multi-line values",
+			source: "config:\n  script: |\n    echo one\n\n    echo two\n  hosts:\n  - a\n  - b\nlast: x\n",
+			change: func(v map[string]any) {
+				setNestedValue(v, "config.port", 1)
+				setNestedValue(v, "config.env.name", "dev")
+			},
+			want: "config:\n  script: |\n    echo one\n\n    echo two\n  hosts:\n  - a\n  - b\n  env:\n    name: dev\n  port: 1\nlast: x\n",
+		},
+		{
+			name:   "nested and outer mappings ending on the same line",
+			source: "a:\n  b:\n    c: 1\n",
+			change: func(v map[string]any) {
+				setNestedValue(v, "a.b.d", 2)
+				setNestedValue(v, "a.e", 3)
+			},
+			want: "a:\n  b:\n    c: 1\n    d: 2\n  e: 3\n",
+		},
+		{
+			name:   "four space indentation",
+			source: "image:\n    repository: nginx\n",
+			change: func(v map[string]any) { setNestedValue(v, "image.tag", "v1") },
+			want:   "image:\n    repository: nginx\n    tag: v1\n",
+		},
+		{
+			name:   "new blocks keep four space indentation",
+			source: "image:\n    repository: nginx\n",
+			change: func(v map[string]any) {
+				setNestedValue(v, "service.ports.http", 80)
+				setNestedValue(v, "service.hosts", []any{"a"})
+			},
+			want: "image:\n    repository: nginx\nservice:\n    hosts:\n        - a\n    ports:\n        http: 80\n",
+		},
+		{
+			name:   "removes keys with their comments",
+			source: "# Image settings\nimage:\n  # the tag\n  tag: v1\n  repository: nginx\nname: app\n",
+			change: func(v map[string]any) {
+				deleteNestedValue(v, "image.tag")
+				deleteNestedValue(v, "name")
+			},
+			want: "# Image settings\nimage:\n  repository: nginx\n",
+		},
+		{
+			name:   "removes emptied parents",
+			source: "name: app\nimage:\n  tag: v1\n",
+			change: func(v map[string]any) { deleteNestedValue(v, "image.tag") },
+			want:   "name: app\n",
+		},
+		{
+			name:   "comments only",
+			source: "# Values are added below\n",
+			change: func(v map[string]any) { setNestedValue(v, "name", "") },
+			want:   "# Values are added below\nname: \"\"\n",
+		},
+		{
+			name:   "no final newline",
+			source: "name: app",
+			change: func(v map[string]any) { setNestedValue(v, "port", 80) },
+			want:   "name: app\nport: 80\n",
+		},
+		{
+			name:   "replaces scalars keeping comments",
+			source: "replicas: 1 # scaled by hand\nimage:\n  tag: \"v1\"\nzone: 'b'\n",
+			change: func(v map[string]any) {
+				v["replicas"] = 2
+				setNestedValue(v, "image.tag", "v2")
+				v["zone"] = "c"
+			},
+			want: "replicas: 2 # scaled by hand\nimage:\n  tag: v2\nzone: c\n",
+		},
+		{
+			name:    "alias with an added anchor",
+			source:  "gateway:\n  port: 8080 # public\nservice:\n  name: web\n",
+			change:  func(v map[string]any) { setNestedValue(v, "service.port", 8080) },
+			aliases: map[string]string{"service.port": "gateway.port"},
+			want:    "gateway:\n  port: &gateway-port 8080 # public\nservice:\n  name: web\n  port: *gateway-port\n",
+		},
+		{
+			name:   "alias of an anchored mapping",
+			source: "base: &defaults\n  cpu: 1\nworker:\n  replicas: 1\n",
+			change: func(v map[string]any) {
+				setNestedValue(v, "worker.resources", map[string]any{"cpu": 1.0})
+			},
+			aliases: map[string]string{"worker.resources": "base"},
+			want:    "base: &defaults\n  cpu: 1\nworker:\n  replicas: 1\n  resources: *defaults\n",
+		},
+		{
+			name:   "alias of a block mapping",
+			source: "limits:\n  cpu: 1\nworker:\n  replicas: 1\n",
+			change: func(v map[string]any) {
+				setNestedValue(v, "worker.limits", map[string]any{"cpu": 1.0})
+			},
+			aliases: map[string]string{"worker.limits": "limits"},
+			want:    "limits: &limits\n  cpu: 1\nworker:\n  replicas: 1\n  limits: *limits\n",
+		},
+		{
+			name:   "comments above added keys",
+			source: "image:\n  repository: nginx\n",
+			change: func(v map[string]any) {
+				setNestedValue(v, "image.tag", "")
+				setNestedValue(v, "service.ports.http", 0)
+				setNestedValue(v, "service.type", "")
+			},
+			comments: map[string]string{
+				"image.tag":          "added by shcv from templates/deployment.yaml:12",
+				"service.ports.http": "added by shcv from templates/service.yaml:8",
+			},
+			want: "image:\n  repository: nginx\n  # added by shcv from templates/deployment.yaml:12\n  tag: \"\"\nservice:\n  ports:\n    # added by shcv from templates/service.yaml:8\n    http: 0\n  type: \"\"\n",
+		},
+		{
+			name:     "comments in an empty file",
+			source:   "",
+			change:   func(v map[string]any) { setNestedValue(v, "name", "") },
+			comments: map[string]string{"name": "added by shcv from templates/NOTES.txt:1"},
+			want:     "# added by shcv from templates/NOTES.txt:1\nname: \"\"\n",
+		},
+		{
+			name:   "banner above added top-level keys",
+			source: "image:\n  repository: nginx\n",
+			change: func(v map[string]any) {
+				setNestedValue(v, "image.tag", "")
+				setNestedValue(v, "service.port", 0)
+				setNestedValue(v, "name", "")
+			},
+			banner: "--- synced by shcv ---",
+			want:   "image:\n  repository: nginx\n  tag: \"\"\n\n# --- synced by shcv ---\nname: \"\"\nservice:\n  port: 0\n",
+		},
+		{
+			name:   "existing banner",
+			source: "image:\n  repository: nginx\n\n# --- synced by shcv ---\nname: \"\"\n",
+			change: func(v map[string]any) { setNestedValue(v, "service.port", 0) },
+			banner: "--- synced by shcv ---",
+			want:   "image:\n  repository: nginx\n\n# --- synced by shcv ---\nname: \"\"\nservice:\n  port: 0\n",
+		},
+		{
+			name:   "banner kept when the key below it is removed",
+			source: "image: nginx\n\n# --- synced by shcv ---\n# the release name\nname: \"\"\n",
+			change: func(v map[string]any) {
+				delete(v, "name")
+				setNestedValue(v, "port", 0)
+			},
+			banner: "--- synced by shcv ---",
+			want:   "image: nginx\n\n# --- synced by shcv ---\nport: 0\n",
+		},
+		{
+			name:   "banner in an empty file",
+			source: "",
+			change: func(v map[string]any) { setNestedValue(v, "name", "") },
+			banner: "--- synced by shcv ---",
+			want:   "# --- synced by shcv ---\nname: \"\"\n",
+		},
+		{
+			name:    "copy before the anchor",
+			source:  "service:\n  name: web\ngateway:\n  port: 8080\n",
+			change:  func(v map[string]any) { setNestedValue(v, "service.port", 8080) },
+			aliases: map[string]string{"service.port": "gateway.port"},
+			want:    "service:\n  name: web\n  port: 8080\ngateway:\n  port: 8080\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]any{}
+			require.NoError(t, yaml.Unmarshal([]byte(tt.source), &values))
+			tt.change(values)
+
+			got, ok := patchValues([]byte(tt.source), values, tt.aliases, tt.comments, tt.banner)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestPatchValuesFallback(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		change func(values map[string]any)
+	}{
+		{
+			name:   "replaced list",
+			source: "hosts:\n- a\n",
+			change: func(v map[string]any) { v["hosts"] = []any{"b"} },
+		},
+		{
+			name:   "flow mapping",
+			source: "image: {repository: nginx}\n",
+			change: func(v map[string]any) { setNestedValue(v, "image.tag", "v1") },
+		},
+		{
+			name:   "aliased mapping",
+			source: "base: &base\n  cpu: 1\nworker: *base\n",
+			change: func(v map[string]any) { setNestedValue(v, "worker.memory", "1Gi") },
+		},
+		{
+			name:   "sequence root",
+			source: "- a\n",
+			change: func(v map[string]any) { v["items"] = []any{"a"} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]any{}
+			_ = yaml.Unmarshal([]byte(tt.source), &values)
+			tt.change(values)
+
+			_, ok := patchValues([]byte(tt.source), values, nil, nil, "")
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestValueFileRender(t *testing.T) {
+	file := ValueFile{Values: map[string]any{"b": 1, "a": 2}}
+	data, err := file.render()
+	require.NoError(t, err)
+	assert.Equal(t, "a: 2\nb: 1\n", string(data))
+
+	// Changes that cannot be patched in are marshaled as a new document,
+	// keeping the order of the existing keys
+	file.source = []byte("# values\nb: [0]\n")
+	data, err = file.render()
+	require.NoError(t, err)
+	assert.Equal(t, "b: 1\na: 2\n", string(data))
+}
+
+func TestValueFileRenderIndent(t *testing.T) {
+	file := ValueFile{
+		Values: map[string]any{"image": map[string]any{"tag": "v1"}, "hosts": []any{"a"}},
+		source: []byte("image:\n    tag: [v0]\n"),
+	}
+	data, err := file.render()
+	require.NoError(t, err)
+	assert.Equal(t, "image:\n    tag: v1\nhosts:\n    - a\n", string(data))
+}
+
+func TestDetectIndent(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   int
+	}{
+		{name: "two spaces", source: "a:\n  b: 1\n", want: 2},
+		{name: "four spaces", source: "list:\n- x\na:\n    b:\n        c: 1\n", want: 4},
+		{name: "nested in a sequence", source: "items:\n- a:\n     b: 1\n", want: 3},
+		{name: "flat", source: "a: 1\nb: {c: 1}\n", want: 2},
+		{name: "empty", source: "", want: 2},
+		{name: "invalid", source: "a: [\n", want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, detectIndent([]byte(tt.source)))
+		})
+	}
+}
+
+func TestMarshalOrdered(t *testing.T) {
+	source := "zone: b\nimage:\n  tag: v1\n  repository: nginx\nitems:\n- name: x\n  id: 1\n"
+	values := map[string]any{
+		"zone":     "c",
+		"replicas": 2,
+		"image":    map[string]any{"repository": "nginx", "tag": "v2", "pullPolicy": "Always"},
+		"items":    []any{map[string]any{"name": "x", "id": 1.0}},
+		"port":     80.0,
+	}
+
+	data, err := marshalOrdered(values, []byte(source))
+	require.NoError(t, err)
+	assert.Equal(t, "zone: c\nimage:\n  tag: v2\n  repository: nginx\n  pullPolicy: Always\nitems:\n- id: 1\n  name: x\nport: 80\nreplicas: 2\n", string(data))
+
+	// Without a source document the keys are sorted like yaml.Marshal does
+	data, err = marshalOrdered(values, nil)
+	require.NoError(t, err)
+	expected, err := yaml.Marshal(values)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(data))
+}
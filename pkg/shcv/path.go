@@ -0,0 +1,31 @@
+package shcv
+
+import "github.com/agentstation/shcv/pkg/shcv/parser"
+
+// SplitValuePath returns the keys of a value path, unquoting quoted keys, see
+// parser.SplitValuePath.
+func SplitValuePath(path string) []string {
+	return parser.SplitValuePath(path)
+}
+
+// JoinValuePath returns the value path of keys, quoting the keys that contain a
+// dot or start with a double quote.
+func JoinValuePath(keys ...string) string {
+	return parser.JoinValuePath(keys...)
+}
+
+// appendKeys returns the path of keys below parent, the empty path being the root.
+func appendKeys(parent string, keys ...string) string {
+	if len(keys) == 0 {
+		return parent
+	}
+	if parent == "" {
+		return JoinValuePath(keys...)
+	}
+	return parent + "." + JoinValuePath(keys...)
+}
+
+// appendKey returns the path of key below parent, the empty path being the root.
+func appendKey(parent, key string) string {
+	return appendKeys(parent, key)
+}
@@ -0,0 +1,54 @@
+package shcv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValuePath(t *testing.T) {
+	tests := []struct {
+		path string
+		keys []string
+	}{
+		{path: "image", keys: []string{"image"}},
+		{path: "image.tag", keys: []string{"image", "tag"}},
+		{path: `annotations."nginx.ingress.kubernetes.io/rewrite-target"`, keys: []string{"annotations", "nginx.ingress.kubernetes.io/rewrite-target"}},
+		{path: `"a.b"."c.d".e`, keys: []string{"a.b", "c.d", "e"}},
+		{path: `labels."\"quoted\""`, keys: []string{"labels", `"quoted"`}},
+		{path: "my-key.Tag_2", keys: []string{"my-key", "Tag_2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.keys, SplitValuePath(tt.path))
+			assert.Equal(t, tt.path, JoinValuePath(tt.keys...))
+		})
+	}
+
+	// Quotes that don't delimit a whole key are part of the key
+	assert.Equal(t, []string{`"a`, `b"c`}, SplitValuePath(`"a.b"c`))
+	assert.Equal(t, []string{`a"b`, "c"}, SplitValuePath(`a"b.c`))
+
+	assert.Equal(t, `annotations."example.com/owner"`, appendKey("annotations", "example.com/owner"))
+	assert.Equal(t, "a.b", appendKeys("", "a", "b"))
+	assert.Equal(t, "a", appendKeys("a"))
+}
+
+func TestNestedValueDottedKeys(t *testing.T) {
+	path := `annotations."nginx.ingress.kubernetes.io/rewrite-target"`
+	values := map[string]any{"annotations": map[string]any{"team": "web"}}
+
+	assert.False(t, valueExists(values, path))
+	setNestedValue(values, path, "/")
+	assert.Equal(t, map[string]any{"annotations": map[string]any{
+		"team": "web",
+		"nginx.ingress.kubernetes.io/rewrite-target": "/",
+	}}, values)
+	assert.True(t, valueExists(values, path))
+	value, ok := lookupValue(values, path)
+	assert.True(t, ok)
+	assert.Equal(t, "/", value)
+
+	deleteNestedValue(values, path)
+	assert.Equal(t, map[string]any{"annotations": map[string]any{"team": "web"}}, values)
+}
@@ -0,0 +1,213 @@
+package shcv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resourceName is the metadata.name of a manifest document.
+type resourceName struct {
+	kind string
+	name string
+	refs []ValueRef
+	file string
+	line int
+}
+
+// CheckPostRender reports chart conventions that break post-renderers such as
+// kustomize, which identify resources by kind and name and add labels to the
+// rendered manifests:
+//
+//   - resources of the same kind whose names are taken from values and render
+//     to the same name, which kustomize rejects as duplicate resource ids
+//   - workload selectors whose labels are taken from values; selectors are
+//     immutable, and post-renderer labels are added to them as well unless
+//     kustomize labels are used with includeSelectors: false
+func (c *Chart) CheckPostRender() ([]Finding, error) {
+	models, err := c.loadTemplateModels()
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	seen := make(map[string]resourceName)
+	for _, model := range models {
+		for _, line := range model.lines {
+			if line.Kind == "" {
+				continue
+			}
+			if line.Key == "name" && len(line.Parents) == 1 && line.Parents[0] == "metadata" {
+				resource := c.resourceName(model.path, line, model.refs[line.Number])
+				id := resource.kind + "/" + resource.name
+				first, ok := seen[id]
+				if !ok {
+					seen[id] = resource
+					continue
+				}
+				if len(first.refs) == 0 && len(resource.refs) == 0 {
+					continue
+				}
+				path := ""
+				if len(resource.refs) > 0 {
+					path = resource.refs[0].Path
+				}
+				findings = append(findings, Finding{
+					Check:      CheckPostRender,
+					Path:       path,
+					SourceFile: resource.file,
+					LineNumber: resource.line,
+					Message: fmt.Sprintf("%s name %q is also used at %s:%d; post-renderers such as kustomize reject duplicate resources",
+						resource.kind, resource.name, c.relPath(first.file), first.line),
+				})
+				continue
+			}
+
+			if !workloadKinds[line.Kind] {
+				continue
+			}
+			for _, ref := range model.refs[line.Number] {
+				view := line.at(ref.Column)
+				if !view.under("selector") || !(view.Key == "matchLabels" || view.under("matchLabels")) {
+					continue
+				}
+				findings = append(findings, Finding{
+					Check:      CheckPostRender,
+					Path:       ref.Path,
+					SourceFile: ref.SourceFile,
+					LineNumber: ref.LineNumber,
+					Message: fmt.Sprintf("%s selector label .Values.%s: selectors are immutable and post-renderer labels are added to them too; use kustomize labels with includeSelectors: false",
+						line.Kind, ref.Path),
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// resourceName returns the name declared on a metadata.name line. Template
+// actions consisting of a single value reference are replaced by the effective
+// value, so that names taken from different values that resolve to the same
+// name are recognized as duplicates.
+func (c *Chart) resourceName(template string, line manifestLine, refs []ValueRef) resourceName {
+	text := line.Text
+	colon := strings.Index(text, ":") + 1
+	value := scalarValue(text[colon:])
+	offset := colon + strings.Index(text[colon:], value)
+
+	var name strings.Builder
+	rest := value
+	for {
+		open := strings.Index(rest, openBrace)
+		if open < 0 {
+			break
+		}
+		end := strings.Index(rest[open:], closeBrace)
+		if end < 0 {
+			break
+		}
+		end += open + len(closeBrace)
+		name.WriteString(rest[:open])
+
+		// Columns are 1-based, offsets into the line 0-based
+		start := offset + len(value) - len(rest) + open
+		var inAction []ValueRef
+		for _, ref := range refs {
+			if ref.Column > start && ref.Column <= start+end-open {
+				inAction = append(inAction, ref)
+			}
+		}
+		if v, ok := c.singleValue(inAction); ok {
+			name.WriteString(fmt.Sprint(v))
+		} else {
+			name.WriteString(rest[open:end])
+		}
+		rest = rest[end:]
+	}
+	name.WriteString(rest)
+
+	return resourceName{kind: line.Kind, name: name.String(), refs: refs, file: template, line: line.Number}
+}
+
+// singleValue returns the effective value of an action consisting of a single reference.
+func (c *Chart) singleValue(refs []ValueRef) (any, bool) {
+	if len(refs) != 1 {
+		return nil, false
+	}
+	return c.resolvedValue(refs[0])
+}
+
+// kustomization is the scaffold of a kustomize post-renderer for the chart
+const kustomization = `# Post-renders the manifests of the chart with kustomize:
+#
+#   helm install RELEASE %s%s --post-renderer %s
+#
+apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - %s
+# Add labels without touching the immutable selectors of the chart's workloads:
+# labels:
+#   - pairs:
+#       team: example
+#     includeSelectors: false
+`
+
+// postRenderScript is the Helm post-renderer running kustomize on the rendered manifests
+const postRenderScript = `#!/bin/sh
+# Helm post-renderer: kustomizes the rendered manifests read from stdin
+set -e
+cd "$(dirname "$0")"
+cat > %s
+exec kubectl kustomize .
+`
+
+// renderedManifests is the file the post-renderer stores the rendered manifests in
+const renderedManifests = "helm-output.yaml"
+
+// WriteKustomizeScaffold writes the files needed to post-render the chart with
+// kustomize to dir: a kustomization.yaml including the rendered manifests and a
+// post-render.sh script to pass to helm --post-renderer, which is shown with
+// the chart's values files in the kustomization. Existing files are not
+// overwritten.
+func (c *Chart) WriteKustomizeScaffold(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating scaffold directory: %w", err)
+	}
+
+	var valuesFlags strings.Builder
+	for _, file := range c.ValuesFiles {
+		valuesFlags.WriteString(" -f " + file.Path)
+	}
+	script := filepath.Join(dir, "post-render.sh")
+	files := []struct {
+		name    string
+		content string
+		mode    os.FileMode
+	}{
+		{"kustomization.yaml", fmt.Sprintf(kustomization, c.Dir, valuesFlags.String(), script, renderedManifests), 0644},
+		{"post-render.sh", fmt.Sprintf(postRenderScript, renderedManifests), 0755},
+	}
+
+	for _, file := range files {
+		path := filepath.Join(dir, file.name)
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("writing scaffold: %s already exists", path)
+		}
+	}
+	for _, file := range files {
+		f, err := os.OpenFile(filepath.Join(dir, file.name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, file.mode)
+		if err != nil {
+			return fmt.Errorf("writing scaffold: %w", err)
+		}
+		if _, err := f.WriteString(file.content); err != nil {
+			f.Close()
+			return fmt.Errorf("writing scaffold: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("writing scaffold: %w", err)
+		}
+	}
+	return nil
+}
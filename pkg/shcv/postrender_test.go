@@ -0,0 +1,118 @@
+package shcv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPostRender(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    string
+		templates map[string]string
+		want      []string
+	}{
+		{
+			name: "distinct names",
+			templates: map[string]string{
+				"a.yaml": "kind: Deployment\nmetadata:\n  name: {{ .Values.name }}-api\n",
+				"b.yaml": "kind: Deployment\nmetadata:\n  name: {{ .Values.name }}-worker\n",
+			},
+		},
+		{
+			name: "same name for different kinds",
+			templates: map[string]string{
+				"a.yaml": "kind: Deployment\nmetadata:\n  name: {{ .Values.name }}\n",
+				"b.yaml": "kind: Service\nmetadata:\n  name: {{ .Values.name }}\n",
+			},
+		},
+		{
+			name: "same value path",
+			templates: map[string]string{
+				"a.yaml": "kind: Deployment\nmetadata:\n  name: {{ .Values.name }}\n",
+				"b.yaml": "kind: Deployment\nmetadata:\n  name: {{ .Values.name }}\n",
+			},
+			want: []string{`templates/b.yaml:3: Deployment name "{{ .Values.name }}" is also used at templates/a.yaml:3; post-renderers such as kustomize reject duplicate resources`},
+		},
+		{
+			name:   "different values resolving to the same name",
+			values: "api:\n  name: app\nworker:\n  name: app\n",
+			templates: map[string]string{
+				"all.yaml": "kind: ConfigMap\nmetadata:\n  name: \"{{ .Values.api.name }}-config\"\n---\nkind: ConfigMap\nmetadata:\n  name: {{ .Values.worker.name }}-config\n",
+			},
+			want: []string{`templates/all.yaml:7: ConfigMap name "app-config" is also used at templates/all.yaml:3; post-renderers such as kustomize reject duplicate resources`},
+		},
+		{
+			name: "literal names are left to helm",
+			templates: map[string]string{
+				"all.yaml": "kind: ConfigMap\nmetadata:\n  name: config\n---\nkind: ConfigMap\nmetadata:\n  name: config\n",
+			},
+		},
+		{
+			name: "selector labels from values",
+			templates: map[string]string{
+				"deployment.yaml": "kind: Deployment\nspec:\n  selector:\n    matchLabels:\n      app: {{ .Values.name }}\n  template:\n    metadata:\n      labels:\n        app: {{ .Values.name }}\n",
+			},
+			want: []string{"templates/deployment.yaml:5: Deployment selector label .Values.name: selectors are immutable and post-renderer labels are added to them too; use kustomize labels with includeSelectors: false"},
+		},
+		{
+			name: "flow style selector labels",
+			templates: map[string]string{
+				"deployment.yaml": "kind: StatefulSet\nspec:\n  selector: {matchLabels: {app: {{ .Values.name }}}}\n",
+			},
+			want: []string{"templates/deployment.yaml:3: StatefulSet selector label .Values.name: selectors are immutable and post-renderer labels are added to them too; use kustomize labels with includeSelectors: false"},
+		},
+		{
+			name: "service selectors are mutable",
+			templates: map[string]string{
+				"service.yaml": "kind: Service\nspec:\n  selector:\n    app: {{ .Values.name }}\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestChart(t, tt.values, tt.templates)
+			chart := loadTestChart(t, dir)
+
+			findings, err := chart.CheckPostRender()
+			require.NoError(t, err)
+			var got []string
+			for _, f := range findings {
+				assert.Equal(t, CheckPostRender, f.Check)
+				f.SourceFile = chart.relPath(f.SourceFile)
+				got = append(got, f.String())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWriteKustomizeScaffold(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", nil)
+	chart, err := NewChart(dir)
+	require.NoError(t, err)
+	require.NoError(t, chart.LoadValueFiles())
+
+	out := filepath.Join(t.TempDir(), "post-render")
+	require.NoError(t, chart.WriteKustomizeScaffold(out))
+
+	content, err := os.ReadFile(filepath.Join(out, "kustomization.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "#   helm install RELEASE "+dir+" -f "+filepath.Join(dir, "values.yaml")+" --post-renderer "+filepath.Join(out, "post-render.sh")+"\n")
+	assert.Contains(t, string(content), "resources:\n  - helm-output.yaml\n")
+
+	info, err := os.Stat(filepath.Join(out, "post-render.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	script, err := os.ReadFile(filepath.Join(out, "post-render.sh"))
+	require.NoError(t, err)
+	assert.Contains(t, string(script), "cat > helm-output.yaml\nexec kubectl kustomize .\n")
+
+	err = chart.WriteKustomizeScaffold(out)
+	assert.ErrorContains(t, err, "kustomization.yaml already exists")
+}
@@ -0,0 +1,24 @@
+package shcv
+
+// Phases of a run reported to a ProgressFunc
+const (
+	// PhaseDiscovery finds the templates of the chart, see FindTemplates
+	PhaseDiscovery = "discovery"
+	// PhaseParsing parses the templates, see ParseTemplates
+	PhaseParsing = "parsing"
+	// PhaseWriting writes the values files and templates, see UpdateValueFiles
+	PhaseWriting = "writing"
+)
+
+// ProgressFunc receives the progress of a phase of a run, see WithProgress:
+// done of total items, templates or files, are processed. The total is 0 while
+// it is not known yet, as when discovering templates, whose last call reports
+// done equal to total.
+type ProgressFunc func(done, total int, phase string)
+
+// progress reports the progress of a phase to the ProgressFunc of the run, if any.
+func (c *config) progress(done, total int, phase string) {
+	if c != nil && c.Progress != nil {
+		c.Progress(done, total, phase)
+	}
+}
@@ -0,0 +1,37 @@
+package shcv
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithProgress(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{
+		"a.yaml": "a: {{ .Values.a }}\n",
+		"b.yaml": "b: {{ .Values.b }}\n",
+	})
+	var calls []string
+	progress := func(done, total int, phase string) {
+		calls = append(calls, fmt.Sprintf("%s %d/%d", phase, done, total))
+	}
+	chart, err := NewChart(dir, WithProgress(progress))
+	require.NoError(t, err)
+	_, err = chart.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"discovery 1/0", "discovery 2/0", "discovery 2/2",
+		"parsing 0/2", "parsing 1/2", "parsing 2/2",
+		"writing 0/1", "writing 1/1",
+	}, calls)
+
+	// Configured templates have a known total
+	calls = nil
+	chart, err = NewChart(dir, WithProgress(progress), WithTemplates([]string{"templates/b.yaml"}))
+	require.NoError(t, err)
+	require.NoError(t, chart.FindTemplates())
+	assert.Equal(t, []string{"discovery 1/1"}, calls)
+}
@@ -0,0 +1,87 @@
+package shcv
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+)
+
+// ReferencesByPath returns the references whose value path matches the glob
+// pattern, in the order they were found. The pattern is matched key by key,
+// with the syntax of path.Match for every key: image.* matches image.tag but
+// not image.tag.digest, and a ** key matches any number of keys, as in
+// **.port. The templates must have been parsed.
+func (c *Chart) ReferencesByPath(glob string) ([]ValueRef, error) {
+	pattern := SplitValuePath(glob)
+	// Check the pattern once, as a reference may not reach its bad keys
+	for _, key := range pattern {
+		if _, err := path.Match(key, ""); err != nil {
+			return nil, fmt.Errorf("invalid value path pattern %q: %w", glob, err)
+		}
+	}
+	var refs []ValueRef
+	for _, ref := range c.References {
+		if matchKeys(pattern, SplitValuePath(ref.Path)) {
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+// matchKeys reports whether the keys of a value path match the keys of a
+// pattern, see ReferencesByPath.
+func matchKeys(pattern, keys []string) bool {
+	if len(pattern) == 0 {
+		return len(keys) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(keys); i++ {
+			if matchKeys(pattern[1:], keys[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(keys) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], keys[0])
+	return ok && matchKeys(pattern[1:], keys[1:])
+}
+
+// ReferencesInFile returns the references found in a template, given relative
+// to the chart directory or absolute, in the order they were found. The
+// templates must have been parsed.
+func (c *Chart) ReferencesInFile(template string) []ValueRef {
+	if !filepath.IsAbs(template) {
+		template = filepath.Join(c.Dir, template)
+	}
+	template = filepath.Clean(template)
+	var refs []ValueRef
+	for _, ref := range c.References {
+		if filepath.Clean(ref.SourceFile) == template {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// MissingReferences returns the first reference of every value path that a
+// values file receiving the missing values does not define, in the order the
+// paths were first referenced: the values a sync would add. Ignored references
+// are left out, see IgnoreDirective. The values files must have been loaded
+// and the templates parsed.
+func (c *Chart) MissingReferences() []ValueRef {
+	missing := make(map[string]bool)
+	for _, m := range c.missingReferences() {
+		missing[m.Ref.Path] = true
+	}
+	var refs []ValueRef
+	for _, ref := range c.References {
+		if missing[ref.Path] && !ref.Ignored {
+			refs = append(refs, ref)
+			missing[ref.Path] = false
+		}
+	}
+	return refs
+}
@@ -0,0 +1,70 @@
+package shcv
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// refPaths returns the value paths of references.
+func refPaths(refs []ValueRef) []string {
+	var paths []string
+	for _, ref := range refs {
+		paths = append(paths, ref.Path)
+	}
+	return paths
+}
+
+func TestReferencesByPath(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{
+		"a.yaml": "{{ .Values.image.tag }} {{ .Values.image.tag.digest }} {{ .Values.service.port }}\n",
+		"b.yaml": "{{ .Values.image.repository }} {{ .Values.gateway.http.port }} {{ .Values.port }}\n",
+	})
+	chart := loadTestChart(t, dir)
+
+	tests := []struct {
+		glob string
+		want []string
+	}{
+		{glob: "image.*", want: []string{"image.tag", "image.repository"}},
+		{glob: "image.t?g", want: []string{"image.tag"}},
+		{glob: "**.port", want: []string{"service.port", "gateway.http.port", "port"}},
+		{glob: "image.**", want: []string{"image.tag", "image.tag.digest", "image.repository"}},
+		{glob: "service.port", want: []string{"service.port"}},
+		{glob: "missing", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.glob, func(t *testing.T) {
+			refs, err := chart.ReferencesByPath(tt.glob)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, refPaths(refs))
+		})
+	}
+
+	_, err := chart.ReferencesByPath("image.[")
+	assert.ErrorContains(t, err, `invalid value path pattern "image.["`)
+}
+
+func TestReferencesInFile(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{
+		"a.yaml":          "{{ .Values.a }}\n",
+		"sub/nested.yaml": "{{ .Values.b }} {{ .Values.c }}\n",
+	})
+	chart := loadTestChart(t, dir)
+	assert.Equal(t, []string{"b", "c"}, refPaths(chart.ReferencesInFile("templates/sub/nested.yaml")))
+	assert.Equal(t, []string{"a"}, refPaths(chart.ReferencesInFile(filepath.Join(dir, "templates", "a.yaml"))))
+	assert.Empty(t, chart.ReferencesInFile("templates/missing.yaml"))
+}
+
+func TestMissingReferences(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{
+		"a.yaml": "{{ .Values.name }} {{ .Values.port }}\n{{ .Values.secret }} # shcv:ignore\n",
+		"b.yaml": "{{ .Values.port | default 80 }} {{ .Values.host }}\n",
+	})
+	chart := loadTestChart(t, dir)
+	missing := chart.MissingReferences()
+	assert.Equal(t, []string{"port", "host"}, refPaths(missing))
+	assert.Equal(t, filepath.Join(dir, "templates", "a.yaml"), missing[0].SourceFile)
+}
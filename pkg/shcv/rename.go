@@ -0,0 +1,250 @@
+package shcv
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// indexCall matches the index function before its first argument
+	indexCall = regexp.MustCompile(`\bindex\s+$`)
+	// indexKey matches a quoted key argument of index
+	indexKey = regexp.MustCompile(`^\s+("(?:[^"\\]|\\.)*"|'[^']*')`)
+)
+
+// RenameValues renames value paths, given as a map from the current to the new
+// path, in the templates and in every values file. A path renames the paths
+// nested below it along with it, and renames are applied from the deepest path
+// up, so that image.Tag=image.tag and Image=image can be combined. Renamed
+// values keep their place in the values files when only their key changes.
+// Keys that are not valid template identifiers, such as kebab-case keys, are
+// written with index.
+//
+// The templates must have been parsed. The changes are returned without being
+// written, and the chart's loaded values are left unchanged; use WriteChanges
+// to apply them. It is an error for a new path to be defined already, or for a renamed key to be written in a form that cannot be
+// rewritten, such as an argument of get. NewValuesMigration records the same
+// renames for the users of the chart.
+func (c *Chart) RenameValues(renames map[string]string) ([]FileChange, error) {
+	ordered, err := orderRenames(renames)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := c.renameReferences(ordered)
+	if err != nil {
+		return nil, err
+	}
+	for i := range c.ValuesFiles {
+		file := c.ValuesFiles[i].editable()
+		change, err := renameInValuesFile(&file, ordered)
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	return changes, nil
+}
+
+// orderRenames validates renames and orders them from the deepest path up.
+func orderRenames(renames map[string]string) ([]ValueRename, error) {
+	ordered := make([]ValueRename, 0, len(renames))
+	for from, to := range renames {
+		switch {
+		case from == "" || to == "":
+			return nil, fmt.Errorf("cannot rename %q to %q: value path is empty", from, to)
+		case from == to:
+			return nil, fmt.Errorf("cannot rename .Values.%s to itself", from)
+		case strings.HasPrefix(to, from+"."):
+			return nil, fmt.Errorf("cannot rename .Values.%s below itself to .Values.%s", from, to)
+		}
+		ordered = append(ordered, ValueRename{From: from, To: to})
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		di, dj := pathDepth(ordered[i].From), pathDepth(ordered[j].From)
+		if di != dj {
+			return di > dj
+		}
+		return ordered[i].From < ordered[j].From
+	})
+	return ordered, nil
+}
+
+// renameInValuesFile applies renames to a loaded values file in order and
+// returns the change, or nil if the file defines none of the renamed paths.
+func renameInValuesFile(file *ValueFile, renames []ValueRename) (*FileChange, error) {
+	changed := false
+	for _, r := range renames {
+		value, ok := lookupValue(file.Values, r.From)
+		if !ok {
+			continue
+		}
+		if valueExists(file.Values, r.To) {
+			return nil, fmt.Errorf("cannot rename .Values.%s to .Values.%s: %s already defines it", r.From, r.To, file.Path)
+		}
+		if err := scalarConflict(file, r.To); err != nil {
+			return nil, err
+		}
+		deleteNestedValue(file.Values, r.From)
+		setNestedValue(file.Values, r.To, value)
+		changed = true
+
+		// Renaming the key in place keeps the position and comments of the value
+		parent, key := splitPath(r.To)
+		if fromParent, _ := splitPath(r.From); parent == fromParent {
+			if renamed, ok := renameKey(file.source, r.From, key); ok {
+				file.source = renamed
+			}
+		}
+	}
+	if !changed {
+		return nil, nil
+	}
+
+	before, err := os.ReadFile(file.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading values file: %w", err)
+	}
+	after, err := file.render()
+	if err != nil {
+		return nil, err
+	}
+	return &FileChange{Path: file.Path, Before: before, After: file.encoding.encode(after)}, nil
+}
+
+// renameReferences returns the template changes renaming the references.
+func (c *Chart) renameReferences(renames []ValueRename) ([]FileChange, error) {
+	type edit struct {
+		start, end int
+		text       string
+	}
+
+	edits := make(map[string][]edit)
+	var templates []string
+	contents := make(map[string]string)
+	for _, ref := range c.References {
+		written, err := writtenPath(ref, contents)
+		if err != nil {
+			return nil, err
+		}
+		content := contents[ref.SourceFile]
+		start := ref.Column - 1
+		if ref.LineNumber > 1 {
+			start += nthIndex(content, '\n', ref.LineNumber-1) + 1
+		}
+		end := ref.EndOffset
+		if start < 0 || start > end || end > len(content) {
+			continue
+		}
+
+		// The keys of index .Values "a" "b" are rewritten along with the reference
+		isIndex := indexCall.MatchString(content[:start])
+		if isIndex {
+			for keys := pathDepth(ref.Path) - pathDepth(written); keys > 0; keys-- {
+				m := indexKey.FindStringSubmatchIndex(content[end:])
+				if m == nil {
+					break
+				}
+				literal := content[end+m[2] : end+m[3]]
+				key := literal[1 : len(literal)-1]
+				if literal[0] == '"' {
+					key, _ = strconv.Unquote(literal)
+				}
+				written = appendKey(written, key)
+				end += m[1]
+			}
+		}
+
+		path, text := ref.Path, written
+		for _, r := range renames {
+			if path != r.From && !strings.HasPrefix(path, r.From+".") {
+				continue
+			}
+			if pathDepth(text) < pathDepth(r.From) {
+				return nil, fmt.Errorf("cannot rename .Values.%s in %s:%d: the key is not written in dot form or with index",
+					r.From, ref.SourceFile, ref.LineNumber)
+			}
+			path = r.To + path[len(r.From):]
+			text = r.To + text[len(r.From):]
+		}
+		if path == ref.Path {
+			continue
+		}
+
+		prefix := strings.TrimSuffix(valuePrefix, ".")
+		if strings.HasPrefix(content[start:], rootPrefix) {
+			prefix = rootPrefix + prefix
+		}
+		if _, ok := edits[ref.SourceFile]; !ok {
+			templates = append(templates, ref.SourceFile)
+		}
+		edits[ref.SourceFile] = append(edits[ref.SourceFile], edit{start, end, valueAccess(prefix, text, isIndex)})
+	}
+
+	var changes []FileChange
+	for _, template := range templates {
+		content := contents[template]
+		list := edits[template]
+		sort.Slice(list, func(i, j int) bool { return list[i].start > list[j].start })
+
+		// Apply the edits from the end of the file so earlier offsets stay valid
+		updated := content
+		next := len(content) + 1
+		for _, e := range list {
+			if e.end > next {
+				continue // the same reference parsed twice
+			}
+			updated = updated[:e.start] + e.text + updated[e.end:]
+			next = e.start
+		}
+		changes = append(changes, FileChange{Path: template, Before: []byte(content), After: []byte(updated)})
+	}
+	return changes, nil
+}
+
+// valueAccess returns the template text reading path from the values root
+// prefix: the dot form if every key is an identifier, the arguments of index
+// for an index call, and a parenthesized index call otherwise.
+func valueAccess(prefix, path string, isIndex bool) string {
+	keys := SplitValuePath(path)
+	identifiers := true
+	for _, key := range keys {
+		identifiers = identifiers && isIdentifier(key)
+	}
+	if identifiers && !isIndex {
+		return prefix + "." + path
+	}
+
+	var b strings.Builder
+	if !isIndex {
+		b.WriteString("(index ")
+	}
+	b.WriteString(prefix)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %q", key)
+	}
+	if !isIndex {
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+// pathDepth returns the number of keys of a value path, 0 for the empty path.
+func pathDepth(path string) int {
+	if path == "" {
+		return 0
+	}
+	return len(SplitValuePath(path))
+}
+
+// splitPath splits a value path into the path of its parent and its last key.
+func splitPath(path string) (string, string) {
+	keys := SplitValuePath(path)
+	return JoinValuePath(keys[:len(keys)-1]...), keys[len(keys)-1]
+}
@@ -0,0 +1,124 @@
+package shcv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameValues(t *testing.T) {
+	tests := []struct {
+		name         string
+		values       string
+		template     string
+		renames      map[string]string
+		wantTemplate string
+		wantValues   string
+		wantErr      string
+	}{
+		{
+			name:         "key in place",
+			values:       "# The image\nimage:\n  repository: nginx # upstream\n  Pull_Policy: Always\nreplicas: 1\n",
+			template:     "policy: {{ .Values.image.Pull_Policy | default \"IfNotPresent\" }}\n",
+			renames:      map[string]string{"image.Pull_Policy": "image.pullPolicy"},
+			wantTemplate: "policy: {{ .Values.image.pullPolicy | default \"IfNotPresent\" }}\n",
+			wantValues:   "# The image\nimage:\n  repository: nginx # upstream\n  pullPolicy: Always\nreplicas: 1\n",
+		},
+		{
+			name:         "parent and nested key",
+			values:       "Image:\n  Tag: v1\n  repository: nginx\n",
+			template:     "image: {{ .Values.Image.repository }}:{{ $.Values.Image.Tag }}\n",
+			renames:      map[string]string{"Image": "image", "Image.Tag": "Image.tag"},
+			wantTemplate: "image: {{ .Values.image.repository }}:{{ $.Values.image.tag }}\n",
+			wantValues:   "image:\n  tag: v1\n  repository: nginx\n",
+		},
+		{
+			name:         "moved to another parent",
+			values:       "imagePullPolicy: Always\nimage:\n  repository: nginx\n",
+			template:     "policy: {{ .Values.imagePullPolicy }}\n",
+			renames:      map[string]string{"imagePullPolicy": "image.pullPolicy"},
+			wantTemplate: "policy: {{ .Values.image.pullPolicy }}\n",
+			wantValues:   "image:\n  repository: nginx\n  pullPolicy: Always\n",
+		},
+		{
+			name:         "CRLF template",
+			values:       "tag: v1\n",
+			template:     "kind: Deployment\r\nimage: {{ .Values.tag }}\r\nalso: {{ .Values.tag | quote }}\r\n",
+			renames:      map[string]string{"tag": "image.tag"},
+			wantTemplate: "kind: Deployment\r\nimage: {{ .Values.image.tag }}\r\nalso: {{ .Values.image.tag | quote }}\r\n",
+			wantValues:   "image:\n  tag: v1\n",
+		},
+		{
+			name:         "kebab-case keys are read with index",
+			values:       "serviceAccount:\n  name: app\n",
+			template:     "a: {{ .Values.serviceAccount.name | quote }}\nb: {{ default \"x\" .Values.serviceAccount.name }}\n",
+			renames:      map[string]string{"serviceAccount": "service-account"},
+			wantTemplate: "a: {{ (index .Values \"service-account\" \"name\") | quote }}\nb: {{ default \"x\" (index .Values \"service-account\" \"name\") }}\n",
+			wantValues:   "service-account:\n  name: app\n",
+		},
+		{
+			name:         "index keys",
+			template:     "a: {{ index .Values \"service-account\" \"name\" }}\nb: {{ index .Values.image \"Tag\" }}\n",
+			renames:      map[string]string{"service-account": "serviceAccount", "image.Tag": "image.tag"},
+			wantTemplate: "a: {{ index .Values \"serviceAccount\" \"name\" }}\nb: {{ index .Values \"image\" \"tag\" }}\n",
+		},
+		{
+			name:     "new path already defined",
+			values:   "image_tag: v1\nimageTag: v2\n",
+			template: "a: {{ .Values.image_tag }}\n",
+			renames:  map[string]string{"image_tag": "imageTag"},
+			wantErr:  "cannot rename .Values.image_tag to .Values.imageTag",
+		},
+		{
+			name:     "key passed to get",
+			template: "a: {{ get .Values.labels \"App\" }}\n",
+			renames:  map[string]string{"labels.App": "labels.app"},
+			wantErr:  "cannot rename .Values.labels.App in",
+		},
+		{
+			name:     "renamed below itself",
+			template: "a: {{ .Values.image }}\n",
+			renames:  map[string]string{"image": "image.name"},
+			wantErr:  "cannot rename .Values.image below itself to .Values.image.name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestChart(t, tt.values, map[string]string{"deployment.yaml": tt.template})
+			chart := loadTestChart(t, dir)
+
+			changes, err := chart.RenameValues(tt.renames)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			got := make(map[string]string)
+			for _, change := range changes {
+				got[chart.relPath(change.Path)] = string(change.After)
+			}
+			want := map[string]string{"templates/deployment.yaml": tt.wantTemplate}
+			if tt.wantValues != "" {
+				want["values.yaml"] = tt.wantValues
+			}
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestRenameKey(t *testing.T) {
+	renamed, ok := renameKey([]byte("a:\n  \"b_c\": 1 # one\n  d: 2\n"), "a.b_c", "bC")
+	require.True(t, ok)
+	assert.Equal(t, "a:\n  bC: 1 # one\n  d: 2\n", string(renamed))
+
+	renamed, ok = renameKey([]byte("a: {b: 1, c: 2}\n"), "a.b", "d")
+	require.True(t, ok)
+	assert.Equal(t, "a: {d: 1, c: 2}\n", string(renamed))
+
+	_, ok = renameKey([]byte("a: {b: 1}\n"), "a.c", "d")
+	assert.False(t, ok)
+}
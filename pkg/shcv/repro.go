@@ -0,0 +1,374 @@
+package shcv
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// reproDir is the top-level directory of a reproduction archive
+const reproDir = "shcv-repro"
+
+// reproManifest describes a reproduction archive: the chart structure, the
+// configuration of the run and what the run found.
+type reproManifest struct {
+	Version string      `json:"version"`
+	Config  reproConfig `json:"config"`
+	Files   []reproFile `json:"files"`
+	Report  reproReport `json:"report"`
+}
+
+// reproConfig is the configuration of the run without the values it holds,
+// such as those of set expressions or the placeholder, which may be secrets.
+type reproConfig struct {
+	ValuesFileName     []string
+	TemplatesDir       string
+	Templates          []string
+	ExcludePatterns    []string
+	TemplateExtensions []string
+	LayeredValues      bool
+	ValuesRootKey      string
+	// SetPaths are the paths given a value by set expressions, without the values
+	SetPaths []string
+	// Placeholder indicates that a placeholder is written for values without a default
+	Placeholder        bool
+	NullValues         bool
+	StringDefaults     bool
+	Strict             bool
+	ErrorMode          ErrorMode
+	DefineValuesPolicy bool
+	NamingPolicy       *NamingPolicy
+	LintPolicy         *LintPolicy
+	FailOnConflict     bool
+	ProvenanceComments bool
+	// SectionBanner indicates that added values are written below a banner
+	SectionBanner            bool
+	SortKeys                 bool
+	InjectDeploymentStrategy bool
+	ValueLinks               []ValueLink
+	ProtectedPaths           []string
+	// Cache indicates that the run-state cache is enabled
+	Cache       bool
+	MaxFileSize int64
+	FileMode    fs.FileMode
+}
+
+// newReproConfig returns the configuration of c recorded in a reproduction
+// archive.
+func newReproConfig(c *config) reproConfig {
+	return reproConfig{
+		ValuesFileName:           c.ValuesFileName,
+		TemplatesDir:             c.TemplatesDir,
+		Templates:                c.Templates,
+		ExcludePatterns:          c.ExcludePatterns,
+		TemplateExtensions:       c.TemplateExtensions,
+		LayeredValues:            c.LayeredValues,
+		ValuesRootKey:            c.ValuesRootKey,
+		SetPaths:                 leafPaths(c.seededValues(), ""),
+		Placeholder:              c.placeholderSet,
+		NullValues:               c.NullValues,
+		StringDefaults:           c.StringDefaults,
+		Strict:                   c.Strict,
+		ErrorMode:                c.ErrorMode,
+		DefineValuesPolicy:       c.DefineValuesPolicy,
+		NamingPolicy:             c.NamingPolicy,
+		LintPolicy:               c.LintPolicy,
+		FailOnConflict:           c.FailOnConflict,
+		ProvenanceComments:       c.ProvenanceComments,
+		SectionBanner:            c.SectionBanner != "",
+		SortKeys:                 c.SortKeys,
+		InjectDeploymentStrategy: c.InjectDeploymentStrategy,
+		ValueLinks:               c.ValueLinks,
+		ProtectedPaths:           c.ProtectedPaths,
+		Cache:                    c.CacheFile != "",
+		MaxFileSize:              c.MaxFileSize,
+		FileMode:                 c.FileMode,
+	}
+}
+
+// leafPaths returns the sorted paths of the values below parent that are not
+// maps.
+func leafPaths(values map[string]any, parent string) []string {
+	var paths []string
+	for key, value := range values {
+		path := appendKey(parent, key)
+		if nested, ok := value.(map[string]any); ok && len(nested) > 0 {
+			paths = append(paths, leafPaths(nested, path)...)
+		} else {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// reproFile is a file of the chart. Only templates and YAML files next to the
+// templates directory are included, with their contents redacted.
+type reproFile struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Included bool   `json:"included"`
+}
+
+// reproReport lists the references and findings of the run, without values.
+type reproReport struct {
+	References []reproRef     `json:"references"`
+	Missing    []reproMissing `json:"missing"`
+	Findings   []reproFinding `json:"findings"`
+}
+
+// reproRef is a parsed reference; its default value is redacted.
+type reproRef struct {
+	Path       string    `json:"path"`
+	HasDefault bool      `json:"hasDefault"`
+	Type       ValueType `json:"type,omitempty"`
+	SourceFile string    `json:"sourceFile"`
+	LineNumber int       `json:"line"`
+	Column     int       `json:"column"`
+}
+
+// reproMissing is a referenced path a values file doesn't define.
+type reproMissing struct {
+	File string `json:"file"`
+	Path string `json:"path"`
+}
+
+// reproFinding is a check finding without its message, which may contain values.
+type reproFinding struct {
+	Check      string `json:"check"`
+	Path       string `json:"path"`
+	SourceFile string `json:"sourceFile"`
+	LineNumber int    `json:"line"`
+}
+
+// CaptureRepro writes a gzip-compressed tar archive that reproduces the run on
+// the chart without exposing its contents, to be attached to bug reports. The
+// archive contains the chart's templates and YAML files with every letter and
+// digit replaced by x, except for template actions, mapping keys, kinds and API
+// versions, so that the redacted files keep their size, line structure and
+// template syntax. A manifest lists all chart files with their sizes, the
+// configuration and the references and findings of the run.
+//
+// The values files must have been loaded and the templates parsed.
+func (c *Chart) CaptureRepro(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+
+	templates := make(map[string]bool, len(c.Templates))
+	for _, template := range c.Templates {
+		templates[template] = true
+	}
+
+	manifest := reproManifest{Version: Version, Config: newReproConfig(c.config), Report: c.reproReport()}
+	err := filepath.WalkDir(c.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != c.Dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		file := reproFile{Path: c.relPath(path), Size: info.Size()}
+		isYAML := filepath.Dir(path) == filepath.Clean(c.Dir) &&
+			(strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml"))
+		if templates[path] || isYAML {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", path, err)
+			}
+			if err := writeArchiveFile(archive, reproDir+"/chart/"+filepath.ToSlash(file.Path), redactContent(content)); err != nil {
+				return err
+			}
+			file.Included = true
+		}
+		manifest.Files = append(manifest.Files, file)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("capturing chart: %w", err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding manifest: %w", err)
+	}
+	if err := writeArchiveFile(archive, reproDir+"/manifest.json", append(data, '\n')); err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+	return nil
+}
+
+// reproReport returns the references and findings of the run with paths relative to the chart.
+func (c *Chart) reproReport() reproReport {
+	report := reproReport{
+		References: []reproRef{},
+		Missing:    []reproMissing{},
+		Findings:   []reproFinding{},
+	}
+	for _, ref := range c.References {
+		report.References = append(report.References, reproRef{
+			Path:       ref.Path,
+			HasDefault: ref.HasDefault,
+			Type:       ref.Type,
+			SourceFile: c.relPath(ref.SourceFile),
+			LineNumber: ref.LineNumber,
+			Column:     ref.Column,
+		})
+	}
+	for _, m := range c.missingReferences() {
+		report.Missing = append(report.Missing, reproMissing{File: c.relPath(m.File), Path: m.Ref.Path})
+	}
+	// Checks failing on unreadable templates leave the findings empty
+	if findings, err := c.RunChecks(); err == nil {
+		for _, f := range findings {
+			report.Findings = append(report.Findings, reproFinding{
+				Check:      f.Check,
+				Path:       f.Path,
+				SourceFile: c.relPath(f.SourceFile),
+				LineNumber: f.LineNumber,
+			})
+		}
+	}
+	return report
+}
+
+// writeArchiveFile adds a regular file to the archive.
+func writeArchiveFile(archive *tar.Writer, name string, content []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(content)),
+		ModTime: time.Unix(0, 0),
+	}
+	if err := archive.WriteHeader(header); err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+	if _, err := archive.Write(content); err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+	return nil
+}
+
+// keptKeys are the keys whose values are kept by redaction because they
+// describe the structure of a manifest rather than its contents
+var keptKeys = map[string]bool{"kind": true, "apiVersion": true}
+
+// redactContent replaces every letter and digit of content, and every
+// non-ASCII character, by x, keeping the mapping keys, kinds and API versions,
+// and template actions except for their string literals and comments. The
+// result has the same line structure, with as many characters on each line;
+// its size differs only where multi-byte characters are replaced.
+func redactContent(content []byte) []byte {
+	out := make([]byte, 0, len(content))
+	// mask writes the character at i, replaced by x if it is redacted, and
+	// returns the offset of its last byte
+	mask := func(i int, redacted bool) int {
+		size := 1
+		if content[i] >= utf8.RuneSelf {
+			_, size = utf8.DecodeRune(content[i:])
+		}
+		if redacted && (content[i] >= utf8.RuneSelf || isAlphaNumeric(content[i])) {
+			out = append(out, 'x')
+		} else {
+			out = append(out, content[i:i+size]...)
+		}
+		return i + size - 1
+	}
+
+	keep := 0 // offset up to which the current line is kept
+	inAction := false
+	inComment := false
+	var quote byte
+	lineStart := true
+	for i := 0; i < len(content); i++ {
+		if lineStart {
+			keep = i + keptPrefix(string(content[i:lineEnd(content, i)]))
+			lineStart = false
+		}
+		ch := content[i]
+		switch {
+		case ch == '\n':
+			lineStart = true
+			out = append(out, ch)
+		case !inAction && bytes.HasPrefix(content[i:], []byte(openBrace)):
+			inAction = true
+			out = append(out, content[i:i+2]...)
+			i++
+			rest := bytes.TrimLeft(bytes.TrimPrefix(content[i+1:], []byte(trimMarker)), " \t")
+			inComment = bytes.HasPrefix(rest, []byte(commentOpen))
+		case inAction && quote == 0 && bytes.HasPrefix(content[i:], []byte(closeBrace)):
+			inAction, inComment = false, false
+			out = append(out, content[i:i+2]...)
+			i++
+		case inAction && !inComment && quote == 0 && (ch == '"' || ch == '\'' || ch == '`'):
+			quote = ch
+			out = append(out, ch)
+		case inAction && quote != 0:
+			if ch == '\\' && quote != '`' {
+				out = append(out, ch)
+				if i++; i < len(content) {
+					i = mask(i, true)
+				}
+			} else if ch == quote {
+				quote = 0
+				out = append(out, ch)
+			} else {
+				i = mask(i, true)
+			}
+		case inAction && !inComment:
+			out = append(out, ch)
+		default:
+			i = mask(i, i >= keep)
+		}
+	}
+	return out
+}
+
+// keptPrefix returns the length of the start of a line kept by redaction: the
+// indentation, list dash and mapping key, or the whole line for kept keys.
+func keptPrefix(line string) int {
+	trimmed := strings.TrimLeft(line, " \t-")
+	if strings.HasPrefix(trimmed, "#") {
+		return 0
+	}
+	key, ok := mappingKey(strings.TrimSpace(trimmed))
+	if !ok {
+		return 0
+	}
+	if keptKeys[key] {
+		return len(line)
+	}
+	return len(line) - len(trimmed) + strings.Index(trimmed, ":") + 1
+}
+
+// lineEnd returns the offset of the end of the line starting at start.
+func lineEnd(content []byte, start int) int {
+	if i := bytes.IndexByte(content[start:], '\n'); i >= 0 {
+		return start + i
+	}
+	return len(content)
+}
@@ -0,0 +1,143 @@
+package shcv
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "keys and structure are kept",
+			content: "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: secret-app\n  - item 42 # note\n",
+			want:    "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: xxxxxx-xxx\n  - xxxx xx # xxxx\n",
+		},
+		{
+			name:    "template actions are kept without their literals",
+			content: `password: {{ .Values.db.password | default "hunter2" | quote }} suffix`,
+			want:    `password: {{ .Values.db.password | default "xxxxxxx" | quote }} xxxxxx`,
+		},
+		{
+			name:    "escaped quotes",
+			content: `{{ printf "say \"hi\" \n" .Values.a }}`,
+			want:    `{{ printf "xxx \"xx\" \x" .Values.a }}`,
+		},
+		{
+			name:    "non-ASCII values and literals",
+			content: "password: пароль-ß3cr€t\nnote: 秘密です\nпорт: {{ .Values.port | default \"элемент\" }}\n",
+			want:    "password: xxxxxx-xxxxxx\nnote: xxxx\nпорт: {{ .Values.port | default \"xxxxxxx\" }}\n",
+		},
+		{
+			name:    "comments",
+			content: "{{- /* internal: token abc */ -}}\n# owner: alice",
+			want:    "{{- /* xxxxxxxx: xxxxx xxx */ -}}\n# xxxxx: xxxxx",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redactContent([]byte(tt.content))
+			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, utf8.RuneCountInString(tt.content), utf8.RuneCount(got))
+		})
+	}
+}
+
+func TestCaptureRepro(t *testing.T) {
+	dir := writeTestChart(t, "db:\n  password: hunter2\n", map[string]string{
+		"deployment.yaml": "kind: Deployment\nspec:\n  image: {{ .Values.image | default \"registry/app\" }}\n",
+		"NOTES.txt":       "Visit {{ .Values.host }}\n",
+	})
+	chart := loadTestChart(t, dir,
+		WithSetValues("db.password=s3cret,db.user=admin"),
+		WithPlaceholder("TODO-ask-alice"),
+		WithSectionBanner("Added for ticket OPS-4242"),
+		WithCacheFile(filepath.Join(t.TempDir(), "private", "cache.json")),
+	)
+
+	var buf bytes.Buffer
+	require.NoError(t, chart.CaptureRepro(&buf))
+
+	files := readArchive(t, &buf)
+	assert.Equal(t, "db:\n  password: xxxxxxx\n", files["shcv-repro/chart/values.yaml"])
+	assert.Equal(t, "kind: Deployment\nspec:\n  image: {{ .Values.image | default \"xxxxxxxx/xxx\" }}\n",
+		files["shcv-repro/chart/templates/deployment.yaml"])
+	assert.Equal(t, "xxxxx {{ .Values.host }}\n", files["shcv-repro/chart/templates/NOTES.txt"])
+	for name, content := range files {
+		assert.NotContains(t, content, "hunter2", name)
+		assert.NotContains(t, content, "registry", name)
+		// The values of the options are not recorded either
+		for _, secret := range []string{"s3cret", "admin", "TODO-ask-alice", "OPS-4242", "private"} {
+			assert.NotContains(t, content, secret, name)
+		}
+	}
+
+	var manifest struct {
+		Version string
+		Config  struct {
+			TemplatesDir string
+			SetPaths     []string
+			Placeholder  bool
+		}
+		Files []struct {
+			Path     string
+			Size     int64
+			Included bool
+		}
+		Report struct {
+			References []struct {
+				Path       string
+				HasDefault bool
+				Line       int
+			}
+			Missing []struct{ File, Path string }
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(files["shcv-repro/manifest.json"]), &manifest))
+	assert.Equal(t, Version, manifest.Version)
+	assert.Equal(t, "templates", manifest.Config.TemplatesDir)
+	assert.Equal(t, []string{"db.password", "db.user"}, manifest.Config.SetPaths)
+	assert.True(t, manifest.Config.Placeholder)
+	assert.Len(t, manifest.Files, 3)
+	for _, file := range manifest.Files {
+		assert.True(t, file.Included, file.Path)
+		assert.Equal(t, int64(len(files["shcv-repro/chart/"+file.Path])), file.Size, file.Path)
+	}
+	require.Len(t, manifest.Report.References, 2)
+	assert.ElementsMatch(t, []string{"image", "host"},
+		[]string{manifest.Report.References[0].Path, manifest.Report.References[1].Path})
+	assert.Len(t, manifest.Report.Missing, 2)
+}
+
+// readArchive returns the files of a gzip-compressed tar archive by name.
+func readArchive(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	gz, err := gzip.NewReader(r)
+	require.NoError(t, err)
+	archive := tar.NewReader(gz)
+
+	files := make(map[string]string)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(archive)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+	}
+}
@@ -0,0 +1,201 @@
+package shcv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// ScaffoldValues returns the changes creating the values file of a chart that
+// has none yet, from the references of its templates, which must have been
+// parsed. Every referenced value is written like a sync adds it, with its
+// template default or the zero value of its type, and the top-level values are
+// grouped in sections below a comment naming the template referencing them
+// first. With schema, the changes also create a values.schema.json declaring
+// the types of the values. The changes are not written, see WriteChanges.
+func (c *Chart) ScaffoldValues(schema bool) ([]FileChange, error) {
+	for _, file := range c.ValuesFiles {
+		if _, err := os.Stat(file.Path); err == nil {
+			return nil, fmt.Errorf("values file %s already exists", file.Path)
+		}
+	}
+	schemaPath := filepath.Join(c.Dir, SchemaFileName)
+	if schema {
+		if _, err := os.Stat(schemaPath); err == nil {
+			return nil, fmt.Errorf("schema %s already exists", schemaPath)
+		}
+	}
+
+	// The default and type of a value are those of its first reference
+	// giving them, as written by a sync
+	refs := make(map[string]*ValueRef)
+	var paths []string
+	for _, ref := range c.References {
+		if ref.Ignored {
+			continue
+		}
+		merged, ok := refs[ref.Path]
+		if !ok {
+			merged = &ValueRef{Path: ref.Path, SourceFile: ref.SourceFile}
+			refs[ref.Path] = merged
+			paths = append(paths, ref.Path)
+		}
+		if merged.DefaultValue == "" {
+			merged.DefaultValue, merged.DefaultUnquoted = ref.DefaultValue, ref.DefaultUnquoted
+		}
+		if merged.Type == TypeUnknown {
+			merged.Type = ref.Type
+		}
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("the templates reference no values")
+	}
+
+	root := &scaffoldValue{}
+	sections := make(map[string]string)
+	var templates []string
+	for _, path := range paths {
+		ref := refs[path]
+		if declared := c.schemaType(path); declared != TypeUnknown {
+			ref.Type = declared
+		}
+		keys := SplitValuePath(path)
+		root.set(keys, c.config.initialValue(*ref), ref.Type != TypeUnknown || ref.DefaultValue != "")
+		if _, ok := sections[keys[0]]; !ok {
+			template := filepath.ToSlash(c.relPath(ref.SourceFile))
+			if !slices.Contains(templates, template) {
+				templates = append(templates, template)
+			}
+			sections[keys[0]] = template
+		}
+	}
+
+	var values bytes.Buffer
+	for i, template := range templates {
+		section := &scaffoldValue{children: root.children}
+		for _, key := range root.keys {
+			if sections[key] == template {
+				section.keys = append(section.keys, key)
+			}
+		}
+		node, err := section.node()
+		if err != nil {
+			return nil, fmt.Errorf("scaffolding values: %w", err)
+		}
+		if i > 0 {
+			values.WriteString("\n")
+		}
+		fmt.Fprintf(&values, "# %s\n", template)
+		enc := yamlv3.NewEncoder(&values)
+		enc.SetIndent(2)
+		if err := enc.Encode(node); err != nil {
+			return nil, fmt.Errorf("scaffolding values: %w", err)
+		}
+	}
+	changes := []FileChange{{Path: c.ValuesFiles[0].Path, After: values.Bytes()}}
+
+	if schema {
+		document := map[string]any{"$schema": "https://json-schema.org/draft-07/schema#"}
+		for key, value := range root.schema() {
+			document[key] = value
+		}
+		data, err := json.MarshalIndent(document, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("scaffolding schema: %w", err)
+		}
+		changes = append(changes, FileChange{Path: schemaPath, After: append(data, '\n')})
+	}
+	return changes, nil
+}
+
+// scaffoldValue is a value of a scaffolded values file: a map holding its keys
+// in the order they were referenced, or a leaf value.
+type scaffoldValue struct {
+	keys     []string
+	children map[string]*scaffoldValue
+	value    any
+	// typed records that the type of a leaf is known, from a default or usage
+	typed bool
+}
+
+// set sets the leaf value at the keys below v. The values nested below a
+// key win over a leaf value for the key itself, as in image and image.tag.
+func (v *scaffoldValue) set(keys []string, value any, typed bool) {
+	if len(keys) == 0 {
+		if v.children == nil {
+			v.value, v.typed = value, typed
+		}
+		return
+	}
+	if v.children == nil {
+		v.children = make(map[string]*scaffoldValue)
+		v.value, v.typed = nil, false
+	}
+	child, ok := v.children[keys[0]]
+	if !ok {
+		child = &scaffoldValue{}
+		v.children[keys[0]] = child
+		v.keys = append(v.keys, keys[0])
+	}
+	child.set(keys[1:], value, typed)
+}
+
+// node returns the YAML node of the value.
+func (v *scaffoldValue) node() (*yamlv3.Node, error) {
+	if v.children == nil {
+		var node yamlv3.Node
+		if err := node.Encode(v.value); err != nil {
+			return nil, err
+		}
+		return &node, nil
+	}
+	mapping := &yamlv3.Node{Kind: yamlv3.MappingNode}
+	for _, key := range v.keys {
+		var keyNode yamlv3.Node
+		if err := keyNode.Encode(key); err != nil {
+			return nil, err
+		}
+		valueNode, err := v.children[key].node()
+		if err != nil {
+			return nil, err
+		}
+		mapping.Content = append(mapping.Content, &keyNode, valueNode)
+	}
+	return mapping, nil
+}
+
+// schema returns the JSON schema of the value, declaring the type of the
+// leaves whose type is known.
+func (v *scaffoldValue) schema() map[string]any {
+	if v.children != nil {
+		properties := make(map[string]any, len(v.keys))
+		for _, key := range v.keys {
+			properties[key] = v.children[key].schema()
+		}
+		return map[string]any{"type": "object", "properties": properties}
+	}
+	if !v.typed {
+		return map[string]any{}
+	}
+	switch v.value.(type) {
+	case int:
+		return map[string]any{"type": "integer"}
+	case float64:
+		return map[string]any{"type": "number"}
+	case bool:
+		return map[string]any{"type": "boolean"}
+	case map[string]any:
+		return map[string]any{"type": "object"}
+	case []any:
+		return map[string]any{"type": "array"}
+	case string:
+		return map[string]any{"type": "string"}
+	}
+	return map[string]any{}
+}
@@ -0,0 +1,81 @@
+package shcv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScaffoldValues(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{
+		"deployment.yaml": `replicas: {{ .Values.replicas | int }}
+image: "{{ .Values.image.repository | default "nginx" }}:{{ .Values.image.tag }}"
+{{- if .Values.debug }}
+{{- end }}
+port: {{ .Values.service.port | default 8080 }}
+`,
+		"service.yaml": `port: {{ .Values.service.port }}
+type: {{ .Values.service.type | default "ClusterIP" }}
+{{- range .Values.ingress.hosts }}{{ . }}{{ end }}
+name: {{ .Values.nameOverride }}
+`,
+	})
+	chart := loadTestChart(t, dir)
+
+	changes, err := chart.ScaffoldValues(true)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, filepath.Join(dir, "values.yaml"), changes[0].Path)
+	assert.Nil(t, changes[0].Before)
+	assert.Equal(t, `# templates/deployment.yaml
+replicas: 0
+image:
+  repository: nginx
+  tag: ""
+debug: false
+service:
+  port: 8080
+  type: ClusterIP
+
+# templates/service.yaml
+ingress:
+  hosts: []
+nameOverride: ""
+`, string(changes[0].After))
+
+	assert.Equal(t, filepath.Join(dir, SchemaFileName), changes[1].Path)
+	assert.JSONEq(t, `{
+  "$schema": "https://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "replicas": {"type": "integer"},
+    "image": {"type": "object", "properties": {"repository": {"type": "string"}, "tag": {}}},
+    "debug": {"type": "boolean"},
+    "service": {"type": "object", "properties": {"port": {"type": "integer"}, "type": {"type": "string"}}},
+    "ingress": {"type": "object", "properties": {"hosts": {"type": "array"}}},
+    "nameOverride": {}
+  }
+}`, string(changes[1].After))
+
+	// The values are written like a sync adds them
+	require.NoError(t, WriteChanges(changes[:1]))
+	chart = loadTestChart(t, dir)
+	assert.Empty(t, chart.MissingValues())
+
+	_, err = chart.ScaffoldValues(false)
+	assert.EqualError(t, err, "values file "+filepath.Join(dir, "values.yaml")+" already exists")
+}
+
+func TestScaffoldValuesErrors(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{"configmap.yaml": "data: {}\n"})
+	_, err := loadTestChart(t, dir).ScaffoldValues(false)
+	assert.EqualError(t, err, "the templates reference no values")
+
+	dir = writeTestChart(t, "", map[string]string{"configmap.yaml": "data: {{ .Values.data }}\n"})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, SchemaFileName), []byte("{}\n"), 0644))
+	_, err = loadTestChart(t, dir).ScaffoldValues(true)
+	assert.EqualError(t, err, "schema "+filepath.Join(dir, SchemaFileName)+" already exists")
+}
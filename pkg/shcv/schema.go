@@ -0,0 +1,195 @@
+package shcv
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SchemaFileName is the JSON schema Helm validates the values of a chart against
+const SchemaFileName = "values.schema.json"
+
+// maxSchemaRefs bounds the $ref chains followed, so that cyclic schemas end
+const maxSchemaRefs = 32
+
+// schemaTypes maps the JSON schema types to the value types written for them
+var schemaTypes = map[string]ValueType{
+	"string":  TypeString,
+	"integer": TypeInt,
+	"number":  TypeInt,
+	"boolean": TypeBool,
+	"object":  TypeMap,
+	"array":   TypeList,
+}
+
+// loadSchema reads the values schema of the chart, which is nil if the chart
+// has none.
+func (c *Chart) loadSchema() error {
+	data, err := os.ReadFile(filepath.Join(c.Dir, SchemaFileName))
+	if os.IsNotExist(err) {
+		c.schema = nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading values schema: %w", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return fmt.Errorf("parsing values schema %s: %w", SchemaFileName, err)
+	}
+	c.schema = schema
+	return nil
+}
+
+// schemaAt returns the schema of the value at path, or nil if the schema does
+// not describe it. Properties are looked up through local $ref pointers,
+// allOf, and additionalProperties.
+func (c *Chart) schemaAt(path string) map[string]any {
+	schema := c.resolveSchema(c.schema)
+	for _, key := range SplitValuePath(path) {
+		if schema == nil {
+			return nil
+		}
+		schema = c.resolveSchema(c.propertySchema(schema, key))
+	}
+	return schema
+}
+
+// propertySchema returns the schema of the property key of an object schema.
+func (c *Chart) propertySchema(schema map[string]any, key string) any {
+	if properties, ok := schema["properties"].(map[string]any); ok {
+		if property, ok := properties[key]; ok {
+			return property
+		}
+	}
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, sub := range all {
+			if sub, ok := c.resolveSchema(sub)["properties"].(map[string]any); ok {
+				if property, ok := sub[key]; ok {
+					return property
+				}
+			}
+		}
+	}
+	if additional, ok := schema["additionalProperties"].(map[string]any); ok {
+		return additional
+	}
+	return nil
+}
+
+// resolveSchema follows the local $ref pointers of schema, such as
+// "#/definitions/image", to the schema they point at.
+func (c *Chart) resolveSchema(schema any) map[string]any {
+	for i := 0; i < maxSchemaRefs; i++ {
+		m, ok := schema.(map[string]any)
+		if !ok {
+			return nil
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#") {
+			return m
+		}
+		schema = any(c.schema)
+		for _, token := range strings.Split(strings.TrimPrefix(ref[1:], "/"), "/") {
+			if token == "" {
+				continue
+			}
+			token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+			parent, ok := schema.(map[string]any)
+			if !ok {
+				return nil
+			}
+			schema = parent[token]
+		}
+	}
+	return nil
+}
+
+// schemaTypeNames returns the types a schema declares, without null.
+func schemaTypeNames(schema map[string]any) []string {
+	var declared []any
+	switch t := schema["type"].(type) {
+	case string:
+		declared = []any{t}
+	case []any:
+		declared = t
+	}
+	var names []string
+	for _, name := range declared {
+		if name, ok := name.(string); ok && name != "null" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// schemaType returns the value type the values schema declares for path, or
+// TypeUnknown if it declares none.
+func (c *Chart) schemaType(path string) ValueType {
+	if c.schema == nil {
+		return TypeUnknown
+	}
+	if names := schemaTypeNames(c.schemaAt(path)); len(names) > 0 {
+		return schemaTypes[names[0]]
+	}
+	return TypeUnknown
+}
+
+// schemaAllows reports whether a value has one of the schema types names.
+func schemaAllows(names []string, value any) bool {
+	for _, name := range names {
+		switch v := value.(type) {
+		case string:
+			if name == "string" {
+				return true
+			}
+		case bool:
+			if name == "boolean" {
+				return true
+			}
+		case int:
+			if name == "integer" || name == "number" {
+				return true
+			}
+		case float64:
+			if name == "number" || name == "integer" && v == math.Trunc(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckSchema reports template defaults that the values.schema.json of the
+// chart rejects, such as default "3" for a value declared an integer: the
+// default would be written to the values file and fail helm install. Only the
+// declared types are checked. Charts without a schema have no findings.
+//
+// The values files must have been loaded and the templates parsed.
+func (c *Chart) CheckSchema() ([]Finding, error) {
+	if c.schema == nil {
+		return nil, nil
+	}
+	var findings []Finding
+	for _, ref := range c.References {
+		if ref.DefaultValue == "" {
+			continue
+		}
+		names := schemaTypeNames(c.schemaAt(ref.Path))
+		if len(names) == 0 || schemaAllows(names, c.config.initialValue(ref)) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Check:      CheckSchema,
+			Path:       ref.Path,
+			SourceFile: ref.SourceFile,
+			LineNumber: ref.LineNumber,
+			Message: fmt.Sprintf("default %s of .Values.%s is not of the type %s declares: %s",
+				writtenDefault(ref), ref.Path, SchemaFileName, strings.Join(names, " or ")),
+		})
+	}
+	return findings, nil
+}
@@ -0,0 +1,129 @@
+package shcv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSchema declares the types of the values of the schema tests.
+const testSchema = `{
+  "$schema": "https://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "replicas": {"type": "integer"},
+    "debug": {"type": "boolean"},
+    "ratio": {"type": "number"},
+    "name": {"type": ["string", "null"]},
+    "image": {"$ref": "#/definitions/image"},
+    "labels": {"type": "object", "additionalProperties": {"type": "string"}},
+    "hosts": {"type": "array"},
+    "service": {"allOf": [{"properties": {"port": {"type": "integer"}}}]}
+  },
+  "definitions": {
+    "image": {
+      "type": "object",
+      "properties": {"pullSecrets": {"type": "array"}, "tag": {"type": "string"}}
+    }
+  }
+}`
+
+// writeSchemaChart creates a chart with templates and the test schema.
+func writeSchemaChart(t *testing.T, values string, templates map[string]string) string {
+	t.Helper()
+	dir := writeTestChart(t, values, templates)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, SchemaFileName), []byte(testSchema), 0644))
+	return dir
+}
+
+func TestSchemaType(t *testing.T) {
+	chart := loadTestChart(t, writeSchemaChart(t, "", nil))
+
+	tests := []struct {
+		path string
+		want ValueType
+	}{
+		{path: "replicas", want: TypeInt},
+		{path: "debug", want: TypeBool},
+		{path: "ratio", want: TypeInt},
+		{path: "name", want: TypeString},
+		{path: "image", want: TypeMap},
+		{path: "image.pullSecrets", want: TypeList},
+		{path: "labels.team", want: TypeString},
+		{path: "service.port", want: TypeInt},
+		{path: "undeclared", want: TypeUnknown},
+		{path: "replicas.nested", want: TypeUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, chart.schemaType(tt.path))
+		})
+	}
+}
+
+func TestProcessReferencesSchemaTypes(t *testing.T) {
+	dir := writeSchemaChart(t, "", map[string]string{
+		"deployment.yaml": `replicas: {{ .Values.replicas }}
+debug: {{ .Values.debug }}
+secrets: {{ toYaml .Values.image.pullSecrets }}
+port: {{ .Values.service.port | quote }}
+tag: {{ .Values.image.tag | default "latest" }}
+other: {{ .Values.other }}
+`,
+	})
+	chart := loadTestChart(t, dir)
+	chart.ProcessReferences()
+
+	values := chart.ValuesFiles[0].Values
+	assert.Equal(t, 0, values["replicas"])
+	assert.Equal(t, false, values["debug"])
+	assert.Equal(t, map[string]any{"pullSecrets": []any{}, "tag": "latest"}, values["image"])
+	assert.Equal(t, map[string]any{"port": 0}, values["service"])
+	assert.Equal(t, "", values["other"])
+}
+
+func TestCheckSchema(t *testing.T) {
+	dir := writeSchemaChart(t, "", map[string]string{
+		"deployment.yaml": `replicas: {{ .Values.replicas | default "3" }}
+debug: {{ .Values.debug | default false }}
+ratio: {{ .Values.ratio | default 0.5 }}
+port: {{ .Values.service.port | default "http" }}
+tag: {{ .Values.image.tag | default 1 }}
+other: {{ .Values.other | default 1 }}
+`,
+	})
+	chart := loadTestChart(t, dir)
+
+	findings, err := chart.CheckSchema()
+	require.NoError(t, err)
+	var got []string
+	for _, f := range findings {
+		assert.Equal(t, CheckSchema, f.Check)
+		f.SourceFile = chart.relPath(f.SourceFile)
+		got = append(got, f.String())
+	}
+	assert.Equal(t, []string{
+		`templates/deployment.yaml:1: default "3" of .Values.replicas is not of the type values.schema.json declares: integer`,
+		`templates/deployment.yaml:4: default "http" of .Values.service.port is not of the type values.schema.json declares: integer`,
+		`templates/deployment.yaml:5: default 1 of .Values.image.tag is not of the type values.schema.json declares: string`,
+	}, got)
+
+	// Charts without a schema have no findings
+	findings, err = loadTestChart(t, writeTestChart(t, "", map[string]string{"a.yaml": "{{ .Values.replicas | default \"3\" }}"})).CheckSchema()
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestLoadSchemaInvalid(t *testing.T) {
+	dir := writeTestChart(t, "", nil)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, SchemaFileName), []byte("{"), 0644))
+
+	chart, err := NewChart(dir)
+	require.NoError(t, err)
+	err = chart.LoadValueFiles()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "parsing values schema values.schema.json")
+}
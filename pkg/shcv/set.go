@@ -0,0 +1,235 @@
+package shcv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxSetIndex bounds the list indexes of set expressions, so that a typo such
+// as hosts[99999999] does not allocate a huge list
+const maxSetIndex = 65536
+
+// setStep is a step of a set expression key: a map key, or a list index.
+type setStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseSetValues parses set expressions, such as "image.tag=1.2.3,hosts={a,b}",
+// with the semantics of helm install --set, into a tree of values. Later
+// expressions override earlier ones.
+//
+// Keys are dot-separated paths, whose keys may index lists, as in
+// "hosts[0].name=web". Values are typed like Helm types them: true and false
+// are booleans, null is null, and decimal integers without a leading zero are
+// integers; anything else, including "1.5", is a string. "{a,b}" is a list. A
+// backslash escapes the next character, such as a dot in a key or a comma in
+// a value.
+func parseSetValues(exprs []string) (map[string]any, error) {
+	values := make(map[string]any)
+	for _, expr := range exprs {
+		p := &setParser{s: []rune(expr)}
+		if err := p.parse(values); err != nil {
+			return nil, fmt.Errorf("parsing %q: %w", expr, err)
+		}
+	}
+	return values, nil
+}
+
+// seededValues returns the values given by the set expressions of the config,
+// see WithSetValues.
+func (c *config) seededValues() map[string]any {
+	if c == nil {
+		return nil
+	}
+	values, _ := parseSetValues(c.SetValues) // validated by NewChart
+	return values
+}
+
+// setParser parses a set expression.
+type setParser struct {
+	s   []rune
+	pos int
+}
+
+// parse parses the assignments of the expression into values.
+func (p *setParser) parse(values map[string]any) error {
+	for p.pos < len(p.s) {
+		key, steps, err := p.key()
+		if err != nil {
+			return err
+		}
+		value, err := p.value()
+		if err != nil {
+			return fmt.Errorf("key %q: %w", key, err)
+		}
+		setStepValue(values, steps, value)
+	}
+	return nil
+}
+
+// key parses a key up to its "=" and returns it with its steps.
+func (p *setParser) key() (string, []setStep, error) {
+	start := p.pos
+	var steps []setStep
+	var key strings.Builder
+	endKey := func() error {
+		if key.Len() == 0 {
+			return fmt.Errorf("key %q has an empty key", string(p.s[start:p.pos]))
+		}
+		steps = append(steps, setStep{key: key.String()})
+		key.Reset()
+		return nil
+	}
+	for ; p.pos < len(p.s); p.pos++ {
+		switch r := p.s[p.pos]; r {
+		case '\\':
+			if p.pos++; p.pos < len(p.s) {
+				key.WriteRune(p.s[p.pos])
+			}
+		case '.':
+			// A dot after an index separates it from the next key
+			if key.Len() == 0 && len(steps) > 0 && steps[len(steps)-1].isIndex {
+				continue
+			}
+			if err := endKey(); err != nil {
+				return "", nil, err
+			}
+		case '[':
+			if key.Len() > 0 {
+				if err := endKey(); err != nil {
+					return "", nil, err
+				}
+			} else if len(steps) == 0 || !steps[len(steps)-1].isIndex {
+				return "", nil, fmt.Errorf("key %q indexes nothing", string(p.s[start:p.pos+1]))
+			}
+			end := p.pos + 1
+			for end < len(p.s) && p.s[end] != ']' {
+				end++
+			}
+			if end == len(p.s) {
+				return "", nil, fmt.Errorf("key %q has an unclosed index", string(p.s[start:]))
+			}
+			index, err := strconv.Atoi(string(p.s[p.pos+1 : end]))
+			if err != nil || index < 0 {
+				return "", nil, fmt.Errorf("key %q has an invalid index %q", string(p.s[start:end+1]), string(p.s[p.pos+1:end]))
+			}
+			if index > maxSetIndex {
+				return "", nil, fmt.Errorf("key %q has index %d, more than %d", string(p.s[start:end+1]), index, maxSetIndex)
+			}
+			steps = append(steps, setStep{index: index, isIndex: true})
+			p.pos = end
+		case '=':
+			name := string(p.s[start:p.pos])
+			if key.Len() > 0 || len(steps) == 0 || !steps[len(steps)-1].isIndex {
+				if err := endKey(); err != nil {
+					return "", nil, err
+				}
+			}
+			p.pos++
+			return name, steps, nil
+		case ',':
+			return "", nil, fmt.Errorf("key %q has no value", string(p.s[start:p.pos]))
+		default:
+			key.WriteRune(r)
+		}
+	}
+	return "", nil, fmt.Errorf("key %q has no value", string(p.s[start:]))
+}
+
+// value parses the value of an assignment, and the comma ending it.
+func (p *setParser) value() (any, error) {
+	if p.pos < len(p.s) && p.s[p.pos] == '{' {
+		p.pos++
+		list := []any{}
+		for {
+			item, end := p.scalar(",}")
+			if end == 0 {
+				return nil, fmt.Errorf("list is not closed with }")
+			}
+			if item != "" || end == ',' || len(list) > 0 {
+				list = append(list, typedSetValue(item))
+			}
+			if end == '}' {
+				break
+			}
+		}
+		if p.pos < len(p.s) {
+			if p.s[p.pos] != ',' {
+				return nil, fmt.Errorf("unexpected %q after the list", string(p.s[p.pos]))
+			}
+			p.pos++
+		}
+		return list, nil
+	}
+	value, _ := p.scalar(",")
+	return typedSetValue(value), nil
+}
+
+// scalar parses an unescaped scalar up to one of the stop characters, and
+// returns it with the stop character found, which is consumed, or 0 at the
+// end of the expression.
+func (p *setParser) scalar(stops string) (string, rune) {
+	var value strings.Builder
+	for ; p.pos < len(p.s); p.pos++ {
+		r := p.s[p.pos]
+		if r == '\\' {
+			if p.pos++; p.pos < len(p.s) {
+				value.WriteRune(p.s[p.pos])
+			}
+			continue
+		}
+		if strings.ContainsRune(stops, r) {
+			p.pos++
+			return value.String(), r
+		}
+		value.WriteRune(r)
+	}
+	return value.String(), 0
+}
+
+// typedSetValue types a scalar of a set expression like Helm does.
+func typedSetValue(s string) any {
+	switch {
+	case strings.EqualFold(s, "true"):
+		return true
+	case strings.EqualFold(s, "false"):
+		return false
+	case strings.EqualFold(s, "null"):
+		return nil
+	case s == "0":
+		return 0
+	}
+	if s != "" && s[0] != '0' {
+		if i, err := strconv.Atoi(s); err == nil {
+			return i
+		}
+	}
+	return s
+}
+
+// setStepValue sets value at the steps below current, creating the maps and
+// lists on the way and replacing the scalars in the way, as Helm does, and
+// returns the updated current value.
+func setStepValue(current any, steps []setStep, value any) any {
+	if len(steps) == 0 {
+		return value
+	}
+	step := steps[0]
+	if step.isIndex {
+		list, _ := current.([]any)
+		for len(list) <= step.index {
+			list = append(list, nil)
+		}
+		list[step.index] = setStepValue(list[step.index], steps[1:], value)
+		return list
+	}
+	m, ok := current.(map[string]any)
+	if !ok {
+		m = make(map[string]any)
+	}
+	m[step.key] = setStepValue(m[step.key], steps[1:], value)
+	return m
+}
@@ -0,0 +1,90 @@
+package shcv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSetValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		exprs   []string
+		want    map[string]any
+		wantErr string
+	}{
+		{
+			name:  "nested keys",
+			exprs: []string{"image.tag=1.2.3,image.pullPolicy=Always"},
+			want:  map[string]any{"image": map[string]any{"tag": "1.2.3", "pullPolicy": "Always"}},
+		},
+		{
+			name:  "typed values",
+			exprs: []string{"a=true,b=FALSE,c=null,d=8080,e=0,f=0755,g=1.5,h=,i=-1"},
+			want:  map[string]any{"a": true, "b": false, "c": nil, "d": 8080, "e": 0, "f": "0755", "g": "1.5", "h": "", "i": -1},
+		},
+		{
+			name:  "lists",
+			exprs: []string{"hosts={a.example.com,b.example.com},ports={80,443},empty={}"},
+			want:  map[string]any{"hosts": []any{"a.example.com", "b.example.com"}, "ports": []any{80, 443}, "empty": []any{}},
+		},
+		{
+			name:  "list indexes",
+			exprs: []string{"servers[1].name=web,servers[1].port=80,matrix[0][1]=x"},
+			want: map[string]any{
+				"servers": []any{nil, map[string]any{"name": "web", "port": 80}},
+				"matrix":  []any{[]any{nil, "x"}},
+			},
+		},
+		{
+			name:  "escapes",
+			exprs: []string{`annotations.nginx\.ingress\.kubernetes\.io/rewrite=/,args=a\,b,eq=x\=y`},
+			want: map[string]any{
+				"annotations": map[string]any{"nginx.ingress.kubernetes.io/rewrite": "/"},
+				"args":        "a,b",
+				"eq":          "x=y",
+			},
+		},
+		{
+			name:  "later expressions override earlier ones",
+			exprs: []string{"image.tag=1,name=app", "image.tag=2", "name.first=web"},
+			want:  map[string]any{"image": map[string]any{"tag": 2}, "name": map[string]any{"first": "web"}},
+		},
+		{name: "missing value", exprs: []string{"a=1,b"}, wantErr: `parsing "a=1,b": key "b" has no value`},
+		{name: "empty key", exprs: []string{"a..b=1"}, wantErr: `parsing "a..b=1": key "a." has an empty key`},
+		{name: "invalid index", exprs: []string{"a[x]=1"}, wantErr: `parsing "a[x]=1": key "a[x]" has an invalid index "x"`},
+		{name: "huge index", exprs: []string{"a[99999999]=1"}, wantErr: `parsing "a[99999999]=1": key "a[99999999]" has index 99999999, more than 65536`},
+		{name: "unclosed list", exprs: []string{"a={x,y"}, wantErr: `parsing "a={x,y": key "a": list is not closed with }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSetValues(tt.exprs)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestProcessReferencesSetValues(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{
+		"deployment.yaml": `image: {{ .Values.image.tag | default "latest" }}
+name: {{ .Values.name }}
+hosts: {{ toYaml .Values.hosts }}
+port: {{ .Values.port }}
+`,
+	})
+	chart := loadTestChart(t, dir, WithSetValues("image.tag=1.2.3,name=web", "hosts={a,b}", "unused=1"))
+	chart.ProcessReferences()
+
+	assert.Equal(t, map[string]any{
+		"name":  "app",
+		"image": map[string]any{"tag": "1.2.3"},
+		"hosts": []any{"a", "b"},
+		"port":  "",
+	}, chart.ValuesFiles[0].Values)
+}
@@ -1,33 +1,60 @@
 package shcv
 
 import (
-	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/agentstation/shcv/pkg/shcv/parser"
 	"sigs.k8s.io/yaml"
 )
 
-// ValueRef represents a Helm value reference found in templates.
-// It tracks where values are used in templates and their default values if specified.
-type ValueRef struct {
-	// Path is the full dot-notation path to the value (e.g. "gateway.domain")
-	Path string
-	// DefaultValue is the value specified in the template using the default function
-	DefaultValue string
-	// SourceFile is the template file where this reference was found
-	SourceFile string
-	// LineNumber is the line number in the source file where the reference appears
-	LineNumber int
-}
+// ValueRef represents a Helm value reference found in templates, see
+// parser.ValueRef.
+type ValueRef = parser.ValueRef
+
+// ValueType is the type of a value inferred from its usage in templates
+type ValueType = parser.ValueType
+
+// Value types inferred from template usage
+const (
+	TypeUnknown = parser.TypeUnknown
+	TypeString  = parser.TypeString
+	TypeInt     = parser.TypeInt
+	TypeBool    = parser.TypeBool
+	TypeMap     = parser.TypeMap
+	TypeList    = parser.TypeList
+)
 
-// ID returns a unique identifier for the value reference
-func (v *ValueRef) ID() string {
-	return fmt.Sprintf("%s:%d:%s", v.Path, v.LineNumber, v.SourceFile)
+// IgnoreDirective is the comment marking value references that are not synced,
+// see parser.IgnoreDirective.
+const IgnoreDirective = parser.IgnoreDirective
+
+// Diagnostic is a recoverable problem found while parsing a template, see
+// parser.Diagnostic.
+type Diagnostic = parser.Diagnostic
+
+// zeroValue returns the zero value written for a missing value of the type
+func zeroValue(t ValueType) any {
+	switch t {
+	case TypeInt:
+		return 0
+	case TypeBool:
+		return false
+	case TypeMap:
+		return map[string]any{}
+	case TypeList:
+		return []any{}
+	default:
+		return ""
+	}
 }
 
 // ValueFile represents a values file
@@ -38,6 +65,64 @@ type ValueFile struct {
 	Values map[string]any
 	// Changed indicates whether values were modified during processing
 	Changed bool
+	// Conflicts lists the values ProcessReferences did not add because a parent
+	// is defined as a scalar or a list
+	Conflicts []*ScalarConflictError
+	// Added lists the paths of the values ProcessReferences added, in the order
+	// they were added
+	Added []string
+	// RootKind is the YAML kind of the file's root, such as "sequence", when it
+	// was not a map and has been wrapped under the configured root key
+	RootKind string
+	// Documents is the number of YAML documents in the file. Only the first
+	// document, the one Helm reads, is loaded and updated; the documents after
+	// it are written back unchanged
+	Documents int
+
+	// encoding is the encoding the file was read with and is written back with
+	encoding textEncoding
+	// source is the decoded text the file was loaded from, which changes are patched into
+	source []byte
+	// injected lists the paths of Added that injectors added, see Injector
+	injected []string
+	// aliases maps the paths added by anchor links to the paths they mirror
+	aliases map[string]string
+	// provenance holds the comments written above added values, by path
+	provenance map[string]string
+	// banner is the comment added top-level values are written below, if any
+	banner string
+	// sortKeys writes the keys of the file sorted, see WithSortKeys
+	sortKeys bool
+	// documents is the text of the documents after the first one
+	documents []byte
+	// onDisk is the content of the file when it was loaded or last written,
+	// to detect concurrent modifications; nil if it was not loaded
+	onDisk *diskContent
+	// original holds the values as loaded, which PlanChanges compares with
+	// and Apply starts from, and rewrite records that loading changed the
+	// file, such as by wrapping its root
+	original map[string]any
+	rewrite  bool
+}
+
+// ValueFileResult describes what UpdateValueFiles did to a values file.
+type ValueFileResult struct {
+	// Path is the path to the values file
+	Path string
+	// Added lists the paths of the values added to the file
+	Added []string
+	// Written indicates that the file was written
+	Written bool
+	// BytesWritten is the size of the content written to the file
+	BytesWritten int
+	// Unchanged indicates that the file was skipped because no value changed
+	Unchanged bool
+}
+
+// diskContent is the content of a file on disk.
+type diskContent struct {
+	data   []byte
+	exists bool
 }
 
 // Chart represents a Helm chart structure and manages its values and templates.
@@ -52,8 +137,22 @@ type Chart struct {
 	References []ValueRef
 	// Templates lists all discovered template files
 	Templates []string
+	// Diagnostics lists the problems found in malformed template actions
+	Diagnostics []Diagnostic
+	// Results lists what the last UpdateValueFiles did to each values file, in
+	// the order of ValuesFiles
+	Results []ValueFileResult
 	// config contains the chart processing configuration
 	config *config
+	// backups maps the files modified by the run to their backup, or "" for
+	// the files the run created
+	backups map[string]string
+	// valuesRootUsed records that a template uses .Values as a whole
+	valuesRootUsed bool
+	// schema is the values.schema.json of the chart, nil if it has none
+	schema map[string]any
+	// templateChanges are the template changes not written in dry-run mode
+	templateChanges []FileChange
 }
 
 // NewChart creates a new Chart instance for the given directory.
@@ -63,12 +162,18 @@ func NewChart(dir string, opts ...Option) (*Chart, error) {
 	}
 
 	// Check if directory exists
-	if _, err := os.Stat(dir); err != nil {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("invalid chart directory: %w: %w", ErrChartNotFound, err)
+	} else if err != nil {
 		return nil, fmt.Errorf("invalid chart directory: %w", err)
 	}
 
 	// Create a new config with the given options
 	config := newConfig(opts)
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+	config.warnDeprecations()
 
 	// create a new chart and return it
 	chart := &Chart{
@@ -79,10 +184,20 @@ func NewChart(dir string, opts ...Option) (*Chart, error) {
 		config:      config,
 	}
 
-	// Initialize ValuesFiles with the configured file names
+	// Initialize ValuesFiles with the configured file names, which are relative
+	// to the chart directory unless absolute. A file named twice is loaded once
+	seen := make(map[string]bool, len(config.ValuesFileName))
 	for _, name := range config.ValuesFileName {
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, name)
+		}
+		if seen[filepath.Clean(path)] {
+			continue
+		}
+		seen[filepath.Clean(path)] = true
 		chart.ValuesFiles = append(chart.ValuesFiles, ValueFile{
-			Path:   filepath.Join(dir, name),
+			Path:   path,
 			Values: make(map[string]any),
 		})
 	}
@@ -90,143 +205,406 @@ func NewChart(dir string, opts ...Option) (*Chart, error) {
 	return chart, nil
 }
 
+// Reset clears the state of a run, such as the templates and references found
+// and the values loaded, keeping the directory, the values files and the
+// options, so that a long-lived chart, as in a watcher or a server, can run
+// again from LoadValueFiles as a new chart would. Templates modified in
+// dry-run mode and not yet written are dropped, and the files of the next run
+// are backed up anew.
+func (c *Chart) Reset() {
+	for i, file := range c.ValuesFiles {
+		c.ValuesFiles[i] = ValueFile{Path: file.Path, Values: make(map[string]any)}
+	}
+	c.References = make([]ValueRef, 0)
+	c.Templates = make([]string, 0)
+	c.Diagnostics = nil
+	c.Results = nil
+	c.backups = nil
+	c.valuesRootUsed = false
+	c.schema = nil
+	c.templateChanges = nil
+}
+
 // LoadValueFiles loads the current values from the value files provided.
 // If the file doesn't exist, an empty values map is initialized.
 // Returns an error if the file exists but cannot be read or parsed.
+// The values.schema.json of the chart, if any, is loaded along with them.
 func (c *Chart) LoadValueFiles() error {
 	// iterate over all values files
 	for i := range c.ValuesFiles {
-		file := &c.ValuesFiles[i] // Get pointer to existing ValueFile
-		data, err := os.ReadFile(file.Path)
-		if err != nil && !os.IsNotExist(err) {
-			return fmt.Errorf("reading values file: %w", err)
+		if err := c.loadValueFile(&c.ValuesFiles[i]); err != nil {
+			return err
 		}
+	}
 
-		// Initialize the values map if nil
-		if file.Values == nil {
-			file.Values = make(map[string]any)
+	return c.loadSchema()
+}
+
+// loadValueFile loads the values of a file, which are empty if it doesn't exist.
+func (c *Chart) loadValueFile(file *ValueFile) error {
+	if info, err := os.Stat(file.Path); err == nil {
+		if err := c.config.checkFileSize(file.Path, info.Size()); err != nil {
+			return err
 		}
+	}
+	data, err := os.ReadFile(file.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading values file: %w", err)
+	}
+	file.onDisk = &diskContent{data: data, exists: err == nil}
 
-		// if the file has data lets unmarshal it into the values map
-		if len(data) > 0 {
-			if err := yaml.Unmarshal(data, &file.Values); err != nil {
-				return fmt.Errorf("parsing values file: %w", err)
-			}
-			if c.config.Verbose {
-				fmt.Printf("loaded values from %s\n", file.Path)
+	// Initialize the values map if nil
+	if file.Values == nil {
+		file.Values = make(map[string]any)
+	}
+
+	// Files saved with a BOM, UTF-16 or Windows line endings are decoded to
+	// UTF-8 first, remembering the encoding for writing the file back
+	data, file.encoding, err = decodeText(data)
+	if err != nil {
+		return fmt.Errorf("decoding values file %s: %w", file.Path, err)
+	}
+	file.source, file.documents, file.Documents = splitDocuments(data)
+	file.banner = c.config.SectionBanner
+	file.sortKeys = c.config.SortKeys
+
+	// if the file has data lets unmarshal it into the values map
+	if len(data) > 0 {
+		var root any
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return invalidValues(file.Path, err)
+		}
+		if err := c.setRoot(file, root); err != nil {
+			return err
+		}
+		// Files whose keys are not sorted are rewritten even if no value is added
+		if file.sortKeys && !keysSorted(file.source) {
+			file.Changed = true
+		}
+		c.config.logger().Debug("loaded values", "file", file.Path)
+	} else {
+		c.config.logger().Debug("no values found", "file", file.Path)
+	}
+	file.original, file.rewrite = copyValue(file.Values).(map[string]any), file.Changed
+	return nil
+}
+
+// splitDocuments splits YAML text into its first document and the text of the
+// documents after it, which starts at the marker ending the first one, and
+// returns the number of documents. A "---" marker before any content, as in
+// "---\nname: app", starts the first document rather than ending it.
+func splitDocuments(data []byte) (first, rest []byte, count int) {
+	started := false // whether the first document has a marker or content
+	offset := 0
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		switch {
+		case documentMarker(line, "---"):
+			if started {
+				if first == nil {
+					first, rest = data[:offset], data[offset:]
+				}
+				count++
 			}
-		} else {
-			if c.config.Verbose {
-				fmt.Printf("no values found in %s\n", file.Path)
+			started = true
+		case documentMarker(line, "..."):
+			if first == nil {
+				first, rest = data[:offset], data[offset:]
 			}
+		case strings.TrimSpace(line) != "" && !strings.HasPrefix(strings.TrimSpace(line), "#") && !strings.HasPrefix(line, "%"):
+			started = true
+		}
+		offset += len(line)
+	}
+	if first == nil {
+		first = data
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return first, rest, 0
+	}
+	return first, rest, count + 1
+}
+
+// documentMarker reports whether a line is the document marker "---" or "...".
+func documentMarker(line, marker string) bool {
+	if !strings.HasPrefix(line, marker) {
+		return false
+	}
+	rest := line[len(marker):]
+	return rest == "" || strings.ContainsAny(rest[:1], " \t\r\n")
+}
+
+// setRoot sets the values of a file from its parsed root. A file holding only
+// comments or null has no values. Any other root that is not a map, such as a
+// sequence or a scalar, is an error unless a root key is configured, in which
+// case it is kept under that key and the file is rewritten as a map.
+func (c *Chart) setRoot(file *ValueFile, root any) error {
+	switch root := root.(type) {
+	case nil:
+		return nil
+	case map[string]any:
+		for key, value := range root {
+			file.Values[key] = value
 		}
+		return nil
 	}
 
+	kind := yamlKind(root)
+	if c.config.ValuesRootKey == "" {
+		return fmt.Errorf("values file %s has a %s at the root instead of a map of values", file.Path, kind)
+	}
+	file.Values[c.config.ValuesRootKey] = root
+	file.RootKind = kind
+	file.Changed = true
+	c.config.logger().Info("wrapped values root", "file", file.Path, "kind", kind, "key", c.config.ValuesRootKey)
 	return nil
 }
 
+// yamlKind names the YAML kind of a parsed value for messages.
+func yamlKind(value any) string {
+	switch value.(type) {
+	case []any:
+		return "sequence"
+	case map[string]any:
+		return "map"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, int64, int:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
 // FindTemplates discovers all template files in the chart's templates directory.
 // It looks for files with .yaml, .yml, or .tpl extensions.
-// Returns an error if the templates directory cannot be accessed.
+// Returns an error if the templates directory cannot be accessed. With
+// WithTemplates, it uses the listed templates instead, which must exist.
 func (c *Chart) FindTemplates() error {
+	return c.FindTemplatesContext(context.Background())
+}
+
+// FindTemplatesContext is like FindTemplates, but stops with the error of ctx
+// when it is canceled or its deadline expires.
+func (c *Chart) FindTemplatesContext(ctx context.Context) error {
+	if len(c.config.Templates) > 0 {
+		return c.listTemplates(ctx)
+	}
+
 	// get the full path to the templates directory
 	dir := filepath.Join(c.Dir, c.config.TemplatesDir)
 
 	// check if the directory exists
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		return fmt.Errorf("templates directory not found: %w", err)
+		return fmt.Errorf("%w: %w", ErrTemplatesDirMissing, err)
 	}
 
 	// walk the templates directory and find all template files
-	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if !d.IsDir() && (strings.HasSuffix(path, ".yaml") ||
-			strings.HasSuffix(path, ".yml") ||
-			strings.HasSuffix(path, ".tpl")) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path != dir {
+			if rel, err := filepath.Rel(dir, path); err == nil && c.isExcluded(rel, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if !d.IsDir() && c.isTemplateFile(d.Name()) {
 			c.Templates = append(c.Templates, path)
+			c.config.progress(len(c.Templates), 0, PhaseDiscovery)
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	c.config.progress(len(c.Templates), len(c.Templates), PhaseDiscovery)
+	return nil
+}
+
+// listTemplates uses the configured templates instead of discovering them.
+func (c *Chart) listTemplates(ctx context.Context) error {
+	for i, template := range c.config.Templates {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		path := template
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.Dir, path)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("template %s not found: %w", template, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("template %s is a directory", template)
+		}
+		c.Templates = append(c.Templates, path)
+		c.config.progress(i+1, len(c.config.Templates), PhaseDiscovery)
+	}
+	return nil
+}
+
+// isExcluded reports whether a path relative to the templates directory matches
+// one of the exclude patterns.
+func (c *Chart) isExcluded(rel string, isDir bool) bool {
+	for _, pattern := range c.config.ExcludePatterns {
+		if strings.HasSuffix(pattern, "/") {
+			if !isDir {
+				continue
+			}
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = filepath.Base(rel)
+		}
+		if ok, _ := filepath.Match(filepath.FromSlash(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// notesFile is the name of the chart's usage notes template
+const notesFile = "NOTES.txt"
+
+// isTemplateFile reports whether a file in the templates directory is scanned for references
+func (c *Chart) isTemplateFile(name string) bool {
+	if name == notesFile {
+		return true
+	}
+	for _, ext := range c.config.TemplateExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
 }
 
 // ParseTemplates scans all discovered templates for .Values references.
 // It identifies both simple references and those with default values.
 // The references are stored in the Chart's References slice.
+//
+// By default, the first template that cannot be read fails the run. With
+// WithErrorMode(ErrorModeCollect), the other templates are still parsed, and
+// the errors, along with those of strict mode and conflicting defaults, are
+// returned together, joined with errors.Join.
 func (c *Chart) ParseTemplates() error {
-	// iterate over all templates
-	for _, template := range c.Templates {
+	return c.ParseTemplatesContext(context.Background())
+}
 
-		// Open the template file and defer closing it
-		file, err := os.Open(template)
+// ParseTemplatesContext is like ParseTemplates, but stops with the error of
+// ctx when it is canceled or its deadline expires, in both error modes.
+func (c *Chart) ParseTemplatesContext(ctx context.Context) error {
+	var errs []error
+	for i, template := range c.Templates {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.config.progress(i, len(c.Templates), PhaseParsing)
+		c.config.logger().Debug("parsing template", "file", template)
+		content, refs, diagnostics, err := c.parseTemplate(template)
 		if err != nil {
-			return fmt.Errorf("opening template %s: %w", template, err)
+			if c.config.ErrorMode != ErrorModeCollect {
+				return err
+			}
+			errs = append(errs, err)
+			continue
 		}
-		defer file.Close()
+		c.config.hooks().templateParsed(template, refs)
 
-		// Create a scanner for efficient reading
-		scanner := bufio.NewScanner(file)
-		var content strings.Builder
-		for scanner.Scan() { // read each line of the template
-			content.WriteString(scanner.Text()) // append the line to the content
-			content.WriteString("\n")           // append a newline to the end of the line
-		}
+		// Apply the references to the chart
+		c.References = append(c.References, refs...)
+		c.Diagnostics = append(c.Diagnostics, diagnostics...)
+		c.valuesRootUsed = c.valuesRootUsed || usesValuesRoot(content)
+	}
+	c.config.progress(len(c.Templates), len(c.Templates), PhaseParsing)
 
-		// Check for any errors from the scanner
-		if err := scanner.Err(); err != nil {
-			return fmt.Errorf("scanning template %s: %w", template, err)
+	if c.config.Strict && len(c.Diagnostics) > 0 {
+		diagnostics := make([]error, 0, len(c.Diagnostics))
+		for _, d := range c.Diagnostics {
+			diagnostics = append(diagnostics, errors.New(d.String()))
 		}
-
-		// Parse the template content
-		if c.config.Verbose {
-			fmt.Printf("parsing template %s\n", template)
+		errs = append(errs, fmt.Errorf("malformed templates in strict mode: %w", errors.Join(diagnostics...)))
+		if c.config.ErrorMode != ErrorModeCollect {
+			return errs[0]
 		}
+	}
 
-		// Parse the template content
-		refs := ParseFile(content.String(), template)
-
-		// Apply the references to the chart
-		c.References = append(c.References, refs...)
+	if conflicts := c.DefaultConflicts(); c.config.FailOnConflict && len(conflicts) > 0 {
+		conflictErrs := make([]error, 0, len(conflicts))
+		for _, conflict := range conflicts {
+			conflictErrs = append(conflictErrs, errors.New(conflict.String()))
+		}
+		errs = append(errs, fmt.Errorf("%d value paths have conflicting defaults: %w", len(conflicts), errors.Join(conflictErrs...)))
 	}
-	return nil
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
 }
 
-// defaultDeploymentStrategy represents the default deployment strategy configuration
-var defaultDeploymentStrategy = map[string]interface{}{
-	"type": "RollingUpdate",
-	"rollingUpdate": map[string]interface{}{
-		"maxSurge":       1,
-		"maxUnavailable": 0,
-	},
+// parseTemplate reads and parses a template whatever the length of its lines,
+// unless it exceeds the limit of WithMaxFileSize, and returns its content as
+// read along with its references and diagnostics, see parser.ParseReader.
+func (c *Chart) parseTemplate(template string) (string, []ValueRef, []Diagnostic, error) {
+	file, err := os.Open(template)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("opening template %s: %w", template, err)
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil {
+		if err := c.config.checkFileSize(template, info.Size()); err != nil {
+			return "", nil, nil, err
+		}
+	}
+
+	var content strings.Builder
+	refs, diagnostics, err := parser.ParseReader(io.TeeReader(file, &content), template)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return content.String(), refs, diagnostics, nil
 }
 
-// ProcessReferences ensures all referenced values exist in values.yaml.
+// ProcessReferences ensures all referenced values exist in values.yaml. It
+// first runs the injectors, see WithInjectors, such as the deployment
+// strategy injector of WithDeploymentStrategyInjection.
 func (c *Chart) ProcessReferences() {
-	// First pass: process deployment strategy for deployment manifests
-	for _, template := range c.Templates {
-		if err := c.injectDeploymentStrategy(template); err != nil && c.config.Verbose {
-			fmt.Printf("warning: failed to process deployment strategy for %s: %v\n", template, err)
+	// First pass: run the injectors on the templates they detect
+	if injectors := c.config.injectors(); len(injectors) > 0 {
+		for _, template := range c.Templates {
+			if err := c.inject(template, injectors); err != nil {
+				c.config.logger().Warn("failed to inject values", "file", template, "error", err)
+			}
 		}
 	}
 
 	processedRefs := make(map[string]bool) // track processed references paths
 	templateRefs := make([]ValueRef, 0)    // final list of references to update
 
-	// Second pass: collect all references and find default values
+	// Second pass: collect all references with the default and type chosen
+	// for their value
+	index := c.Index()
 	for _, ref := range c.References {
-		// Skip if we've already processed this reference
-		if processedRefs[ref.Path] {
+		// Skip if we've already processed this reference, or it is ignored
+		if processedRefs[ref.Path] || ref.Ignored {
 			continue
 		}
-
-		// iterate over all references with the same path
-		// and find the first default value if it exists
-		for _, r := range c.References {
-			if ref.Path == r.Path && r.DefaultValue != "" {
-				ref.DefaultValue = r.DefaultValue
-				break
-			}
+		value := index.Values[ref.Path]
+		if value.DefaultValue != "" {
+			ref.DefaultValue, ref.DefaultUnquoted = value.DefaultValue, value.DefaultUnquoted
+		}
+		if value.Type != TypeUnknown {
+			ref.Type = value.Type
 		}
 
 		// Add this reference to the final list and mark as processed
@@ -235,277 +613,351 @@ func (c *Chart) ProcessReferences() {
 	}
 
 	// Third pass: process all other references
+	seeds := c.config.seededValues()
+	prompted := make(map[string]promptAnswer)
 	for i := range c.ValuesFiles {
+		if !c.receivesAdditions(i) {
+			continue
+		}
 		file := &c.ValuesFiles[i] // Get pointer to existing ValueFile
+		missing := c.missingLinks(file)
+
+		// References nested below a scalar or a list the file defines, such as
+		// service.port for service: web, are reported instead of replacing it
+		var conflicted map[string]bool
+		for _, ref := range templateRefs {
+			if err := scalarConflict(file, ref.Path); err != nil {
+				file.Conflicts = append(file.Conflicts, err)
+				if conflicted == nil {
+					conflicted = make(map[string]bool)
+				}
+				conflicted[ref.Path] = true
+			}
+		}
 
 		// iterate over each template reference
 		for _, ref := range templateRefs {
+			if conflicted[ref.Path] {
+				continue
+			}
 			// Only set the value if it doesn't already exist or has a default value
-			if !valueExists(file.Values, ref.Path) {
-				setNestedValue(file.Values, ref.Path, ref.DefaultValue)
+			if !c.defined(i, ref.Path) {
+				// The type declared by the schema wins over the inferred one
+				if declared := c.schemaType(ref.Path); declared != TypeUnknown {
+					ref.Type = declared
+				}
+				value := c.config.initialValue(ref)
+				if seeded, ok := lookupValue(seeds, ref.Path); ok {
+					value = copyValue(seeded)
+				} else if answer, ok := c.prompt(ref, prompted); ok {
+					value = copyValue(answer)
+				}
+				if !c.config.hooks().valueAdded(file.Path, ref, value) {
+					continue
+				}
+				setNestedValue(file.Values, ref.Path, value)
 				file.Changed = true
+				file.Added = append(file.Added, ref.Path)
+				if c.config != nil && c.config.ProvenanceComments {
+					if file.provenance == nil {
+						file.provenance = make(map[string]string)
+					}
+					file.provenance[ref.Path] = fmt.Sprintf("added by shcv from %s:%d", filepath.ToSlash(c.relPath(ref.SourceFile)), ref.LineNumber)
+				}
 			}
 		}
+
+		// Linked values follow the values they mirror, including the ones just added
+		c.linkValues(file, missing)
 	}
 }
 
-// injectDeploymentStrategy detects if a template is a Kubernetes Deployment and injects strategy values
-func (c *Chart) injectDeploymentStrategy(templatePath string) error {
-	content, err := os.ReadFile(templatePath)
-	if err != nil {
-		return fmt.Errorf("reading template: %w", err)
+// promptAnswer is the answer of the value prompt for a path.
+type promptAnswer struct {
+	value any
+	ok    bool
+}
+
+// prompt returns the value the configured prompt gives for a reference without
+// a default, asking once per path: the answers are recorded in prompted.
+func (c *Chart) prompt(ref ValueRef, prompted map[string]promptAnswer) (any, bool) {
+	if c.config == nil || c.config.ValuePrompt == nil || ref.DefaultValue != "" {
+		return nil, false
 	}
+	answer, asked := prompted[ref.Path]
+	if !asked {
+		answer.value, answer.ok = c.config.ValuePrompt(ref)
+		prompted[ref.Path] = answer
+	}
+	return answer.value, answer.ok
+}
 
-	// Quick check if this might be a deployment
-	if !bytes.Contains(content, []byte("kind: Deployment")) {
-		return nil
+// recordTemplateChange records a template change that is not written, replacing
+// an earlier change of the same template.
+func (c *Chart) recordTemplateChange(change FileChange) {
+	if bytes.Equal(change.Before, change.After) {
+		return
 	}
+	for i := range c.templateChanges {
+		if c.templateChanges[i].Path == change.Path {
+			c.templateChanges[i] = change
+			return
+		}
+	}
+	c.templateChanges = append(c.templateChanges, change)
+}
 
-	// Parse YAML to confirm it's a deployment
-	// First, remove Helm template directives that might interfere with YAML parsing
-	cleanContent := removeHelmTemplates(content)
+// UpdateValueFiles ensures all referenced values exist in values.yaml.
+// It adds missing values with appropriate defaults and updates the file.
+// The operation is skipped if no changes are needed, and in dry-run mode.
+func (c *Chart) UpdateValueFiles() error {
+	return c.UpdateValueFilesContext(context.Background())
+}
 
-	var manifest struct {
-		Kind string `yaml:"kind"`
+// UpdateValueFilesContext is like UpdateValueFiles, but fails with the error
+// of ctx when it is canceled or its deadline expires before the files are
+// written, including while waiting for the chart lock. Once writing has
+// started, the files are all written or all restored. The outcome for each
+// file is recorded in Results; in dry-run mode no file is written.
+func (c *Chart) UpdateValueFilesContext(ctx context.Context) error {
+	c.Results = nil
+	if c.config.DryRun {
+		c.recordResults(nil)
+		return nil
 	}
-	if err := yaml.Unmarshal(cleanContent, &manifest); err != nil {
-		return fmt.Errorf("parsing manifest: %w", err)
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-
-	if manifest.Kind != "Deployment" {
+	changed := false
+	for _, file := range c.ValuesFiles {
+		changed = changed || file.Changed
+	}
+	if !changed {
+		c.recordResults(nil)
 		return nil
 	}
+	return c.withLockContext(ctx, c.writeValueFiles)
+}
 
-	if c.config.Verbose {
-		fmt.Printf("Found deployment manifest in %s\n", templatePath)
+// recordResults sets Results from the values files and the sizes of those
+// written, by path.
+func (c *Chart) recordResults(written map[string]int) {
+	c.Results = make([]ValueFileResult, 0, len(c.ValuesFiles))
+	for _, file := range c.ValuesFiles {
+		size, ok := written[file.Path]
+		c.Results = append(c.Results, ValueFileResult{
+			Path:         file.Path,
+			Added:        file.Added,
+			Written:      ok,
+			BytesWritten: size,
+			Unchanged:    !file.Changed,
+		})
 	}
+}
 
-	// Add deployment strategy values if they don't exist
+// Changes returns the changes of the run to the chart files, without writing
+// them: the templates ProcessReferences modifies in dry-run mode, see
+// WithDryRun, followed by the values files changed since they were loaded.
+// Files whose content does not change are left out.
+func (c *Chart) Changes() ([]FileChange, error) {
+	changes := append([]FileChange(nil), c.templateChanges...)
 	for i := range c.ValuesFiles {
 		file := &c.ValuesFiles[i]
-
-		// Initialize values map if needed
-		if file.Values == nil {
-			file.Values = make(map[string]interface{})
+		if !file.Changed {
+			continue
 		}
-
-		if c.config.Verbose {
-			fmt.Printf("Processing values file: %s\n", file.Path)
-			fmt.Printf("Current values: %+v\n", file.Values)
+		before, err := os.ReadFile(file.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("reading values file: %w", err)
 		}
-
-		// Get or create deployment map while preserving existing structure
-		var deployment map[string]interface{}
-		if existingDeployment, ok := file.Values["deployment"]; ok {
-			if c.config.Verbose {
-				fmt.Printf("Found existing deployment section: %+v\n", existingDeployment)
-			}
-			if deploymentMap, ok := existingDeployment.(map[string]interface{}); ok {
-				deployment = deploymentMap
-			} else {
-				deployment = make(map[string]interface{})
-				file.Values["deployment"] = deployment
-			}
-		} else {
-			deployment = make(map[string]interface{})
-			file.Values["deployment"] = deployment
+		after, err := file.render()
+		if err != nil {
+			return nil, err
 		}
-
-		// Check if strategy exists
-		if _, hasStrategy := deployment["strategy"]; !hasStrategy {
-			if c.config.Verbose {
-				fmt.Printf("Adding strategy section to deployment\n")
-			}
-			// Create a deep copy of defaultDeploymentStrategy
-			strategy := make(map[string]interface{})
-			for k, v := range defaultDeploymentStrategy {
-				if m, ok := v.(map[string]interface{}); ok {
-					// Deep copy nested map
-					strategy[k] = make(map[string]interface{})
-					for k2, v2 := range m {
-						strategy[k].(map[string]interface{})[k2] = v2
-					}
-				} else {
-					strategy[k] = v
-				}
-			}
-			deployment["strategy"] = strategy
-			file.Changed = true
-
-			if c.config.Verbose {
-				fmt.Printf("Updated deployment section: %+v\n", deployment)
-			}
-
-			// Only update the template if we added new values
-			updatedContent := updateDeploymentTemplate(content)
-			if err := os.WriteFile(templatePath, updatedContent, 0644); err != nil {
-				return fmt.Errorf("updating template: %w", err)
-			}
-		} else if c.config.Verbose {
-			fmt.Printf("Strategy section already exists\n")
+		if after = file.encoding.encode(after); !bytes.Equal(before, after) {
+			changes = append(changes, FileChange{Path: file.Path, Before: before, After: after})
 		}
 	}
-
-	return nil
+	return changes, nil
 }
 
-// removeHelmTemplates removes Helm template directives from YAML content
-func removeHelmTemplates(content []byte) []byte {
-	lines := strings.Split(string(content), "\n")
-	var cleanLines []string
-
-	for _, line := range lines {
-		// Skip lines with Helm template directives
-		if strings.Contains(line, "{{") || strings.Contains(line, "}}") {
-			continue
-		}
-		// Skip lines with Helm template comments
-		if strings.Contains(line, "{{-") || strings.Contains(line, "-}}") {
-			continue
-		}
-		cleanLines = append(cleanLines, line)
+// Render returns the new content of the chart files the run changes, by path,
+// without writing anything, so that callers such as web services can store the
+// files themselves. With WithDryRun, which ProcessReferences needs to leave the
+// templates unchanged on disk, the templates it would modify are included.
+// Files whose content does not change are left out, see Changes.
+func (c *Chart) Render() (map[string][]byte, error) {
+	changes, err := c.Changes()
+	if err != nil {
+		return nil, err
 	}
-
-	return []byte(strings.Join(cleanLines, "\n"))
+	files := make(map[string][]byte, len(changes))
+	for _, change := range changes {
+		files[change.Path] = change.After
+	}
+	return files, nil
 }
 
-// updateDeploymentTemplate adds the strategy configuration to a deployment template
-func updateDeploymentTemplate(content []byte) []byte {
-	// Split the content into lines
-	lines := strings.Split(string(content), "\n")
-
-	// Find the spec: line and its indentation
-	specIndex := -1
-	specIndent := ""
-	strategyExists := false
-	inSpec := false
-	inTemplate := false
-	templateDepth := 0
-
-	for i, line := range lines {
-		trimmed := strings.TrimSpace(line)
+// writeValueFiles writes the changed values files together: if one of them
+// cannot be written, the files already written are restored.
+func (c *Chart) writeValueFiles() error {
+	return c.writeFiles(nil)
+}
 
-		// Track template section depth
-		if strings.Contains(line, "template:") {
-			templateDepth++
-			if templateDepth == 1 {
-				inTemplate = true
-			}
+// writeFiles writes the changed values files together with the template
+// changes, like writeValueFiles.
+func (c *Chart) writeFiles(templates []FileChange) error {
+	var writes []fileWrite
+	for i := range c.ValuesFiles {
+		file := &c.ValuesFiles[i]
+		if !file.Changed {
 			continue
 		}
 
-		// Track when we're in the main spec section
-		if trimmed == "spec:" {
-			if templateDepth == 0 {
-				specIndex = i
-				specIndent = line[:len(line)-len(trimmed)]
-				inSpec = true
-			}
-			continue
+		if err := file.checkUnmodified(); err != nil {
+			return err
 		}
 
-		// Only look for strategy within the main spec section
-		if inSpec && !inTemplate {
-			if strings.HasPrefix(trimmed, "strategy:") {
-				strategyExists = true
-				break
-			}
-			// If we hit a line with less indentation than spec, we're out of the main spec
-			if len(line) > 0 {
-				currentIndent := line[:len(line)-len(trimmed)]
-				if len(currentIndent) <= len(specIndent) {
-					inSpec = false
-				}
-			}
+		// Patch the changes into the file, keeping its comments and layout
+		data, err := file.render()
+		if err != nil {
+			return err
+		}
+		if err := c.backup(file.Path); err != nil {
+			return err
 		}
+		// Write the formatted YAML in its original encoding
+		writes = append(writes, fileWrite{path: file.Path, data: file.encoding.encode(data)})
+	}
+	for _, change := range templates {
+		if err := c.backup(change.Path); err != nil {
+			return err
+		}
+		writes = append(writes, fileWrite{path: change.Path, data: change.After})
+	}
 
-		// Track template section depth
-		if inTemplate {
-			currentIndent := len(line) - len(strings.TrimLeft(line, " "))
-			if currentIndent <= len(specIndent) {
-				templateDepth--
-				if templateDepth == 0 {
-					inTemplate = false
-				}
-			}
+	c.config.progress(0, len(writes), PhaseWriting)
+	if err := writeFilesAtomic(writes, c.config.fileMode()); err != nil {
+		return fmt.Errorf("writing values file: %w", err)
+	}
+	for i, write := range writes {
+		c.config.logger().Info("updated values", "file", write.path)
+		c.config.hooks().fileWritten(write.path)
+		c.config.progress(i+1, len(writes), PhaseWriting)
+	}
+	written := make(map[string]int)
+	for i := range c.ValuesFiles {
+		if file := &c.ValuesFiles[i]; file.Changed {
+			written[file.Path] = len(writes[0].data)
+			file.onDisk = &diskContent{data: writes[0].data, exists: true}
+			file.original, file.rewrite = copyValue(file.Values).(map[string]any), false
+			writes = writes[1:]
 		}
 	}
+	c.recordResults(written)
+	return nil
+}
 
-	// If strategy already exists or we can't find spec, return unchanged
-	if strategyExists || specIndex == -1 {
-		return content
+// checkUnmodified returns ErrWriteConflict if the values file was modified
+// since it was loaded or last written.
+func (f *ValueFile) checkUnmodified() error {
+	if f.onDisk == nil {
+		return nil
 	}
+	data, err := os.ReadFile(f.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading values file: %w", err)
+	}
+	if (err == nil) != f.onDisk.exists || !bytes.Equal(data, f.onDisk.data) {
+		return fmt.Errorf("writing %s: %w", f.Path, ErrWriteConflict)
+	}
+	return nil
+}
 
-	// Find the indentation of the first item under spec
-	baseIndent := ""
-	indentWidth := 2 // Default indent width
-	for i := specIndex + 1; i < len(lines); i++ {
-		line := lines[i]
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" || strings.HasPrefix(trimmed, "{{") {
-			continue
-		}
-		if len(line) > len(trimmed) {
-			baseIndent = line[:len(line)-len(trimmed)]
-			indentWidth = len(baseIndent) - len(specIndent)
-			break
+// initialValue returns the value written for a missing reference: its default
+// if the template specifies one, otherwise null in null mode, the configured
+// placeholder for scalars or the zero value of its inferred type
+func (c *config) initialValue(ref ValueRef) any {
+	if ref.DefaultValue != "" {
+		if ref.DefaultUnquoted && (c == nil || !c.StringDefaults) {
+			return typedLiteral(ref.DefaultValue)
 		}
+		return ref.DefaultValue
 	}
-	if baseIndent == "" {
-		baseIndent = specIndent + strings.Repeat(" ", indentWidth)
+	if c != nil && c.NullValues {
+		return nil
 	}
-
-	// Create the strategy section with proper indentation
-	strategySection := []string{
-		baseIndent + "strategy:",
-		baseIndent + strings.Repeat(" ", indentWidth) + "type: {{ .Values.deployment.strategy.type }}",
-		baseIndent + strings.Repeat(" ", indentWidth) + "rollingUpdate:",
-		baseIndent + strings.Repeat(" ", indentWidth*2) + "maxSurge: {{ .Values.deployment.strategy.rollingUpdate.maxSurge }}",
-		baseIndent + strings.Repeat(" ", indentWidth*2) + "maxUnavailable: {{ .Values.deployment.strategy.rollingUpdate.maxUnavailable }}",
+	if c != nil && c.placeholderSet && ref.Type != TypeMap && ref.Type != TypeList {
+		if s, ok := c.Placeholder.(string); ok {
+			return strings.ReplaceAll(s, PathMarker, ref.Path)
+		}
+		return c.Placeholder
 	}
+	return zeroValue(ref.Type)
+}
 
-	// Insert the strategy section right after spec:
-	result := make([]string, 0, len(lines)+len(strategySection))
-	result = append(result, lines[:specIndex+1]...)
-	result = append(result, strategySection...)
-	result = append(result, lines[specIndex+1:]...)
-
-	return []byte(strings.Join(result, "\n"))
+// typedLiteral converts an unquoted template literal to its native type:
+// an int, a float64 or a bool, falling back to the literal itself.
+func typedLiteral(literal string) any {
+	if i, err := strconv.Atoi(literal); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(literal, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(literal); err == nil {
+		return b
+	}
+	return literal
 }
 
-// UpdateValueFiles ensures all referenced values exist in values.yaml.
-// It adds missing values with appropriate defaults and updates the file.
-// The operation is skipped if no changes are needed.
-func (c *Chart) UpdateValueFiles() error {
-	// iterate over each values file
-	for i := range c.ValuesFiles {
-		file := &c.ValuesFiles[i]
-		if !file.Changed {
-			continue
-		}
+// ScalarConflictError reports a value that cannot be added to a values file
+// because one of its parents is defined as a scalar or a list, such as
+// service.port when the file defines service: web. The file is left unchanged
+// instead of replacing the parent with a map.
+type ScalarConflictError struct {
+	// File is the values file
+	File string
+	// Path is the value path that was not added
+	Path string
+	// Parent is the parent path defined as a scalar or a list
+	Parent string
+	// Value is the value of Parent
+	Value any
+}
 
-		// Convert to YAML with proper formatting
-		data, err := yaml.Marshal(file.Values)
-		if err != nil {
-			return fmt.Errorf("encoding values: %w", err)
-		}
+// Error describes the conflict.
+func (e *ScalarConflictError) Error() string {
+	value := fmt.Sprint(e.Value)
+	if s, ok := e.Value.(string); ok {
+		value = fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%s: cannot add .Values.%s: %s is a %s (%s), not a map",
+		e.File, e.Path, e.Parent, yamlKind(e.Value), value)
+}
 
-		// Write the formatted YAML to file
-		if err := os.WriteFile(file.Path, data, 0644); err != nil {
-			return fmt.Errorf("writing values file: %w", err)
+// scalarConflict returns the conflict error if a parent of path is defined in
+// the file as a value other than a map or null.
+func scalarConflict(file *ValueFile, path string) *ScalarConflictError {
+	parts := SplitValuePath(path)
+	current := file.Values
+	for i, part := range parts[:len(parts)-1] {
+		v, ok := current[part]
+		if !ok || v == nil {
+			return nil
 		}
-
-		if c.config.Verbose {
-			fmt.Printf("updated values in %s\n", file.Path)
+		nested, ok := v.(map[string]any)
+		if !ok {
+			return &ScalarConflictError{File: file.Path, Path: path, Parent: JoinValuePath(parts[:i+1]...), Value: v}
 		}
+		current = nested
 	}
-
 	return nil
 }
 
 // setNestedValue sets a nested value in the Values map
-func setNestedValue(values map[string]any, path string, value string) {
-	parts := strings.Split(path, ".")
+func setNestedValue(values map[string]any, path string, value any) {
+	parts := SplitValuePath(path)
 	current := values
 
 	// Create nested structure
@@ -528,10 +980,15 @@ func setNestedValue(values map[string]any, path string, value string) {
 	current[parts[len(parts)-1]] = value
 }
 
+// Defines reports whether the file defines the value at path, null included.
+func (f *ValueFile) Defines(path string) bool {
+	return valueExists(f.Values, path)
+}
+
 // valueExists is a function to check if a value exists in the values map at the given path
 func valueExists(values map[string]any, path string) bool {
 	current := values
-	parts := strings.Split(path, ".")
+	parts := SplitValuePath(path)
 
 	for i, part := range parts {
 		v, ok := current[part]
@@ -548,3 +1005,42 @@ func valueExists(values map[string]any, path string) bool {
 	}
 	return true
 }
+
+// lookupValue returns the value at the given path in the values map
+func lookupValue(values map[string]any, path string) (any, bool) {
+	current := values
+	parts := SplitValuePath(path)
+
+	for i, part := range parts {
+		v, ok := current[part]
+		if !ok {
+			return nil, false
+		}
+		if i == len(parts)-1 {
+			return v, true
+		}
+		current, ok = v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// deleteNestedValue removes the value at the given path from the values map,
+// along with any parent maps left empty
+func deleteNestedValue(values map[string]any, path string) {
+	parts := SplitValuePath(path)
+	if len(parts) > 1 {
+		nested, ok := values[parts[0]].(map[string]any)
+		if !ok {
+			return
+		}
+		deleteNestedValue(nested, JoinValuePath(parts[1:]...))
+		if len(nested) == 0 {
+			delete(values, parts[0])
+		}
+		return
+	}
+	delete(values, parts[0])
+}
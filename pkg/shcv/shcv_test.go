@@ -2,6 +2,7 @@ package shcv
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -71,6 +72,23 @@ func TestNewChart(t *testing.T) {
 	}
 }
 
+func TestNewChartValuesFilePaths(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", nil)
+	external := filepath.Join(t.TempDir(), "values-prod.yaml")
+	require.NoError(t, os.WriteFile(external, []byte("name: prod\n"), 0644))
+
+	chart, err := NewChart(dir, WithValuesFileNames([]string{"values-dev.yaml", external, "./values.yaml", "values-dev.yaml"}))
+	require.NoError(t, err)
+	var paths []string
+	for _, file := range chart.ValuesFiles {
+		paths = append(paths, file.Path)
+	}
+	assert.Equal(t, []string{filepath.Join(dir, "values.yaml"), filepath.Join(dir, "values-dev.yaml"), external}, paths)
+
+	require.NoError(t, chart.LoadValueFiles())
+	assert.Equal(t, map[string]any{"name": "prod"}, chart.ValuesFiles[2].Values)
+}
+
 func TestChart_LoadValueFiles(t *testing.T) {
 	// Create a temporary directory for testing
 	tmpDir, err := os.MkdirTemp("", "shcv-test-*")
@@ -122,6 +140,7 @@ func TestChart_FindTemplates(t *testing.T) {
 	tests := []struct {
 		name          string
 		templatesDir  string
+		opts          []Option
 		setup         func(string, string) error
 		cleanup       func(string, string) error
 		wantTemplates []string
@@ -139,7 +158,9 @@ func TestChart_FindTemplates(t *testing.T) {
 					"deployment.yaml": "",
 					"service.yml":     "",
 					"ingress.tpl":     "",
+					"NOTES.txt":       "",
 					"README.md":       "", // Should be ignored
+					"other.txt":       "", // Should be ignored
 				}
 				for name, content := range files {
 					if err := os.WriteFile(filepath.Join(templatesPath, name), []byte(content), 0644); err != nil {
@@ -149,11 +170,94 @@ func TestChart_FindTemplates(t *testing.T) {
 				return nil
 			},
 			wantTemplates: []string{
+				"NOTES.txt",
 				"deployment.yaml",
 				"ingress.tpl",
 				"service.yml",
 			},
 		},
+		{
+			name:         "exclude patterns",
+			templatesDir: "templates",
+			opts:         []Option{WithExcludePatterns([]string{"_*.tpl", "tests/", "vendor/*.yaml"})},
+			setup: func(dir, templatesDir string) error {
+				for _, name := range []string{
+					"deployment.yaml",
+					"_helpers.tpl",
+					"nested/_labels.tpl",
+					"nested/service.yaml",
+					"tests/test-connection.yaml",
+					"vendor/redis.yaml",
+					"vendor/keep.tpl",
+				} {
+					path := filepath.Join(dir, templatesDir, name)
+					if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+						return err
+					}
+					if err := os.WriteFile(path, nil, 0644); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			wantTemplates: []string{
+				"deployment.yaml",
+				filepath.Join("nested", "service.yaml"),
+				filepath.Join("vendor", "keep.tpl"),
+			},
+		},
+		{
+			name:         "listed templates",
+			templatesDir: "templates",
+			opts:         []Option{WithTemplates([]string{"templates/deployment.yaml", "templates/nested/svc.yaml"})},
+			setup: func(dir, templatesDir string) error {
+				for _, name := range []string{"deployment.yaml", "service.yaml", "nested/svc.yaml"} {
+					path := filepath.Join(dir, templatesDir, name)
+					if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+						return err
+					}
+					if err := os.WriteFile(path, nil, 0644); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			wantTemplates: []string{
+				"deployment.yaml",
+				filepath.Join("nested", "svc.yaml"),
+			},
+		},
+		{
+			name:         "missing listed template",
+			templatesDir: "templates",
+			opts:         []Option{WithTemplates([]string{"templates/missing.yaml"})},
+			setup: func(dir, templatesDir string) error {
+				return os.MkdirAll(filepath.Join(dir, templatesDir), 0755)
+			},
+			wantErr: true,
+		},
+		{
+			name:         "custom template extensions",
+			templatesDir: "templates",
+			opts:         []Option{WithTemplateExtensions("txt", ".json")},
+			setup: func(dir, templatesDir string) error {
+				templatesPath := filepath.Join(dir, templatesDir)
+				if err := os.MkdirAll(templatesPath, 0755); err != nil {
+					return err
+				}
+				for _, name := range []string{"deployment.yaml", "config.json", "banner.txt", "README.md"} {
+					if err := os.WriteFile(filepath.Join(templatesPath, name), nil, 0644); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			wantTemplates: []string{
+				"banner.txt",
+				"config.json",
+				"deployment.yaml",
+			},
+		},
 		{
 			name:         "empty templates directory",
 			templatesDir: "templates",
@@ -261,10 +365,8 @@ func TestChart_FindTemplates(t *testing.T) {
 			}
 
 			chart := &Chart{
-				Dir: tempDir,
-				config: &config{
-					TemplatesDir: tt.templatesDir,
-				},
+				Dir:    tempDir,
+				config: newConfig(append([]Option{WithTemplatesDir(tt.templatesDir)}, tt.opts...)),
 			}
 
 			err := chart.FindTemplates()
@@ -510,6 +612,15 @@ func TestValueExists(t *testing.T) {
 	}
 }
 
+func TestValueFileDefines(t *testing.T) {
+	file := ValueFile{Values: map[string]any{"image": map[string]any{"tag": nil}, "name": "app"}}
+	assert.True(t, file.Defines("image"))
+	assert.True(t, file.Defines("image.tag"))
+	assert.True(t, file.Defines("name"))
+	assert.False(t, file.Defines("name.first"))
+	assert.False(t, file.Defines("image.repository"))
+}
+
 func TestParseTemplates(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -608,18 +719,19 @@ func TestParseTemplates(t *testing.T) {
 			},
 		},
 		{
-			name: "invalid template content",
+			name: "line longer than a scanner token",
 			setup: func(dir string) error {
-				// Create a file with a line that's too long for the scanner
-				var longLine strings.Builder
-				for i := 0; i < bufio.MaxScanTokenSize+1; i++ {
-					longLine.WriteByte('a')
-				}
-				return os.WriteFile(filepath.Join(dir, "test.yaml"), []byte(longLine.String()), 0644)
+				longLine := strings.Repeat("a", bufio.MaxScanTokenSize+1) + "{{ .Values.key }}"
+				return os.WriteFile(filepath.Join(dir, "test.yaml"), []byte(longLine), 0644)
+			},
+			templates: []string{"test.yaml"},
+			wantRefs: []ValueRef{
+				{
+					Path:       "key",
+					SourceFile: "test.yaml",
+					LineNumber: 1,
+				},
 			},
-			templates:   []string{"test.yaml"},
-			wantErr:     true,
-			errContains: "scanning template",
 		},
 	}
 
@@ -726,6 +838,277 @@ func TestProcessReferences(t *testing.T) {
 	}
 }
 
+func TestProcessReferencesTypedZeroValues(t *testing.T) {
+	chart := &Chart{
+		References: []ValueRef{
+			{Path: "replicas", Type: TypeInt},
+			{Path: "enabled", Type: TypeBool},
+			{Path: "resources", Type: TypeMap},
+			{Path: "hosts", Type: TypeList},
+			{Path: "name", Type: TypeString},
+			{Path: "untyped"},
+			{Path: "port", Type: TypeInt, DefaultValue: "8080"},
+			{Path: "later"},
+			{Path: "later", Type: TypeBool},
+		},
+		ValuesFiles: []ValueFile{{Path: "values.yaml", Values: map[string]any{}}},
+		config:      defaultConfig(),
+	}
+
+	chart.ProcessReferences()
+
+	assert.Equal(t, map[string]any{
+		"replicas":  0,
+		"enabled":   false,
+		"resources": map[string]any{},
+		"hosts":     []any{},
+		"name":      "",
+		"untyped":   "",
+		"port":      "8080",
+		"later":     false,
+	}, chart.ValuesFiles[0].Values)
+}
+
+func TestProcessReferencesTypedDefaults(t *testing.T) {
+	refs := []ValueRef{
+		{Path: "port", DefaultValue: "8080", DefaultUnquoted: true},
+		{Path: "ratio", DefaultValue: "0.5", DefaultUnquoted: true},
+		{Path: "debug", DefaultValue: "false", DefaultUnquoted: true},
+		{Path: "version", DefaultValue: "1.2.3", DefaultUnquoted: true},
+		{Path: "tag", DefaultValue: "8080"},
+		{Path: "later"},
+		{Path: "later", DefaultValue: "3", DefaultUnquoted: true},
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		want map[string]any
+	}{
+		{
+			name: "typed",
+			want: map[string]any{
+				"port": 8080, "ratio": 0.5, "debug": false, "version": "1.2.3", "tag": "8080", "later": 3,
+			},
+		},
+		{
+			name: "string defaults",
+			opts: []Option{WithStringDefaults(true)},
+			want: map[string]any{
+				"port": "8080", "ratio": "0.5", "debug": "false", "version": "1.2.3", "tag": "8080", "later": "3",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := &Chart{
+				References:  refs,
+				ValuesFiles: []ValueFile{{Path: "values.yaml", Values: map[string]any{}}},
+				config:      newConfig(tt.opts),
+			}
+			chart.ProcessReferences()
+			assert.Equal(t, tt.want, chart.ValuesFiles[0].Values)
+		})
+	}
+}
+
+func TestProcessReferencesNullValues(t *testing.T) {
+	chart := &Chart{
+		References: []ValueRef{
+			{Path: "image.tag"},
+			{Path: "replicas", Type: TypeInt},
+			{Path: "resources", Type: TypeMap},
+			{Path: "hosts", Type: TypeList},
+			{Path: "port", DefaultValue: "8080", DefaultUnquoted: true},
+		},
+		ValuesFiles: []ValueFile{{Path: "values.yaml", Values: map[string]any{}}},
+		config:      newConfig([]Option{WithNullValues(true)}),
+	}
+	chart.ProcessReferences()
+
+	assert.Equal(t, map[string]any{
+		"image":     map[string]any{"tag": nil},
+		"replicas":  nil,
+		"resources": nil,
+		"hosts":     nil,
+		"port":      8080,
+	}, chart.ValuesFiles[0].Values)
+}
+
+func TestProcessReferencesScalarConflicts(t *testing.T) {
+	chart := &Chart{
+		References: []ValueRef{
+			{Path: "service.port", Type: TypeInt},
+			{Path: "hosts.primary"},
+			{Path: "image.tag"},
+			{Path: "resources.limits.cpu"},
+			{Path: "ingress", Type: TypeBool},
+			{Path: "ingress.host"},
+		},
+		ValuesFiles: []ValueFile{{Path: "values.yaml", Values: map[string]any{
+			"service":   "web",
+			"hosts":     []any{"a"},
+			"image":     nil,
+			"resources": map[string]any{"limits": 2.0},
+		}}},
+		config: defaultConfig(),
+	}
+	chart.ProcessReferences()
+
+	file := chart.ValuesFiles[0]
+	assert.Equal(t, map[string]any{
+		"service":   "web",
+		"hosts":     []any{"a"},
+		"image":     map[string]any{"tag": ""},
+		"resources": map[string]any{"limits": 2.0},
+		"ingress":   map[string]any{"host": ""},
+	}, file.Values)
+	assert.Equal(t, []string{"image.tag", "ingress", "ingress.host"}, file.Added)
+
+	var got []string
+	for _, conflict := range file.Conflicts {
+		got = append(got, conflict.Error())
+	}
+	assert.Equal(t, []string{
+		`values.yaml: cannot add .Values.service.port: service is a string ("web"), not a map`,
+		`values.yaml: cannot add .Values.hosts.primary: hosts is a sequence ([a]), not a map`,
+		`values.yaml: cannot add .Values.resources.limits.cpu: resources.limits is a number (2), not a map`,
+	}, got)
+
+	var conflict *ScalarConflictError
+	require.ErrorAs(t, file.Conflicts[0], &conflict)
+	assert.Equal(t, "service", conflict.Parent)
+	assert.Equal(t, "web", conflict.Value)
+}
+
+func TestProcessReferencesValuePrompt(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{
+		"deployment.yaml": `domain: {{ .Values.gateway.domain }}
+replicas: {{ .Values.replicas | int }}
+tag: {{ .Values.image.tag | default "latest" }}
+host: {{ .Values.gateway.domain }}
+port: {{ .Values.port }}
+name: {{ .Values.name }}
+seeded: {{ .Values.seeded }}
+`,
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "values-prod.yaml"), nil, 0644))
+	var asked []string
+	chart := loadTestChart(t, dir,
+		WithValuesFileNames([]string{"values-prod.yaml"}),
+		WithSetValues("seeded=1"),
+		WithValuePrompt(func(ref ValueRef) (any, bool) {
+			asked = append(asked, fmt.Sprintf("%s (%s)", ref.Path, ref.Type))
+			switch ref.Path {
+			case "gateway.domain":
+				return "example.com", true
+			case "replicas":
+				return 3, true
+			}
+			return nil, false
+		}),
+	)
+	chart.ProcessReferences()
+
+	// Every path is asked for once, for all values files
+	assert.Equal(t, []string{"gateway.domain ()", "replicas (int)", "port ()", "name ()"}, asked)
+	assert.Equal(t, "", chart.ValuesFiles[1].Values["name"])
+	for _, file := range chart.ValuesFiles {
+		assert.Equal(t, map[string]any{"domain": "example.com"}, file.Values["gateway"])
+		assert.Equal(t, 3, file.Values["replicas"])
+		assert.Equal(t, "", file.Values["port"])
+		assert.Equal(t, 1, file.Values["seeded"])
+	}
+}
+
+func TestProcessReferencesProvenanceComments(t *testing.T) {
+	dir := writeTestChart(t, "# Image settings\nimage:\n  repository: nginx\n", map[string]string{
+		"deployment.yaml": "image: {{ .Values.image.repository }}:{{ .Values.image.tag }}\nreplicas: {{ .Values.replicas | default 1 }}\n",
+	})
+	chart := loadTestChart(t, dir, WithProvenanceComments(true))
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+
+	data, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "# Image settings\nimage:\n  repository: nginx\n  # added by shcv from templates/deployment.yaml:1\n  tag: \"\"\n# added by shcv from templates/deployment.yaml:2\nreplicas: 1\n", string(data))
+}
+
+func TestProcessReferencesSectionBanner(t *testing.T) {
+	dir := writeTestChart(t, "image:\n  repository: nginx\n", map[string]string{
+		"deployment.yaml": "image: {{ .Values.image.repository }}:{{ .Values.image.tag }}\nreplicas: {{ .Values.replicas | default 1 }}\n",
+	})
+	chart := loadTestChart(t, dir, WithSectionBanner("--- synced by shcv ---"))
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+
+	data, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "image:\n  repository: nginx\n  tag: \"\"\n\n# --- synced by shcv ---\nreplicas: 1\n", string(data))
+
+	// Later runs append below the banner instead of writing it again
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "templates", "service.yaml"), []byte("port: {{ .Values.port }}\n"), 0644))
+	chart = loadTestChart(t, dir, WithSectionBanner("--- synced by shcv ---"))
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+
+	data, err = os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "image:\n  repository: nginx\n  tag: \"\"\n\n# --- synced by shcv ---\nreplicas: 1\nport: \"\"\n", string(data))
+}
+
+func TestProcessReferencesPlaceholder(t *testing.T) {
+	refs := []ValueRef{
+		{Path: "image.tag"},
+		{Path: "replicas", Type: TypeInt},
+		{Path: "resources", Type: TypeMap},
+		{Path: "hosts", Type: TypeList},
+		{Path: "port", DefaultValue: "8080"},
+	}
+
+	tests := []struct {
+		name        string
+		placeholder any
+		want        map[string]any
+	}{
+		{
+			name:        "null",
+			placeholder: nil,
+			want: map[string]any{
+				"image":     map[string]any{"tag": nil},
+				"replicas":  nil,
+				"resources": map[string]any{},
+				"hosts":     []any{},
+				"port":      "8080",
+			},
+		},
+		{
+			name:        "path marker",
+			placeholder: "TODO: " + PathMarker,
+			want: map[string]any{
+				"image":     map[string]any{"tag": "TODO: image.tag"},
+				"replicas":  "TODO: replicas",
+				"resources": map[string]any{},
+				"hosts":     []any{},
+				"port":      "8080",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := &Chart{
+				References:  refs,
+				ValuesFiles: []ValueFile{{Path: "values.yaml", Values: map[string]any{}}},
+				config:      newConfig([]Option{WithPlaceholder(tt.placeholder)}),
+			}
+			chart.ProcessReferences()
+			assert.Equal(t, tt.want, chart.ValuesFiles[0].Values)
+		})
+	}
+}
+
 func TestUpdateValueFiles(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -897,6 +1280,7 @@ func TestUpdateValueFiles(t *testing.T) {
 			}
 
 			chart := &Chart{
+				Dir:         tempDir,
 				ValuesFiles: tt.files,
 				config:      &config{Verbose: tt.verbose},
 			}
@@ -923,6 +1307,199 @@ func TestUpdateValueFiles(t *testing.T) {
 	}
 }
 
+func TestChartReset(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{"deployment.yaml": "kind: Deployment\nspec:\n  replicas: {{ .Values.replicas }}\n"})
+	chart := loadTestChart(t, dir, WithDryRun(true), WithDeploymentStrategyInjection(true))
+	chart.ProcessReferences()
+	changes, err := chart.Changes()
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+
+	chart.Reset()
+	assert.Empty(t, chart.Templates)
+	assert.Empty(t, chart.References)
+	require.Len(t, chart.ValuesFiles, 1)
+	assert.Equal(t, ValueFile{Path: filepath.Join(dir, "values.yaml"), Values: map[string]any{}}, chart.ValuesFiles[0])
+	changes, err = chart.Changes()
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// The options are kept
+	require.NoError(t, chart.LoadValueFiles())
+	require.NoError(t, chart.FindTemplates())
+	require.NoError(t, chart.ParseTemplates())
+	assert.Len(t, chart.References, 1)
+	chart.ProcessReferences()
+	changes, err = chart.Changes()
+	require.NoError(t, err)
+	assert.Len(t, changes, 2)
+}
+
+func TestUpdateValueFilesFileMode(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{"service.yaml": "port: {{ .Values.port }}\n"})
+	values := filepath.Join(dir, "values.yaml")
+	require.NoError(t, os.Chmod(values, 0640))
+	mode := func() os.FileMode {
+		info, err := os.Stat(values)
+		require.NoError(t, err)
+		return info.Mode().Perm()
+	}
+
+	// The mode of the values file is kept by default
+	chart := loadTestChart(t, dir)
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+	assert.Equal(t, os.FileMode(0640), mode())
+
+	chart = loadTestChart(t, dir, WithFileMode(0600))
+	chart.ValuesFiles[0].Values["name"] = "other"
+	chart.ValuesFiles[0].Changed = true
+	require.NoError(t, chart.UpdateValueFiles())
+	assert.Equal(t, os.FileMode(0600), mode())
+}
+
+func TestUpdateValueFilesResults(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{"service.yaml": "port: {{ .Values.port | default 80 }}\n"})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "values-prod.yaml"), []byte("port: 443\n"), 0644))
+	values, prod := filepath.Join(dir, "values.yaml"), filepath.Join(dir, "values-prod.yaml")
+	chart := loadTestChart(t, dir, WithValuesFileNames([]string{"values.yaml", "values-prod.yaml"}))
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+
+	data, err := os.ReadFile(values)
+	require.NoError(t, err)
+	assert.Equal(t, []ValueFileResult{
+		{Path: values, Added: []string{"port"}, Written: true, BytesWritten: len(data)},
+		{Path: prod, Unchanged: true},
+	}, chart.Results)
+
+	// Nothing is written once the files are in sync
+	chart = loadTestChart(t, dir, WithValuesFileNames([]string{"values.yaml", "values-prod.yaml"}))
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+	assert.Equal(t, []ValueFileResult{{Path: values, Unchanged: true}, {Path: prod, Unchanged: true}}, chart.Results)
+
+	// A dry run records the values it would add without writing them
+	dir = writeTestChart(t, "name: app\n", map[string]string{"service.yaml": "port: {{ .Values.port }}\n"})
+	chart = loadTestChart(t, dir, WithDryRun(true))
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+	assert.Equal(t, []ValueFileResult{{Path: filepath.Join(dir, "values.yaml"), Added: []string{"port"}}}, chart.Results)
+}
+
+func TestChangesDryRun(t *testing.T) {
+	template := "kind: Deployment\nspec:\n  replicas: {{ .Values.replicas | int }}\n"
+	dir := writeTestChart(t, "replicas: 1\n", map[string]string{"deployment.yaml": template})
+	chart := loadTestChart(t, dir, WithDryRun(true), WithDeploymentStrategyInjection(true))
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+
+	// The files are unchanged
+	data, err := os.ReadFile(filepath.Join(dir, "templates", "deployment.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, template, string(data))
+	data, err = os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "replicas: 1\n", string(data))
+
+	changes, err := chart.Changes()
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, filepath.Join(dir, "templates", "deployment.yaml"), changes[0].Path)
+	assert.Equal(t, template, string(changes[0].Before))
+	assert.Contains(t, string(changes[0].After), "strategy:\n    type: {{ .Values.deployment.strategy.type }}")
+	assert.Equal(t, filepath.Join(dir, "values.yaml"), changes[1].Path)
+	assert.Equal(t, "replicas: 1\n", string(changes[1].Before))
+	assert.Contains(t, string(changes[1].After), "deployment:\n  strategy:\n")
+
+	// Without changes to make, there are none
+	chart = loadTestChart(t, writeTestChart(t, "replicas: 1\n", map[string]string{"a.yaml": "{{ .Values.replicas }}"}), WithDryRun(true))
+	chart.ProcessReferences()
+	changes, err = chart.Changes()
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestRender(t *testing.T) {
+	template := "kind: Deployment\nspec:\n  replicas: {{ .Values.replicas | int }}\n"
+	dir := writeTestChart(t, "name: app\n", map[string]string{"deployment.yaml": template})
+	chart := loadTestChart(t, dir, WithDryRun(true), WithDeploymentStrategyInjection(true))
+	chart.ProcessReferences()
+	files, err := chart.Render()
+	require.NoError(t, err)
+	templatePath, valuesPath := filepath.Join(dir, "templates", "deployment.yaml"), filepath.Join(dir, "values.yaml")
+	require.Len(t, files, 2)
+	assert.Contains(t, string(files[templatePath]), "strategy:\n    type: {{ .Values.deployment.strategy.type }}")
+	assert.Contains(t, string(files[valuesPath]), "replicas: 0\n")
+
+	// Nothing is written
+	data, err := os.ReadFile(templatePath)
+	require.NoError(t, err)
+	assert.Equal(t, template, string(data))
+	data, err = os.ReadFile(valuesPath)
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\n", string(data))
+}
+
+func TestUpdateValueFilesRollback(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{"service.yaml": "{{ .Values.port }}\n"})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "values-prod.yaml"), []byte("name: prod\n"), 0644))
+	chart := loadTestChart(t, dir, WithValuesFileNames([]string{"values-dev.yaml", "values-prod.yaml"}))
+	chart.ProcessReferences()
+
+	// The third file fails after the first two were replaced
+	failRename(t, filepath.Join(dir, "values-prod.yaml"))
+	err := chart.UpdateValueFiles()
+	var rollback *RollbackError
+	require.ErrorAs(t, err, &rollback)
+	assert.Equal(t, []string{filepath.Join(dir, "values.yaml"), filepath.Join(dir, "values-dev.yaml")}, rollback.Restored)
+
+	content, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\n", string(content))
+	assert.NoFileExists(t, filepath.Join(dir, "values-dev.yaml"))
+	content, err = os.ReadFile(filepath.Join(dir, "values-prod.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: prod\n", string(content))
+}
+
+func TestProcessReferencesLayered(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{
+		"deployment.yaml": "kind: Deployment\nspec:\n  replicas: {{ .Values.replicas }}\n  image: {{ .Values.image | default \"nginx\" }}\n",
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "values-prod.yaml"), []byte("replicas: 3\ndeployment:\n  strategy:\n    type: Recreate\n"), 0644))
+	chart := loadTestChart(t, dir, WithValuesFileNames([]string{"values-dev.yaml", "values-prod.yaml"}), WithLayeredValues(true))
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+
+	// Only the base layer receives the values no layer defines
+	content, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\nimage: nginx\n", string(content))
+	assert.NoFileExists(t, filepath.Join(dir, "values-dev.yaml"))
+	content, err = os.ReadFile(filepath.Join(dir, "values-prod.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "replicas: 3\ndeployment:\n  strategy:\n    type: Recreate\n", string(content))
+}
+
+func TestProcessReferencesDottedKeys(t *testing.T) {
+	dir := writeTestChart(t, "annotations:\n  team: web\n", map[string]string{
+		"ingress.yaml": "rewrite: {{ index .Values.annotations \"nginx.ingress.kubernetes.io/rewrite-target\" | default \"/\" }}\n" +
+			"name: {{ get .Values.labels \"app.kubernetes.io/name\" }}\n",
+	})
+	chart := loadTestChart(t, dir)
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+
+	content, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "annotations:\n  team: web\n  nginx.ingress.kubernetes.io/rewrite-target: /\nlabels:\n  app.kubernetes.io/name: \"\"\n", string(content))
+
+	// The keys round-trip: the chart is in sync afterwards
+	chart = loadTestChart(t, dir)
+	assert.Empty(t, chart.MissingValues())
+}
+
 func TestLoadValueFiles(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -1072,6 +1649,118 @@ func TestLoadValueFiles(t *testing.T) {
 	}
 }
 
+func TestLoadValueFilesRoot(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		rootKey  string
+		want     map[string]any
+		wantKind string
+		wantErr  string
+	}{
+		{name: "only comments", content: "# no values yet\n", want: map[string]any{}},
+		{name: "null", content: "~\n", want: map[string]any{}},
+		{name: "sequence", content: "- a\n- b\n", wantErr: "has a sequence at the root instead of a map of values"},
+		{name: "scalar", content: "hello\n", wantErr: "has a string at the root instead of a map of values"},
+		{
+			name:     "wrapped sequence",
+			content:  "- a\n- b\n",
+			rootKey:  "items",
+			want:     map[string]any{"items": []any{"a", "b"}},
+			wantKind: "sequence",
+		},
+		{
+			name:     "wrapped number",
+			content:  "3\n",
+			rootKey:  "replicas",
+			want:     map[string]any{"replicas": float64(3)},
+			wantKind: "number",
+		},
+		{name: "map with root key", content: "a: 1\n", rootKey: "items", want: map[string]any{"a": float64(1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "values.yaml"), []byte(tt.content), 0644))
+			chart, err := NewChart(dir, WithValuesRootKey(tt.rootKey))
+			require.NoError(t, err)
+
+			err = chart.LoadValueFiles()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, filepath.Join(dir, "values.yaml")+" "+tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			file := chart.ValuesFiles[0]
+			assert.Equal(t, tt.want, file.Values)
+			assert.Equal(t, tt.wantKind, file.RootKind)
+			assert.Equal(t, tt.wantKind != "", file.Changed)
+		})
+	}
+}
+
+func TestSplitDocuments(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantFirst string
+		wantRest  string
+		wantCount int
+	}{
+		{name: "empty", data: "", wantFirst: "", wantCount: 0},
+		{name: "single document", data: "a: 1\n", wantFirst: "a: 1\n", wantCount: 1},
+		{name: "leading marker", data: "# values\n---\na: 1\n", wantFirst: "# values\n---\na: 1\n", wantCount: 1},
+		{name: "two documents", data: "a: 1\n---\nb: 2\n", wantFirst: "a: 1\n", wantRest: "---\nb: 2\n", wantCount: 2},
+		{
+			name:      "leading marker and two more documents",
+			data:      "---\na: 1\n--- # staging\nb: 2\n---\nc: 3",
+			wantFirst: "---\na: 1\n",
+			wantRest:  "--- # staging\nb: 2\n---\nc: 3",
+			wantCount: 3,
+		},
+		{name: "empty first document", data: "---\n---\nb: 2\n", wantFirst: "---\n", wantRest: "---\nb: 2\n", wantCount: 2},
+		{name: "document end marker", data: "a: 1\n...\n", wantFirst: "a: 1\n", wantRest: "...\n", wantCount: 1},
+		{name: "marker inside a block scalar", data: "script: |\n  ---\n  echo\n", wantFirst: "script: |\n  ---\n  echo\n", wantCount: 1},
+		{name: "key starting with dashes", data: "---a: 1\n", wantFirst: "---a: 1\n", wantCount: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, rest, count := splitDocuments([]byte(tt.data))
+			assert.Equal(t, tt.wantFirst, string(first))
+			assert.Equal(t, tt.wantRest, string(rest))
+			assert.Equal(t, tt.wantCount, count)
+		})
+	}
+}
+
+func TestUpdateValueFilesMultipleDocuments(t *testing.T) {
+	rest := "---\n# staging overrides\nimage:   {tag: \"2.0\"}\n---\nreplicas: 3\n"
+	dir := writeTestChart(t, "image:\n  repository: nginx\n"+rest, map[string]string{
+		"deployment.yaml": "image: {{ .Values.image.repository }}:{{ .Values.image.tag }}\nreplicas: {{ .Values.replicas | int }}\n",
+	})
+	chart := loadTestChart(t, dir)
+	file := chart.ValuesFiles[0]
+	assert.Equal(t, 3, file.Documents)
+	assert.Equal(t, map[string]any{"image": map[string]any{"repository": "nginx"}}, file.Values)
+
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+	data, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "image:\n  repository: nginx\n  tag: \"\"\nreplicas: 0\n"+rest, string(data))
+
+	// Documents are kept when the first one is rewritten instead of patched
+	chart = loadTestChart(t, dir)
+	chart.ValuesFiles[0].Values["image"] = []any{"nginx"}
+	chart.ValuesFiles[0].Changed = true
+	require.NoError(t, chart.UpdateValueFiles())
+	data, err = os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "image:\n- nginx\nreplicas: 0\n"+rest, string(data))
+}
+
 func TestChart_InjectDeploymentStrategy(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -1146,6 +1835,33 @@ spec:
 				assert.False(t, chart.ValuesFiles[0].Changed)
 			},
 		},
+		{
+			name:     "flow style deployment spec",
+			template: "flow.yaml",
+			setup: func(dir string) error {
+				content := `{apiVersion: apps/v1, kind: Deployment,
+  spec: {replicas: 1}}`
+				return os.WriteFile(filepath.Join(dir, "flow.yaml"), []byte(content), 0644)
+			},
+			validate: func(t *testing.T, chart *Chart, dir string) {
+				// A strategy cannot be injected into a flow mapping
+				assert.False(t, chart.ValuesFiles[0].Changed)
+			},
+		},
+		{
+			name:     "tab indented deployment",
+			template: "tabs.yaml",
+			setup: func(dir string) error {
+				content := "apiVersion: apps/v1\nkind:\tDeployment\nspec:\n\treplicas: 1"
+				return os.WriteFile(filepath.Join(dir, "tabs.yaml"), []byte(content), 0644)
+			},
+			validate: func(t *testing.T, chart *Chart, dir string) {
+				assert.True(t, chart.ValuesFiles[0].Changed)
+				content, err := os.ReadFile(filepath.Join(dir, "tabs.yaml"))
+				require.NoError(t, err)
+				assert.Contains(t, string(content), "spec:\n\tstrategy:\n\t\ttype: {{ .Values.deployment.strategy.type }}")
+			},
+		},
 		{
 			name:     "existing strategy",
 			template: "deployment.yaml",
@@ -1166,7 +1882,7 @@ metadata:
 					},
 				}
 				chart.ValuesFiles[0].Changed = false // Reset the changed flag
-				err := chart.injectDeploymentStrategy(filepath.Join(dir, "deployment.yaml"))
+				err := chart.inject(filepath.Join(dir, "deployment.yaml"), []Injector{DeploymentStrategyInjector{}})
 				assert.NoError(t, err)
 				assert.False(t, chart.ValuesFiles[0].Changed)
 				strategy := chart.ValuesFiles[0].Values["deployment"].(map[string]interface{})["strategy"].(map[string]interface{})
@@ -1205,7 +1921,7 @@ metadata:
 				}
 			}
 
-			err = chart.injectDeploymentStrategy(filepath.Join(tempDir, tt.template))
+			err = chart.inject(filepath.Join(tempDir, tt.template), []Injector{DeploymentStrategyInjector{}})
 			assert.NoError(t, err)
 
 			if tt.validate != nil {
@@ -1646,6 +2362,46 @@ spec:
         resources: {{- toYaml .Values.deployment.gateway.resources | nindent 10 }}
       serviceAccountName: {{ include "gateway.fullname" . }}-sa`,
 		},
+		{
+			name:     "tab indentation",
+			input:    "apiVersion: apps/v1\nkind: Deployment\nspec:\n\treplicas: 1\n\ttemplate:\n\t\tspec: {}",
+			expected: "apiVersion: apps/v1\nkind: Deployment\nspec:\n\tstrategy:\n\t\ttype: {{ .Values.deployment.strategy.type }}\n\t\trollingUpdate:\n\t\t\tmaxSurge: {{ .Values.deployment.strategy.rollingUpdate.maxSurge }}\n\t\t\tmaxUnavailable: {{ .Values.deployment.strategy.rollingUpdate.maxUnavailable }}\n\treplicas: 1\n\ttemplate:\n\t\tspec: {}",
+		},
+		{
+			name: "flow style spec",
+			input: `apiVersion: apps/v1
+kind: Deployment
+spec: {replicas: 1, selector: {matchLabels: {app: test}}}`,
+			expected: `apiVersion: apps/v1
+kind: Deployment
+spec: {replicas: 1, selector: {matchLabels: {app: test}}}`,
+		},
+		{
+			name: "deployment after another document",
+			input: `apiVersion: v1
+kind: Service
+spec:
+  ports: [{port: 80}]
+---
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  replicas: 1`,
+			expected: `apiVersion: v1
+kind: Service
+spec:
+  ports: [{port: 80}]
+---
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  strategy:
+    type: {{ .Values.deployment.strategy.type }}
+    rollingUpdate:
+      maxSurge: {{ .Values.deployment.strategy.rollingUpdate.maxSurge }}
+      maxUnavailable: {{ .Values.deployment.strategy.rollingUpdate.maxUnavailable }}
+  replicas: 1`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -1717,7 +2473,7 @@ spec:
 	require.NoError(t, os.WriteFile(valuesPath, []byte(valuesContent), 0644))
 
 	// Create chart with verbose mode
-	chart, err := NewChart(tempDir, WithVerbose(true))
+	chart, err := NewChart(tempDir, WithVerbose(true), WithDeploymentStrategyInjection(true))
 	require.NoError(t, err)
 
 	// Load values
@@ -1774,3 +2530,93 @@ spec:
 	assert.Contains(t, string(updatedContent), "maxSurge: {{ .Values.deployment.strategy.rollingUpdate.maxSurge }}", "deployment should contain maxSurge")
 	assert.Contains(t, string(updatedContent), "maxUnavailable: {{ .Values.deployment.strategy.rollingUpdate.maxUnavailable }}", "deployment should contain maxUnavailable")
 }
+
+func TestParseTemplatesStrict(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{"a.yaml": "a: {{ .Values.a\nb: {{ .Values.b }}\n"})
+
+	chart := loadTestChart(t, dir)
+	assert.Equal(t, []Diagnostic{{
+		SourceFile: filepath.Join(dir, "templates", "a.yaml"),
+		LineNumber: 1,
+		Column:     4,
+		Message:    "unclosed action",
+	}}, chart.Diagnostics)
+	assert.Len(t, chart.References, 1)
+
+	chart, err := NewChart(dir, WithStrict(true))
+	require.NoError(t, err)
+	require.NoError(t, chart.FindTemplates())
+	err = chart.ParseTemplates()
+	assert.ErrorContains(t, err, "malformed templates in strict mode")
+	assert.ErrorContains(t, err, "a.yaml:1:4: unclosed action")
+}
+
+func TestChartContextCanceled(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{"a.yaml": "a: {{ .Values.a }}\n"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	chart, err := NewChart(dir)
+	require.NoError(t, err)
+	assert.ErrorIs(t, chart.FindTemplatesContext(ctx), context.Canceled)
+	assert.Empty(t, chart.Templates)
+
+	listed, err := NewChart(dir, WithTemplates([]string{"templates/a.yaml"}))
+	require.NoError(t, err)
+	assert.ErrorIs(t, listed.FindTemplatesContext(ctx), context.Canceled)
+
+	require.NoError(t, chart.LoadValueFiles())
+	require.NoError(t, chart.FindTemplates())
+	assert.ErrorIs(t, chart.ParseTemplatesContext(ctx), context.Canceled)
+	assert.Empty(t, chart.References)
+
+	require.NoError(t, chart.ParseTemplates())
+	chart.ProcessReferences()
+	assert.ErrorIs(t, chart.UpdateValueFilesContext(ctx), context.Canceled)
+	assert.NoFileExists(t, filepath.Join(dir, "values.yaml"))
+	require.NoError(t, chart.UpdateValueFilesContext(context.Background()))
+	assert.FileExists(t, filepath.Join(dir, "values.yaml"))
+}
+
+func TestParseTemplatesLongLines(t *testing.T) {
+	blob := strings.Repeat("QUJD", 50000)
+	dir := writeTestChart(t, "", map[string]string{
+		"secret.yaml": "data: " + blob + "\r\nkey: {{ .Values.key }}\r\ncert: {{ .Values.cert }}" + blob + "{{ .Values.tail }}",
+	})
+	chart := loadTestChart(t, dir)
+	assert.Equal(t, []string{"key", "cert", "tail"}, refPaths(chart.References))
+	assert.Equal(t, 3, chart.References[2].LineNumber)
+}
+
+func TestParseTemplatesErrorMode(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{
+		"a.yaml": "a: {{ .Values.a }}\n",
+		"b.yaml": "b: {{ .Values.b\n",
+		"c.yaml": "c: {{ .Values.c }}\n",
+	})
+	parse := func(opts ...Option) (*Chart, error) {
+		chart, err := NewChart(dir, opts...)
+		require.NoError(t, err)
+		require.NoError(t, chart.FindTemplates())
+		// Templates removed after being found cannot be read
+		chart.Templates = append(chart.Templates, filepath.Join(dir, "templates", "gone.yaml"), filepath.Join(dir, "templates", "lost.yaml"))
+		return chart, chart.ParseTemplates()
+	}
+
+	_, err := parse(WithStrict(true))
+	assert.ErrorContains(t, err, "gone.yaml")
+	assert.NotContains(t, err.Error(), "lost.yaml")
+	assert.NotContains(t, err.Error(), "strict mode")
+
+	chart, err := parse(WithStrict(true), WithErrorMode(ErrorModeCollect))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.ErrorContains(t, err, "gone.yaml")
+	assert.ErrorContains(t, err, "lost.yaml")
+	assert.ErrorContains(t, err, "b.yaml:1:4: unclosed action")
+	// The templates that can be read are still parsed
+	var paths []string
+	for _, ref := range chart.References {
+		paths = append(paths, ref.Path)
+	}
+	assert.ElementsMatch(t, []string{"a", "c"}, paths)
+}
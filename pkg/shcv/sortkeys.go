@@ -0,0 +1,82 @@
+package shcv
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// sortKeys rewrites a YAML document with the keys of all its mappings sorted
+// alphabetically, keeping their comments and the indentation width of the
+// document. A document whose sorted keys would use an alias before its anchor
+// cannot be sorted.
+func sortKeys(document []byte, values map[string]any) ([]byte, error) {
+	if len(bytes.TrimSpace(document)) == 0 {
+		return document, nil
+	}
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(document, &doc); err != nil {
+		return nil, err
+	}
+	sortMappings(&doc)
+
+	var out bytes.Buffer
+	enc := yamlv3.NewEncoder(&out)
+	enc.SetIndent(detectIndent(document))
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	if !decodesTo(out.Bytes(), values) {
+		return nil, errors.New("sorting the keys would use an alias before its anchor")
+	}
+	return out.Bytes(), nil
+}
+
+// sortMappings sorts the entries of the mappings of node and its children by key.
+func sortMappings(node *yamlv3.Node) {
+	for _, child := range node.Content {
+		sortMappings(child)
+	}
+	if node.Kind != yamlv3.MappingNode {
+		return
+	}
+	entries := make([][2]*yamlv3.Node, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		entries = append(entries, [2]*yamlv3.Node{node.Content[i], node.Content[i+1]})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i][0].Value < entries[j][0].Value })
+	for i, entry := range entries {
+		node.Content[2*i], node.Content[2*i+1] = entry[0], entry[1]
+	}
+}
+
+// keysSorted reports whether the keys of all mappings of a YAML document are
+// sorted alphabetically.
+func keysSorted(document []byte) bool {
+	var doc yamlv3.Node
+	if yamlv3.Unmarshal(document, &doc) != nil {
+		return true
+	}
+	var walk func(node *yamlv3.Node) bool
+	walk = func(node *yamlv3.Node) bool {
+		if node.Kind == yamlv3.MappingNode {
+			for i := 2; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value < node.Content[i-2].Value {
+					return false
+				}
+			}
+		}
+		for _, child := range node.Content {
+			if !walk(child) {
+				return false
+			}
+		}
+		return true
+	}
+	return walk(&doc)
+}
@@ -0,0 +1,89 @@
+package shcv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestSortKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "nested mappings",
+			document: "zone: eu\nimage:\n    tag: v1\n    repository: app\nhosts:\n    - b\n    - a\n",
+			want:     "hosts:\n    - b\n    - a\nimage:\n    repository: app\n    tag: v1\nzone: eu\n",
+		},
+		{
+			name:     "comments stay with their keys",
+			document: "# the zone\nzone: eu # primary\nname: app\n",
+			want:     "name: app\n# the zone\nzone: eu # primary\n",
+		},
+		{
+			name:     "mappings in lists",
+			document: "ports:\n  - name: http\n    port: 80\n",
+			want:     "ports:\n  - name: http\n    port: 80\n",
+		},
+		{
+			name:     "empty document",
+			document: "",
+			want:     "",
+		},
+		{
+			name:     "alias before its anchor",
+			document: "b: &port 80\na: *port\n",
+			wantErr:  "alias before its anchor",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]any{}
+			require.NoError(t, yaml.Unmarshal([]byte(tt.document), &values))
+			got, err := sortKeys([]byte(tt.document), values)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+			assert.True(t, keysSorted(got))
+		})
+	}
+}
+
+func TestKeysSorted(t *testing.T) {
+	assert.True(t, keysSorted([]byte("a: 1\nb:\n  c: 2\n  d: 3\n")))
+	assert.False(t, keysSorted([]byte("a: 1\nb:\n  d: 2\n  c: 3\n")))
+	assert.False(t, keysSorted([]byte("list:\n  - b: 1\n    a: 2\n")))
+	assert.True(t, keysSorted(nil))
+}
+
+func TestSyncSortKeys(t *testing.T) {
+	dir := writeTestChart(t, "name: app\nimage:\n  tag: v1\n", map[string]string{
+		"a.yaml": "{{ .Values.image.repository }} {{ .Values.name }}\n",
+	})
+
+	chart := loadTestChart(t, dir, WithSortKeys(true))
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+	content, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "image:\n  repository: \"\"\n  tag: v1\nname: app\n", string(content))
+
+	// Files whose keys are not sorted are rewritten without missing values
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "values.yaml"), []byte("name: app\nimage:\n  tag: v1\n  repository: app\n"), 0644))
+	chart = loadTestChart(t, dir, WithSortKeys(true))
+	chart.ProcessReferences()
+	require.NoError(t, chart.UpdateValueFiles())
+	content, err = os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "image:\n  repository: app\n  tag: v1\nname: app\n", string(content))
+}
@@ -0,0 +1,125 @@
+package shcv
+
+import (
+	"context"
+	"fmt"
+)
+
+// Report is the result of Sync.
+type Report struct {
+	// Templates lists the templates found
+	Templates []string
+	// References lists the value references found in the templates
+	References []ValueRef
+	// Added maps the values files to the paths of the values added to them, or
+	// that a dry run would add, in the order they were added
+	Added map[string][]string
+	// Updated lists the values files that were written
+	Updated []string
+	// Results describes what was done to each values file, see Chart.Results
+	Results []ValueFileResult
+	// Conflicts lists the values that could not be added because a parent is
+	// defined as a scalar or a list
+	Conflicts []*ScalarConflictError
+	// DefaultConflicts lists the values given differing defaults in the templates
+	DefaultConflicts []DefaultConflict
+	// Diagnostics lists the malformed template actions
+	Diagnostics []Diagnostic
+	// Findings lists the findings of the checks, see RunChecks
+	Findings []Finding
+	// Warnings describes the problems above, and those of the values files,
+	// such as a wrapped root, as messages for display
+	Warnings []string
+	// Unchanged reports that the run was skipped because nothing changed since
+	// the last one, see WithCacheFile
+	Unchanged bool
+}
+
+// Sync runs the whole pipeline on a chart created by NewChart, which otherwise
+// takes the calls to LoadValueFiles, FindTemplates, ParseTemplates,
+// ProcessReferences and UpdateValueFiles in that order: it adds the values
+// referenced by the templates to the values files and writes them, unless in
+// dry-run mode, see Changes. The checks of RunChecks are run as well, and with
+// a cache file the run is recorded, or skipped if nothing changed. The report
+// holds the results up to the failure when an error is returned. Sync stops
+// with the error of ctx when it is canceled or its deadline expires. It resets
+// the chart first, see Reset, so it can be called again on the same chart.
+func (c *Chart) Sync(ctx context.Context) (*Report, error) {
+	c.Reset()
+	report := &Report{Added: make(map[string][]string)}
+	if err := c.LoadValueFiles(); err != nil {
+		return report, fmt.Errorf("loading values: %w", err)
+	}
+	for _, file := range c.ValuesFiles {
+		if file.RootKind != "" {
+			report.warn("%s has a %s at the root instead of a map of values; it is wrapped under %s", file.Path, file.RootKind, c.config.ValuesRootKey)
+		}
+		if file.Documents > 1 {
+			report.warn("%s holds %d YAML documents; only the first, which Helm reads, is synced", file.Path, file.Documents)
+		}
+	}
+
+	if err := c.FindTemplatesContext(ctx); err != nil {
+		return report, fmt.Errorf("finding templates: %w", err)
+	}
+	report.Templates = c.Templates
+	unchanged, err := c.Unchanged()
+	if err != nil {
+		return report, err
+	}
+	if unchanged {
+		report.Unchanged = true
+		return report, nil
+	}
+
+	err = c.ParseTemplatesContext(ctx)
+	report.References, report.Diagnostics = c.References, c.Diagnostics
+	if err != nil {
+		return report, fmt.Errorf("parsing templates: %w", err)
+	}
+	for _, d := range c.Diagnostics {
+		report.warn("%s", d)
+	}
+	report.DefaultConflicts = c.DefaultConflicts()
+	for _, conflict := range report.DefaultConflicts {
+		report.warn("%s", conflict)
+	}
+	if report.Findings, err = c.RunChecks(); err != nil {
+		return report, fmt.Errorf("checking chart: %w", err)
+	}
+	for _, finding := range report.Findings {
+		report.warn("%s", finding)
+	}
+
+	c.ProcessReferences()
+	for _, file := range c.ValuesFiles {
+		if len(file.Added) > 0 {
+			report.Added[file.Path] = file.Added
+		}
+		for _, conflict := range file.Conflicts {
+			report.Conflicts = append(report.Conflicts, conflict)
+			report.warn("%s; the value is left unchanged", conflict)
+		}
+	}
+	if c.config.DryRun {
+		return report, nil
+	}
+	if err := c.UpdateValueFilesContext(ctx); err != nil {
+		return report, fmt.Errorf("updating values: %w", err)
+	}
+	report.Results = c.Results
+	for _, file := range c.ValuesFiles {
+		if file.Changed {
+			report.Updated = append(report.Updated, file.Path)
+		}
+	}
+	if err := c.RecordRun(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// warn adds a warning to the report.
+func (r *Report) warn(format string, args ...any) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
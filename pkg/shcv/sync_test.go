@@ -0,0 +1,116 @@
+package shcv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChartSync(t *testing.T) {
+	dir := writeTestChart(t, "name: app\nservice: web\n", map[string]string{
+		"a.yaml": "name: {{ .Values.name }}\nport: {{ .Values.port | default 80 }}\nhost: {{ .Values.host\n",
+		"b.yaml": "port: {{ .Values.port | default 8080 }}\nsvc: {{ .Values.service.port }}\n",
+	})
+	valuesPath := filepath.Join(dir, "values.yaml")
+
+	chart, err := NewChart(dir)
+	require.NoError(t, err)
+	report, err := chart.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, report.Templates, 2)
+	assert.Len(t, report.References, 4)
+	assert.Equal(t, map[string][]string{valuesPath: {"port"}}, report.Added)
+	assert.Equal(t, []string{valuesPath}, report.Updated)
+	require.Len(t, report.Conflicts, 1)
+	assert.Equal(t, "service.port", report.Conflicts[0].Path)
+	require.Len(t, report.DefaultConflicts, 1)
+	assert.Equal(t, "port", report.DefaultConflicts[0].Path)
+	require.Len(t, report.Diagnostics, 1)
+	assert.Len(t, report.Warnings, 3)
+	assert.False(t, report.Unchanged)
+
+	content, err := os.ReadFile(valuesPath)
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\nservice: web\nport: 80\n", string(content))
+	assert.Equal(t, []ValueFileResult{{Path: valuesPath, Added: []string{"port"}, Written: true, BytesWritten: len(content)}}, report.Results)
+}
+
+func TestChartSyncDryRun(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{"a.yaml": "{{ .Values.port }}\n"})
+	chart, err := NewChart(dir, WithDryRun(true))
+	require.NoError(t, err)
+	report, err := chart.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{filepath.Join(dir, "values.yaml"): {"port"}}, report.Added)
+	assert.Empty(t, report.Updated)
+
+	content, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\n", string(content))
+}
+
+func TestChartSyncRepeated(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{"a.yaml": "{{ .Values.name }} {{ .Values.port }}\n"})
+	valuesPath := filepath.Join(dir, "values.yaml")
+	chart, err := NewChart(dir)
+	require.NoError(t, err)
+	report, err := chart.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{valuesPath: {"port"}}, report.Added)
+
+	// A second run starts over instead of accumulating the first one
+	report, err = chart.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, report.Templates, 1)
+	assert.Len(t, report.References, 2)
+	assert.Empty(t, report.Added)
+	assert.Empty(t, report.Updated)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "templates", "b.yaml"), []byte("{{ .Values.host }}\n"), 0644))
+	report, err = chart.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, report.Templates, 2)
+	assert.Equal(t, map[string][]string{valuesPath: {"host"}}, report.Added)
+	content, err := os.ReadFile(valuesPath)
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\nport: \"\"\nhost: \"\"\n", string(content))
+}
+
+func TestChartSyncErrors(t *testing.T) {
+	dir := writeTestChart(t, "", map[string]string{"a.yaml": "{{ .Values.port\n"})
+
+	chart, err := NewChart(dir, WithStrict(true))
+	require.NoError(t, err)
+	report, err := chart.Sync(context.Background())
+	assert.ErrorContains(t, err, "parsing templates: malformed templates in strict mode")
+	// The report holds the results up to the failure
+	assert.Len(t, report.Templates, 1)
+	assert.Len(t, report.Diagnostics, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	chart, err = NewChart(dir)
+	require.NoError(t, err)
+	_, err = chart.Sync(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoFileExists(t, filepath.Join(dir, "values.yaml"))
+}
+
+func TestChartSyncCache(t *testing.T) {
+	dir := writeTestChart(t, "name: app\n", map[string]string{"a.yaml": "{{ .Values.port }}\n"})
+	sync := func() *Report {
+		chart, err := NewChart(dir, WithCacheFile(".shcv-cache.json"))
+		require.NoError(t, err)
+		report, err := chart.Sync(context.Background())
+		require.NoError(t, err)
+		return report
+	}
+	assert.False(t, sync().Unchanged)
+	report := sync()
+	assert.True(t, report.Unchanged)
+	assert.Empty(t, report.Added)
+}
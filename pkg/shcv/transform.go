@@ -0,0 +1,397 @@
+package shcv
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// FileChange is the new content computed for a chart file by a transform.
+type FileChange struct {
+	// Path is the path of the file to change
+	Path string
+	// Before is the current content of the file (nil if it doesn't exist)
+	Before []byte
+	// After is the new content of the file
+	After []byte
+}
+
+// Diff returns the change as a unified diff with paths relative to dir.
+func (f FileChange) Diff(dir string) string {
+	name := f.Path
+	if rel, err := filepath.Rel(dir, f.Path); err == nil {
+		name = rel
+	}
+	return unifiedDiff("a/"+name, "b/"+name, diffText(f.Before), diffText(f.After))
+}
+
+// Patch returns the change as a patch in the format of git diff, with paths
+// relative to dir, so that git apply run in dir applies it. Unlike Diff, the
+// contents are compared in their own encoding, and a file that doesn't exist
+// is created by the patch.
+func (f FileChange) Patch(dir string) string {
+	name := f.Path
+	absDir, dirErr := filepath.Abs(dir)
+	absPath, pathErr := filepath.Abs(f.Path)
+	if dirErr == nil && pathErr == nil {
+		if rel, err := filepath.Rel(absDir, absPath); err == nil {
+			name = rel
+		}
+	}
+	name = filepath.ToSlash(name)
+	if f.Before != nil && bytes.Equal(f.Before, f.After) {
+		return ""
+	}
+
+	header := fmt.Sprintf("diff --git a/%s b/%s\n", name, name)
+	from := "a/" + name
+	if f.Before == nil {
+		header += "new file mode 100644\n"
+		from = "/dev/null"
+	}
+	return header + unifiedDiff(from, "b/"+name, f.Before, f.After)
+}
+
+// diffText returns file content as UTF-8 text for diffing, whatever its encoding.
+func diffText(content []byte) []byte {
+	if text, _, err := decodeText(content); err == nil {
+		return text
+	}
+	return content
+}
+
+// WriteChanges writes all changes so that either every file is updated or none is:
+// the new contents are first written and synced to temporary files next to
+// their targets, which are then renamed into place. If a rename fails, the
+// files already replaced are restored and the *RollbackError lists them.
+func WriteChanges(changes []FileChange) error {
+	writes := make([]fileWrite, 0, len(changes))
+	for _, change := range changes {
+		writes = append(writes, fileWrite{path: change.Path, data: change.After})
+	}
+	return writeFilesAtomic(writes, defaultFileMode)
+}
+
+// selectsPath reports whether path is one of the selected paths or nested below one.
+func selectsPath(selected []string, path string) bool {
+	for _, s := range selected {
+		if path == s || strings.HasPrefix(path, s+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultLiteral matches a literal default argument in a template
+const defaultLiteral = `("(?:[^"\\]|\\.)*"|'[^']*'|-?[0-9][0-9.]*|true|false)`
+
+var (
+	// pipedDefault matches a default applied with a pipe right after a reference
+	pipedDefault = regexp.MustCompile(`^\s*\|\s*default\s+` + defaultLiteral)
+	// calledDefault matches a default function call right before a reference
+	calledDefault = regexp.MustCompile(`default\s+` + defaultLiteral + `\s+$`)
+)
+
+// LiftDefaults moves the template defaults of the selected paths into the first
+// values file: every `| default "x"` (or `default "x" .Values.path`) applied to a
+// selected reference is removed from the templates and the literal is written to
+// the values file instead, unless the file already defines the path. A path
+// selects itself and every path nested below it.
+//
+// The templates must have been parsed. The changes are returned without being
+// written, and the chart's loaded values are left unchanged; use WriteChanges
+// to apply them. It is an error for a selected path to have differing defaults.
+func (c *Chart) LiftDefaults(paths []string) ([]FileChange, error) {
+	if len(c.ValuesFiles) == 0 {
+		return nil, fmt.Errorf("no values file to lift defaults into")
+	}
+
+	// Group the selected references with defaults by template
+	byTemplate := make(map[string][]ValueRef)
+	defaults := make(map[string]ValueRef)
+	for _, ref := range c.References {
+		if ref.DefaultValue == "" || !selectsPath(paths, ref.Path) {
+			continue
+		}
+		if previous, ok := defaults[ref.Path]; ok && previous.DefaultValue != ref.DefaultValue {
+			return nil, fmt.Errorf("conflicting defaults for %s: %q (%s:%d) and %q (%s:%d)",
+				ref.Path, previous.DefaultValue, previous.SourceFile, previous.LineNumber,
+				ref.DefaultValue, ref.SourceFile, ref.LineNumber)
+		}
+		defaults[ref.Path] = ref
+		byTemplate[ref.SourceFile] = append(byTemplate[ref.SourceFile], ref)
+	}
+
+	templates := make([]string, 0, len(byTemplate))
+	for template := range byTemplate {
+		templates = append(templates, template)
+	}
+	sort.Strings(templates)
+
+	var changes []FileChange
+	lifted := make(map[string]any)
+	for _, template := range templates {
+		content, err := os.ReadFile(template)
+		if err != nil {
+			return nil, fmt.Errorf("reading template %s: %w", template, err)
+		}
+
+		refs := byTemplate[template]
+		sort.Slice(refs, func(i, j int) bool { return refs[i].EndOffset > refs[j].EndOffset })
+
+		// Remove the defaults from the end of the file so earlier offsets stay valid
+		updated := string(content)
+		for _, ref := range refs {
+			var literal string
+			updated, literal = removeDefault(updated, ref)
+			if literal == "" {
+				continue
+			}
+			lifted[ref.Path] = literalValue(literal)
+		}
+
+		if updated != string(content) {
+			changes = append(changes, FileChange{Path: template, Before: content, After: []byte(updated)})
+		}
+	}
+
+	// Write the lifted literals into a copy of the base values file
+	file := c.ValuesFiles[0].editable()
+	liftedPaths := make([]string, 0, len(lifted))
+	for path := range lifted {
+		liftedPaths = append(liftedPaths, path)
+	}
+	sort.Strings(liftedPaths)
+
+	changed := false
+	for _, path := range liftedPaths {
+		if err := scalarConflict(&file, path); err != nil {
+			return nil, err
+		}
+		if !valueExists(file.Values, path) {
+			setNestedValue(file.Values, path, lifted[path])
+			changed = true
+		}
+	}
+	if changed {
+		before, err := os.ReadFile(file.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("reading values file: %w", err)
+		}
+		after, err := file.render()
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, FileChange{Path: file.Path, Before: before, After: file.encoding.encode(after)})
+	}
+
+	return changes, nil
+}
+
+// editable returns a copy of the values file whose values can be changed to
+// render a FileChange without changing the chart's loaded values.
+func (f *ValueFile) editable() ValueFile {
+	copied := *f
+	copied.Values, _ = copyValue(f.Values).(map[string]any)
+	return copied
+}
+
+// removeDefault removes the literal default applied to ref from the template
+// content and returns the updated content and the removed literal, or "" if
+// the default could not be located.
+func removeDefault(content string, ref ValueRef) (string, string) {
+	token := valuePrefix + ref.Path
+	start := ref.EndOffset - len(token)
+	if start < 0 || ref.EndOffset > len(content) || content[start:ref.EndOffset] != token {
+		return content, ""
+	}
+
+	if m := pipedDefault.FindStringSubmatchIndex(content[ref.EndOffset:]); m != nil {
+		return content[:ref.EndOffset] + content[ref.EndOffset+m[1]:], content[ref.EndOffset+m[2] : ref.EndOffset+m[3]]
+	}
+	// References through the root variable start at the $
+	if start > 0 && content[start-1] == rootPrefix[0] {
+		start--
+	}
+	if m := calledDefault.FindStringSubmatchIndex(content[:start]); m != nil {
+		return content[:m[0]] + content[start:], content[m[2]:m[3]]
+	}
+	return content, ""
+}
+
+// literalValue converts a template literal to the value written to a values file.
+// Quoted literals stay strings, other literals keep their YAML type.
+func literalValue(literal string) any {
+	switch literal[0] {
+	case '"', '\'':
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(literal[1 : len(literal)-1])
+	}
+	var value any
+	if err := yaml.Unmarshal([]byte(literal), &value); err != nil {
+		return literal
+	}
+	return value
+}
+
+// PushDefaults is the inverse of LiftDefaults: the literal values the first values
+// file defines for the selected paths are added as `| default` to every selected
+// reference that has no default yet, so that the chart also renders with an empty
+// values file. With remove set, the pushed values are removed from the values file.
+// Only scalar values are pushed; maps and lists stay in the values file.
+//
+// The templates must have been parsed. The changes are returned without being
+// written, and the chart's loaded values are left unchanged; use WriteChanges
+// to apply them.
+func (c *Chart) PushDefaults(paths []string, remove bool) ([]FileChange, error) {
+	if len(c.ValuesFiles) == 0 {
+		return nil, fmt.Errorf("no values file to push defaults from")
+	}
+	file := c.ValuesFiles[0].editable()
+
+	// Group the selected references with a literal value by template
+	byTemplate := make(map[string][]ValueRef)
+	literals := make(map[string]string)
+	for _, ref := range c.References {
+		if ref.DefaultValue != "" || !selectsPath(paths, ref.Path) {
+			continue
+		}
+		value, ok := lookupValue(file.Values, ref.Path)
+		if !ok {
+			continue
+		}
+		literal, ok := templateLiteral(value)
+		if !ok {
+			continue
+		}
+		literals[ref.Path] = literal
+		byTemplate[ref.SourceFile] = append(byTemplate[ref.SourceFile], ref)
+	}
+
+	templates := make([]string, 0, len(byTemplate))
+	for template := range byTemplate {
+		templates = append(templates, template)
+	}
+	sort.Strings(templates)
+
+	var changes []FileChange
+	// kept records the paths with a reference that could not be rewritten
+	kept := make(map[string]bool)
+	for _, template := range templates {
+		content, err := os.ReadFile(template)
+		if err != nil {
+			return nil, fmt.Errorf("reading template %s: %w", template, err)
+		}
+
+		refs := byTemplate[template]
+		sort.Slice(refs, func(i, j int) bool { return refs[i].EndOffset > refs[j].EndOffset })
+
+		// Add the defaults from the end of the file so earlier offsets stay valid
+		updated := string(content)
+		for _, ref := range refs {
+			var ok bool
+			updated, ok = addDefault(updated, ref, literals[ref.Path])
+			if !ok {
+				kept[ref.Path] = true
+			}
+		}
+
+		if updated != string(content) {
+			changes = append(changes, FileChange{Path: template, Before: content, After: []byte(updated)})
+		}
+	}
+
+	if !remove {
+		return changes, nil
+	}
+
+	pushed := make([]string, 0, len(literals))
+	for path := range literals {
+		if !kept[path] {
+			pushed = append(pushed, path)
+		}
+	}
+	if len(pushed) == 0 {
+		return changes, nil
+	}
+	sort.Strings(pushed)
+	for _, path := range pushed {
+		deleteNestedValue(file.Values, path)
+	}
+
+	before, err := os.ReadFile(file.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading values file: %w", err)
+	}
+	after, err := file.render()
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, FileChange{Path: file.Path, Before: before, After: file.encoding.encode(after)})
+	return changes, nil
+}
+
+// addDefault adds literal as the default of ref in the template content and
+// reports whether the reference could be located. A reference that starts the
+// action's pipeline and is not followed by arguments gets a `| default` pipe;
+// anywhere else it is wrapped in parentheses so the default applies to the
+// reference alone.
+func addDefault(content string, ref ValueRef, literal string) (string, bool) {
+	token := valuePrefix + ref.Path
+	start := ref.EndOffset - len(token)
+	if start < 0 || ref.EndOffset > len(content) || content[start:ref.EndOffset] != token {
+		return content, false
+	}
+	if start > 0 && content[start-1] == rootPrefix[0] {
+		start--
+	}
+
+	rest := strings.TrimLeft(content[ref.EndOffset:], " \t")
+	followedByPipe := strings.HasPrefix(rest, defaultPipe) ||
+		strings.HasPrefix(rest, closeBrace) ||
+		strings.HasPrefix(rest, trimMarker+closeBrace)
+
+	if followedByPipe && startsPipeline(content[:start]) {
+		return content[:ref.EndOffset] + " | default " + literal + content[ref.EndOffset:], true
+	}
+	return content[:start] + "(" + content[start:ref.EndOffset] + " | default " + literal + ")" +
+		content[ref.EndOffset:], true
+}
+
+// startsPipeline reports whether a reference preceded by before is the first
+// command of its action's pipeline.
+func startsPipeline(before string) bool {
+	before = strings.TrimRight(before, " \t")
+	for _, keyword := range controlKeywords {
+		if trimmed := strings.TrimSuffix(before, keyword); trimmed != before {
+			before = strings.TrimRight(trimmed, " \t")
+			break
+		}
+	}
+	before = strings.TrimSuffix(before, trimMarker)
+	return strings.HasSuffix(before, openBrace)
+}
+
+// templateLiteral formats a scalar value as a template literal.
+func templateLiteral(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return "", false
+		}
+		return strconv.Quote(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int, int64:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
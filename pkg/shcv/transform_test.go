@@ -0,0 +1,332 @@
+package shcv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiftDefaults(t *testing.T) {
+	dir := writeTestChart(t, "name: app\nimage:\n  tag: v2\n", map[string]string{
+		"deployment.yaml": `image: {{ .Values.image.repository | default "nginx" | quote }}
+tag: {{ .Values.image.tag | default "latest" }}
+port: {{ default 8080 .Values.service.port }}
+name: {{ .Values.name | default "other" }}
+`,
+		"service.yaml": "port: {{ (default 8080 .Values.service.port) | int }}\n",
+	})
+	chart := loadTestChart(t, dir)
+
+	changes, err := chart.LiftDefaults([]string{"image", "service.port"})
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+
+	assert.Equal(t, filepath.Join(dir, "templates", "deployment.yaml"), changes[0].Path)
+	assert.Equal(t, `image: {{ .Values.image.repository | quote }}
+tag: {{ .Values.image.tag }}
+port: {{ .Values.service.port }}
+name: {{ .Values.name | default "other" }}
+`, string(changes[0].After))
+
+	assert.Equal(t, filepath.Join(dir, "templates", "service.yaml"), changes[1].Path)
+	assert.Equal(t, "port: {{ (.Values.service.port) | int }}\n", string(changes[1].After))
+
+	// Existing values are kept, missing ones are lifted with their literal type
+	// and appended to their parent
+	assert.Equal(t, filepath.Join(dir, "values.yaml"), changes[2].Path)
+	assert.Equal(t, "name: app\nimage:\n  tag: v2\n  repository: nginx\nservice:\n  port: 8080\n", string(changes[2].After))
+	assert.Contains(t, changes[2].Diff(dir), "--- a/values.yaml\n+++ b/values.yaml\n")
+
+	// Nothing is written until the changes are applied
+	content, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: app\nimage:\n  tag: v2\n", string(content))
+
+	require.NoError(t, WriteChanges(changes))
+	for _, change := range changes {
+		content, err := os.ReadFile(change.Path)
+		require.NoError(t, err)
+		assert.Equal(t, string(change.After), string(content))
+	}
+}
+
+func TestTransformsCRLF(t *testing.T) {
+	dir := writeTestChart(t, "image:\n  tag: v2\n", map[string]string{
+		"deployment.yaml": "kind: Deployment\r\ntag: {{ .Values.image.tag }}\r\nport: {{ .Values.port | default 8080 }}\r\n",
+	})
+	path := filepath.Join(dir, "templates", "deployment.yaml")
+
+	t.Run("lift", func(t *testing.T) {
+		changes, err := loadTestChart(t, dir).LiftDefaults([]string{"port"})
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		assert.Equal(t, path, changes[0].Path)
+		assert.Equal(t, "kind: Deployment\r\ntag: {{ .Values.image.tag }}\r\nport: {{ .Values.port }}\r\n", string(changes[0].After))
+		assert.Equal(t, "image:\n  tag: v2\nport: 8080\n", string(changes[1].After))
+	})
+
+	t.Run("push", func(t *testing.T) {
+		changes, err := loadTestChart(t, dir).PushDefaults([]string{"image.tag"}, false)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		assert.Equal(t, "kind: Deployment\r\ntag: {{ .Values.image.tag | default \"v2\" }}\r\nport: {{ .Values.port | default 8080 }}\r\n", string(changes[0].After))
+	})
+}
+
+func TestTransformsKeepLoadedValues(t *testing.T) {
+	dir := writeTestChart(t, "image:\n  tag: v2\nreplicas: 1\n", map[string]string{
+		"deployment.yaml": "tag: {{ .Values.image.tag }}\nport: {{ .Values.port | default 8080 }}\nreplicas: {{ .Values.replicas }}\n",
+	})
+	transforms := map[string]func(c *Chart) ([]FileChange, error){
+		"lift": func(c *Chart) ([]FileChange, error) { return c.LiftDefaults([]string{"port"}) },
+		"push": func(c *Chart) ([]FileChange, error) { return c.PushDefaults([]string{"image"}, true) },
+		"rename": func(c *Chart) ([]FileChange, error) {
+			return c.RenameValues(map[string]string{"replicas": "replicaCount"})
+		},
+	}
+	for name, transform := range transforms {
+		t.Run(name, func(t *testing.T) {
+			chart := loadTestChart(t, dir)
+			want := copyValue(chart.ValuesFiles[0].Values)
+			changes, err := transform(chart)
+			require.NoError(t, err)
+			require.NotEmpty(t, changes)
+			assert.Equal(t, want, chart.ValuesFiles[0].Values)
+		})
+	}
+}
+
+func TestLiftDefaultsErrors(t *testing.T) {
+	t.Run("conflicting defaults", func(t *testing.T) {
+		dir := writeTestChart(t, "", map[string]string{
+			"a.yaml": `{{ .Values.tag | default "a" }}`,
+			"b.yaml": `{{ .Values.tag | default "b" }}`,
+		})
+		chart := loadTestChart(t, dir)
+		_, err := chart.LiftDefaults([]string{"tag"})
+		assert.ErrorContains(t, err, "conflicting defaults for tag")
+	})
+
+	t.Run("scalar parent", func(t *testing.T) {
+		dir := writeTestChart(t, "service: web\n", map[string]string{"a.yaml": `{{ .Values.service.port | default 80 }}`})
+		chart := loadTestChart(t, dir)
+		_, err := chart.LiftDefaults([]string{"service"})
+		var conflict *ScalarConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "service.port", conflict.Path)
+	})
+
+	t.Run("no values file", func(t *testing.T) {
+		chart := &Chart{config: defaultConfig()}
+		_, err := chart.LiftDefaults([]string{"tag"})
+		assert.Error(t, err)
+	})
+
+	t.Run("nothing selected", func(t *testing.T) {
+		dir := writeTestChart(t, "", map[string]string{"a.yaml": `{{ .Values.tag | default "a" }}`})
+		chart := loadTestChart(t, dir)
+		changes, err := chart.LiftDefaults([]string{"image"})
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+}
+
+func TestRemoveDefault(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantContent string
+		wantLiteral string
+	}{
+		{name: "piped string", content: `{{ .Values.a | default "x y" }}`, wantContent: `{{ .Values.a }}`, wantLiteral: `"x y"`},
+		{name: "piped escaped string", content: `{{ .Values.a | default "say \"hi\"" }}`, wantContent: `{{ .Values.a }}`, wantLiteral: `"say \"hi\""`},
+		{name: "piped number", content: `{{ .Values.a | default 3 -}}`, wantContent: `{{ .Values.a -}}`, wantLiteral: "3"},
+		{name: "called bool", content: `{{ default true .Values.a }}`, wantContent: `{{ .Values.a }}`, wantLiteral: "true"},
+		{name: "called on root reference", content: `{{ default "x" $.Values.a }}`, wantContent: `{{ $.Values.a }}`, wantLiteral: `"x"`},
+		{name: "piped on root reference", content: `{{ $.Values.a | default "x" }}`, wantContent: `{{ $.Values.a }}`, wantLiteral: `"x"`},
+		{name: "non-literal default", content: `{{ .Values.a | default .Values.b }}`, wantContent: `{{ .Values.a | default .Values.b }}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refs := ParseFile(tt.content, "test.yaml")
+			require.NotEmpty(t, refs)
+			content, literal := removeDefault(tt.content, refs[0])
+			assert.Equal(t, tt.wantContent, content)
+			assert.Equal(t, tt.wantLiteral, literal)
+		})
+	}
+
+	t.Run("stale offsets", func(t *testing.T) {
+		content, literal := removeDefault("{{ .Values.a }}", ValueRef{Path: "b", EndOffset: 12})
+		assert.Equal(t, "{{ .Values.a }}", content)
+		assert.Empty(t, literal)
+	})
+}
+
+func TestLiteralValue(t *testing.T) {
+	assert.Equal(t, "x", literalValue(`"x"`))
+	assert.Equal(t, `say "hi"`, literalValue(`"say \"hi\""`))
+	assert.Equal(t, "8080", literalValue(`'8080'`))
+	assert.Equal(t, float64(8080), literalValue("8080"))
+	assert.Equal(t, true, literalValue("true"))
+}
+
+func TestWriteChanges(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.yaml")
+	require.NoError(t, os.WriteFile(existing, []byte("old\n"), 0600))
+
+	changes := []FileChange{
+		{Path: existing, Before: []byte("old\n"), After: []byte("new\n")},
+		{Path: filepath.Join(dir, "created.yaml"), After: []byte("created\n")},
+	}
+	require.NoError(t, WriteChanges(changes))
+
+	content, err := os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(content))
+	info, err := os.Stat(existing)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	content, err = os.ReadFile(filepath.Join(dir, "created.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "created\n", string(content))
+
+	t.Run("staging failure leaves files untouched", func(t *testing.T) {
+		err := WriteChanges([]FileChange{
+			{Path: existing, After: []byte("changed\n")},
+			{Path: filepath.Join(dir, "missing", "file.yaml"), After: []byte("x\n")},
+		})
+		assert.ErrorContains(t, err, "staging")
+
+		content, err := os.ReadFile(existing)
+		require.NoError(t, err)
+		assert.Equal(t, "new\n", string(content))
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2, "temporary files should be removed")
+	})
+}
+
+func TestPushDefaults(t *testing.T) {
+	dir := writeTestChart(t, "name: app\nimage:\n  repository: nginx\n  tag: \"1.25\"\nservice:\n  port: 8080\n  labels:\n    a: b\n", map[string]string{
+		"deployment.yaml": `image: {{ .Values.image.repository }}:{{ .Values.image.tag | quote }}
+port: {{ int .Values.service.port }}
+{{- if .Values.service.port }}
+labels: {{ toYaml .Values.service.labels }}
+{{- end }}
+name: {{ .Values.name | default "other" }}
+`,
+	})
+	chart := loadTestChart(t, dir)
+
+	changes, err := chart.PushDefaults([]string{"image", "service", "name"}, true)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+
+	assert.Equal(t, filepath.Join(dir, "templates", "deployment.yaml"), changes[0].Path)
+	assert.Equal(t, `image: {{ .Values.image.repository | default "nginx" }}:{{ .Values.image.tag | default "1.25" | quote }}
+port: {{ int (.Values.service.port | default 8080) }}
+{{- if .Values.service.port | default 8080 }}
+labels: {{ toYaml .Values.service.labels }}
+{{- end }}
+name: {{ .Values.name | default "other" }}
+`, string(changes[0].After))
+
+	// Maps and values whose reference already has a default stay in the values file
+	assert.Equal(t, filepath.Join(dir, "values.yaml"), changes[1].Path)
+	assert.Equal(t, "name: app\nservice:\n  labels:\n    a: b\n", string(changes[1].After))
+
+	// The pushed defaults are parsed back as defaults
+	refs := ParseFile(string(changes[0].After), "deployment.yaml")
+	defaults := make(map[string]string)
+	for _, ref := range refs {
+		defaults[ref.Path] = ref.DefaultValue
+	}
+	assert.Equal(t, "nginx", defaults["image.repository"])
+	assert.Equal(t, "1.25", defaults["image.tag"])
+	assert.Equal(t, "8080", defaults["service.port"])
+
+	t.Run("keep values", func(t *testing.T) {
+		chart := loadTestChart(t, dir)
+		changes, err := chart.PushDefaults([]string{"image.tag"}, false)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		assert.Contains(t, string(changes[0].After), `{{ .Values.image.tag | default "1.25" | quote }}`)
+	})
+
+	t.Run("no values file", func(t *testing.T) {
+		chart := &Chart{config: defaultConfig()}
+		_, err := chart.PushDefaults([]string{"tag"}, false)
+		assert.Error(t, err)
+	})
+}
+
+func TestAddDefault(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "alone", content: `{{ .Values.a }}`, want: `{{ .Values.a | default "x" }}`},
+		{name: "trimmed", content: `{{- .Values.a -}}`, want: `{{- .Values.a | default "x" -}}`},
+		{name: "followed by pipe", content: `{{ .Values.a | upper }}`, want: `{{ .Values.a | default "x" | upper }}`},
+		{name: "control keyword", content: `{{ with .Values.a }}`, want: `{{ with .Values.a | default "x" }}`},
+		{name: "function argument", content: `{{ quote .Values.a }}`, want: `{{ quote (.Values.a | default "x") }}`},
+		{name: "followed by argument", content: `{{ eq .Values.a "y" }}`, want: `{{ eq (.Values.a | default "x") "y" }}`},
+		{name: "root reference", content: `{{ quote $.Values.a }}`, want: `{{ quote ($.Values.a | default "x") }}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refs := ParseFile(tt.content, "test.yaml")
+			require.NotEmpty(t, refs)
+			content, ok := addDefault(tt.content, refs[0], `"x"`)
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, content)
+		})
+	}
+}
+
+func TestTemplateLiteral(t *testing.T) {
+	for value, want := range map[any]string{"a \"b\"": `"a \"b\""`, true: "true", float64(8080): "8080", 0.5: "0.5"} {
+		got, ok := templateLiteral(value)
+		assert.True(t, ok)
+		assert.Equal(t, want, got)
+	}
+	for _, value := range []any{"", nil, map[string]any{}, []any{}} {
+		_, ok := templateLiteral(value)
+		assert.False(t, ok)
+	}
+}
+
+func TestFileChangePatch(t *testing.T) {
+	dir := t.TempDir()
+	changed := FileChange{Path: filepath.Join(dir, "chart", "values.yaml"), Before: []byte("a: 1"), After: []byte("a: 1\nb: 2\n")}
+	assert.Equal(t, `diff --git a/chart/values.yaml b/chart/values.yaml
+--- a/chart/values.yaml
++++ b/chart/values.yaml
+@@ -1 +1,2 @@
+-a: 1
+\ No newline at end of file
++a: 1
++b: 2
+`, changed.Patch(dir))
+
+	created := FileChange{Path: filepath.Join(dir, "values-dev.yaml"), After: []byte("b: 2\n")}
+	assert.Equal(t, `diff --git a/values-dev.yaml b/values-dev.yaml
+new file mode 100644
+--- /dev/null
++++ b/values-dev.yaml
+@@ -0,0 +1 @@
++b: 2
+`, created.Patch(dir))
+
+	assert.Empty(t, FileChange{Path: "values.yaml", Before: []byte("a: 1\n"), After: []byte("a: 1\n")}.Patch(dir))
+}
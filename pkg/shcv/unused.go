@@ -0,0 +1,243 @@
+package shcv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+var (
+	// valuesRoot matches the values root used as a whole, such as in toYaml
+	// .Values or include "name" $.Values, rather than one of its keys
+	valuesRoot = regexp.MustCompile(`(^|[^\w.$])\$?\.Values($|[^\w.])`)
+	// rootIndexKey matches the values root indexed with a literal key, which
+	// the parser reads as a reference to that key
+	rootIndexKey = regexp.MustCompile(`\bindex\s+\$?\.Values\s+["']`)
+	// chartVersionSuffix matches the -<semver>.tgz suffix of a packaged chart,
+	// such as the -12.1.0.tgz of postgresql-12.1.0.tgz
+	chartVersionSuffix = regexp.MustCompile(`-v?\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?\.tgz$`)
+)
+
+// ValuePath is a value defined in a values file.
+type ValuePath struct {
+	// Path is the dot-notation path of the value
+	Path string `json:"path"`
+	// File is the values file defining the value
+	File string `json:"file"`
+	// LineNumber is the line of the value's key in the file
+	LineNumber int `json:"line"`
+	// PossiblyUsed indicates that no template references the value, but it may
+	// still be read: by a template string in a values file rendered with tpl,
+	// or by a template using .Values as a whole
+	PossiblyUsed bool `json:"possiblyUsed,omitempty"`
+}
+
+// valueUsage records which value paths the templates use.
+type valueUsage struct {
+	// referenced are the paths the templates reference
+	referenced map[string]bool
+	// possibly are the paths referenced by template strings in values files
+	possibly map[string]bool
+	// root records that a template uses .Values as a whole
+	root bool
+}
+
+// usage returns the paths the templates of the chart use.
+func (c *Chart) usage() valueUsage {
+	u := valueUsage{
+		referenced: make(map[string]bool),
+		possibly:   make(map[string]bool),
+		root:       c.valuesRootUsed,
+	}
+	for _, ref := range c.References {
+		u.referenced[ref.Path] = true
+	}
+
+	// Values holding template strings are usually rendered with tpl, which
+	// makes the values they reference reachable without a template reference
+	for _, file := range c.ValuesFiles {
+		for _, s := range templateStrings(file.Values) {
+			for _, ref := range ParseFile(s, file.Path) {
+				u.possibly[ref.Path] = true
+			}
+		}
+	}
+	return u
+}
+
+// usesValuesRoot reports whether template content uses .Values as a whole.
+func usesValuesRoot(content string) bool {
+	for _, action := range templateActions(content) {
+		if valuesRoot.MatchString(rootIndexKey.ReplaceAllString(action, "")) {
+			return true
+		}
+	}
+	return false
+}
+
+// usesPath reports whether paths use path: when they include it, a value nested
+// below it, or a parent using it as a whole, such as toYaml .Values.resources.
+func usesPath(paths map[string]bool, path string) bool {
+	if paths[path] {
+		return true
+	}
+	for referenced := range paths {
+		if strings.HasPrefix(referenced, path+".") || strings.HasPrefix(path, referenced+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// UnusedValues returns the values the values files define that no template
+// references, including keys nested in used maps such as image.tag next to a
+// referenced image.repository, in file and document order. A map that is not
+// used at all is returned once, at its key. Values below a value the templates
+// use as a whole, such as resources passed to toYaml, are used. Values that may
+// still be read in ways the templates don't show are marked
+// ValuePath.PossiblyUsed.
+//
+// The values files must have been loaded and the templates parsed. The chart is
+// not modified; PruneUnused removes the unused values.
+func (c *Chart) UnusedValues() []ValuePath {
+	u := c.usage()
+
+	var unused []ValuePath
+	for _, file := range c.ValuesFiles {
+		var parent string // the last unused path, whose nested keys are skipped
+		for _, key := range valueKeys(file.source) {
+			if parent != "" && strings.HasPrefix(key.path, parent+".") || !valueExists(file.Values, key.path) {
+				continue
+			}
+			if usesPath(u.referenced, key.path) {
+				continue
+			}
+			parent = key.path
+			unused = append(unused, ValuePath{
+				Path:         key.path,
+				File:         file.Path,
+				LineNumber:   key.line,
+				PossiblyUsed: u.root || usesPath(u.possibly, key.path),
+			})
+		}
+	}
+	return unused
+}
+
+// PruneUnused removes the values no template references from the values files,
+// and returns them. Values that may still be read are kept, see
+// ValuePath.PossiblyUsed, as are the protected paths: global, which Helm shares
+// with subcharts, the values of the subcharts, see subcharts, and the paths
+// configured with WithProtectedPaths. The changes are written by
+// UpdateValueFiles.
+//
+// The templates must have been parsed. Pruning fails when only some templates
+// are scanned, or when templates have malformed actions, as the references
+// missed would be pruned.
+func (c *Chart) PruneUnused() ([]ValuePath, error) {
+	if len(c.config.Templates) > 0 || len(c.config.ExcludePatterns) > 0 {
+		return nil, errors.New("cannot prune unused values when only some templates are scanned")
+	}
+	if len(c.Diagnostics) > 0 {
+		return nil, fmt.Errorf("cannot prune unused values: %d malformed template actions may hide references", len(c.Diagnostics))
+	}
+
+	unused := c.UnusedValues()
+	protected := append([]string{"global"}, c.config.ProtectedPaths...)
+	subcharts, err := c.subcharts()
+	if err != nil {
+		return nil, fmt.Errorf("cannot prune unused values: %w", err)
+	}
+	protected = append(protected, subcharts...)
+
+	var pruned []ValuePath
+	for _, value := range unused {
+		if value.PossiblyUsed || selectsPath(protected, value.Path) {
+			continue
+		}
+		for i := range c.ValuesFiles {
+			if file := &c.ValuesFiles[i]; file.Path == value.File {
+				deleteNestedValue(file.Values, value.Path)
+				file.Changed = true
+			}
+		}
+		pruned = append(pruned, value)
+	}
+	return pruned, nil
+}
+
+// subcharts returns the names of the subcharts, whose values are read by the
+// subcharts rather than by the chart's templates: the directories and packaged
+// charts of the charts directory, and the dependencies of Chart.yaml by name
+// and alias, which may not have been fetched yet.
+func (c *Chart) subcharts() ([]string, error) {
+	var names []string
+	entries, _ := os.ReadDir(filepath.Join(c.Dir, "charts"))
+	for _, entry := range entries {
+		switch name := entry.Name(); {
+		case entry.IsDir():
+			names = append(names, JoinValuePath(name))
+		case chartVersionSuffix.MatchString(name):
+			names = append(names, JoinValuePath(chartVersionSuffix.ReplaceAllString(name, "")))
+		case strings.HasSuffix(name, ".tgz"):
+			names = append(names, JoinValuePath(strings.TrimSuffix(name, ".tgz")))
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.Dir, "Chart.yaml"))
+	if os.IsNotExist(err) {
+		return names, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading chart dependencies: %w", err)
+	}
+	var metadata struct {
+		Dependencies []struct {
+			Name  string `yaml:"name"`
+			Alias string `yaml:"alias"`
+		} `yaml:"dependencies"`
+	}
+	if err := yamlv3.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("reading chart dependencies: %w", err)
+	}
+	for _, dependency := range metadata.Dependencies {
+		for _, name := range []string{dependency.Name, dependency.Alias} {
+			if name != "" {
+				names = append(names, JoinValuePath(name))
+			}
+		}
+	}
+	return names, nil
+}
+
+// templateStrings returns the strings holding template actions among values,
+// sorted.
+func templateStrings(values any) []string {
+	var strs []string
+	var walk func(value any)
+	walk = func(value any) {
+		switch value := value.(type) {
+		case string:
+			if strings.Contains(value, openBrace) {
+				strs = append(strs, value)
+			}
+		case map[string]any:
+			for _, v := range value {
+				walk(v)
+			}
+		case []any:
+			for _, v := range value {
+				walk(v)
+			}
+		}
+	}
+	walk(values)
+	sort.Strings(strs)
+	return strs
+}
@@ -0,0 +1,154 @@
+package shcv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneUnused(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     string
+		templates  map[string]string
+		subchart   string
+		chartYAML  string
+		opts       []Option
+		wantPruned []string
+		wantValues string
+		wantErr    string
+	}{
+		{
+			name:       "unused keys",
+			values:     "# The image\nimage:\n  repository: nginx\n  tag: v1 # unused\nlegacy:\n  enabled: true\n  port: 80\nreplicas: 1\n",
+			templates:  map[string]string{"a.yaml": "image: {{ .Values.image.repository }}\nreplicas: {{ .Values.replicas }}\n"},
+			wantPruned: []string{"image.tag", "legacy"},
+			wantValues: "# The image\nimage:\n  repository: nginx\nreplicas: 1\n",
+		},
+		{
+			name:      "values used as a whole",
+			values:    "resources:\n  limits:\n    cpu: 1\npodAnnotations:\n  team: web\n",
+			templates: map[string]string{"a.yaml": "{{ toYaml .Values.resources }}\n{{- range $k, $v := .Values.podAnnotations }}{{ $k }}{{ end }}\n"},
+		},
+		{
+			name:       "values reachable through tpl are kept",
+			values:     "config: |\n  host: {{ .Values.db.host }}\ndb:\n  host: localhost\n  port: 5432\nunused: 1\n",
+			templates:  map[string]string{"a.yaml": "{{ tpl .Values.config . }}\n"},
+			wantPruned: []string{"unused"},
+			wantValues: "config: |\n  host: {{ .Values.db.host }}\ndb:\n  host: localhost\n  port: 5432\n",
+		},
+		{
+			name:      "values root used as a whole",
+			values:    "name: app\nunused: 1\n",
+			templates: map[string]string{"a.yaml": "{{ include \"app.labels\" .Values }}\nname: {{ index .Values \"name\" }}\n"},
+		},
+		{
+			name:       "protected paths",
+			values:     "global:\n  registry: docker.io\nredis:\n  enabled: true\nci:\n  token: x\nunused: 1\n",
+			templates:  map[string]string{"a.yaml": "{{ .Values.name }}\n"},
+			subchart:   "redis",
+			opts:       []Option{WithProtectedPaths("ci.*")},
+			wantPruned: []string{"unused"},
+			wantValues: "global:\n  registry: docker.io\nredis:\n  enabled: true\nci:\n  token: x\n",
+		},
+		{
+			name:       "packaged subchart",
+			values:     "postgresql:\n  auth:\n    password: x\nunused: 1\n",
+			templates:  map[string]string{"a.yaml": "{{ .Values.name }}\n"},
+			subchart:   "postgresql-12.1.0.tgz",
+			wantPruned: []string{"unused"},
+			wantValues: "postgresql:\n  auth:\n    password: x\n",
+		},
+		{
+			name:       "dependencies not fetched",
+			values:     "postgresql:\n  enabled: true\ncache:\n  enabled: true\nunused: 1\n",
+			templates:  map[string]string{"a.yaml": "{{ .Values.name }}\n"},
+			chartYAML:  "apiVersion: v2\nname: app\ndependencies:\n  - name: postgresql\n    version: 12.1.0\n  - name: redis\n    alias: cache\n",
+			wantPruned: []string{"unused"},
+			wantValues: "postgresql:\n  enabled: true\ncache:\n  enabled: true\n",
+		},
+		{
+			name:      "invalid Chart.yaml",
+			values:    "unused: 1\n",
+			templates: map[string]string{"a.yaml": "{{ .Values.name }}\n"},
+			chartYAML: "dependencies: [\n",
+			wantErr:   "cannot prune unused values: reading chart dependencies: yaml: line 1: did not find expected node content",
+		},
+		{
+			name:       "dotted keys",
+			values:     "annotations:\n  example.com/owner: web\n  example.com/unused: x\n",
+			templates:  map[string]string{"a.yaml": "{{ index .Values.annotations \"example.com/owner\" }}\n"},
+			wantPruned: []string{`annotations."example.com/unused"`},
+			wantValues: "annotations:\n  example.com/owner: web\n",
+		},
+		{
+			name:      "some templates scanned",
+			values:    "unused: 1\n",
+			templates: map[string]string{"a.yaml": "{{ .Values.name }}\n"},
+			opts:      []Option{WithTemplates([]string{"templates/a.yaml"})},
+			wantErr:   "cannot prune unused values when only some templates are scanned",
+		},
+		{
+			name:      "malformed templates",
+			values:    "unused: 1\n",
+			templates: map[string]string{"a.yaml": "{{ .Values.name\n"},
+			wantErr:   "cannot prune unused values: 1 malformed template actions may hide references",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestChart(t, tt.values, tt.templates)
+			if strings.HasSuffix(tt.subchart, ".tgz") {
+				require.NoError(t, os.MkdirAll(filepath.Join(dir, "charts"), 0755))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "charts", tt.subchart), nil, 0644))
+			} else if tt.subchart != "" {
+				require.NoError(t, os.MkdirAll(filepath.Join(dir, "charts", tt.subchart), 0755))
+			}
+			if tt.chartYAML != "" {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte(tt.chartYAML), 0644))
+			}
+			chart := loadTestChart(t, dir, tt.opts...)
+			pruned, err := chart.PruneUnused()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var paths []string
+			for _, value := range pruned {
+				assert.Equal(t, filepath.Join(dir, "values.yaml"), value.File)
+				paths = append(paths, value.Path)
+			}
+			assert.Equal(t, tt.wantPruned, paths)
+
+			require.NoError(t, chart.UpdateValueFiles())
+			content, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+			require.NoError(t, err)
+			want := tt.wantValues
+			if want == "" {
+				want = tt.values
+			}
+			assert.Equal(t, want, string(content))
+		})
+	}
+}
+
+func TestUnusedValues(t *testing.T) {
+	dir := writeTestChart(t, "name: app\nimage:\n  tag: v1\nconfig: '{{ .Values.extra }}'\nextra:\n  a: 1\n", map[string]string{
+		"a.yaml": "{{ .Values.name }} {{ .Values.image.repository }} {{ tpl .Values.config . }}\n",
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "values-prod.yaml"), []byte("name: prod\nreplicas: 3\n"), 0644))
+	chart := loadTestChart(t, dir, WithValuesFileNames([]string{"values-prod.yaml"}))
+
+	assert.Equal(t, []ValuePath{
+		{Path: "image.tag", File: filepath.Join(dir, "values.yaml"), LineNumber: 3},
+		{Path: "extra", File: filepath.Join(dir, "values.yaml"), LineNumber: 5, PossiblyUsed: true},
+		{Path: "replicas", File: filepath.Join(dir, "values-prod.yaml"), LineNumber: 2},
+	}, chart.UnusedValues())
+}
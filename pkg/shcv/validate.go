@@ -0,0 +1,86 @@
+package shcv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnresolvedValue is a value path that references without a default read while
+// no values file gives it a value, see Chart.Validate.
+type UnresolvedValue struct {
+	// Path is the value path, such as image.tag
+	Path string
+	// Refs holds the references to the path without a default, in the order
+	// they were found
+	Refs []ValueRef
+}
+
+// String formats the unresolved value with the location of every reference.
+func (u UnresolvedValue) String() string {
+	locations := make([]string, 0, len(u.Refs))
+	for _, ref := range u.Refs {
+		locations = append(locations, fmt.Sprintf("%s:%d", ref.SourceFile, ref.LineNumber))
+	}
+	return fmt.Sprintf(".Values.%s has no value and no default (%s)", u.Path, strings.Join(locations, ", "))
+}
+
+// Validate returns the value paths that do not resolve: the paths referenced
+// without a default that the values files, merged in order with the later
+// files overriding the earlier ones as by MergeValues, leave undefined or null. They are listed
+// in the order the paths were first referenced. A reference whose default is
+// another value, as in {{ .Values.b | default .Values.a }}, resolves when that
+// value does, either from the values files or through its own default.
+// Ignored references are left out, see IgnoreDirective. Validate modifies nothing; the values files must
+// have been loaded and the templates parsed.
+func (c *Chart) Validate() []UnresolvedValue {
+	layers := make([]map[string]any, 0, len(c.ValuesFiles))
+	for _, file := range c.ValuesFiles {
+		layers = append(layers, file.Values)
+	}
+	values := MergeValues(layers...)
+
+	index := c.Index()
+	var unresolved []UnresolvedValue
+	for _, path := range index.Paths {
+		var refs []ValueRef
+		for _, ref := range index.Values[path].Occurrences {
+			if !ref.Ignored && !ref.HasDefault && !defaultResolves(index, values, ref.DefaultPath, nil) {
+				refs = append(refs, ref)
+			}
+		}
+		if len(refs) == 0 {
+			continue
+		}
+		if _, ok := lookupValue(values, path); ok {
+			continue
+		}
+		unresolved = append(unresolved, UnresolvedValue{Path: path, Refs: refs})
+	}
+	return unresolved
+}
+
+// defaultResolves reports whether the value path a default is taken from
+// resolves: values defines it, or one of its references has a default that
+// resolves. Seen holds the paths already followed, which ends default cycles.
+func defaultResolves(index *ReferenceIndex, values map[string]any, path string, seen map[string]bool) bool {
+	if path == "" || seen[path] {
+		return false
+	}
+	if _, ok := lookupValue(values, path); ok {
+		return true
+	}
+	value, ok := index.Lookup(path)
+	if !ok {
+		return false
+	}
+	if seen == nil {
+		seen = make(map[string]bool)
+	}
+	seen[path] = true
+	for _, ref := range value.Occurrences {
+		if ref.HasDefault || defaultResolves(index, values, ref.DefaultPath, seen) {
+			return true
+		}
+	}
+	return false
+}
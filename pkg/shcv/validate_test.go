@@ -0,0 +1,62 @@
+package shcv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	dir := writeTestChart(t, "name: app\nimage:\n  tag: \"1.0\"\nport: 80\n", map[string]string{
+		"a.yaml": "name: {{ .Values.name }}\ntag: {{ .Values.image.tag }}\nport: {{ .Values.port }}\n" +
+			"replicas: {{ .Values.replicas | default 1 }}\n",
+		"b.yaml": "host: {{ .Values.host }}\nhost: {{ .Values.host | default \"localhost\" }}\n" +
+			"secret: {{ .Values.secret }} # shcv:ignore\nregion: {{ .Values.region }}\n",
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "values-prod.yaml"), []byte("port: null\nregion: eu\n"), 0644))
+	values := filepath.Join(dir, "values.yaml")
+	before, err := os.ReadFile(values)
+	require.NoError(t, err)
+
+	chart := loadTestChart(t, dir, WithValuesFileNames([]string{"values-prod.yaml"}))
+	unresolved := chart.Validate()
+	require.Len(t, unresolved, 2)
+
+	// A later values file overriding a value with null unsets it
+	assert.Equal(t, "port", unresolved[0].Path)
+	assert.Equal(t, ".Values.port has no value and no default ("+filepath.Join(dir, "templates", "a.yaml")+":3)", unresolved[0].String())
+
+	// Only the references without a default are listed
+	assert.Equal(t, "host", unresolved[1].Path)
+	require.Len(t, unresolved[1].Refs, 1)
+	assert.Equal(t, 1, unresolved[1].Refs[0].LineNumber)
+
+	// Nothing is modified
+	assert.False(t, chart.ValuesFiles[0].Changed)
+	after, err := os.ReadFile(values)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+}
+
+func TestValidateDefaults(t *testing.T) {
+	dir := writeTestChart(t, "fallback: x\n", map[string]string{
+		"a.yaml": "empty: {{ .Values.empty | default \"\" }}\n" +
+			"empty: {{ default \"\" .Values.prefix }}\n" +
+			"fromValue: {{ .Values.fromValue | default .Values.fallback }}\n" +
+			"chained: {{ .Values.chained | default (.Values.middle | default \"y\") }}\n" +
+			"missing: {{ .Values.missing | default .Values.unset }}\n" +
+			"cycle: {{ .Values.cycleA | default .Values.cycleB }}{{ .Values.cycleB | default .Values.cycleA }}\n",
+	})
+	chart := loadTestChart(t, dir)
+
+	var paths []string
+	for _, value := range chart.Validate() {
+		paths = append(paths, value.Path)
+	}
+	// An explicit empty default resolves, as does a default taken from a value
+	// defined in the values files or given a default of its own
+	assert.Equal(t, []string{"missing", "unset", "cycleA", "cycleB"}, paths)
+}
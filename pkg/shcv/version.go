@@ -1,4 +1,43 @@
 package shcv
 
+import (
+	"runtime"
+	"runtime/debug"
+)
+
 // Version is the current version of shcv
 const Version = "1.0.7"
+
+// commit and date are the VCS revision and the build date, set at build time
+// with -ldflags "-X github.com/agentstation/shcv/pkg/shcv.commit=...".
+var (
+	commit string
+	date   string
+)
+
+// BuildInfo describes the build of shcv, for support and debugging.
+type BuildInfo struct {
+	Version string `json:"version"`
+	// Commit is the VCS revision shcv was built from, empty when unknown
+	Commit string `json:"commit,omitempty"`
+	// Date is the build date, or the commit date when not set at build time
+	Date      string `json:"date,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+// GetBuildInfo returns the build information of shcv. The commit and date
+// set with ldflags win over the VCS information recorded by the Go toolchain.
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{Version: Version, Commit: commit, Date: date, GoVersion: runtime.Version()}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.Date == "":
+				info.Date = setting.Value
+			}
+		}
+	}
+	return info
+}
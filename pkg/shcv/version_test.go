@@ -0,0 +1,21 @@
+package shcv
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBuildInfo(t *testing.T) {
+	info := GetBuildInfo()
+	assert.Equal(t, Version, info.Version)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+
+	oldCommit, oldDate := commit, date
+	t.Cleanup(func() { commit, date = oldCommit, oldDate })
+	commit, date = "abc1234", "2024-05-01T10:00:00Z"
+	info = GetBuildInfo()
+	assert.Equal(t, "abc1234", info.Commit)
+	assert.Equal(t, "2024-05-01T10:00:00Z", info.Date)
+}